@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runExport implements the `repoguide export` subcommand, shorthand for
+// --format sqlite -o: --sqlite names the database, and the remaining
+// arguments are the flags and path of an ordinary run.
+func runExport(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		_, _ = fmt.Fprint(stderr, `Usage: repoguide export --sqlite FILE [flags] [path]
+
+Write the map to a SQLite database, for questions the built-in queries do
+not cover:
+
+  repoguide export --sqlite map.db
+  sqlite3 map.db "SELECT name FROM symbols WHERE name NOT IN (SELECT callee FROM call_edges)"
+
+The database has tables files, symbols, dependencies, call_edges, and
+call_sites; symbols, dependencies, and call sites refer to files by id.
+An existing FILE is replaced once the new database is complete.
+
+Equivalent to repoguide --format sqlite -o FILE, and takes the same flags:
+-n, --max-tokens, --file, -l, --with-tests, --no-calls.
+`)
+		if len(args) == 0 {
+			return fmt.Errorf("export needs --sqlite FILE")
+		}
+		return nil
+	}
+	var path string
+	switch {
+	case args[0] == "--sqlite" || args[0] == "-sqlite":
+		if len(args) < 2 || args[1] == "" || strings.HasPrefix(args[1], "-") {
+			return fmt.Errorf("export: --sqlite needs a file")
+		}
+		path, args = args[1], args[2:]
+	case strings.HasPrefix(args[0], "--sqlite=") || strings.HasPrefix(args[0], "-sqlite="):
+		_, path, _ = strings.Cut(args[0], "=")
+		args = args[1:]
+	}
+	if path == "" {
+		return fmt.Errorf("export needs --sqlite FILE before any other flags")
+	}
+	return run(append([]string{"--format", "sqlite", "-o", path}, args...), stdin, stdout, stderr)
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"io"
+	"os"
+	"strings"
 )
 
 const headerBase = `# Repository Map
@@ -15,11 +17,17 @@ key symbols, and dependencies of the codebase in TOON format.
 - **files**: Source files ranked by importance (PageRank over the dependency
   graph). Higher rank = more central to the codebase. Start here to find
   the most important files.
-- **symbols**: Exported definitions (classes, functions, methods) with file
-  location and signature. Use as a lookup index when you know the name —
-  not as a scanning surface.
+- **symbols**: Exported definitions (classes, functions, methods, constants,
+  variables) with file location and signature. Use as a lookup index when
+  you know the name — not as a scanning surface.
 - **dependencies**: Cross-file references showing which files depend on
   which. The "symbols" column lists the specific symbols referenced.
+- **calls**: Function-level call graph. The "count" column is how many
+  times the caller calls the callee: high counts mark tight coupling.
+- **cycles**: Groups of files that import each other circularly. Only
+  present when the dependency graph has cycles.
+- **packages**: Go packages by directory, with the first sentence of each
+  package's doc comment. Go files also carry their package in **files**.
 
 ## Usage tips
 
@@ -51,11 +59,17 @@ Test files are included (` + "`--with-tests`" + `).
 - **files**: Source files ranked by importance (PageRank over the dependency
   graph). Higher rank = more central to the codebase. Start here to find
   the most important files.
-- **symbols**: Exported definitions (classes, functions, methods) with file
-  location and signature. Use as a lookup index when you know the name —
-  not as a scanning surface.
+- **symbols**: Exported definitions (classes, functions, methods, constants,
+  variables) with file location and signature. Use as a lookup index when
+  you know the name — not as a scanning surface.
 - **dependencies**: Cross-file references showing which files depend on
   which. The "symbols" column lists the specific symbols referenced.
+- **calls**: Function-level call graph. The "count" column is how many
+  times the caller calls the callee: high counts mark tight coupling.
+- **cycles**: Groups of files that import each other circularly. Only
+  present when the dependency graph has cycles.
+- **packages**: Go packages by directory, with the first sentence of each
+  package's doc comment. Go files also carry their package in **files**.
 
 ## Usage tips
 
@@ -76,27 +90,41 @@ comma-separated values.
 
 const headerFocused = `# Focused query — callers, callsites, and dependencies
 
-- **callsites**: every call or import occurrence — ` + "`caller`" + ` = calling function, or ` + "`<import>`" + ` for file-level imports
+- **callsites**: every call or import occurrence — ` + "`kind`" + ` = ` + "`call`" + ` (` + "`caller`" + ` = calling function) or ` + "`import`" + ` (a file-level import; ` + "`caller`" + ` = ` + "`<import>`" + `)
 - **members**: fields and methods of matched class/struct definitions (only present with ` + "`--members`" + `)
 - **dependencies**: cross-file imports — ` + "`source`" + ` imports ` + "`target`" + `
 - **Do not pipe this output through ` + "`head`" + ` or ` + "`tail`" + `** — the complete output is the value.
 
 ---`
 
-// writeOutput writes the TOON data to w, prepending the agent context header
-// unless raw is true. focused selects the compact header for --symbol / --file
-// queries; withTests selects the test-inclusive header variant.
-func writeOutput(w io.Writer, toonData string, raw, withTests, focused bool) {
-	if !raw {
-		var h string
-		switch {
-		case focused:
-			h = headerFocused
-		case withTests:
-			h = headerWithTests
-		default:
-			h = headerBase
-		}
+// header returns the default agent context header: the compact one for
+// --symbol / --file queries when focused, the test-inclusive variant when
+// withTests, and the full one otherwise.
+func header(withTests, focused bool) string {
+	switch {
+	case focused:
+		return headerFocused
+	case withTests:
+		return headerWithTests
+	default:
+		return headerBase
+	}
+}
+
+// readHeader returns the contents of a --header-file without trailing
+// newlines, so it is followed by the map exactly like the default header.
+func readHeader(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading --header-file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// writeOutput writes the TOON data to w, preceded by h unless h is empty
+// (--raw).
+func writeOutput(w io.Writer, toonData, h string) {
+	if h != "" {
 		_, _ = fmt.Fprintln(w, h)
 	}
 	_, _ = fmt.Fprintln(w, toonData)
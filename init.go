@@ -143,8 +143,9 @@ below). These bypass the cache read but write back to it, so full runs stay fast
    output is the value; truncating it loses callsites.
 
 3. **` + "`--symbol`" + ` returns call sites AND import sites for a name.** The ` + "`callsites`" + `
-   table includes every function-call occurrence (` + "`caller`" + ` = calling function) and
-   every file-level import (` + "`caller`" + ` = ` + "`<import>`" + `), each with exact file+line.
+   table includes every function-call occurrence (` + "`kind`" + ` = ` + "`call`" + `, ` + "`caller`" + ` =
+   calling function) and every file-level import (` + "`kind`" + ` = ` + "`import`" + `), each with
+   exact file+line.
    The ` + "`dependencies`" + ` table shows which files import the file that defines the
    symbol. Together these answer both "who calls this?" and "who imports this?".
    Use the line numbers directly with ` + "`Read(offset=N, limit=10)`" + ` rather than
@@ -0,0 +1,121 @@
+// Package cache persists parse results between runs so that only files that
+// changed since the last run need to be re-parsed.
+package cache
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+// Stamp identifies one version of a file on disk. A file whose stamp matches
+// its cached stamp is assumed unchanged. Stamps come in two kinds that never
+// match each other: modification time and size (StampOf), which is cheap, or
+// content hash and size (ContentStamp), which survives a fresh checkout where
+// every mtime is new.
+type Stamp struct {
+	ModTime int64  `json:"mtime,omitempty"` // Unix nanoseconds
+	Size    int64  `json:"size"`
+	Hash    string `json:"hash,omitempty"` // FNV-1a 64 of the contents, in hex
+}
+
+// StampOf returns the stamp for a stat result.
+func StampOf(fi os.FileInfo) Stamp {
+	return Stamp{ModTime: fi.ModTime().UnixNano(), Size: fi.Size()}
+}
+
+// ContentStamp returns the stamp for a file's contents.
+func ContentStamp(data []byte) Stamp {
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return Stamp{Size: int64(len(data)), Hash: strconv.FormatUint(h.Sum64(), 16)}
+}
+
+// Entry holds the parse result for one file.
+type Entry struct {
+	Stamp
+	Language     string         `json:"language"`
+	Tags         []model.Tag    `json:"tags"`
+	SyntaxErrors bool           `json:"syntax_errors,omitempty"`
+	Package      string         `json:"package,omitempty"`
+	PackageDoc   string         `json:"package_doc,omitempty"`
+	Imports      []model.Import `json:"imports,omitempty"`
+}
+
+// FormatVersion is the version of the cache contents: the Entry layout and
+// the tags and map the parser and encoder produce. Bump it whenever they
+// change in a way an older cache would not reflect.
+const FormatVersion = 5
+
+// Cache is the on-disk cache file: per-file parse results plus the last full
+// TOON map, which can be replayed verbatim while none of its inputs changed.
+type Cache struct {
+	// Version identifies the binary that wrote the cache (see Load).
+	Version string `json:"version,omitempty"`
+	// Output is the raw TOON map from the last full run.
+	Output string `json:"output,omitempty"`
+	// Inputs records every file Output was built from, including files that
+	// were skipped for size, so additions and deletions invalidate Output.
+	Inputs map[string]Stamp `json:"inputs,omitempty"`
+	// Files maps repo-relative paths to their parse results.
+	Files map[string]Entry `json:"files"`
+}
+
+// Load reads the cache at path. A missing, unreadable, or malformed cache
+// (including one written in an older format) yields an empty cache, and so
+// does one whose Version is not version: a cache written by another release
+// may hold tags or a map that this one would produce differently.
+func Load(path, version string) *Cache {
+	c := &Cache{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(data, c); err != nil || c.Version != version {
+		return &Cache{}
+	}
+	return c
+}
+
+// Save writes the cache to path, creating parent directories as needed.
+func (c *Cache) Save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Lookup returns the cached parse result for path if its stamp and language
+// match.
+func (c *Cache) Lookup(path, language string, s Stamp) (Entry, bool) {
+	if c == nil {
+		return Entry{}, false
+	}
+	e, ok := c.Files[path]
+	if !ok || e.Stamp != s || e.Language != language {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+// OutputFresh reports whether the cached Output was built from exactly the
+// given files, each unchanged.
+func (c *Cache) OutputFresh(inputs map[string]Stamp) bool {
+	if c == nil || c.Output == "" || len(c.Inputs) != len(inputs) {
+		return false
+	}
+	for path, s := range inputs {
+		if cached, ok := c.Inputs[path]; !ok || cached != s {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "sub", "cache")
+
+	c := &Cache{
+		Version: "2/v1.0.0",
+		Output:  "repo: x",
+		Inputs:  map[string]Stamp{"a.go": {ModTime: 1, Size: 2}},
+		Files: map[string]Entry{
+			"a.go": {
+				Stamp:        Stamp{ModTime: 1, Size: 2},
+				Language:     "go",
+				Tags:         []model.Tag{{Name: "Foo", Kind: model.Definition, SymbolKind: model.Function, Line: 3, File: "a.go", Signature: "Foo()"}},
+				SyntaxErrors: true,
+			},
+		},
+	}
+	if err := c.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got := Load(path, "2/v1.0.0")
+	if got.Output != c.Output {
+		t.Errorf("Output = %q, want %q", got.Output, c.Output)
+	}
+	e, ok := got.Lookup("a.go", "go", Stamp{ModTime: 1, Size: 2})
+	if !ok || len(e.Tags) != 1 || e.Tags[0] != c.Files["a.go"].Tags[0] || !e.SyntaxErrors {
+		t.Errorf("Lookup = %+v, %v", e, ok)
+	}
+}
+
+func TestLoadVersionMismatch(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "cache")
+
+	c := &Cache{
+		Version: "2/v1.0.0",
+		Output:  "repo: x",
+		Files:   map[string]Entry{"a.go": {Stamp: Stamp{ModTime: 1, Size: 2}, Language: "go"}},
+	}
+	if err := c.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	for _, version := range []string{"2/v1.1.0", "3/v1.0.0", ""} {
+		if got := Load(path, version); got.Output != "" || len(got.Files) != 0 {
+			t.Errorf("Load(%q) of a %q cache = %+v, want empty", version, c.Version, got)
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	// Missing file and an old-style TOON cache both load as empty.
+	if c := Load(filepath.Join(dir, "missing"), ""); len(c.Files) != 0 || c.Output != "" {
+		t.Errorf("missing cache should be empty, got %+v", c)
+	}
+	old := filepath.Join(dir, "old")
+	if err := os.WriteFile(old, []byte("repo: x\nfiles[0]:\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if c := Load(old, ""); len(c.Files) != 0 || c.Output != "" {
+		t.Errorf("malformed cache should be empty, got %+v", c)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+	s := Stamp{ModTime: 10, Size: 20}
+	c := &Cache{Files: map[string]Entry{"a.py": {Stamp: s, Language: "python"}}}
+
+	tests := []struct {
+		name     string
+		path     string
+		language string
+		stamp    Stamp
+		want     bool
+	}{
+		{"match", "a.py", "python", s, true},
+		{"modified", "a.py", "python", Stamp{ModTime: 11, Size: 20}, false},
+		{"resized", "a.py", "python", Stamp{ModTime: 10, Size: 21}, false},
+		{"language changed", "a.py", "ruby", s, false},
+		{"unknown path", "b.py", "python", s, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, ok := c.Lookup(tt.path, tt.language, tt.stamp); ok != tt.want {
+				t.Errorf("Lookup = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+
+	var nilCache *Cache
+	if _, ok := nilCache.Lookup("a.py", "python", s); ok {
+		t.Error("nil cache should never hit")
+	}
+}
+
+func TestOutputFresh(t *testing.T) {
+	t.Parallel()
+	inputs := map[string]Stamp{"a.py": {ModTime: 1, Size: 1}, "b.py": {ModTime: 2, Size: 2}}
+	c := &Cache{Output: "repo: x", Inputs: inputs}
+
+	if !c.OutputFresh(map[string]Stamp{"a.py": {ModTime: 1, Size: 1}, "b.py": {ModTime: 2, Size: 2}}) {
+		t.Error("identical inputs should be fresh")
+	}
+	if c.OutputFresh(map[string]Stamp{"a.py": {ModTime: 1, Size: 1}, "b.py": {ModTime: 3, Size: 2}}) {
+		t.Error("modified file should be stale")
+	}
+	if c.OutputFresh(map[string]Stamp{"a.py": {ModTime: 1, Size: 1}}) {
+		t.Error("deleted file should be stale")
+	}
+	if c.OutputFresh(map[string]Stamp{"a.py": {ModTime: 1, Size: 1}, "c.py": {ModTime: 2, Size: 2}}) {
+		t.Error("renamed file should be stale")
+	}
+	if (&Cache{Inputs: inputs}).OutputFresh(inputs) {
+		t.Error("cache without output should be stale")
+	}
+}
+
+func TestContentStamp(t *testing.T) {
+	t.Parallel()
+
+	a := ContentStamp([]byte("x = 1\n"))
+	if a != ContentStamp([]byte("x = 1\n")) {
+		t.Error("identical contents should have equal stamps")
+	}
+	if a.Size != 6 || a.Hash == "" || a.ModTime != 0 {
+		t.Errorf("unexpected stamp %+v", a)
+	}
+	if a == ContentStamp([]byte("x = 2\n")) {
+		t.Error("different contents should have different stamps")
+	}
+}
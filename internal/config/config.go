@@ -0,0 +1,295 @@
+// Package config loads per-repo defaults from a .repoguide.toml or
+// .repoguide.yaml file at the repository root.
+//
+// Only a small, flat subset of each format is supported: one key per line,
+// with string, integer, boolean, and string-list values. That covers every
+// setting repoguide reads, without a third-party parser.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileNames lists the config files looked up at the repo root, in order of
+// preference. Only the first one found is loaded.
+var FileNames = []string{".repoguide.toml", ".repoguide.yaml", ".repoguide.yml"}
+
+// Config holds repo-level defaults. A nil field was not set in the file.
+type Config struct {
+	// Path is the file the config was loaded from, or "" if there was none.
+	Path        string
+	Languages   []string
+	Exclude     []string
+	MaxFiles    *int
+	MaxFileSize *int
+	WithTests   *bool
+}
+
+// Load reads the first of FileNames present in root. If none exists, it
+// returns an empty Config.
+func Load(root string) (*Config, error) {
+	for _, name := range FileNames {
+		path := filepath.Join(root, name)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := Parse(name, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		cfg.Path = path
+		return cfg, nil
+	}
+	return &Config{}, nil
+}
+
+// Parse decodes config data. The format is chosen by the extension of name:
+// .toml for TOML, anything else for YAML. Keys may use underscores or
+// hyphens (max_files or max-files); unknown keys are an error so typos don't
+// go unnoticed.
+func Parse(name string, data []byte) (*Config, error) {
+	var (
+		values map[string]value
+		err    error
+	)
+	if filepath.Ext(name) == ".toml" {
+		values, err = parseTOML(string(data))
+	} else {
+		values, err = parseYAML(string(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	for key, v := range values {
+		switch strings.ReplaceAll(key, "-", "_") {
+		case "languages":
+			cfg.Languages, err = v.asList()
+		case "exclude":
+			cfg.Exclude, err = v.asList()
+		case "max_files":
+			cfg.MaxFiles, err = v.asInt()
+		case "max_file_size":
+			cfg.MaxFileSize, err = v.asInt()
+		case "with_tests":
+			cfg.WithTests, err = v.asBool()
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %q", v.line, key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", v.line, key, err)
+		}
+	}
+	return cfg, nil
+}
+
+// value is a raw config value: a scalar, or a list when isList is set.
+type value struct {
+	line   int
+	scalar string
+	items  []string
+	isList bool
+}
+
+// asList accepts a list or a comma-separated string ("go, python").
+func (v value) asList() ([]string, error) {
+	if v.isList {
+		return v.items, nil
+	}
+	var items []string
+	for _, s := range strings.Split(v.scalar, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			items = append(items, s)
+		}
+	}
+	return items, nil
+}
+
+func (v value) asInt() (*int, error) {
+	if v.isList {
+		return nil, errors.New("want an integer, got a list")
+	}
+	n, err := strconv.Atoi(strings.ReplaceAll(v.scalar, "_", ""))
+	if err != nil {
+		return nil, fmt.Errorf("want an integer, got %q", v.scalar)
+	}
+	return &n, nil
+}
+
+func (v value) asBool() (*bool, error) {
+	if v.isList {
+		return nil, errors.New("want true or false, got a list")
+	}
+	b, err := strconv.ParseBool(v.scalar)
+	if err != nil {
+		return nil, fmt.Errorf("want true or false, got %q", v.scalar)
+	}
+	return &b, nil
+}
+
+// parseTOML parses top-level "key = value" lines. Values are quoted strings,
+// bare integers and booleans, or arrays of those, which may span lines.
+func parseTOML(data string) (map[string]value, error) {
+	values := make(map[string]value)
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(stripComment(lines[i]))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("line %d: tables are not supported", lineNo)
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key = unquote(strings.TrimSpace(key))
+		raw = strings.TrimSpace(raw)
+
+		// Multi-line arrays: keep reading until the closing bracket.
+		if strings.HasPrefix(raw, "[") {
+			for !strings.HasSuffix(raw, "]") && i+1 < len(lines) {
+				i++
+				raw += " " + strings.TrimSpace(stripComment(lines[i]))
+			}
+		}
+		v, err := parseScalarOrFlow(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		v.line = lineNo
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", lineNo, key)
+		}
+		values[key] = v
+	}
+	return values, nil
+}
+
+// parseYAML parses top-level "key: value" lines. Values are scalars, flow
+// lists ([a, b]), or block lists of "- item" lines under an empty value.
+func parseYAML(data string) (map[string]value, error) {
+	values := make(map[string]value)
+	var listKey string // key whose block list is being read
+	for i, rawLine := range strings.Split(data, "\n") {
+		lineNo := i + 1
+		line := strings.TrimRight(stripComment(rawLine), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok && line != trimmed {
+			if listKey == "" {
+				return nil, fmt.Errorf("line %d: list item without a key", lineNo)
+			}
+			v := values[listKey]
+			v.items = append(v.items, unquote(strings.TrimSpace(item)))
+			values[listKey] = v
+			continue
+		}
+		if line != trimmed {
+			return nil, fmt.Errorf("line %d: nested mappings are not supported", lineNo)
+		}
+		key, raw, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", lineNo)
+		}
+		key = unquote(strings.TrimSpace(key))
+		raw = strings.TrimSpace(raw)
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", lineNo, key)
+		}
+		listKey = ""
+		if raw == "" {
+			values[key] = value{line: lineNo, isList: true}
+			listKey = key
+			continue
+		}
+		v, err := parseScalarOrFlow(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		v.line = lineNo
+		values[key] = v
+	}
+	return values, nil
+}
+
+// parseScalarOrFlow parses a single scalar or a bracketed list of scalars.
+func parseScalarOrFlow(raw string) (value, error) {
+	if !strings.HasPrefix(raw, "[") {
+		return value{scalar: unquote(raw)}, nil
+	}
+	if !strings.HasSuffix(raw, "]") {
+		return value{}, errors.New("unterminated list")
+	}
+	v := value{isList: true}
+	for _, item := range splitFlow(raw[1 : len(raw)-1]) {
+		if item = strings.TrimSpace(item); item != "" {
+			v.items = append(v.items, unquote(item))
+		}
+	}
+	return v, nil
+}
+
+// splitFlow splits list contents on commas outside quotes, so quoted globs
+// like "{a,b}/**" stay whole.
+func splitFlow(s string) []string {
+	var (
+		items []string
+		quote rune
+		start int
+	)
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+// stripComment removes a trailing # comment that is not inside quotes.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote strips matching single or double quotes.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	t.Parallel()
+
+	data := `# project defaults
+languages = ["go", "typescript"]
+exclude = [
+  "gen/**",   # generated code
+  "{proto,pb}/**",
+]
+max_files = 50
+max-file-size = 2_000_000
+with_tests = true
+`
+	cfg, err := Parse(".repoguide.toml", []byte(data))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !slices.Equal(cfg.Languages, []string{"go", "typescript"}) {
+		t.Errorf("Languages = %v", cfg.Languages)
+	}
+	if !slices.Equal(cfg.Exclude, []string{"gen/**", "{proto,pb}/**"}) {
+		t.Errorf("Exclude = %v", cfg.Exclude)
+	}
+	if cfg.MaxFiles == nil || *cfg.MaxFiles != 50 {
+		t.Errorf("MaxFiles = %v", cfg.MaxFiles)
+	}
+	if cfg.MaxFileSize == nil || *cfg.MaxFileSize != 2000000 {
+		t.Errorf("MaxFileSize = %v", cfg.MaxFileSize)
+	}
+	if cfg.WithTests == nil || !*cfg.WithTests {
+		t.Errorf("WithTests = %v", cfg.WithTests)
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	t.Parallel()
+
+	data := `---
+languages: go, python
+exclude:
+  - gen/**
+  - "vendor/**"  # third-party
+max_files: 20
+`
+	cfg, err := Parse(".repoguide.yaml", []byte(data))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !slices.Equal(cfg.Languages, []string{"go", "python"}) {
+		t.Errorf("Languages = %v", cfg.Languages)
+	}
+	if !slices.Equal(cfg.Exclude, []string{"gen/**", "vendor/**"}) {
+		t.Errorf("Exclude = %v", cfg.Exclude)
+	}
+	if cfg.MaxFiles == nil || *cfg.MaxFiles != 20 {
+		t.Errorf("MaxFiles = %v", cfg.MaxFiles)
+	}
+	if cfg.MaxFileSize != nil || cfg.WithTests != nil {
+		t.Error("unset keys should stay nil")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		file string
+		data string
+		want string
+	}{
+		{"unknown key", ".repoguide.toml", `max_file = 3`, `unknown key "max_file"`},
+		{"bad int", ".repoguide.toml", `max_files = "lots"`, "want an integer"},
+		{"bad bool", ".repoguide.yaml", `with_tests: maybe`, "want true or false"},
+		{"table", ".repoguide.toml", "[tool]\nx = 1", "tables are not supported"},
+		{"no equals", ".repoguide.toml", "languages", "expected key = value"},
+		{"unterminated", ".repoguide.toml", `exclude = ["a"`, "unterminated list"},
+		{"duplicate", ".repoguide.yaml", "max_files: 1\nmax_files: 2", "duplicate key"},
+		{"nested", ".repoguide.yaml", "languages:\n  go: true", "nested mappings"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Parse(tt.file, []byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load without config: %v", err)
+	}
+	if cfg.Path != "" || cfg.MaxFiles != nil {
+		t.Errorf("expected empty config, got %+v", cfg)
+	}
+
+	// TOML takes precedence over YAML when both exist.
+	if err := os.WriteFile(filepath.Join(dir, ".repoguide.yaml"), []byte("max_files: 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".repoguide.toml"), []byte("max_files = 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.MaxFiles == nil || *cfg.MaxFiles != 2 || filepath.Base(cfg.Path) != ".repoguide.toml" {
+		t.Errorf("expected .repoguide.toml with max_files 2, got %+v", cfg)
+	}
+}
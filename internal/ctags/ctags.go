@@ -0,0 +1,80 @@
+// Package ctags renders definitions as a ctags-compatible tags file for
+// editor jump-to-definition.
+package ctags
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+// header marks the output as an extended-format tags file sorted by byte
+// value, so editors can binary-search it.
+const header = "!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/\n" +
+	"!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n" +
+	"!_TAG_PROGRAM_NAME\trepoguide\t//\n"
+
+// kinds maps symbol kinds to single-letter ctags kinds. Fields share "m"
+// (member) with methods, as in C and C++ tags.
+var kinds = map[model.SymbolKind]string{
+	model.Class:    "c",
+	model.Constant: "d",
+	model.Field:    "m",
+	model.Function: "f",
+	model.Method:   "m",
+	model.Variable: "v",
+}
+
+// entry is one line of the tags file.
+type entry struct {
+	name, file, kind, class string
+	line                    int
+}
+
+// Encode renders every definition in files as a tags line:
+//
+//	name<TAB>file<TAB>line;"<TAB>kind[<TAB>class:Owner]
+//
+// Members are tagged by their bare name, which is what an editor looks up
+// under the cursor, with the owning type in a class field. Lines are sorted
+// by name, then file and line, after the standard pseudo-tag header.
+func Encode(files []model.FileInfo) string {
+	var entries []entry
+	for i := range files {
+		fi := &files[i]
+		for j := range fi.Tags {
+			t := &fi.Tags[j]
+			if t.Kind != model.Definition {
+				continue
+			}
+			e := entry{name: t.Name, file: fi.Path, kind: kinds[t.SymbolKind], line: t.Line}
+			if t.SymbolKind == model.Method || t.SymbolKind == model.Field {
+				e.class, e.name = model.SplitMember(t.Name)
+			}
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if a.file != b.file {
+			return a.file < b.file
+		}
+		return a.line < b.line
+	})
+
+	var b strings.Builder
+	b.WriteString(header)
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s\t%s\t%d;\"\t%s", e.name, e.file, e.line, e.kind)
+		if e.class != "" {
+			fmt.Fprintf(&b, "\tclass:%s", e.class)
+		}
+		b.WriteByte('\n')
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,47 @@
+package ctags
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	files := []model.FileInfo{
+		{Path: "server.go", Tags: []model.Tag{
+			{Name: "Server", Kind: model.Definition, SymbolKind: model.Class, Line: 3},
+			{Name: "Server.addr", Kind: model.Definition, SymbolKind: model.Field, Line: 4},
+			{Name: "Server.Handle", Kind: model.Definition, SymbolKind: model.Method, Line: 8},
+			{Name: "helper", Kind: model.Reference, SymbolKind: model.Function, Line: 9},
+			{Name: "Version", Kind: model.Definition, SymbolKind: model.Constant, Line: 1},
+		}},
+		{Path: "cmd/main.go", Tags: []model.Tag{
+			{Name: "main", Kind: model.Definition, SymbolKind: model.Function, Line: 5},
+			{Name: "Handle", Kind: model.Definition, SymbolKind: model.Function, Line: 2},
+			{Name: "main", Kind: model.Entry, SymbolKind: model.Function, Line: 5},
+		}},
+	}
+
+	got := Encode(files)
+	want := header + `Handle	cmd/main.go	2;"	f
+Handle	server.go	8;"	m	class:Server
+Server	server.go	3;"	c
+Version	server.go	1;"	d
+addr	server.go	4;"	m	class:Server
+main	cmd/main.go	5;"	f`
+	if got != want {
+		t.Errorf("Encode =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEncodeEmpty(t *testing.T) {
+	t.Parallel()
+
+	got := Encode(nil)
+	if !strings.HasPrefix(got, "!_TAG_FILE_FORMAT") || strings.Count(got, "\n") != 2 {
+		t.Errorf("expected only the pseudo-tag header, got:\n%s", got)
+	}
+}
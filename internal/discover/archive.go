@@ -0,0 +1,228 @@
+package discover
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/phobologic/repoguide/internal/lang"
+)
+
+// ArchiveFile is a source file read from an archive. Source is nil when the
+// file is larger than the size limit passed to Archive.
+type ArchiveFile struct {
+	FileEntry
+	Size   int64
+	Source []byte
+}
+
+// IsArchive reports whether p names a supported archive format by extension:
+// .tar, .tar.gz, .tgz, or .zip.
+func IsArchive(p string) bool {
+	return archiveKind(p) != ""
+}
+
+func archiveKind(p string) string {
+	lower := strings.ToLower(p)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tgz"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	}
+	return ""
+}
+
+// ArchiveName returns the archive's base name without its archive extension,
+// e.g. "repoguide-1.2" for /tmp/repoguide-1.2.tar.gz.
+func ArchiveName(p string) string {
+	base := filepath.Base(p)
+	lower := strings.ToLower(base)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(lower, ext) {
+			return base[:len(base)-len(ext)]
+		}
+	}
+	return base
+}
+
+// Archive reads source files directly from a tar, gzipped tar, or zip
+// archive, applying the same language, hidden-path, and vendor-directory
+// rules as Files. Directory entries, symlinks, and other non-regular entries
+// are skipped, as are paths that would escape the archive root. Files larger
+// than maxSize bytes are returned without their source so the caller can
+// report them. If every file shares one top-level directory (as in release
+// tarballs and git archive --prefix), that directory is stripped from paths.
+func Archive(p string, languages []string, maxSize int) ([]ArchiveFile, error) {
+	langSet := make(map[string]struct{}, len(languages))
+	for _, l := range languages {
+		langSet[l] = struct{}{}
+	}
+
+	files := make(map[string]ArchiveFile)
+	add := func(name string, mode fs.FileMode, size int64, open func() (io.Reader, error)) error {
+		if !mode.IsRegular() {
+			return nil
+		}
+		rel, ok := archivePath(name)
+		if !ok {
+			return nil
+		}
+		langName := lang.ForExtension(path.Ext(rel))
+		if langName == "" {
+			return nil
+		}
+		if len(langSet) > 0 {
+			if _, ok := langSet[langName]; !ok {
+				return nil
+			}
+		}
+		f := ArchiveFile{FileEntry: FileEntry{Path: rel, Language: langName}, Size: size}
+		if size <= int64(maxSize) {
+			r, err := open()
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if f.Source, err = io.ReadAll(r); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		files[rel] = f // a later entry for the same path replaces an earlier one
+		return nil
+	}
+
+	var err error
+	switch archiveKind(p) {
+	case "zip":
+		err = readZip(p, add)
+	case "tar", "tgz":
+		err = readTar(p, archiveKind(p) == "tgz", add)
+	default:
+		return nil, fmt.Errorf("%s: unsupported archive type (want .tar, .tar.gz, .tgz, or .zip)", p)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ArchiveFile, 0, len(files))
+	for _, f := range files {
+		results = append(results, f)
+	}
+	stripCommonDir(results)
+	for i := range results {
+		results[i].Path = filepath.FromSlash(results[i].Path)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Path < results[j].Path
+	})
+	return results, nil
+}
+
+type archiveAdder func(name string, mode fs.FileMode, size int64, open func() (io.Reader, error)) error
+
+func readZip(p string, add archiveAdder) error {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = zr.Close() }()
+	for _, zf := range zr.File {
+		var rc io.ReadCloser
+		open := func() (io.Reader, error) {
+			var err error
+			rc, err = zf.Open()
+			return rc, err
+		}
+		err := add(zf.Name, zf.Mode(), int64(zf.UncompressedSize64), open)
+		if rc != nil {
+			_ = rc.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTar(p string, gzipped bool, add archiveAdder) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		defer func() { _ = gz.Close() }()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		open := func() (io.Reader, error) { return tr, nil }
+		if err := add(hdr.Name, hdr.FileInfo().Mode(), hdr.Size, open); err != nil {
+			return err
+		}
+	}
+}
+
+// archivePath cleans an archive entry name into a slash-separated relative
+// path. It reports false for absolute or escaping paths and for paths under
+// hidden or vendor directories, which Files would not descend into.
+func archivePath(name string) (string, bool) {
+	name = path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	if path.IsAbs(name) || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ".") {
+			return "", false
+		}
+		if _, skip := skipDirs[part]; skip && i < len(parts)-1 {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// stripCommonDir removes a top-level directory shared by every file.
+func stripCommonDir(files []ArchiveFile) {
+	if len(files) == 0 {
+		return
+	}
+	prefix, _, ok := strings.Cut(files[0].Path, "/")
+	if !ok {
+		return
+	}
+	prefix += "/"
+	for _, f := range files {
+		if !strings.HasPrefix(f.Path, prefix) {
+			return
+		}
+	}
+	for i := range files {
+		files[i].Path = strings.TrimPrefix(files[i].Path, prefix)
+	}
+}
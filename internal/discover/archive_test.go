@@ -0,0 +1,180 @@
+package discover
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type archiveEntry struct {
+	name    string
+	content string
+	dir     bool
+	symlink bool
+}
+
+var sampleArchive = []archiveEntry{
+	{name: "proj-1.0/", dir: true},
+	{name: "proj-1.0/main.py", content: "import util\n"},
+	{name: "proj-1.0/lib/util.py", content: "def helper(): pass\n"},
+	{name: "proj-1.0/lib/big.py", content: "x = 1\n" + string(make([]byte, 100))},
+	{name: "proj-1.0/README.md", content: "# proj\n"},
+	{name: "proj-1.0/.hidden/secret.py", content: "pass\n"},
+	{name: "proj-1.0/node_modules/pkg.js", content: "x()\n"},
+	{name: "proj-1.0/link.py", content: "main.py", symlink: true},
+	{name: "../escape.py", content: "pass\n"},
+}
+
+func writeTarGz(t *testing.T, entries []archiveEntry) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "proj-1.0.tar.gz")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		switch {
+		case e.dir:
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+		case e.symlink:
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.content, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, c := range []interface{ Close() error }{tw, gz, f} {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+func writeZip(t *testing.T, entries []archiveEntry) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "proj.zip")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		switch {
+		case e.dir:
+			hdr.SetMode(os.ModeDir | 0o755)
+		case e.symlink:
+			hdr.SetMode(os.ModeSymlink | 0o777)
+		default:
+			hdr.SetMode(0o644)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !e.dir {
+			if _, err := w.Write([]byte(e.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestArchive(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		path func(*testing.T) string
+	}{
+		{"tar.gz", func(t *testing.T) string { return writeTarGz(t, sampleArchive) }},
+		{"zip", func(t *testing.T) string { return writeZip(t, sampleArchive) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			files, err := Archive(tt.path(t), nil, 50)
+			if err != nil {
+				t.Fatalf("Archive: %v", err)
+			}
+			want := []struct {
+				path   string
+				source string
+			}{
+				{filepath.Join("lib", "big.py"), ""},
+				{filepath.Join("lib", "util.py"), "def helper(): pass\n"},
+				{"main.py", "import util\n"},
+			}
+			if len(files) != len(want) {
+				t.Fatalf("got %d files, want %d: %+v", len(files), len(want), files)
+			}
+			for i, w := range want {
+				f := files[i]
+				if f.Path != w.path || f.Language != "python" || string(f.Source) != w.source {
+					t.Errorf("file %d = {%q %q %q}, want {%q python %q}", i, f.Path, f.Language, f.Source, w.path, w.source)
+				}
+			}
+			if files[0].Size <= 50 {
+				t.Errorf("big.py size = %d, want > 50", files[0].Size)
+			}
+		})
+	}
+}
+
+func TestArchiveLanguageFilter(t *testing.T) {
+	t.Parallel()
+	p := writeTarGz(t, []archiveEntry{
+		{name: "a.py", content: "pass\n"},
+		{name: "b.go", content: "package b\n"},
+	})
+	files, err := Archive(p, []string{"go"}, 1000)
+	if err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "b.go" {
+		t.Errorf("got %+v, want only b.go", files)
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path string
+		want bool
+		name string
+	}{
+		{"src.tar", true, "src"},
+		{"/tmp/proj-1.2.tar.gz", true, "proj-1.2"},
+		{"proj.TGZ", true, "proj"},
+		{"proj.zip", true, "proj"},
+		{"proj.gz", false, "proj.gz"},
+		{"main.go", false, "main.go"},
+	}
+	for _, tt := range tests {
+		if got := IsArchive(tt.path); got != tt.want {
+			t.Errorf("IsArchive(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+		if got := ArchiveName(tt.path); got != tt.name {
+			t.Errorf("ArchiveName(%q) = %q, want %q", tt.path, got, tt.name)
+		}
+	}
+}
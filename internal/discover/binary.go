@@ -0,0 +1,36 @@
+package discover
+
+import "unicode/utf8"
+
+// sniffLen is how much of a file LooksBinary inspects.
+const sniffLen = 8 << 10
+
+// maxInvalidUTF8 is the share of bytes in the sniffed prefix that may be
+// invalid UTF-8 before the file counts as binary, which tolerates a stray
+// Latin-1 comment in otherwise textual source.
+const maxInvalidUTF8 = 0.1
+
+// LooksBinary reports whether data, the contents of a file, is binary rather
+// than source text despite its extension (a pickled blob named .py, say): its
+// first few KB contain a NUL byte, or are largely invalid UTF-8.
+func LooksBinary(data []byte) bool {
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	invalid := 0
+	for i := 0; i < len(data); {
+		if data[i] == 0 {
+			return true
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			// A rune cut off by the prefix is not evidence of binary.
+			if !utf8.FullRune(data[i:]) {
+				break
+			}
+			invalid++
+		}
+		i += size
+	}
+	return float64(invalid) > maxInvalidUTF8*float64(len(data))
+}
@@ -0,0 +1,32 @@
+package discover
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLooksBinary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"empty", "", false},
+		{"source", "def main():\n    print('héllo')\n", false},
+		{"nul byte", "\x80\x04\x95\x00\x00cbuiltins\n", true},
+		{"nul past the sniffed prefix", strings.Repeat("x", sniffLen) + "\x00", false},
+		{"stray latin-1", "# caf\xe9\n" + strings.Repeat("x = 1\n", 10), false},
+		{"mostly invalid utf-8", strings.Repeat("\xff\xfe\x81", 20), true},
+		{"rune cut off by the prefix", strings.Repeat("x", sniffLen-1) + "é", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := LooksBinary([]byte(tt.data)); got != tt.want {
+				t.Errorf("LooksBinary = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
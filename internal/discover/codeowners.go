@@ -0,0 +1,153 @@
+package discover
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// codeownersPaths are where GitHub looks for a CODEOWNERS file, relative to
+// the repository root, in the order it looks.
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// Codeowners is a parsed GitHub CODEOWNERS file, for looking up who owns
+// each file.
+type Codeowners struct {
+	// Path is the CODEOWNERS file the rules were read from.
+	Path string
+	// prefix is root's path below the directory holding the CODEOWNERS
+	// file, which its patterns are relative to; "" when they are the same.
+	prefix string
+	rules  []ownerRule
+}
+
+// ownerRule is one line of a CODEOWNERS file: a pattern and the owners of
+// the files it matches (none, to leave them unowned).
+type ownerRule struct {
+	pattern string
+	owners  []string
+}
+
+// LoadCodeowners finds and parses the CODEOWNERS file that applies to root.
+// It looks in the places GitHub does (.github/, the root itself, and docs/)
+// at root and then each directory above it, stopping at the top of the git
+// work tree, so a subdirectory of a repository uses the repository's file.
+// It returns nil and no error when there is no CODEOWNERS file.
+func LoadCodeowners(root string) (*Codeowners, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	for dir := abs; ; {
+		for _, name := range codeownersPaths {
+			p := filepath.Join(dir, filepath.FromSlash(name))
+			data, err := os.ReadFile(p)
+			if err != nil {
+				continue
+			}
+			prefix, err := filepath.Rel(dir, abs)
+			if err != nil {
+				return nil, err
+			}
+			if prefix == "." {
+				prefix = ""
+			}
+			return &Codeowners{Path: p, prefix: filepath.ToSlash(prefix), rules: parseCodeowners(string(data))}, nil
+		}
+		parent := filepath.Dir(dir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil || parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// parseCodeowners reads the rules of a CODEOWNERS file. Blank lines and
+// comments are skipped, as are negated patterns, which GitHub does not
+// support either.
+func parseCodeowners(data string) []ownerRule {
+	var rules []ownerRule
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "!") {
+			continue
+		}
+		rule := ownerRule{pattern: fields[0]}
+		for _, f := range fields[1:] {
+			if strings.HasPrefix(f, "#") {
+				break
+			}
+			rule.owners = append(rule.owners, f)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// Owners returns the owners of the file at relPath (relative to the root
+// passed to LoadCodeowners). As on GitHub, the last matching rule wins, and
+// a file matching no rule, or a last rule listing no owners, has none.
+func (c *Codeowners) Owners(relPath string) []string {
+	p := path.Join(c.prefix, filepath.ToSlash(relPath))
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		if matchOwnerPattern(c.rules[i].pattern, p) {
+			return c.rules[i].owners
+		}
+	}
+	return nil
+}
+
+// matchOwnerPattern reports whether relPath matches a CODEOWNERS pattern.
+// The syntax is that of .gitignore: a pattern starting with / or containing
+// one before its end is anchored at the repository root, any other matches
+// at any depth, and a pattern naming a directory matches everything beneath
+// it. Unlike .gitignore, a wildcard in the last segment matches only at that
+// depth, so docs/* owns docs/a.md but not docs/guide/b.md.
+func matchOwnerPattern(pattern, relPath string) bool {
+	dir := strings.HasSuffix(pattern, "/")
+	trimmed := strings.Trim(pattern, "/")
+	if trimmed == "" {
+		return false
+	}
+	segs := strings.Split(trimmed, "/")
+	if !strings.HasPrefix(pattern, "/") && len(segs) == 1 {
+		segs = append([]string{"**"}, segs...)
+	}
+	last := segs[len(segs)-1]
+	exact := !dir && last != "**" && strings.ContainsAny(last, "*?[")
+	return matchOwnerSegments(segs, strings.Split(relPath, "/"), exact, dir)
+}
+
+// matchOwnerSegments matches pattern segments against path segments, as
+// matchSegments does, except that an exact pattern must consume the whole
+// path and a dir pattern must leave some of it (a file beneath the
+// directory).
+func matchOwnerSegments(pattern, parts []string, exact, dir bool) bool {
+	if len(pattern) == 0 {
+		switch {
+		case exact:
+			return len(parts) == 0
+		case dir:
+			return len(parts) > 0
+		}
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchOwnerSegments(pattern[1:], parts[i:], exact, dir) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return matchOwnerSegments(pattern[1:], parts[1:], exact, dir)
+}
@@ -0,0 +1,108 @@
+package discover
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMatchOwnerPattern(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*", "a/b/c.go", true},
+		{"*.js", "web/app/main.js", true},
+		{"*.js", "web/app/main.ts", false},
+		{"docs/*", "docs/a.md", true},
+		{"docs/*", "docs/guide/b.md", false},
+		{"docs/*", "x/docs/a.md", false},
+		{"/build/logs/", "build/logs/x/y.go", true},
+		{"/build/logs/", "x/build/logs/y.go", false},
+		{"apps/", "x/apps/y.go", true},
+		{"apps/", "apps", false},
+		{"/apps/", "x/apps/y.go", false},
+		{"src/app", "src/app/a.go", true},
+		{"src/app", "x/src/app/a.go", false},
+		{"/script", "script/deploy.go", true},
+		{"**/logs", "a/b/logs/x.go", true},
+		{"docs/**", "docs/a/b/c.md", true},
+		{"docs/**/*.md", "docs/a/b/c.md", true},
+		{"docs/**/*.md", "docs/a/b/c.go", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"|"+tt.path, func(t *testing.T) {
+			t.Parallel()
+			if got := matchOwnerPattern(tt.pattern, tt.path); got != tt.want {
+				t.Errorf("matchOwnerPattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadCodeowners(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, ".github"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := `# Default owners
+*       @org/everyone
+
+*.go    @gophers # inline comment
+/api/   @org/api @alice
+/api/generated/
+!/api/skip.go @nobody
+`
+	if err := os.WriteFile(filepath.Join(root, ".github", "CODEOWNERS"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	co, err := LoadCodeowners(root)
+	if err != nil || co == nil {
+		t.Fatalf("LoadCodeowners = %v, %v", co, err)
+	}
+	for _, tc := range []struct {
+		path string
+		want []string
+	}{
+		{"README.md", []string{"@org/everyone"}},
+		{"cmd/main.go", []string{"@gophers"}},
+		{"api/server.go", []string{"@org/api", "@alice"}},
+		{"api/generated/types.go", nil},
+		{"api/skip.go", []string{"@org/api", "@alice"}},
+	} {
+		if got := co.Owners(tc.path); !slices.Equal(got, tc.want) {
+			t.Errorf("Owners(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+
+	// A subdirectory uses the repository's file, with its paths re-rooted.
+	if err := os.MkdirAll(filepath.Join(root, "api"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := LoadCodeowners(filepath.Join(root, "api"))
+	if err != nil || sub == nil {
+		t.Fatalf("LoadCodeowners(api) = %v, %v", sub, err)
+	}
+	if got := sub.Owners("server.go"); !slices.Equal(got, []string{"@org/api", "@alice"}) {
+		t.Errorf("Owners from api/ = %q", got)
+	}
+
+	// No file up to the top of the work tree: no owners, no error.
+	bare := t.TempDir()
+	if err := os.Mkdir(filepath.Join(bare, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if co, err := LoadCodeowners(bare); co != nil || err != nil {
+		t.Errorf("LoadCodeowners without a file = %v, %v", co, err)
+	}
+}
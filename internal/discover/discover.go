@@ -2,11 +2,16 @@
 package discover
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -40,18 +45,43 @@ var skipDirs = map[string]struct{}{
 	"egg-info":      {},
 }
 
+// IgnoreFile is the name of the repoguide-specific ignore file at the repo root.
+// It uses .gitignore syntax and layers on top of the git/.gitignore filtering.
+const IgnoreFile = ".repoguideignore"
+
 // Files discovers parseable source files under root.
 // If languages is non-empty, only files matching one of the listed languages are returned.
-func Files(root string, languages []string) ([]FileEntry, error) {
+// Files excluded by git or by a .repoguideignore at root are skipped. Outside
+// a git repo, every .gitignore under root applies to its own subtree.
+// If maxDepth is positive, only files at most maxDepth path components below
+// root are returned (1 means top-level files only), and directories at that
+// depth are not descended into.
+// If noGitignore is true, git and .gitignore are not consulted at all, so
+// ignored files (generated code, build output) are included;
+// .repoguideignore, hidden paths, and vendor directories are still skipped.
+// If log is non-nil, every skipped directory and file is written to it with
+// the reason, along with each file kept and its language (--verbose).
+func Files(root string, languages []string, maxDepth int, noGitignore bool, log io.Writer) ([]FileEntry, error) {
 	langSet := make(map[string]struct{}, len(languages))
 	for _, l := range languages {
 		langSet[l] = struct{}{}
 	}
-	gitFiles := gitLsFiles(root)
-	var gi *ignore.GitIgnore
-	if gitFiles == nil {
-		gi = loadGitignore(root)
+	logf := func(format string, args ...any) {
+		if log != nil {
+			_, _ = fmt.Fprintf(log, "discover: "+format+"\n", args...)
+		}
+	}
+	var (
+		gitFiles   map[string]struct{}
+		gitignores gitignoreSet
+	)
+	if !noGitignore {
+		gitFiles = gitLsFiles(root)
+		if gitFiles == nil {
+			gitignores = make(gitignoreSet)
+		}
 	}
+	rgi := loadIgnoreFile(filepath.Join(root, IgnoreFile))
 
 	var results []FileEntry
 
@@ -64,48 +94,80 @@ func Files(root string, languages []string) ([]FileEntry, error) {
 
 		if d.IsDir() {
 			if path == root {
+				gitignores.load(root, ".")
 				return nil
 			}
-			if _, skip := skipDirs[name]; skip || strings.HasPrefix(name, ".") {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return nil
+			}
+			if _, skip := skipDirs[name]; skip {
+				logf("skip dir %s (vendor, build, or tool directory)", rel)
+				return filepath.SkipDir
+			}
+			if strings.HasPrefix(name, ".") {
+				logf("skip dir %s (hidden)", rel)
 				return filepath.SkipDir
 			}
+			if maxDepth > 0 && strings.Count(rel, string(filepath.Separator))+1 >= maxDepth {
+				logf("skip dir %s (deeper than --max-depth)", rel)
+				return filepath.SkipDir
+			}
+			if gitignores != nil {
+				if gitignores.matches(rel + string(filepath.Separator)) {
+					logf("skip dir %s (gitignored)", rel)
+					return filepath.SkipDir
+				}
+				gitignores.load(root, rel)
+			}
 			return nil
 		}
 
-		if strings.HasPrefix(name, ".") {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
 			return nil
 		}
 
-		// Skip symlinks
-		if d.Type()&os.ModeSymlink != 0 {
+		if strings.HasPrefix(name, ".") {
+			logf("skip %s (hidden)", rel)
 			return nil
 		}
 
-		rel, err := filepath.Rel(root, path)
-		if err != nil {
+		// Skip symlinks
+		if d.Type()&os.ModeSymlink != 0 {
+			logf("skip %s (symlink)", rel)
 			return nil
 		}
 
 		if gitFiles != nil {
 			if _, ok := gitFiles[rel]; !ok {
+				logf("skip %s (not tracked by git)", rel)
 				return nil
 			}
-		} else if gi != nil && gi.MatchesPath(rel) {
+		} else if gitignores.matches(rel) {
+			logf("skip %s (gitignored)", rel)
+			return nil
+		}
+		if rgi != nil && rgi.MatchesPath(rel) {
+			logf("skip %s (%s)", rel, IgnoreFile)
 			return nil
 		}
 
 		ext := filepath.Ext(name)
 		langName := lang.ForExtension(ext)
 		if langName == "" {
+			logf("skip %s (no supported language for %q)", rel, ext)
 			return nil
 		}
 
 		if len(langSet) > 0 {
 			if _, ok := langSet[langName]; !ok {
+				logf("skip %s (%s, not in --langs)", rel, langName)
 				return nil
 			}
 		}
 
+		logf("add %s (%s)", rel, langName)
 		results = append(results, FileEntry{Path: rel, Language: langName})
 		return nil
 	})
@@ -120,6 +182,95 @@ func Files(root string, languages []string) ([]FileEntry, error) {
 	return results, nil
 }
 
+// Dirs returns root and every directory beneath it that Files descends into,
+// skipping hidden and well-known vendor/build directories. Ignore files are
+// not consulted.
+func Dirs(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != root {
+			name := d.Name()
+			if _, skip := skipDirs[name]; skip || strings.HasPrefix(name, ".") {
+				return filepath.SkipDir
+			}
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	return dirs, err
+}
+
+// FromList builds the file set from newline-separated paths read from r instead
+// of walking the tree. Paths may be relative to root or absolute; blank lines
+// and duplicates are ignored. Paths outside root, missing files, and files
+// with unsupported extensions are dropped with a warning written to warn.
+// If languages is non-empty, files in other languages are dropped silently.
+func FromList(root string, r io.Reader, languages []string, warn io.Writer) ([]FileEntry, error) {
+	langSet := make(map[string]struct{}, len(languages))
+	for _, l := range languages {
+		langSet[l] = struct{}{}
+	}
+
+	seen := make(map[string]struct{})
+	var results []FileEntry
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		rel := filepath.Clean(line)
+		if filepath.IsAbs(rel) {
+			var err error
+			if rel, err = filepath.Rel(root, rel); err != nil {
+				_, _ = fmt.Fprintf(warn, "Warning: %s: skipped (outside %s)\n", line, root)
+				continue
+			}
+		}
+		if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			_, _ = fmt.Fprintf(warn, "Warning: %s: skipped (outside %s)\n", line, root)
+			continue
+		}
+		if _, dup := seen[rel]; dup {
+			continue
+		}
+		seen[rel] = struct{}{}
+
+		langName := lang.ForExtension(filepath.Ext(rel))
+		if langName == "" {
+			_, _ = fmt.Fprintf(warn, "Warning: %s: skipped (unsupported file type)\n", line)
+			continue
+		}
+		if len(langSet) > 0 {
+			if _, ok := langSet[langName]; !ok {
+				continue
+			}
+		}
+
+		info, err := os.Stat(filepath.Join(root, rel))
+		if err != nil || info.IsDir() {
+			_, _ = fmt.Fprintf(warn, "Warning: %s: skipped (not a file)\n", line)
+			continue
+		}
+
+		results = append(results, FileEntry{Path: rel, Language: langName})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading file list: %w", err)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Path < results[j].Path
+	})
+
+	return results, nil
+}
+
 func gitLsFiles(root string) map[string]struct{} {
 	gitDir := filepath.Join(root, ".git")
 	info, err := os.Stat(gitDir)
@@ -146,9 +297,293 @@ func gitLsFiles(root string) map[string]struct{} {
 	return files
 }
 
+// ChangedSince returns the files under root changed between ref and HEAD,
+// as reported by "git diff --name-only ref...HEAD" (changes on the current
+// branch since it diverged from ref). Paths are relative to root; changes
+// outside root and deleted files are left out. It fails if root is not inside
+// a git work tree or git cannot resolve ref.
+func ChangedSince(root, ref string) (map[string]struct{}, error) {
+	if strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid git ref %q", ref)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := checkWorkTree(ctx, root); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "diff", "--name-only", "--relative", "-z", ref+"...HEAD")
+	cmd.Dir = root
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git diff %s...HEAD: %s", ref, msg)
+		}
+		return nil, fmt.Errorf("git diff %s...HEAD: %w", ref, err)
+	}
+
+	files := make(map[string]struct{})
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name == "" {
+			continue
+		}
+		rel := filepath.FromSlash(name)
+		if info, err := os.Stat(filepath.Join(root, rel)); err != nil || info.IsDir() {
+			continue
+		}
+		files[rel] = struct{}{}
+	}
+	return files, nil
+}
+
+// checkWorkTree returns an error unless root is inside a git work tree.
+func checkWorkTree(ctx context.Context, root string) error {
+	check := exec.CommandContext(ctx, "git", "rev-parse", "--is-inside-work-tree")
+	check.Dir = root
+	if out, err := check.Output(); err != nil || strings.TrimSpace(string(out)) != "true" {
+		return fmt.Errorf("%s is not inside a git repository", root)
+	}
+	return nil
+}
+
+// LastModified returns the commit time (Unix seconds) of the most recent
+// commit touching each file under root, from a single "git log" walk. Paths
+// are relative to root; files with no commits (untracked or newly added) are
+// absent. It fails if root is not inside a git work tree.
+func LastModified(root string) (map[string]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := checkWorkTree(ctx, root); err != nil {
+		return nil, err
+	}
+
+	// Each commit prints "@<time>" followed by the files it touched; the
+	// log is newest first, so a file's first appearance is its last change.
+	cmd := exec.CommandContext(ctx, "git", "-c", "core.quotePath=false", "log",
+		"--format=@%ct", "--name-only", "--relative", "--no-renames", "HEAD")
+	cmd.Dir = root
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git log: %s", msg)
+		}
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+
+	times := make(map[string]int64)
+	var current int64
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == "" {
+			continue
+		}
+		if ts, ok := strings.CutPrefix(line, "@"); ok {
+			if t, err := strconv.ParseInt(ts, 10, 64); err == nil {
+				current = t
+				continue
+			}
+		}
+		rel := filepath.FromSlash(line)
+		if _, seen := times[rel]; !seen {
+			times[rel] = current
+		}
+	}
+	return times, nil
+}
+
+// RecentAuthors returns the number of distinct commit authors (by email) of
+// each file under root over the last days days, from a single "git log" walk.
+// Paths are relative to root; files with no commits in the window are
+// absent. It fails if root is not inside a git work tree.
+func RecentAuthors(root string, days int) (map[string]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := checkWorkTree(ctx, root); err != nil {
+		return nil, err
+	}
+
+	// Each commit prints a NUL and its author's email, which no path can
+	// start with, followed by the files it touched.
+	cmd := exec.CommandContext(ctx, "git", "-c", "core.quotePath=false", "log",
+		fmt.Sprintf("--since=%d.days.ago", days),
+		"--format=%x00%aE", "--name-only", "--relative", "--no-renames", "HEAD")
+	cmd.Dir = root
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git log: %s", msg)
+		}
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+
+	authors := make(map[string]map[string]struct{})
+	var current string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == "" {
+			continue
+		}
+		if email, ok := strings.CutPrefix(line, "\x00"); ok {
+			current = strings.ToLower(email)
+			continue
+		}
+		rel := filepath.FromSlash(line)
+		if authors[rel] == nil {
+			authors[rel] = make(map[string]struct{})
+		}
+		authors[rel][current] = struct{}{}
+	}
+
+	counts := make(map[string]int, len(authors))
+	for rel, set := range authors {
+		counts[rel] = len(set)
+	}
+	return counts, nil
+}
+
+// Exclude returns the files whose paths match none of the patterns. Each
+// pattern acts as a successive filter, so a file matching any of them is
+// dropped. See MatchGlob for pattern syntax.
+func Exclude(files []FileEntry, patterns []string) []FileEntry {
+	if len(patterns) == 0 {
+		return files
+	}
+	var kept []FileEntry
+	for _, f := range files {
+		if !matchAny(patterns, f.Path) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// matchAny reports whether relPath matches any of the patterns.
+func matchAny(patterns []string, relPath string) bool {
+	for _, p := range patterns {
+		if MatchGlob(p, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsGlob reports whether s contains glob syntax (*, ?, [, or {), as opposed
+// to being a plain substring.
+func IsGlob(s string) bool {
+	return strings.ContainsAny(s, "*?[{")
+}
+
+// ValidateGlob returns an error if pattern is not a valid MatchGlob pattern.
+func ValidateGlob(pattern string) error {
+	for _, alt := range expandBraces(pattern) {
+		for _, seg := range strings.Split(alt, "/") {
+			if _, err := path.Match(seg, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// MatchGlob reports whether relPath matches pattern. Both are slash-separated;
+// each pattern segment uses path.Match syntax, and a "**" segment matches zero
+// or more directories. Braces list alternatives ("*.{go,py}"). As in
+// .gitignore, a pattern with no slash matches the base name at any depth
+// ("*.pb.go"), and a pattern that matches a directory matches everything
+// beneath it ("internal/pb").
+func MatchGlob(pattern, relPath string) bool {
+	for _, alt := range expandBraces(pattern) {
+		if matchGlob(alt, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandBraces expands brace alternatives, including nested ones:
+// "a.{go,py}" yields ["a.go", "a.py"]. An unbalanced brace is left as is.
+func expandBraces(pattern string) []string {
+	open := strings.IndexByte(pattern, '{')
+	if open < 0 {
+		return []string{pattern}
+	}
+	var alts []string
+	depth, start := 0, open+1
+	for i := open; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case ',':
+			if depth == 1 {
+				alts = append(alts, pattern[start:i])
+				start = i + 1
+			}
+		case '}':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			alts = append(alts, pattern[start:i])
+			var out []string
+			for _, alt := range alts {
+				out = append(out, expandBraces(pattern[:open]+alt+pattern[i+1:])...)
+			}
+			return out
+		}
+	}
+	return []string{pattern}
+}
+
+func matchGlob(pattern, relPath string) bool {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		for _, part := range parts {
+			if ok, _ := path.Match(pattern, part); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return matchSegments(strings.Split(pattern, "/"), parts)
+}
+
+// matchSegments matches pattern segments against path segments. It succeeds
+// once the pattern is exhausted, so a pattern naming a directory matches the
+// files below it.
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return true
+}
+
 // IsTestFile reports whether relPath appears to be a test file, based on
 // path conventions that are consistent across major languages:
 //   - a directory component named test, tests, spec, specs, or __tests__
+//   - a directory component named testdata (Go fixtures, ignored by the go tool)
 //   - a filename whose base starts with test_, ends with _test or _spec,
 //     or contains .test or .spec (handles .test.js, .spec.ts, etc.)
 func IsTestFile(relPath string) bool {
@@ -156,7 +591,7 @@ func IsTestFile(relPath string) bool {
 	// Check directory components (everything except the filename).
 	for _, dir := range parts[:len(parts)-1] {
 		switch strings.ToLower(dir) {
-		case "test", "tests", "spec", "specs", "__tests__":
+		case "test", "tests", "spec", "specs", "__tests__", "testdata":
 			return true
 		}
 	}
@@ -171,8 +606,56 @@ func IsTestFile(relPath string) bool {
 		strings.Contains(base, ".spec")
 }
 
-func loadGitignore(root string) *ignore.GitIgnore {
-	path := filepath.Join(root, ".gitignore")
+// IsTestFileWith reports whether relPath is a test file by IsTestFile's
+// conventions or matches any of the project-specific patterns (see MatchGlob).
+func IsTestFileWith(relPath string, patterns []string) bool {
+	return IsTestFile(relPath) || matchAny(patterns, relPath)
+}
+
+// gitignoreSet holds the .gitignore rules found while walking a tree that
+// git does not track, keyed by the repo-relative directory containing each
+// file ("." for root).
+type gitignoreSet map[string]*ignore.GitIgnore
+
+// load reads dir's .gitignore, if any. It is a no-op on a nil set.
+func (s gitignoreSet) load(root, dir string) {
+	if s == nil {
+		return
+	}
+	if gi := loadIgnoreFile(filepath.Join(root, dir, ".gitignore")); gi != nil {
+		s[dir] = gi
+	}
+}
+
+// matches reports whether relPath is ignored by the .gitignore of any
+// directory above it, each matched against the path relative to its own
+// directory. A trailing separator marks relPath as a directory. Unlike git,
+// a negation in a deeper file does not re-include a path ignored higher up.
+func (s gitignoreSet) matches(relPath string) bool {
+	isDir := strings.HasSuffix(relPath, string(filepath.Separator))
+	relPath = strings.TrimSuffix(relPath, string(filepath.Separator))
+	for dir := filepath.Dir(relPath); ; dir = filepath.Dir(dir) {
+		if gi := s[dir]; gi != nil {
+			sub := relPath
+			if dir != "." {
+				sub = strings.TrimPrefix(relPath, dir+string(filepath.Separator))
+			}
+			if isDir {
+				sub += "/"
+			}
+			if gi.MatchesPath(sub) {
+				return true
+			}
+		}
+		if dir == "." {
+			return false
+		}
+	}
+}
+
+// loadIgnoreFile compiles a gitignore-syntax file, returning nil if it does not
+// exist or cannot be read.
+func loadIgnoreFile(path string) *ignore.GitIgnore {
 	gi, err := ignore.CompileIgnoreFile(path)
 	if err != nil {
 		return nil
@@ -1,8 +1,11 @@
 package discover
 
 import (
+	"bytes"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -19,7 +22,7 @@ func TestDiscoverPythonFiles(t *testing.T) {
 	// Hidden file should be ignored
 	writeFile(t, dir, ".hidden.py", "secret")
 
-	entries, err := Files(dir, nil)
+	entries, err := Files(dir, nil, 0, false, nil)
 	if err != nil {
 		t.Fatalf("Files: %v", err)
 	}
@@ -58,7 +61,7 @@ func TestDiscoverSkipDirs(t *testing.T) {
 	writeFile(t, dir, "__pycache__/cached.py", "pass")
 	writeFile(t, dir, ".hidden/secret.py", "pass")
 
-	entries, err := Files(dir, nil)
+	entries, err := Files(dir, nil, 0, false, nil)
 	if err != nil {
 		t.Fatalf("Files: %v", err)
 	}
@@ -71,6 +74,138 @@ func TestDiscoverSkipDirs(t *testing.T) {
 	}
 }
 
+func TestDiscoverMaxDepth(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	writeFile(t, dir, "main.py", "pass")
+	writeFile(t, dir, "pkg/mod.py", "pass")
+	writeFile(t, dir, "pkg/sub/deep.py", "pass")
+	writeFile(t, dir, "pkg/sub/deeper/deepest.py", "pass")
+
+	tests := []struct {
+		maxDepth int
+		want     []string
+	}{
+		{0, []string{"main.py", "pkg/mod.py", "pkg/sub/deep.py", "pkg/sub/deeper/deepest.py"}},
+		{1, []string{"main.py"}},
+		{2, []string{"main.py", "pkg/mod.py"}},
+		{3, []string{"main.py", "pkg/mod.py", "pkg/sub/deep.py"}},
+	}
+	for _, tt := range tests {
+		entries, err := Files(dir, nil, tt.maxDepth, false, nil)
+		if err != nil {
+			t.Fatalf("Files(maxDepth=%d): %v", tt.maxDepth, err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, filepath.ToSlash(e.Path))
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Files(maxDepth=%d) = %v, want %v", tt.maxDepth, got, tt.want)
+		}
+	}
+}
+
+func TestDiscoverRepoguideIgnore(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	writeFile(t, dir, "main.py", "pass")
+	writeFile(t, dir, "docs/conf.py", "pass")
+	writeFile(t, dir, "fixtures/data.py", "pass")
+	writeFile(t, dir, "lib/generated_pb2.py", "pass")
+	writeFile(t, dir, ".gitignore", "fixtures/\n")
+	writeFile(t, dir, IgnoreFile, "docs/\n*_pb2.py\n")
+
+	entries, err := Files(dir, nil, 0, false, nil)
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+
+	// docs/ and *_pb2.py are dropped only via .repoguideignore; fixtures/ via .gitignore.
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d: %+v", len(entries), entries)
+	}
+	if entries[0].Path != "main.py" {
+		t.Errorf("expected main.py, got %q", entries[0].Path)
+	}
+}
+
+func TestDiscoverNestedGitignore(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	writeFile(t, dir, "main.py", "pass")
+	writeFile(t, dir, "local.py", "pass")
+	writeFile(t, dir, "pkg/api.py", "pass")
+	writeFile(t, dir, "pkg/local.py", "pass")
+	writeFile(t, dir, "pkg/scratch.py", "pass")
+	writeFile(t, dir, "pkg/out/gen.py", "pass")
+	writeFile(t, dir, "pkg/sub/scratch.py", "pass")
+	writeFile(t, dir, "other/out/keep.py", "pass")
+	writeFile(t, dir, ".gitignore", "*.pyc\n")
+	// Rules in pkg/.gitignore apply only beneath pkg/, relative to it.
+	writeFile(t, dir, "pkg/.gitignore", "out/\n/local.py\nscratch.py\n")
+
+	entries, err := Files(dir, nil, 0, false, nil)
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+
+	var got []string
+	for _, e := range entries {
+		got = append(got, filepath.ToSlash(e.Path))
+	}
+	want := []string{"local.py", "main.py", "other/out/keep.py", "pkg/api.py"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverNoGitignore(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	writeFile(t, dir, "main.py", "pass")
+	writeFile(t, dir, "gen/api_pb2.py", "pass")
+	writeFile(t, dir, "scratch.py", "pass")
+	writeFile(t, dir, "node_modules/pkg.py", "pass")
+	writeFile(t, dir, "skip/me.py", "pass")
+	writeFile(t, dir, ".gitignore", "gen/\n")
+	writeFile(t, dir, IgnoreFile, "skip/\n")
+	git(t, dir, "init", "-q")
+	git(t, dir, "add", "main.py", ".gitignore")
+
+	paths := func(noGitignore bool) string {
+		t.Helper()
+		entries, err := Files(dir, nil, 0, noGitignore, nil)
+		if err != nil {
+			t.Fatalf("Files: %v", err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, filepath.ToSlash(e.Path))
+		}
+		return strings.Join(got, " ")
+	}
+
+	if got, want := paths(false), "main.py scratch.py"; got != want {
+		t.Errorf("with git: got %q, want %q", got, want)
+	}
+	// Gitignored files are included; .repoguideignore and vendor
+	// directories still apply.
+	if got, want := paths(true), "gen/api_pb2.py main.py scratch.py"; got != want {
+		t.Errorf("noGitignore: got %q, want %q", got, want)
+	}
+}
+
 func TestDiscoverLanguageFilter(t *testing.T) {
 	t.Parallel()
 
@@ -79,7 +214,7 @@ func TestDiscoverLanguageFilter(t *testing.T) {
 	writeFile(t, dir, "main.py", "pass")
 	writeFile(t, dir, "lib.py", "pass")
 
-	entries, err := Files(dir, []string{"python"})
+	entries, err := Files(dir, []string{"python"}, 0, false, nil)
 	if err != nil {
 		t.Fatalf("Files: %v", err)
 	}
@@ -87,7 +222,7 @@ func TestDiscoverLanguageFilter(t *testing.T) {
 		t.Fatalf("expected 2 entries for python filter, got %d", len(entries))
 	}
 
-	entries, err = Files(dir, []string{"javascript"})
+	entries, err = Files(dir, []string{"javascript"}, 0, false, nil)
 	if err != nil {
 		t.Fatalf("Files: %v", err)
 	}
@@ -96,6 +231,39 @@ func TestDiscoverLanguageFilter(t *testing.T) {
 	}
 }
 
+func TestDiscoverVerboseLog(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "main.py", "pass")
+	writeFile(t, dir, "node_modules/pkg.py", "pass")
+	writeFile(t, dir, ".hidden/secret.py", "pass")
+	writeFile(t, dir, "notes.txt", "todo")
+	writeFile(t, dir, "lib.go", "package lib")
+
+	var log bytes.Buffer
+	entries, err := Files(dir, []string{"python"}, 0, false, &log)
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d: %+v", len(entries), entries)
+	}
+
+	out := log.String()
+	for _, want := range []string{
+		"discover: skip dir node_modules (vendor, build, or tool directory)",
+		"discover: skip dir .hidden (hidden)",
+		`discover: skip notes.txt (no supported language for ".txt")`,
+		"discover: skip lib.go (go, not in --langs)",
+		"discover: add main.py (python)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log missing %q:\n%s", want, out)
+		}
+	}
+}
+
 func TestDiscoverSymlinksSkipped(t *testing.T) {
 	t.Parallel()
 
@@ -108,7 +276,7 @@ func TestDiscoverSymlinksSkipped(t *testing.T) {
 		t.Skip("symlinks not supported")
 	}
 
-	entries, err := Files(dir, nil)
+	entries, err := Files(dir, nil, 0, false, nil)
 	if err != nil {
 		t.Fatalf("Files: %v", err)
 	}
@@ -135,6 +303,8 @@ func TestIsTestFile(t *testing.T) {
 		{"src/__tests__/foo.js", true},
 		{"src/test/java/FooTest.java", true},
 		{"test/foo_test.exs", true},
+		{"pkg/testdata/sample.go", true},
+		{"testdata/fixture.py", true},
 		// Filename patterns
 		{"internal/graph/graph_test.go", true},
 		{"test_helpers.py", true},
@@ -160,6 +330,155 @@ func TestIsTestFile(t *testing.T) {
 	}
 }
 
+func TestIsTestFileWith(t *testing.T) {
+	t.Parallel()
+	patterns := []string{"**/*_integration.go", "qa/**"}
+	cases := []struct {
+		path string
+		want bool
+	}{
+		{"internal/db/store_integration.go", true},
+		{"qa/smoke.py", true},
+		{"internal/graph/graph_test.go", true}, // built-in conventions still apply
+		{"internal/db/store.go", false},
+	}
+	for _, tc := range cases {
+		if got := IsTestFileWith(tc.path, patterns); got != tc.want {
+			t.Errorf("IsTestFileWith(%q) = %v, want %v", tc.path, got, tc.want)
+		}
+	}
+	if IsTestFileWith("qa/smoke.py", nil) {
+		t.Error("no patterns should fall back to IsTestFile")
+	}
+}
+
+func TestFromList(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "main.py", "pass")
+	writeFile(t, dir, "lib/util.go", "package lib")
+	writeFile(t, dir, "readme.txt", "hello")
+
+	input := strings.Join([]string{
+		"main.py",
+		"",
+		"./main.py",
+		filepath.Join(dir, "lib", "util.go"),
+		"readme.txt",
+		"deleted.py",
+		"../outside.py",
+	}, "\n")
+
+	var warn bytes.Buffer
+	entries, err := FromList(dir, strings.NewReader(input), nil, &warn)
+	if err != nil {
+		t.Fatalf("FromList: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if entries[0].Path != filepath.Join("lib", "util.go") || entries[0].Language != "go" {
+		t.Errorf("entry 0: %+v", entries[0])
+	}
+	if entries[1].Path != "main.py" || entries[1].Language != "python" {
+		t.Errorf("entry 1: %+v", entries[1])
+	}
+	for _, skipped := range []string{"readme.txt", "deleted.py", "../outside.py"} {
+		if !strings.Contains(warn.String(), skipped) {
+			t.Errorf("expected warning for %s, got:\n%s", skipped, warn.String())
+		}
+	}
+}
+
+func TestFromListLanguageFilter(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "main.py", "pass")
+	writeFile(t, dir, "main.go", "package main")
+
+	var warn bytes.Buffer
+	entries, err := FromList(dir, strings.NewReader("main.py\nmain.go\n"), []string{"go"}, &warn)
+	if err != nil {
+		t.Fatalf("FromList: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Path != "main.go" {
+		t.Errorf("expected only main.go, got %+v", entries)
+	}
+	if warn.Len() != 0 {
+		t.Errorf("language-filtered files should not warn, got:\n%s", warn.String())
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"internal/pb/**", "internal/pb/api.pb.go", true},
+		{"internal/pb/**", "internal/pb/v1/api.pb.go", true},
+		{"internal/pb/**", "internal/pbx/api.go", false},
+		{"internal/pb", "internal/pb/api.pb.go", true},
+		{"internal/pb/", "internal/pb/api.pb.go", true},
+		{"**/testdata/**", "a/b/testdata/x.go", true},
+		{"**/testdata/**", "testdata/x.go", true},
+		{"*.pb.go", "internal/pb/api.pb.go", true},
+		{"*.pb.go", "internal/api.go", false},
+		{"cmd/*.go", "cmd/main.go", true},
+		{"cmd/*.go", "cmd/sub/main.go", false},
+		{"cmd/*.go", "pkg/cmd/main.go", false},
+		{"vendor", "vendor/lib/x.go", true},
+		{"a/**/z.go", "a/z.go", true},
+		{"a/**/z.go", "a/b/c/z.go", true},
+		{"a/**/z.go", "a/b/c/y.go", false},
+		{"*.{go,py}", "pkg/main.py", true},
+		{"*.{go,py}", "pkg/main.rb", false},
+		{"{cmd,internal/{a,b}}/*.go", "internal/b/x.go", true},
+		{"{cmd,internal/{a,b}}/*.go", "internal/c/x.go", false},
+		{"a{b.go", "a{b.go", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"|"+tt.path, func(t *testing.T) {
+			t.Parallel()
+			if got := MatchGlob(tt.pattern, tt.path); got != tt.want {
+				t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExclude(t *testing.T) {
+	t.Parallel()
+
+	files := []FileEntry{
+		{Path: "main.go", Language: "go"},
+		{Path: "internal/pb/api.pb.go", Language: "go"},
+		{Path: "internal/server/server.go", Language: "go"},
+		{Path: "scripts/gen.py", Language: "python"},
+	}
+	got := Exclude(files, []string{"internal/pb/**", "scripts"})
+	if len(got) != 2 || got[0].Path != "main.go" || got[1].Path != "internal/server/server.go" {
+		t.Errorf("Exclude = %+v", got)
+	}
+}
+
+func TestValidateGlob(t *testing.T) {
+	t.Parallel()
+
+	if err := ValidateGlob("internal/**/*.go"); err != nil {
+		t.Errorf("valid pattern rejected: %v", err)
+	}
+	if err := ValidateGlob("internal/[.go"); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+}
+
 func writeFile(t *testing.T, root, rel, content string) {
 	t.Helper()
 	path := filepath.Join(root, rel)
@@ -171,3 +490,156 @@ func writeFile(t *testing.T, root, rel, content string) {
 		t.Fatal(err)
 	}
 }
+
+// git runs a git command in dir, failing the test on error.
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestChangedSince(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	writeFile(t, dir, "app/main.py", "print('v1')")
+	writeFile(t, dir, "app/old.py", "x = 1")
+	writeFile(t, dir, "other/util.py", "y = 1")
+	git(t, dir, "init", "-q", "-b", "main")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "base")
+	git(t, dir, "checkout", "-q", "-b", "feature")
+	writeFile(t, dir, "app/main.py", "print('v2')")
+	writeFile(t, dir, "app/new.py", "z = 1")
+	writeFile(t, dir, "other/util.py", "y = 2")
+	git(t, dir, "rm", "-q", "app/old.py")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "change")
+
+	got, err := ChangedSince(filepath.Join(dir, "app"), "main")
+	if err != nil {
+		t.Fatalf("ChangedSince: %v", err)
+	}
+	// Deleted files and changes outside root are dropped; paths are root-relative.
+	want := map[string]struct{}{"main.py": {}, "new.py": {}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for p := range want {
+		if _, ok := got[p]; !ok {
+			t.Errorf("missing %s in %v", p, got)
+		}
+	}
+
+	if _, err := ChangedSince(dir, "no-such-ref"); err == nil {
+		t.Error("expected error for unknown ref")
+	}
+	if _, err := ChangedSince(dir, "--output=x"); err == nil {
+		t.Error("expected error for ref that looks like a flag")
+	}
+}
+
+func TestChangedSinceNotGit(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	_, err := ChangedSince(t.TempDir(), "main")
+	if err == nil || !strings.Contains(err.Error(), "not inside a git repository") {
+		t.Errorf("expected not-a-repository error, got %v", err)
+	}
+}
+
+func TestLastModified(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	commitAt := func(when string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q"}, args...)...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE="+when, "GIT_AUTHOR_DATE="+when)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git commit: %v\n%s", err, out)
+		}
+	}
+	writeFile(t, dir, "app/old.py", "x = 1")
+	writeFile(t, dir, "app/hot.py", "y = 1")
+	writeFile(t, dir, "other.py", "z = 1")
+	git(t, dir, "init", "-q", "-b", "main")
+	git(t, dir, "add", ".")
+	commitAt("1700000000 +0000", "-m", "base")
+	writeFile(t, dir, "app/hot.py", "y = 2")
+	git(t, dir, "add", ".")
+	commitAt("1700086400 +0000", "-m", "change")
+	writeFile(t, dir, "app/untracked.py", "w = 1")
+
+	got, err := LastModified(filepath.Join(dir, "app"))
+	if err != nil {
+		t.Fatalf("LastModified: %v", err)
+	}
+	want := map[string]int64{"old.py": 1700000000, "hot.py": 1700086400}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for p, ts := range want {
+		if got[p] != ts {
+			t.Errorf("%s: got %d, want %d", p, got[p], ts)
+		}
+	}
+
+	if _, err := LastModified(t.TempDir()); err == nil {
+		t.Error("expected error outside a git repository")
+	}
+}
+
+func TestRecentAuthors(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	commitAs := func(email string) {
+		t.Helper()
+		git(t, dir, "add", ".")
+		git(t, dir, "-c", "user.email="+email, "commit", "-q", "-m", "change by "+email)
+	}
+	writeFile(t, dir, "app/hot.py", "x = 1")
+	writeFile(t, dir, "app/quiet.py", "y = 1")
+	git(t, dir, "init", "-q", "-b", "main")
+	commitAs("alice@example.com")
+	writeFile(t, dir, "app/hot.py", "x = 2")
+	commitAs("bob@example.com")
+	writeFile(t, dir, "app/hot.py", "x = 3")
+	commitAs("Alice@Example.com") // same author, different case
+	writeFile(t, dir, "app/new.py", "z = 1")
+
+	got, err := RecentAuthors(filepath.Join(dir, "app"), 30)
+	if err != nil {
+		t.Fatalf("RecentAuthors: %v", err)
+	}
+	want := map[string]int{"hot.py": 2, "quiet.py": 1}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for p, n := range want {
+		if got[p] != n {
+			t.Errorf("%s: got %d authors, want %d", p, got[p], n)
+		}
+	}
+
+	if _, err := RecentAuthors(t.TempDir(), 30); err == nil {
+		t.Error("expected error outside a git repository")
+	}
+}
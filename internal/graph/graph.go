@@ -2,29 +2,102 @@
 package graph
 
 import (
+	"maps"
 	"math"
+	"path"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/phobologic/repoguide/internal/model"
 )
 
-// BuildGraph creates dependency edges from cross-file symbol references.
-// Returns a list of dependencies suitable for the RepoMap.
-func BuildGraph(fileInfos []model.FileInfo) []model.Dependency {
-	// Build definition index: symbol name → set of files that define it
-	defines := make(map[string]map[string]struct{})
+// maxMethodCandidates caps how many same-named methods a bare method call
+// may resolve to. A name shared by more types (String, Close, Error) says
+// too little about which one is called to be worth an edge to each.
+const maxMethodCandidates = 3
+
+// symbolIndex maps definition names to the files that define them.
+// Interface methods are also indexed by their bare method name, because a
+// call through an interface value (w.Write(...)) names only the method, and
+// so are concrete methods, for calls whose receiver type is unknown.
+type symbolIndex struct {
+	defines    map[string]map[string]struct{} // symbol name → defining files
+	interfaces map[string][]string            // bare method name → qualified interface methods
+	methods    map[string][]string            // bare method name → qualified concrete methods
+}
+
+func newSymbolIndex(fileInfos []model.FileInfo) *symbolIndex {
+	idx := &symbolIndex{
+		defines:    make(map[string]map[string]struct{}),
+		interfaces: make(map[string][]string),
+		methods:    make(map[string][]string),
+	}
 	for i := range fileInfos {
 		fi := &fileInfos[i]
 		for j := range fi.Tags {
 			tag := &fi.Tags[j]
-			if tag.Kind == model.Definition {
-				if defines[tag.Name] == nil {
-					defines[tag.Name] = make(map[string]struct{})
+			if tag.Kind != model.Definition {
+				continue
+			}
+			if idx.defines[tag.Name] == nil {
+				idx.defines[tag.Name] = make(map[string]struct{})
+			}
+			idx.defines[tag.Name][fi.Path] = struct{}{}
+			owner, bare := model.SplitMember(tag.Name)
+			switch {
+			case tag.Interface:
+				if !contains(idx.interfaces[bare], tag.Name) {
+					idx.interfaces[bare] = append(idx.interfaces[bare], tag.Name)
+				}
+			case tag.SymbolKind == model.Method && owner != "":
+				if !contains(idx.methods[bare], tag.Name) {
+					idx.methods[bare] = append(idx.methods[bare], tag.Name)
 				}
-				defines[tag.Name][fi.Path] = struct{}{}
 			}
 		}
 	}
+	for _, names := range idx.interfaces {
+		sort.Strings(names)
+	}
+	for _, names := range idx.methods {
+		sort.Strings(names)
+	}
+	return idx
+}
+
+// resolve returns the definition names a reference may refer to: the name
+// itself if something defines it, followed by any interface methods with
+// that bare name. Failing both, a bare name resolves to the concrete methods
+// of that name when there are at most maxMethodCandidates of them. A method
+// qualified by a repo type but not defined on it (one promoted from an
+// embedded type) is resolved by its bare name. It returns nil for names with
+// no definition in the repo.
+func (idx *symbolIndex) resolve(name string) []string {
+	var names []string
+	if _, ok := idx.defines[name]; ok {
+		names = append(names, name)
+	} else if owner, bare := model.SplitMember(name); owner != "" {
+		if _, ok := idx.defines[owner]; ok {
+			return idx.resolve(bare)
+		}
+		return nil
+	}
+	names = append(names, idx.interfaces[name]...)
+	if len(names) == 0 && len(idx.methods[name]) <= maxMethodCandidates {
+		names = idx.methods[name]
+	}
+	return names
+}
+
+// BuildGraph creates dependency edges from cross-file symbol references.
+// A call to an interface method by its bare name (w.Write) counts as a
+// reference to each interface declaring it ("Writer.Write"). A file import
+// that resolves to a file in the repo (see importResolver) is an edge too,
+// with no symbols unless references add some.
+// Returns a list of dependencies suitable for the RepoMap.
+func BuildGraph(fileInfos []model.FileInfo) []model.Dependency {
+	idx := newSymbolIndex(fileInfos)
 
 	// Build edges: source → target → list of symbols
 	type edgeKey struct{ src, tgt string }
@@ -37,21 +110,32 @@ func BuildGraph(fileInfos []model.FileInfo) []model.Dependency {
 			if tag.Kind != model.Reference {
 				continue
 			}
-			defFiles := defines[tag.Name]
-			if defFiles == nil {
+			for _, name := range idx.resolve(tag.Name) {
+				// Iterate in sorted order for determinism
+				for _, defFile := range sortedKeys(idx.defines[name]) {
+					if defFile == fi.Path {
+						continue // no self-edges
+					}
+					key := edgeKey{fi.Path, defFile}
+					// Only add symbol if not already present
+					if !contains(edgeSymbols[key], name) {
+						edgeSymbols[key] = append(edgeSymbols[key], name)
+					}
+				}
+			}
+		}
+	}
+
+	imports := newImportResolver(fileInfos)
+	for i := range fileInfos {
+		fi := &fileInfos[i]
+		for _, imp := range fi.Imports {
+			tgt := imports.resolve(fi.Path, imp)
+			if tgt == "" || tgt == fi.Path {
 				continue
 			}
-			// Iterate in sorted order for determinism
-			sorted := sortedKeys(defFiles)
-			for _, defFile := range sorted {
-				if defFile == fi.Path {
-					continue // no self-edges
-				}
-				key := edgeKey{fi.Path, defFile}
-				// Only add symbol if not already present
-				if !contains(edgeSymbols[key], tag.Name) {
-					edgeSymbols[key] = append(edgeSymbols[key], tag.Name)
-				}
+			if key := (edgeKey{fi.Path, tgt}); edgeSymbols[key] == nil {
+				edgeSymbols[key] = []string{}
 			}
 		}
 	}
@@ -76,23 +160,92 @@ func BuildGraph(fileInfos []model.FileInfo) []model.Dependency {
 	return deps
 }
 
-// BuildCallGraph builds function-level call edges from the parsed file infos.
-// An edge is only included when the callee is a known definition in the repo
-// and the caller (Enclosing) is non-empty. Edges are deduplicated and sorted.
-func BuildCallGraph(fileInfos []model.FileInfo) []model.CallEdge {
-	// Build set of all known definition names.
-	knownDefs := make(map[string]struct{})
+// importResolver maps file imports (model.Import) to the files they load.
+type importResolver struct {
+	files    map[string]struct{}
+	loadPath map[string][]string // path below a lib directory, or from the root → files
+}
+
+func newImportResolver(fileInfos []model.FileInfo) *importResolver {
+	r := &importResolver{
+		files:    make(map[string]struct{}, len(fileInfos)),
+		loadPath: make(map[string][]string),
+	}
 	for i := range fileInfos {
-		for j := range fileInfos[i].Tags {
-			tag := &fileInfos[i].Tags[j]
-			if tag.Kind == model.Definition {
-				knownDefs[tag.Name] = struct{}{}
+		p := fileInfos[i].Path
+		r.files[p] = struct{}{}
+		r.loadPath[p] = append(r.loadPath[p], p)
+		parts := strings.Split(p, "/")
+		for k, dir := range parts[:len(parts)-1] {
+			if dir == "lib" {
+				rest := strings.Join(parts[k+1:], "/")
+				r.loadPath[rest] = append(r.loadPath[rest], p)
 			}
 		}
 	}
+	return r
+}
+
+// resolve returns the file that from loads with imp, or "" if it is not in
+// the repo. The importing file's extension is added to a path without it. A
+// relative import is resolved exactly against from's directory. Any other
+// import is resolved heuristically against a load path of the repo root and
+// every lib directory (as in Ruby gems, which put lib on the load path);
+// when several files match, the one sharing the most directories with from
+// wins, and a tie resolves to nothing.
+func (r *importResolver) resolve(from string, imp model.Import) string {
+	name := imp.Path
+	if ext := path.Ext(from); !strings.HasSuffix(name, ext) {
+		name += ext
+	}
+	if imp.Relative {
+		p := path.Join(path.Dir(from), name)
+		if _, ok := r.files[p]; ok {
+			return p
+		}
+		return ""
+	}
+
+	best, bestShared, tied := "", -1, false
+	for _, candidate := range r.loadPath[path.Clean(name)] {
+		shared := sharedDirs(path.Dir(from), path.Dir(candidate))
+		switch {
+		case shared > bestShared:
+			best, bestShared, tied = candidate, shared, false
+		case shared == bestShared && candidate != best:
+			tied = true
+		}
+	}
+	if tied {
+		return ""
+	}
+	return best
+}
+
+// sharedDirs returns the number of leading directories two slash-separated
+// directory paths have in common.
+func sharedDirs(a, b string) int {
+	if a == "." || b == "." {
+		return 0
+	}
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	n := 0
+	for n < len(as) && n < len(bs) && as[n] == bs[n] {
+		n++
+	}
+	return n
+}
+
+// BuildCallGraph builds function-level call edges from the parsed file infos.
+// An edge is only included when the callee is a known definition in the repo
+// and the caller (Enclosing) is non-empty. A call by bare method name also
+// yields an edge to each interface method of that name. Edges are
+// deduplicated and sorted.
+func BuildCallGraph(fileInfos []model.FileInfo) []model.CallEdge {
+	idx := newSymbolIndex(fileInfos)
 
 	type edgeKey struct{ caller, callee string }
-	seen := make(map[edgeKey]struct{})
+	seen := make(map[edgeKey]int) // edge → index in edges
 
 	var edges []model.CallEdge
 	for i := range fileInfos {
@@ -101,15 +254,15 @@ func BuildCallGraph(fileInfos []model.FileInfo) []model.CallEdge {
 			if tag.Kind != model.Reference || tag.Enclosing == "" {
 				continue
 			}
-			if _, ok := knownDefs[tag.Name]; !ok {
-				continue
-			}
-			key := edgeKey{tag.Enclosing, tag.Name}
-			if _, dup := seen[key]; dup {
-				continue
+			for _, callee := range idx.resolve(tag.Name) {
+				key := edgeKey{tag.Enclosing, callee}
+				if k, dup := seen[key]; dup {
+					edges[k].Count++
+					continue
+				}
+				seen[key] = len(edges)
+				edges = append(edges, model.CallEdge{Caller: tag.Enclosing, Callee: callee, Count: 1})
 			}
-			seen[key] = struct{}{}
-			edges = append(edges, model.CallEdge{Caller: tag.Enclosing, Callee: tag.Name})
 		}
 	}
 
@@ -126,20 +279,12 @@ func BuildCallGraph(fileInfos []model.FileInfo) []model.CallEdge {
 // BuildCallSites returns all individual call and import occurrences with source
 // locations. Unlike BuildCallGraph, it does not deduplicate: if a function calls
 // another three times, three CallSite entries are returned. Module-level import
-// references (where no enclosing function exists) are included with Caller set to
-// "<import>". Intended for focused (--symbol / --file) queries where precise line
+// references (where no enclosing function exists) are included with Kind
+// model.SiteImport and Caller set to model.ImportCaller; the rest have Kind
+// model.SiteCall. Intended for focused (--symbol / --file) queries where precise line
 // numbers matter.
 func BuildCallSites(fileInfos []model.FileInfo) []model.CallSite {
-	// Build set of all known definition names.
-	knownDefs := make(map[string]struct{})
-	for i := range fileInfos {
-		for j := range fileInfos[i].Tags {
-			tag := &fileInfos[i].Tags[j]
-			if tag.Kind == model.Definition {
-				knownDefs[tag.Name] = struct{}{}
-			}
-		}
-	}
+	idx := newSymbolIndex(fileInfos)
 
 	var sites []model.CallSite
 	for i := range fileInfos {
@@ -148,19 +293,19 @@ func BuildCallSites(fileInfos []model.FileInfo) []model.CallSite {
 			if tag.Kind != model.Reference {
 				continue
 			}
-			if _, ok := knownDefs[tag.Name]; !ok {
-				continue
-			}
-			caller := tag.Enclosing
+			caller, kind := tag.Enclosing, model.SiteCall
 			if caller == "" {
-				caller = "<import>"
+				caller, kind = model.ImportCaller, model.SiteImport
+			}
+			for _, callee := range idx.resolve(tag.Name) {
+				sites = append(sites, model.CallSite{
+					Caller: caller,
+					Callee: callee,
+					File:   fileInfos[i].Path,
+					Line:   tag.Line,
+					Kind:   kind,
+				})
 			}
-			sites = append(sites, model.CallSite{
-				Caller: caller,
-				Callee: tag.Name,
-				File:   fileInfos[i].Path,
-				Line:   tag.Line,
-			})
 		}
 	}
 
@@ -180,13 +325,489 @@ func BuildCallSites(fileInfos []model.FileInfo) []model.CallSite {
 	return sites
 }
 
+// DedupeCallSites collapses sites with the same caller, callee, and file into
+// one site whose Lines lists every call line in ascending order. Line is set
+// to the first of them. Sites keep the order of their first occurrence.
+func DedupeCallSites(sites []model.CallSite) []model.CallSite {
+	type siteKey struct{ caller, callee, file string }
+	index := make(map[siteKey]int)
+	var out []model.CallSite
+	for i := range sites {
+		cs := &sites[i]
+		k := siteKey{cs.Caller, cs.Callee, cs.File}
+		if j, ok := index[k]; ok {
+			if !slices.Contains(out[j].Lines, cs.Line) {
+				out[j].Lines = append(out[j].Lines, cs.Line)
+			}
+			continue
+		}
+		index[k] = len(out)
+		out = append(out, model.CallSite{Caller: cs.Caller, Callee: cs.Callee, File: cs.File, Line: cs.Line, Lines: []int{cs.Line}, Kind: cs.Kind})
+	}
+	for i := range out {
+		sort.Ints(out[i].Lines)
+		out[i].Line = out[i].Lines[0]
+	}
+	return out
+}
+
+// FindExternals returns references that match no definition in the repo,
+// deduplicated by name with a reference count, sorted by count descending
+// and then by name. These are the names BuildGraph drops: stdlib and
+// third-party symbols. Imports written as paths (Go's "errors", C++'s
+// <stdio.h>) are skipped, since they name files or packages, not symbols.
+func FindExternals(fileInfos []model.FileInfo) []model.External {
+	idx := newSymbolIndex(fileInfos)
+
+	counts := make(map[string]int)
+	for i := range fileInfos {
+		for j := range fileInfos[i].Tags {
+			tag := &fileInfos[i].Tags[j]
+			if tag.Kind != model.Reference || isImportPath(tag) {
+				continue
+			}
+			if len(idx.resolve(tag.Name)) > 0 {
+				continue
+			}
+			counts[tag.Name]++
+		}
+	}
+
+	externals := make([]model.External, 0, len(counts))
+	for name, n := range counts {
+		externals = append(externals, model.External{Name: name, Count: n})
+	}
+	sort.Slice(externals, func(i, j int) bool {
+		if externals[i].Count != externals[j].Count {
+			return externals[i].Count > externals[j].Count
+		}
+		return externals[i].Name < externals[j].Name
+	})
+	return externals
+}
+
+// isImportPath reports whether an import reference is a quoted path or
+// file name rather than an imported name such as Python's json.
+func isImportPath(tag *model.Tag) bool {
+	return tag.SymbolKind == model.Module && strings.ContainsAny(tag.Name, `"<>/.`)
+}
+
+// Neighbors returns files together with their direct neighbors in deps: every
+// file that depends on one of them or that one of them depends on.
+func Neighbors(deps []model.Dependency, files map[string]struct{}) map[string]struct{} {
+	result := make(map[string]struct{}, len(files))
+	for f := range files {
+		result[f] = struct{}{}
+	}
+	for _, d := range deps {
+		_, srcOK := files[d.Source]
+		_, tgtOK := files[d.Target]
+		if srcOK {
+			result[d.Target] = struct{}{}
+		}
+		if tgtOK {
+			result[d.Source] = struct{}{}
+		}
+	}
+	return result
+}
+
+// FileRefs returns the reference tags of the given files, deduplicated by
+// (file, name) with the line of the first occurrence, sorted by file and then
+// name. Files not in files are skipped.
+func FileRefs(fileInfos []model.FileInfo, files map[string]struct{}) []model.Ref {
+	type refKey struct{ file, name string }
+	first := make(map[refKey]int)
+	for i := range fileInfos {
+		fi := &fileInfos[i]
+		if _, ok := files[fi.Path]; !ok {
+			continue
+		}
+		for j := range fi.Tags {
+			tag := &fi.Tags[j]
+			if tag.Kind != model.Reference {
+				continue
+			}
+			key := refKey{fi.Path, tag.Name}
+			if line, seen := first[key]; !seen || tag.Line < line {
+				first[key] = tag.Line
+			}
+		}
+	}
+
+	refs := make([]model.Ref, 0, len(first))
+	for key, line := range first {
+		refs = append(refs, model.Ref{File: key.file, Name: key.name, Line: line})
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].File != refs[j].File {
+			return refs[i].File < refs[j].File
+		}
+		return refs[i].Name < refs[j].Name
+	})
+	return refs
+}
+
+// DegreeMetrics returns the in- and out-degree of each file in deps, in the
+// order of files. Degrees count distinct neighboring files, so a file that
+// imports another through several symbols adds one to each side.
+func DegreeMetrics(files []model.FileInfo, deps []model.Dependency) []model.Metric {
+	type pair struct{ src, tgt string }
+	seen := make(map[pair]struct{}, len(deps))
+	in := make(map[string]int)
+	out := make(map[string]int)
+	for _, d := range deps {
+		p := pair{d.Source, d.Target}
+		if _, dup := seen[p]; dup || d.Source == d.Target {
+			continue
+		}
+		seen[p] = struct{}{}
+		out[d.Source]++
+		in[d.Target]++
+	}
+
+	metrics := make([]model.Metric, len(files))
+	for i := range files {
+		path := files[i].Path
+		metrics[i] = model.Metric{File: path, InDegree: in[path], OutDegree: out[path], Rank: files[i].Rank}
+	}
+	return metrics
+}
+
+// FindEntrypoints returns the entrypoint tags of the given files, sorted by
+// file and then line. Files not in files are skipped.
+func FindEntrypoints(fileInfos []model.FileInfo, files map[string]struct{}) []model.Entrypoint {
+	var entries []model.Entrypoint
+	for i := range fileInfos {
+		fi := &fileInfos[i]
+		if _, ok := files[fi.Path]; !ok {
+			continue
+		}
+		for j := range fi.Tags {
+			if tag := &fi.Tags[j]; tag.Kind == model.Entry {
+				entries = append(entries, model.Entrypoint{File: fi.Path, Name: tag.Name, Line: tag.Line})
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].File != entries[j].File {
+			return entries[i].File < entries[j].File
+		}
+		return entries[i].Line < entries[j].Line
+	})
+	return entries
+}
+
+// unusedKinds are the symbol kinds FindUnused reports: those whose uses are
+// captured as references (calls). Types, constants, variables, and fields
+// are mostly used by value or in type positions, which are not, so every one
+// of them would look unused.
+var unusedKinds = map[model.SymbolKind]struct{}{
+	model.Function: {},
+	model.Method:   {},
+}
+
+// FindUnused returns the exported functions and methods that no other file
+// references, sorted by file and then line: likely dead code, though uses the map can't
+// see (from outside the repo, or through reflection) make it approximate.
+// A reference counts when it resolves to the definition as in BuildGraph, or
+// for methods when it names the method's bare name at all, since a call
+// through an interface or an untyped receiver can reach any method of that
+// name. Entrypoints (such as main), definitions in files isTest reports as
+// tests, and unexported definitions are never reported.
+func FindUnused(fileInfos []model.FileInfo, isTest func(path string) bool) []model.Tag {
+	idx := newSymbolIndex(fileInfos)
+
+	type defKey struct{ file, name string }
+	used := make(map[defKey]struct{})
+	usedMethods := make(map[string]map[string]struct{}) // bare name → referencing files
+	for i := range fileInfos {
+		fi := &fileInfos[i]
+		for j := range fi.Tags {
+			tag := &fi.Tags[j]
+			if tag.Kind != model.Reference {
+				continue
+			}
+			for _, name := range idx.resolve(tag.Name) {
+				for defFile := range idx.defines[name] {
+					if defFile != fi.Path {
+						used[defKey{defFile, name}] = struct{}{}
+					}
+				}
+			}
+			_, bare := model.SplitMember(tag.Name)
+			if usedMethods[bare] == nil {
+				usedMethods[bare] = make(map[string]struct{})
+			}
+			usedMethods[bare][fi.Path] = struct{}{}
+		}
+	}
+
+	var unused []model.Tag
+	for i := range fileInfos {
+		fi := &fileInfos[i]
+		if isTest != nil && isTest(fi.Path) {
+			continue
+		}
+		entries := make(map[string]struct{})
+		for j := range fi.Tags {
+			if tag := &fi.Tags[j]; tag.Kind == model.Entry {
+				entries[tag.Name] = struct{}{}
+			}
+		}
+		for j := range fi.Tags {
+			tag := &fi.Tags[j]
+			if tag.Kind != model.Definition || tag.Unexported {
+				continue
+			}
+			if _, ok := unusedKinds[tag.SymbolKind]; !ok {
+				continue
+			}
+			if _, ok := entries[tag.Name]; ok || tag.Name == "main" {
+				continue
+			}
+			if _, ok := used[defKey{fi.Path, tag.Name}]; ok {
+				continue
+			}
+			if tag.SymbolKind == model.Method && referencedElsewhere(usedMethods, tag.Name, fi.Path) {
+				continue
+			}
+			t := *tag
+			t.File = fi.Path
+			unused = append(unused, t)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		if unused[i].File != unused[j].File {
+			return unused[i].File < unused[j].File
+		}
+		return unused[i].Line < unused[j].Line
+	})
+	return unused
+}
+
+// ResolveEntries returns the qualified names of the definitions in fileInfos
+// that names refers to, sorted: those named exactly as an entry, or whose
+// member part is one (so "ServeHTTP" finds "Server.ServeHTTP").
+func ResolveEntries(fileInfos []model.FileInfo, names []string) []string {
+	want := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		want[n] = struct{}{}
+	}
+	found := make(map[string]struct{})
+	for i := range fileInfos {
+		for j := range fileInfos[i].Tags {
+			tag := &fileInfos[i].Tags[j]
+			if tag.Kind != model.Definition {
+				continue
+			}
+			_, member := model.SplitMember(tag.Name)
+			_, exact := want[tag.Name]
+			_, byMember := want[member]
+			if exact || byMember {
+				found[tag.Name] = struct{}{}
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(found))
+}
+
+// Reachable returns the names of the symbols reachable from entries by
+// following call edges, entries included. The traversal is breadth-first
+// over the static call graph, so it over-approximates what runs where a
+// name resolves to several definitions, and misses calls made through
+// dynamic dispatch, reflection, or function values.
+func Reachable(edges []model.CallEdge, entries []string) map[string]struct{} {
+	callees := make(map[string][]string)
+	for i := range edges {
+		ce := &edges[i]
+		callees[ce.Caller] = append(callees[ce.Caller], ce.Callee)
+	}
+
+	reached := make(map[string]struct{}, len(entries))
+	queue := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if _, ok := reached[e]; !ok {
+			reached[e] = struct{}{}
+			queue = append(queue, e)
+		}
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, callee := range callees[name] {
+			if _, ok := reached[callee]; !ok {
+				reached[callee] = struct{}{}
+				queue = append(queue, callee)
+			}
+		}
+	}
+	return reached
+}
+
+// referencedElsewhere reports whether a file other than file references the
+// bare name of the method name.
+func referencedElsewhere(usedMethods map[string]map[string]struct{}, name, file string) bool {
+	_, bare := model.SplitMember(name)
+	for f := range usedMethods[bare] {
+		if f != file {
+			return true
+		}
+	}
+	return false
+}
+
+// FindCycles returns the import cycles in deps: every strongly connected
+// component with more than one file, found with Tarjan's algorithm. Files
+// within a cycle are sorted, and cycles are sorted by their first file.
+// BuildGraph never emits self-edges, so single-file components are not cycles.
+func FindCycles(deps []model.Dependency) [][]string {
+	adj := make(map[string][]string)
+	nodeSet := make(map[string]struct{})
+	for _, d := range deps {
+		adj[d.Source] = append(adj[d.Source], d.Target)
+		nodeSet[d.Source] = struct{}{}
+		nodeSet[d.Target] = struct{}{}
+	}
+
+	var (
+		index   int
+		indices = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		cycles  [][]string
+	)
+
+	var strongConnect func(v string)
+	strongConnect = func(v string) {
+		indices[v] = index
+		lowlink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range adj[v] {
+			if _, visited := indices[w]; !visited {
+				strongConnect(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], indices[w])
+			}
+		}
+
+		if lowlink[v] != indices[v] {
+			return
+		}
+		var component []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		if len(component) > 1 {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	// Visit nodes in sorted order for deterministic traversal.
+	for _, v := range sortedKeys(nodeSet) {
+		if _, visited := indices[v]; !visited {
+			strongConnect(v)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0] < cycles[j][0]
+	})
+	return cycles
+}
+
+// EdgeWeights maps source file → target file → edge weight for PageRank.
+type EdgeWeights map[string]map[string]float64
+
+func (w EdgeWeights) add(src, tgt string, weight float64) {
+	if w[src] == nil {
+		w[src] = make(map[string]float64)
+	}
+	w[src][tgt] += weight
+}
+
+// ImportWeights weights each dependency edge by the number of distinct symbols
+// the source references in the target. An edge from a file import alone,
+// with no symbols, weighs as much as one symbol.
+func ImportWeights(deps []model.Dependency) EdgeWeights {
+	w := make(EdgeWeights)
+	for _, d := range deps {
+		w.add(d.Source, d.Target, float64(max(len(d.Symbols), 1)))
+	}
+	return w
+}
+
+// CallWeights weights each file pair by the number of call and import sites
+// in the source file that refer to symbols defined in the target, so heavily
+// used files accumulate more rank than files referenced once. sites is
+// typically the output of BuildCallSites. Self-references are ignored.
+func CallWeights(fileInfos []model.FileInfo, sites []model.CallSite) EdgeWeights {
+	defines := make(map[string][]string) // symbol name → defining files
+	for i := range fileInfos {
+		for j := range fileInfos[i].Tags {
+			tag := &fileInfos[i].Tags[j]
+			if tag.Kind == model.Definition {
+				defines[tag.Name] = append(defines[tag.Name], fileInfos[i].Path)
+			}
+		}
+	}
+
+	w := make(EdgeWeights)
+	for i := range sites {
+		cs := &sites[i]
+		for _, defFile := range defines[cs.Callee] {
+			if defFile != cs.File {
+				w.add(cs.File, defFile, 1)
+			}
+		}
+	}
+	return w
+}
+
+// Default PageRank parameters, used for zero fields of PageRankOptions.
+const (
+	DefaultDamping    = 0.85
+	DefaultIterations = 100
+	pageRankTolerance = 1e-6
+)
+
+// PageRankOptions tunes PageRank. Zero fields take the defaults.
+type PageRankOptions struct {
+	// Alpha is the damping factor: the probability of following an edge
+	// rather than jumping to a random file. Must be in (0, 1).
+	Alpha float64
+	// Iterations caps the power iterations; ranking stops earlier once the
+	// ranks converge.
+	Iterations int
+}
+
 // Rank applies PageRank to file_infos and sorts them by rank descending.
-func Rank(fileInfos []model.FileInfo, deps []model.Dependency) {
+// Each dependency edge is weighted by its number of referenced symbols.
+func Rank(fileInfos []model.FileInfo, deps []model.Dependency, opts PageRankOptions) {
+	RankWeighted(fileInfos, ImportWeights(deps), opts)
+}
+
+// RankWeighted applies PageRank over the given edge weights and sorts
+// fileInfos by rank descending. A source file distributes its rank to its
+// targets in proportion to edge weight.
+func RankWeighted(fileInfos []model.FileInfo, weights EdgeWeights, opts PageRankOptions) {
 	if len(fileInfos) == 0 {
 		return
 	}
 
-	if len(deps) == 0 {
+	if len(weights) == 0 {
 		uniform := 1.0 / float64(len(fileInfos))
 		for i := range fileInfos {
 			fileInfos[i].Rank = uniform
@@ -194,26 +815,24 @@ func Rank(fileInfos []model.FileInfo, deps []model.Dependency) {
 		return
 	}
 
-	// Build adjacency for PageRank
-	// Edge from source to target means source references target.
-	// Count edges per (source, target) pair.
-	outEdges := make(map[string][]string) // node → list of targets (with repeats for multi-edges)
-	outDegree := make(map[string]int)     // total out-edges per node
-	nodes := make(map[string]struct{})
-
-	for i := range fileInfos {
-		nodes[fileInfos[i].Path] = struct{}{}
+	alpha, iterations := opts.Alpha, opts.Iterations
+	if alpha == 0 {
+		alpha = DefaultDamping
+	}
+	if iterations == 0 {
+		iterations = DefaultIterations
 	}
 
-	for _, d := range deps {
-		// Each symbol is an edge
-		for range d.Symbols {
-			outEdges[d.Source] = append(outEdges[d.Source], d.Target)
-			outDegree[d.Source]++
+	nodes := make([]string, 0, len(fileInfos))
+	seen := make(map[string]struct{}, len(fileInfos))
+	for i := range fileInfos {
+		if _, ok := seen[fileInfos[i].Path]; !ok {
+			seen[fileInfos[i].Path] = struct{}{}
+			nodes = append(nodes, fileInfos[i].Path)
 		}
 	}
 
-	ranks := pageRank(nodes, outEdges, outDegree, 0.85, 100, 1e-6)
+	ranks := pageRank(nodes, weights, alpha, iterations, pageRankTolerance)
 
 	for i := range fileInfos {
 		fileInfos[i].Rank = ranks[fileInfos[i].Path]
@@ -224,66 +843,155 @@ func Rank(fileInfos []model.FileInfo, deps []model.Dependency) {
 	})
 }
 
-func pageRank(
-	nodes map[string]struct{},
-	outEdges map[string][]string,
-	outDegree map[string]int,
-	alpha float64,
-	maxIter int,
-	tol float64,
-) map[string]float64 {
+// recencyHalfLife is how much older than the newest file a file must be for
+// its recency score to halve.
+const recencyHalfLife = 30 * 24 * 60 * 60 // seconds
+
+// BoostRecency blends each file's rank with a recency score and re-sorts
+// fileInfos by the result: rank' = (1-weight)*rank + weight*recency. A file's
+// recency decays by half for every 30 days between its last change (Unix
+// seconds, from times) and the newest change among fileInfos, and the scores
+// are normalized to sum to 1 like PageRank. Files missing from times have no
+// commits yet, so they count as the newest.
+func BoostRecency(fileInfos []model.FileInfo, times map[string]int64, weight float64) {
+	if len(fileInfos) == 0 || weight <= 0 {
+		return
+	}
+	var newest int64
+	for p := range times {
+		newest = max(newest, times[p])
+	}
+	scores := make([]float64, len(fileInfos))
+	for i := range fileInfos {
+		scores[i] = 1
+		if t, ok := times[fileInfos[i].Path]; ok {
+			scores[i] = math.Pow(0.5, float64(newest-t)/recencyHalfLife)
+		}
+	}
+	blendRank(fileInfos, scores, weight)
+}
+
+// BoostHotspots blends each file's rank with its share of recent authors and
+// re-sorts fileInfos by the result: rank' = (1-weight)*rank + weight*share,
+// where share is the file's distinct author count (from authors) divided by
+// the total over fileInfos. Files touched by many people rise, as churn
+// hotspots. If no file has recent authors, ranks are left unchanged.
+func BoostHotspots(fileInfos []model.FileInfo, authors map[string]int, weight float64) {
+	if len(fileInfos) == 0 || weight <= 0 {
+		return
+	}
+	scores := make([]float64, len(fileInfos))
+	var total float64
+	for i := range fileInfos {
+		scores[i] = float64(authors[fileInfos[i].Path])
+		total += scores[i]
+	}
+	if total == 0 {
+		return
+	}
+	blendRank(fileInfos, scores, weight)
+}
+
+// blendRank normalizes scores (parallel to fileInfos) to sum to 1, mixes
+// them into the ranks with the given weight, and re-sorts by rank.
+func blendRank(fileInfos []model.FileInfo, scores []float64, weight float64) {
+	var total float64
+	for _, s := range scores {
+		total += s
+	}
+	for i := range fileInfos {
+		fileInfos[i].Rank = (1-weight)*fileInfos[i].Rank + weight*scores[i]/total
+	}
+	sort.SliceStable(fileInfos, func(i, j int) bool {
+		return fileInfos[i].Rank > fileInfos[j].Rank
+	})
+}
+
+// pageRank runs PageRank over nodes, which must be distinct. Edges to
+// files outside nodes still count toward their source's out-degree, but the
+// rank they carry is dropped.
+//
+// Nodes are numbered densely and the power iteration runs over slices, which
+// is much faster than string-keyed maps on large graphs. Sources and targets
+// are visited in a fixed order, so the floating-point sums, and with them
+// the ranks, are the same on every run.
+func pageRank(nodes []string, outEdges EdgeWeights, alpha float64, maxIter int, tol float64) map[string]float64 {
 	n := len(nodes)
 	if n == 0 {
 		return nil
 	}
 
-	rank := make(map[string]float64, n)
+	id := make(map[string]int, n)
+	for i, node := range nodes {
+		id[node] = i
+	}
+	type edge struct {
+		to     int
+		weight float64
+	}
+	edges := make([][]edge, n)
+	outDegree := make([]float64, n) // total outgoing weight per node
+	for i, src := range nodes {
+		targets := outEdges[src]
+		for _, tgt := range slices.Sorted(maps.Keys(targets)) {
+			outDegree[i] += targets[tgt]
+			if j, ok := id[tgt]; ok {
+				edges[i] = append(edges[i], edge{to: j, weight: targets[tgt]})
+			}
+		}
+	}
+
+	rank := make([]float64, n)
+	newRank := make([]float64, n)
 	initial := 1.0 / float64(n)
-	for node := range nodes {
-		rank[node] = initial
+	for i := range rank {
+		rank[i] = initial
 	}
 
 	teleport := (1.0 - alpha) / float64(n)
 
 	for iter := 0; iter < maxIter; iter++ {
-		newRank := make(map[string]float64, n)
-
 		// Dangling node contribution (nodes with no outgoing edges)
 		var danglingSum float64
-		for node := range nodes {
-			if outDegree[node] == 0 {
-				danglingSum += rank[node]
+		for i := range rank {
+			if outDegree[i] == 0 {
+				danglingSum += rank[i]
 			}
 		}
-		danglingContrib := alpha * danglingSum / float64(n)
-
-		for node := range nodes {
-			newRank[node] = teleport + danglingContrib
+		base := teleport + alpha*danglingSum/float64(n)
+		for i := range newRank {
+			newRank[i] = base
 		}
 
-		// Distribute rank through edges
-		for src, targets := range outEdges {
-			deg := float64(outDegree[src])
-			contrib := alpha * rank[src] / deg
-			for _, tgt := range targets {
-				newRank[tgt] += contrib
+		// Distribute rank through edges in proportion to their weight
+		for i, out := range edges {
+			if outDegree[i] == 0 {
+				continue
+			}
+			share := alpha * rank[i] / outDegree[i]
+			for _, e := range out {
+				newRank[e.to] += share * e.weight
 			}
 		}
 
 		// Check convergence
 		var diff float64
-		for node := range nodes {
-			diff += math.Abs(newRank[node] - rank[node])
+		for i := range rank {
+			diff += math.Abs(newRank[i] - rank[i])
 		}
 
-		rank = newRank
+		rank, newRank = newRank, rank
 
 		if diff < tol {
 			break
 		}
 	}
 
-	return rank
+	ranks := make(map[string]float64, n)
+	for i, node := range nodes {
+		ranks[node] = rank[i]
+	}
+	return ranks
 }
 
 func sortedKeys(m map[string]struct{}) []string {
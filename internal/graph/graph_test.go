@@ -1,7 +1,12 @@
 package graph
 
 import (
+	"fmt"
+	"maps"
 	"math"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/phobologic/repoguide/internal/model"
@@ -39,6 +44,51 @@ func TestBuildGraphCrossFileRef(t *testing.T) {
 	}
 }
 
+func TestBuildGraphFileImports(t *testing.T) {
+	t.Parallel()
+
+	fileInfos := []model.FileInfo{
+		{
+			Path: "app/main.rb", Language: "ruby",
+			Imports: []model.Import{
+				{Path: "helpers", Relative: true}, // app/helpers.rb
+				{Path: "shop/cart"},               // the only lib/shop/cart.rb
+				{Path: "json"},                    // stdlib: not in the repo
+				{Path: "util"},                    // two lib/util.rb, neither closer
+				{Path: "../config/boot", Relative: true},
+			},
+			Tags: []model.Tag{{Name: "Cart", Kind: model.Reference, SymbolKind: model.Function}},
+		},
+		{Path: "app/helpers.rb", Language: "ruby"},
+		{
+			Path: "lib/shop/cart.rb", Language: "ruby",
+			Tags: []model.Tag{{Name: "Cart", Kind: model.Definition, SymbolKind: model.Class}},
+		},
+		{Path: "config/boot.rb", Language: "ruby"},
+		{Path: "gems/a/lib/util.rb", Language: "ruby"},
+		{Path: "gems/b/lib/util.rb", Language: "ruby"},
+		{
+			// Within gems/a, the gem's own lib/util.rb is the closer match.
+			Path: "gems/a/lib/a.rb", Language: "ruby",
+			Imports: []model.Import{{Path: "util"}},
+		},
+	}
+
+	got := make(map[string]string)
+	for _, d := range BuildGraph(fileInfos) {
+		got[d.Source+" -> "+d.Target] = strings.Join(d.Symbols, " ")
+	}
+	want := map[string]string{
+		"app/main.rb -> app/helpers.rb":         "",
+		"app/main.rb -> lib/shop/cart.rb":       "Cart",
+		"app/main.rb -> config/boot.rb":         "",
+		"gems/a/lib/a.rb -> gems/a/lib/util.rb": "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("deps = %v, want %v", got, want)
+	}
+}
+
 func TestBuildGraphNoSelfEdge(t *testing.T) {
 	t.Parallel()
 
@@ -59,6 +109,64 @@ func TestBuildGraphNoSelfEdge(t *testing.T) {
 	}
 }
 
+func TestBuildGraphInterfaceMethod(t *testing.T) {
+	t.Parallel()
+
+	fileInfos := []model.FileInfo{
+		{
+			Path:     "io.go",
+			Language: "go",
+			Tags: []model.Tag{
+				{Name: "Writer", Kind: model.Definition, SymbolKind: model.Class},
+				{Name: "Writer.Write", Kind: model.Definition, SymbolKind: model.Method, Interface: true},
+			},
+		},
+		{
+			Path:     "file.go",
+			Language: "go",
+			Tags: []model.Tag{
+				// A bare name an interface declares does not also reach
+				// concrete methods of that name.
+				{Name: "File.Write", Kind: model.Definition, SymbolKind: model.Method},
+			},
+		},
+		{
+			Path:     "log.go",
+			Language: "go",
+			Tags: []model.Tag{
+				{Name: "Write", Kind: model.Reference, SymbolKind: model.Function, Enclosing: "Log"},
+				{Name: "Flush", Kind: model.Reference, SymbolKind: model.Function, Enclosing: "Log"},
+			},
+		},
+	}
+
+	deps := BuildGraph(fileInfos)
+	if len(deps) != 1 {
+		t.Fatalf("expected 1 dep, got %+v", deps)
+	}
+	if deps[0].Source != "log.go" || deps[0].Target != "io.go" {
+		t.Errorf("dep: %+v", deps[0])
+	}
+	if len(deps[0].Symbols) != 1 || deps[0].Symbols[0] != "Writer.Write" {
+		t.Errorf("symbols: %v", deps[0].Symbols)
+	}
+
+	edges := BuildCallGraph(fileInfos)
+	if len(edges) != 1 || edges[0] != (model.CallEdge{Caller: "Log", Callee: "Writer.Write", Count: 1}) {
+		t.Errorf("call edges: %+v", edges)
+	}
+
+	sites := BuildCallSites(fileInfos)
+	if len(sites) != 1 || sites[0].Callee != "Writer.Write" {
+		t.Errorf("call sites: %+v", sites)
+	}
+
+	externals := FindExternals(fileInfos)
+	if len(externals) != 1 || externals[0].Name != "Flush" {
+		t.Errorf("externals: %+v", externals)
+	}
+}
+
 func TestBuildGraphNoDefs(t *testing.T) {
 	t.Parallel()
 
@@ -78,6 +186,55 @@ func TestBuildGraphNoDefs(t *testing.T) {
 	}
 }
 
+func TestFindExternals(t *testing.T) {
+	t.Parallel()
+
+	fileInfos := []model.FileInfo{
+		{
+			Path: "a.py",
+			Tags: []model.Tag{
+				{Name: "foo", Kind: model.Definition, SymbolKind: model.Function},
+				{Name: "len", Kind: model.Reference, SymbolKind: model.Function},
+				{Name: "print", Kind: model.Reference, SymbolKind: model.Function},
+				{Name: "foo", Kind: model.Reference, SymbolKind: model.Function},
+			},
+		},
+		{
+			Path: "b.py",
+			Tags: []model.Tag{
+				{Name: "print", Kind: model.Reference, SymbolKind: model.Function},
+				{Name: "json", Kind: model.Reference, SymbolKind: model.Module},
+				{Name: "foo", Kind: model.Reference, SymbolKind: model.Function},
+			},
+		},
+		{
+			Path: "c.go",
+			Tags: []model.Tag{
+				{Name: `"errors"`, Kind: model.Reference, SymbolKind: model.Module},
+				{Name: `"net/http"`, Kind: model.Reference, SymbolKind: model.Module},
+			},
+		},
+		{
+			Path: "d.cpp",
+			Tags: []model.Tag{
+				{Name: "<stdio.h>", Kind: model.Reference, SymbolKind: model.Module},
+				{Name: "util.h", Kind: model.Reference, SymbolKind: model.Module},
+			},
+		},
+	}
+
+	got := FindExternals(fileInfos)
+	want := []model.External{{Name: "print", Count: 2}, {Name: "json", Count: 1}, {Name: "len", Count: 1}}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("externals[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
 func TestRankUniform(t *testing.T) {
 	t.Parallel()
 
@@ -87,7 +244,7 @@ func TestRankUniform(t *testing.T) {
 		{Path: "c.py"},
 	}
 
-	Rank(fileInfos, nil)
+	Rank(fileInfos, nil, PageRankOptions{})
 
 	expected := 1.0 / 3.0
 	for _, fi := range fileInfos {
@@ -111,7 +268,7 @@ func TestRankWithEdges(t *testing.T) {
 		{Source: "c.py", Target: "b.py", Symbols: []string{"y"}},
 	}
 
-	Rank(fileInfos, deps)
+	Rank(fileInfos, deps, PageRankOptions{})
 
 	// b.py should have highest rank (referenced by both a and c)
 	if fileInfos[0].Path != "b.py" {
@@ -134,9 +291,92 @@ func TestRankWithEdges(t *testing.T) {
 	}
 }
 
+func TestRankWeightedByCalls(t *testing.T) {
+	t.Parallel()
+
+	// main.py imports one symbol from each of heavy.py and light.py, but
+	// calls heavy's symbol three times and light's once.
+	fileInfos := []model.FileInfo{
+		{Path: "main.py", Tags: []model.Tag{
+			{Name: "h", Kind: model.Reference, Enclosing: "run", Line: 2},
+			{Name: "h", Kind: model.Reference, Enclosing: "run", Line: 3},
+			{Name: "h", Kind: model.Reference, Enclosing: "run", Line: 4},
+			{Name: "l", Kind: model.Reference, Enclosing: "run", Line: 5},
+		}},
+		{Path: "heavy.py", Tags: []model.Tag{{Name: "h", Kind: model.Definition}}},
+		{Path: "light.py", Tags: []model.Tag{{Name: "l", Kind: model.Definition}}},
+	}
+
+	weights := CallWeights(fileInfos, BuildCallSites(fileInfos))
+	if got := weights["main.py"]["heavy.py"]; got != 3 {
+		t.Errorf("main→heavy weight = %v, want 3", got)
+	}
+	if got := weights["main.py"]["light.py"]; got != 1 {
+		t.Errorf("main→light weight = %v, want 1", got)
+	}
+
+	RankWeighted(fileInfos, weights, PageRankOptions{})
+	if fileInfos[0].Path != "heavy.py" {
+		t.Errorf("expected heavy.py first, got %s", fileInfos[0].Path)
+	}
+
+	// Import weighting sees one symbol per edge, so the two targets tie.
+	deps := BuildGraph(fileInfos)
+	Rank(fileInfos, deps, PageRankOptions{})
+	ranks := map[string]float64{}
+	for _, fi := range fileInfos {
+		ranks[fi.Path] = fi.Rank
+	}
+	if math.Abs(ranks["heavy.py"]-ranks["light.py"]) > 1e-9 {
+		t.Errorf("import ranks should tie: heavy=%f light=%f", ranks["heavy.py"], ranks["light.py"])
+	}
+}
+
+func TestRankOptions(t *testing.T) {
+	t.Parallel()
+
+	// A chain a → b → c: c collects rank from both, b from a alone.
+	deps := []model.Dependency{
+		{Source: "a.py", Target: "b.py", Symbols: []string{"x"}},
+		{Source: "b.py", Target: "c.py", Symbols: []string{"y"}},
+	}
+	rank := func(opts PageRankOptions) map[string]float64 {
+		fileInfos := []model.FileInfo{{Path: "a.py"}, {Path: "b.py"}, {Path: "c.py"}}
+		Rank(fileInfos, deps, opts)
+		ranks := make(map[string]float64)
+		for _, fi := range fileInfos {
+			ranks[fi.Path] = fi.Rank
+		}
+		return ranks
+	}
+
+	def := rank(PageRankOptions{})
+	if got := rank(PageRankOptions{Alpha: DefaultDamping, Iterations: DefaultIterations}); !reflect.DeepEqual(got, def) {
+		t.Errorf("explicit defaults = %v, want %v", got, def)
+	}
+	// Ranks are summed in a fixed order, so repeated runs agree exactly.
+	for range 5 {
+		if got := rank(PageRankOptions{}); !reflect.DeepEqual(got, def) {
+			t.Fatalf("ranks differ between runs: %v vs %v", got, def)
+		}
+	}
+
+	// Lower damping pulls ranks toward uniform.
+	low := rank(PageRankOptions{Alpha: 0.1})
+	if spread, defSpread := low["c.py"]-low["a.py"], def["c.py"]-def["a.py"]; spread >= defSpread {
+		t.Errorf("alpha 0.1 spread %f should be below default spread %f", spread, defSpread)
+	}
+
+	// One iteration from uniform has not yet passed a's rank on to c.
+	one := rank(PageRankOptions{Iterations: 1})
+	if math.Abs(one["c.py"]-def["c.py"]) < 1e-3 {
+		t.Errorf("one iteration should not have converged: c=%f, converged %f", one["c.py"], def["c.py"])
+	}
+}
+
 func TestRankEmpty(t *testing.T) {
 	t.Parallel()
-	Rank(nil, nil) // should not panic
+	Rank(nil, nil, PageRankOptions{}) // should not panic
 }
 
 func TestBuildCallGraph(t *testing.T) {
@@ -179,15 +419,20 @@ func TestBuildCallGraphDeduplication(t *testing.T) {
 				{Name: "bar", Kind: model.Definition, SymbolKind: model.Function},
 				{Name: "foo", Kind: model.Definition, SymbolKind: model.Function},
 				// foo calls bar multiple times — should produce only one edge
-				{Name: "bar", Kind: model.Reference, SymbolKind: model.Function, Enclosing: "foo"},
-				{Name: "bar", Kind: model.Reference, SymbolKind: model.Function, Enclosing: "foo"},
+				{Name: "bar", Kind: model.Reference, SymbolKind: model.Function, Enclosing: "foo", Line: 3},
+				{Name: "bar", Kind: model.Reference, SymbolKind: model.Function, Enclosing: "foo", Line: 4},
+				{Name: "bar", Kind: model.Reference, SymbolKind: model.Function, Enclosing: "foo", Line: 7},
 			},
 		},
 	}
 
 	edges := BuildCallGraph(fileInfos)
 	if len(edges) != 1 {
-		t.Errorf("expected 1 deduplicated edge, got %d: %+v", len(edges), edges)
+		t.Fatalf("expected 1 deduplicated edge, got %d: %+v", len(edges), edges)
+	}
+	// The edge counts the calls it stands for, one per call site.
+	if sites := BuildCallSites(fileInfos); edges[0].Count != 3 || len(sites) != edges[0].Count {
+		t.Errorf("edge count = %d, want 3 (one per call site: %+v)", edges[0].Count, sites)
 	}
 }
 
@@ -257,10 +502,10 @@ func TestBuildCallSites(t *testing.T) {
 		}
 	}
 	// Sorted by caller then line: <import> sorts before "foo".
-	if sites[0].Caller != "<import>" || sites[0].Line != 5 {
-		t.Errorf("expected sites[0] = <import> at line 5, got %+v", sites[0])
+	if sites[0].Caller != "<import>" || sites[0].Line != 5 || sites[0].Kind != model.SiteImport {
+		t.Errorf("expected sites[0] = <import> import at line 5, got %+v", sites[0])
 	}
-	if sites[1].Caller != "foo" || sites[1].Line != 10 {
+	if sites[1].Caller != "foo" || sites[1].Line != 10 || sites[1].Kind != model.SiteCall {
 		t.Errorf("expected sites[1] = foo at line 10, got %+v", sites[1])
 	}
 	if sites[2].Caller != "foo" || sites[2].Line != 20 {
@@ -275,3 +520,372 @@ func TestBuildCallSitesEmpty(t *testing.T) {
 		t.Errorf("expected nil, got %v", sites)
 	}
 }
+
+func TestNeighbors(t *testing.T) {
+	t.Parallel()
+
+	deps := []model.Dependency{
+		{Source: "a.go", Target: "b.go"},
+		{Source: "c.go", Target: "a.go"},
+		{Source: "b.go", Target: "d.go"},
+	}
+	got := Neighbors(deps, map[string]struct{}{"a.go": {}})
+	want := []string{"a.go", "b.go", "c.go"}
+	if keys := sortedKeys(got); strings.Join(keys, " ") != strings.Join(want, " ") {
+		t.Errorf("Neighbors = %v, want %v", keys, want)
+	}
+}
+
+func TestFileRefs(t *testing.T) {
+	t.Parallel()
+
+	fileInfos := []model.FileInfo{
+		{
+			Path: "b.py",
+			Tags: []model.Tag{
+				{Name: "helper", Kind: model.Reference, SymbolKind: model.Function, Line: 9},
+				{Name: "helper", Kind: model.Reference, SymbolKind: model.Function, Line: 4},
+				{Name: "os", Kind: model.Reference, SymbolKind: model.Module, Line: 1},
+				{Name: "main", Kind: model.Definition, SymbolKind: model.Function, Line: 3},
+			},
+		},
+		{
+			Path: "a.py",
+			Tags: []model.Tag{{Name: "print", Kind: model.Reference, SymbolKind: model.Function, Line: 2}},
+		},
+		{
+			Path: "skipped.py",
+			Tags: []model.Tag{{Name: "print", Kind: model.Reference, SymbolKind: model.Function, Line: 1}},
+		},
+	}
+
+	got := FileRefs(fileInfos, map[string]struct{}{"a.py": {}, "b.py": {}})
+	want := []model.Ref{
+		{File: "a.py", Name: "print", Line: 2},
+		{File: "b.py", Name: "helper", Line: 4},
+		{File: "b.py", Name: "os", Line: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("FileRefs = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ref %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFindCycles(t *testing.T) {
+	t.Parallel()
+
+	deps := []model.Dependency{
+		{Source: "a.py", Target: "b.py", Symbols: []string{"B"}},
+		{Source: "b.py", Target: "c.py", Symbols: []string{"C"}},
+		{Source: "c.py", Target: "a.py", Symbols: []string{"A"}},
+		{Source: "c.py", Target: "d.py", Symbols: []string{"D"}},
+		{Source: "x.py", Target: "y.py", Symbols: []string{"Y"}},
+		{Source: "y.py", Target: "x.py", Symbols: []string{"X"}},
+	}
+
+	cycles := FindCycles(deps)
+	if len(cycles) != 2 {
+		t.Fatalf("expected 2 cycles, got %v", cycles)
+	}
+	want := [][]string{{"a.py", "b.py", "c.py"}, {"x.py", "y.py"}}
+	for i := range want {
+		if strings.Join(cycles[i], " ") != strings.Join(want[i], " ") {
+			t.Errorf("cycle %d = %v, want %v", i, cycles[i], want[i])
+		}
+	}
+}
+
+func TestFindCyclesAcyclic(t *testing.T) {
+	t.Parallel()
+
+	deps := []model.Dependency{
+		{Source: "a.py", Target: "b.py", Symbols: []string{"B"}},
+		{Source: "b.py", Target: "c.py", Symbols: []string{"C"}},
+	}
+	if cycles := FindCycles(deps); len(cycles) != 0 {
+		t.Errorf("expected no cycles, got %v", cycles)
+	}
+}
+
+func TestDedupeCallSites(t *testing.T) {
+	t.Parallel()
+
+	sites := []model.CallSite{
+		{Caller: "run", Callee: "log", File: "a.py", Line: 35},
+		{Caller: "run", Callee: "log", File: "a.py", Line: 10},
+		{Caller: "run", Callee: "save", File: "a.py", Line: 12},
+		{Caller: "run", Callee: "log", File: "b.py", Line: 3},
+		{Caller: "run", Callee: "log", File: "a.py", Line: 20},
+		{Caller: "run", Callee: "log", File: "a.py", Line: 20},
+	}
+	got := DedupeCallSites(sites)
+	want := []model.CallSite{
+		{Caller: "run", Callee: "log", File: "a.py", Line: 10, Lines: []int{10, 20, 35}},
+		{Caller: "run", Callee: "save", File: "a.py", Line: 12, Lines: []int{12}},
+		{Caller: "run", Callee: "log", File: "b.py", Line: 3, Lines: []int{3}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DedupeCallSites = %+v, want %+v", got, want)
+	}
+}
+
+func TestBoostRecency(t *testing.T) {
+	t.Parallel()
+
+	const day = 24 * 60 * 60
+	fileInfos := []model.FileInfo{
+		{Path: "core.go", Rank: 0.6},
+		{Path: "hot.go", Rank: 0.3},
+		{Path: "new.go", Rank: 0.1},
+	}
+	times := map[string]int64{
+		"core.go": 1_000_000 - 60*day, // two half-lives old
+		"hot.go":  1_000_000,
+	}
+	BoostRecency(fileInfos, times, 0.5)
+
+	// Recency scores 0.25, 1, 1 (new.go has no commits) normalize to
+	// 1/9, 4/9, 4/9.
+	want := map[string]float64{
+		"hot.go":  0.5*0.3 + 0.5*4.0/9,
+		"core.go": 0.5*0.6 + 0.5*1.0/9,
+		"new.go":  0.5*0.1 + 0.5*4.0/9,
+	}
+	order := []string{"hot.go", "core.go", "new.go"}
+	for i, fi := range fileInfos {
+		if fi.Path != order[i] {
+			t.Errorf("position %d = %s, want %s", i, fi.Path, order[i])
+		}
+		if math.Abs(fi.Rank-want[fi.Path]) > 1e-9 {
+			t.Errorf("%s rank = %f, want %f", fi.Path, fi.Rank, want[fi.Path])
+		}
+	}
+
+	// A zero weight leaves ranks untouched.
+	before := fileInfos[0].Rank
+	BoostRecency(fileInfos, times, 0)
+	if fileInfos[0].Rank != before {
+		t.Errorf("weight 0 changed rank: %f → %f", before, fileInfos[0].Rank)
+	}
+}
+
+func TestBoostHotspots(t *testing.T) {
+	t.Parallel()
+
+	fileInfos := []model.FileInfo{
+		{Path: "core.go", Rank: 0.6},
+		{Path: "hot.go", Rank: 0.3},
+		{Path: "quiet.go", Rank: 0.1},
+	}
+	BoostHotspots(fileInfos, map[string]int{"core.go": 1, "hot.go": 3}, 0.5)
+
+	// Author counts 1, 3, 0 normalize to 1/4, 3/4, 0.
+	want := map[string]float64{
+		"hot.go":   0.5*0.3 + 0.5*0.75,
+		"core.go":  0.5*0.6 + 0.5*0.25,
+		"quiet.go": 0.5 * 0.1,
+	}
+	order := []string{"hot.go", "core.go", "quiet.go"}
+	for i, fi := range fileInfos {
+		if fi.Path != order[i] {
+			t.Errorf("position %d = %s, want %s", i, fi.Path, order[i])
+		}
+		if math.Abs(fi.Rank-want[fi.Path]) > 1e-9 {
+			t.Errorf("%s rank = %f, want %f", fi.Path, fi.Rank, want[fi.Path])
+		}
+	}
+
+	// No recent authors at all leaves ranks untouched.
+	before := fileInfos[0].Rank
+	BoostHotspots(fileInfos, nil, 0.5)
+	if fileInfos[0].Rank != before {
+		t.Errorf("empty authors changed rank: %f → %f", before, fileInfos[0].Rank)
+	}
+}
+
+func TestBuildCallGraphMethodResolution(t *testing.T) {
+	t.Parallel()
+
+	fileInfos := []model.FileInfo{
+		{Path: "server.go", Tags: []model.Tag{
+			{Name: "Server", Kind: model.Definition, SymbolKind: model.Class},
+			{Name: "Base", Kind: model.Definition, SymbolKind: model.Class},
+			{Name: "Server.Handle", Kind: model.Definition, SymbolKind: model.Method},
+			{Name: "Server.flush", Kind: model.Definition, SymbolKind: model.Method},
+			{Name: "Client.flush", Kind: model.Definition, SymbolKind: model.Method},
+			{Name: "Base.Close", Kind: model.Definition, SymbolKind: model.Method},
+		}},
+		{Path: "names.go", Tags: []model.Tag{
+			{Name: "A.String", Kind: model.Definition, SymbolKind: model.Method},
+			{Name: "B.String", Kind: model.Definition, SymbolKind: model.Method},
+			{Name: "C.String", Kind: model.Definition, SymbolKind: model.Method},
+			{Name: "D.String", Kind: model.Definition, SymbolKind: model.Method},
+		}},
+		{Path: "main.go", Tags: []model.Tag{
+			// srv.Handle(): receiver type unknown, one method of that name.
+			{Name: "Handle", Kind: model.Reference, SymbolKind: model.Function, Enclosing: "main"},
+			// c.flush(): two candidates, both linked.
+			{Name: "flush", Kind: model.Reference, SymbolKind: model.Function, Enclosing: "main"},
+			// x.String(): too many candidates to be informative.
+			{Name: "String", Kind: model.Reference, SymbolKind: model.Function, Enclosing: "main"},
+			// s.Close() on a receiver: promoted from embedded Base.
+			{Name: "Server.Close", Kind: model.Reference, SymbolKind: model.Function, Enclosing: "Server.Stop"},
+			// s.flush() on a receiver: resolved to its type only.
+			{Name: "Server.flush", Kind: model.Reference, SymbolKind: model.Function, Enclosing: "Server.Stop"},
+			// A qualified name on an unknown type stays unresolved.
+			{Name: "os.Exit", Kind: model.Reference, SymbolKind: model.Function, Enclosing: "main"},
+		}},
+	}
+
+	got := BuildCallGraph(fileInfos)
+	want := []model.CallEdge{
+		{Caller: "Server.Stop", Callee: "Base.Close", Count: 1},
+		{Caller: "Server.Stop", Callee: "Server.flush", Count: 1},
+		{Caller: "main", Callee: "Client.flush", Count: 1},
+		{Caller: "main", Callee: "Server.Handle", Count: 1},
+		{Caller: "main", Callee: "Server.flush", Count: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildCallGraph =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestDegreeMetrics(t *testing.T) {
+	t.Parallel()
+
+	files := []model.FileInfo{
+		{Path: "util.go", Rank: 0.5},
+		{Path: "main.go", Rank: 0.2},
+		{Path: "lonely.go", Rank: 0.1},
+	}
+	deps := []model.Dependency{
+		{Source: "main.go", Target: "util.go", Symbols: []string{"A", "B"}},
+		{Source: "srv.go", Target: "util.go", Symbols: []string{"A"}},
+		{Source: "main.go", Target: "srv.go", Symbols: []string{"Serve"}},
+		{Source: "main.go", Target: "util.go", Symbols: []string{"C"}}, // same pair again
+	}
+	got := DegreeMetrics(files, deps)
+	want := []model.Metric{
+		{File: "util.go", InDegree: 2, OutDegree: 0, Rank: 0.5},
+		{File: "main.go", InDegree: 0, OutDegree: 2, Rank: 0.2},
+		{File: "lonely.go", InDegree: 0, OutDegree: 0, Rank: 0.1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DegreeMetrics = %+v, want %+v", got, want)
+	}
+}
+
+func TestFindUnused(t *testing.T) {
+	t.Parallel()
+
+	def := func(name string, kind model.SymbolKind, line int) model.Tag {
+		return model.Tag{Name: name, Kind: model.Definition, SymbolKind: kind, Line: line}
+	}
+	ref := func(name string) model.Tag {
+		return model.Tag{Name: name, Kind: model.Reference, SymbolKind: model.Function}
+	}
+	fileInfos := []model.FileInfo{
+		{Path: "lib.go", Tags: []model.Tag{
+			def("Used", model.Function, 1),
+			def("Dead", model.Function, 2),
+			def("SelfOnly", model.Function, 3),
+			ref("SelfOnly"),
+			def("Config", model.Class, 4),
+			def("Server.Handle", model.Method, 5),
+			def("Server.Close", model.Method, 6),
+			{Name: "helper", Kind: model.Definition, SymbolKind: model.Function, Line: 7, Unexported: true},
+		}},
+		{Path: "main.go", Tags: []model.Tag{
+			def("main", model.Function, 1),
+			def("Serve", model.Function, 2),
+			{Name: "Serve", Kind: model.Entry, SymbolKind: model.Function, Line: 2},
+			ref("Used"),
+			ref("h.Handle"),
+		}},
+		{Path: "lib_test.go", Tags: []model.Tag{def("TestDead", model.Function, 1), ref("Dead")}},
+	}
+	isTest := func(path string) bool { return strings.HasSuffix(path, "_test.go") }
+
+	var got []string
+	for _, tag := range FindUnused(fileInfos, isTest) {
+		got = append(got, fmt.Sprintf("%s:%s:%d", tag.File, tag.Name, tag.Line))
+	}
+	// The test file's reference keeps Dead, and its own definitions are
+	// never reported.
+	want := []string{"lib.go:SelfOnly:3", "lib.go:Server.Close:6"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindUnused = %v, want %v", got, want)
+	}
+
+	// Without the test file (the default), Dead is unused.
+	got = nil
+	for _, tag := range FindUnused(fileInfos[:2], isTest) {
+		got = append(got, tag.Name)
+	}
+	if want := []string{"Dead", "SelfOnly", "Server.Close"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindUnused without tests = %v, want %v", got, want)
+	}
+}
+
+func TestFindEntrypoints(t *testing.T) {
+	t.Parallel()
+
+	fileInfos := []model.FileInfo{
+		{Path: "b.py", Tags: []model.Tag{
+			{Name: "__main__", Kind: model.Entry, Line: 9},
+			{Name: "main", Kind: model.Definition, SymbolKind: model.Function, Line: 2},
+			{Name: "main", Kind: model.Entry, Line: 2},
+		}},
+		{Path: "a.go", Tags: []model.Tag{{Name: "main", Kind: model.Entry, Line: 5}}},
+		{Path: "hidden.go", Tags: []model.Tag{{Name: "main", Kind: model.Entry, Line: 1}}},
+	}
+	got := FindEntrypoints(fileInfos, map[string]struct{}{"a.go": {}, "b.py": {}})
+	want := []model.Entrypoint{
+		{File: "a.go", Name: "main", Line: 5},
+		{File: "b.py", Name: "main", Line: 2},
+		{File: "b.py", Name: "__main__", Line: 9},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindEntrypoints = %+v, want %+v", got, want)
+	}
+}
+
+func TestResolveEntries(t *testing.T) {
+	t.Parallel()
+
+	fileInfos := []model.FileInfo{
+		{Path: "server.go", Tags: []model.Tag{
+			{Name: "Server.ServeHTTP", Kind: model.Definition, SymbolKind: model.Method, Line: 10},
+			{Name: "main", Kind: model.Reference, Line: 3},
+		}},
+		{Path: "main.go", Tags: []model.Tag{{Name: "main", Kind: model.Definition, SymbolKind: model.Function, Line: 5}}},
+	}
+	got := ResolveEntries(fileInfos, []string{"main", "ServeHTTP", "missing"})
+	want := []string{"Server.ServeHTTP", "main"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolveEntries = %v, want %v", got, want)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	t.Parallel()
+
+	edges := []model.CallEdge{
+		{Caller: "main", Callee: "run"},
+		{Caller: "run", Callee: "load"},
+		{Caller: "load", Callee: "run"}, // cycle
+		{Caller: "orphan", Callee: "load"},
+		{Caller: "test", Callee: "orphan"},
+	}
+	got := slices.Sorted(maps.Keys(Reachable(edges, []string{"main"})))
+	if want := []string{"load", "main", "run"}; !slices.Equal(got, want) {
+		t.Errorf("Reachable = %v, want %v", got, want)
+	}
+	if got := Reachable(nil, []string{"main"}); len(got) != 1 {
+		t.Errorf("an entry with no calls should reach only itself, got %v", got)
+	}
+}
@@ -0,0 +1,431 @@
+// Package jsonout implements JSON encoding of a repository map for programmatic
+// consumers.
+package jsonout
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+// RepoMap is the JSON document shape. Field names are lowercase and every
+// slice is emitted as an array (never null) so consumers can rely on a stable
+// schema.
+type RepoMap struct {
+	Repo         string       `json:"repo"`
+	Root         string       `json:"root"`
+	Files        []File       `json:"files"`
+	Dependencies []Dependency `json:"dependencies"`
+	Calls        []CallEdge   `json:"calls"`
+	CallSites    []CallSite   `json:"callsites"`
+	Members      []Tag        `json:"members"`
+	Cycles       [][]string   `json:"cycles"`
+	Externals    []External   `json:"externals"`
+	Refs         []Ref        `json:"refs"`
+	Entrypoints  []Entrypoint `json:"entrypoints"`
+	Metrics      []Metric     `json:"metrics"`
+	Diagnostics  []Diagnostic `json:"diagnostics"`
+	Unused       []Unused     `json:"unused"`
+	Unreachable  []Unused     `json:"unreachable"`
+	Packages     []Package    `json:"packages"`
+}
+
+// File is a ranked source file with its definitions. Package is set only
+// for languages with a package clause (Go), and Owners only with
+// --with-owners, for files that have any.
+type File struct {
+	Path     string   `json:"path"`
+	Language string   `json:"language"`
+	Package  string   `json:"package,omitempty"`
+	Rank     float64  `json:"rank"`
+	Owners   []string `json:"owners,omitempty"`
+	Tags     []Tag    `json:"tags"`
+}
+
+// Tag is a single definition within a file.
+type Tag struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Line      int    `json:"line"`
+	Signature string `json:"signature"`
+}
+
+// Dependency is a file-level edge: Source references symbols defined in Target.
+type Dependency struct {
+	Source  string   `json:"source"`
+	Target  string   `json:"target"`
+	Symbols []string `json:"symbols"`
+}
+
+// CallEdge is a function-level call edge, with the number of call sites
+// behind it.
+type CallEdge struct {
+	Caller string `json:"caller"`
+	Callee string `json:"callee"`
+	Count  int    `json:"count"`
+}
+
+// CallSite is a single call or import occurrence. Kind is "call" or
+// "import"; an import's Caller is "<import>".
+type CallSite struct {
+	Caller string `json:"caller"`
+	Callee string `json:"callee"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Lines  []int  `json:"lines,omitempty"` // every line, with --dedupe-callsites
+	Kind   string `json:"kind"`
+}
+
+// External is a referenced symbol with no in-repo definition.
+type External struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Ref is a name referenced by a file, at its first occurrence.
+type Ref struct {
+	File string `json:"file"`
+	Name string `json:"name"`
+	Line int    `json:"line"`
+}
+
+// Entrypoint is a likely place where execution starts.
+type Entrypoint struct {
+	File string `json:"file"`
+	Name string `json:"name"`
+	Line int    `json:"line"`
+}
+
+// Metric is a file's in-degree, out-degree, and rank in the dependency graph.
+type Metric struct {
+	File      string  `json:"file"`
+	InDegree  int     `json:"in_degree"`
+	OutDegree int     `json:"out_degree"`
+	Rank      float64 `json:"rank"`
+}
+
+// Diagnostic flags a file whose entry may be incomplete.
+type Diagnostic struct {
+	File    string `json:"file"`
+	Message string `json:"message"`
+}
+
+// Unused is a function or method that no other file references (unused),
+// or that no entrypoint reaches through the call graph (unreachable).
+type Unused struct {
+	File string `json:"file"`
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	Line int    `json:"line"`
+}
+
+// Package is a documented Go package among the files shown.
+type Package struct {
+	Dir  string `json:"dir"`
+	Name string `json:"name"`
+	Doc  string `json:"doc"`
+}
+
+// Convert builds the JSON document for rm. Only definition tags are included,
+// matching the TOON symbols table. Ranks are rounded to four decimal places
+// (the TOON precision) so that floating-point noise from PageRank does not
+// make otherwise identical runs differ.
+func Convert(rm *model.RepoMap) *RepoMap {
+	out := &RepoMap{
+		Repo:         rm.RepoName,
+		Root:         rm.Root,
+		Files:        make([]File, 0, len(rm.Files)),
+		Dependencies: make([]Dependency, 0, len(rm.Dependencies)),
+		Calls:        make([]CallEdge, 0, len(rm.CallEdges)),
+		CallSites:    make([]CallSite, 0, len(rm.CallSites)),
+		Members:      make([]Tag, 0, len(rm.Members)),
+		Cycles:       make([][]string, 0, len(rm.Cycles)),
+		Externals:    make([]External, 0, len(rm.Externals)),
+		Refs:         make([]Ref, 0, len(rm.Refs)),
+		Entrypoints:  make([]Entrypoint, 0, len(rm.Entrypoints)),
+		Metrics:      make([]Metric, 0, len(rm.Metrics)),
+		Diagnostics:  make([]Diagnostic, 0, len(rm.Diagnostics)),
+		Unused:       make([]Unused, 0, len(rm.Unused)),
+		Unreachable:  make([]Unused, 0, len(rm.Unreachable)),
+		Packages:     make([]Package, 0, len(rm.Packages)),
+	}
+
+	for i := range rm.Files {
+		fi := &rm.Files[i]
+		f := File{
+			Path:     fi.Path,
+			Language: fi.Language,
+			Package:  fi.Package,
+			Rank:     math.Round(fi.Rank*1e4) / 1e4,
+			Owners:   fi.Owners,
+			Tags:     []Tag{},
+		}
+		for j := range fi.Tags {
+			if fi.Tags[j].Kind == model.Definition {
+				f.Tags = append(f.Tags, convertTag(&fi.Tags[j]))
+			}
+		}
+		out.Files = append(out.Files, f)
+	}
+
+	for i := range rm.Dependencies {
+		d := &rm.Dependencies[i]
+		syms := make([]string, len(d.Symbols))
+		copy(syms, d.Symbols)
+		out.Dependencies = append(out.Dependencies, Dependency{Source: d.Source, Target: d.Target, Symbols: syms})
+	}
+
+	for i := range rm.CallEdges {
+		ce := &rm.CallEdges[i]
+		out.Calls = append(out.Calls, CallEdge{Caller: ce.Caller, Callee: ce.Callee, Count: ce.Count})
+	}
+
+	for i := range rm.CallSites {
+		cs := &rm.CallSites[i]
+		out.CallSites = append(out.CallSites, CallSite{Caller: cs.Caller, Callee: cs.Callee, File: cs.File, Line: cs.Line, Lines: cs.Lines, Kind: string(cs.Kind)})
+	}
+
+	for i := range rm.Members {
+		out.Members = append(out.Members, convertTag(&rm.Members[i]))
+	}
+
+	for _, c := range rm.Cycles {
+		out.Cycles = append(out.Cycles, append([]string(nil), c...))
+	}
+
+	for _, e := range rm.Externals {
+		out.Externals = append(out.Externals, External{Name: e.Name, Count: e.Count})
+	}
+
+	for _, r := range rm.Refs {
+		out.Refs = append(out.Refs, Ref{File: r.File, Name: r.Name, Line: r.Line})
+	}
+
+	for _, e := range rm.Entrypoints {
+		out.Entrypoints = append(out.Entrypoints, Entrypoint{File: e.File, Name: e.Name, Line: e.Line})
+	}
+
+	for _, m := range rm.Metrics {
+		out.Metrics = append(out.Metrics, Metric{File: m.File, InDegree: m.InDegree, OutDegree: m.OutDegree, Rank: math.Round(m.Rank*1e4) / 1e4})
+	}
+
+	for _, d := range rm.Diagnostics {
+		out.Diagnostics = append(out.Diagnostics, Diagnostic{File: d.File, Message: d.Message})
+	}
+
+	for i := range rm.Unused {
+		u := &rm.Unused[i]
+		out.Unused = append(out.Unused, Unused{File: u.File, Name: u.Name, Kind: string(u.SymbolKind), Line: u.Line})
+	}
+
+	for i := range rm.Unreachable {
+		u := &rm.Unreachable[i]
+		out.Unreachable = append(out.Unreachable, Unused{File: u.File, Name: u.Name, Kind: string(u.SymbolKind), Line: u.Line})
+	}
+
+	for _, p := range rm.Packages {
+		out.Packages = append(out.Packages, Package{Dir: p.Dir, Name: p.Name, Doc: p.Doc})
+	}
+
+	return out
+}
+
+// Encode converts a RepoMap into indented JSON.
+func Encode(rm *model.RepoMap) (string, error) {
+	data, err := json.MarshalIndent(Convert(rm), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Decode reads a JSON document written by Encode back into a RepoMap. It
+// restores the files with their definitions, the dependencies, calls, refs,
+// and packages; tags come back as definitions with their kind, line, and
+// signature only, and the other tables are left empty.
+func Decode(data []byte) (*model.RepoMap, error) {
+	var doc RepoMap
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	rm := &model.RepoMap{RepoName: doc.Repo, Root: doc.Root}
+	for _, f := range doc.Files {
+		fi := model.FileInfo{Path: f.Path, Language: f.Language, Package: f.Package, Rank: f.Rank, Owners: f.Owners}
+		for _, t := range f.Tags {
+			fi.Tags = append(fi.Tags, model.Tag{
+				Name:       t.Name,
+				Kind:       model.Definition,
+				SymbolKind: model.SymbolKind(t.Kind),
+				Line:       t.Line,
+				File:       f.Path,
+				Signature:  t.Signature,
+			})
+		}
+		rm.Files = append(rm.Files, fi)
+	}
+	for _, d := range doc.Dependencies {
+		rm.Dependencies = append(rm.Dependencies, model.Dependency{Source: d.Source, Target: d.Target, Symbols: d.Symbols})
+	}
+	for _, c := range doc.Calls {
+		rm.CallEdges = append(rm.CallEdges, model.CallEdge{Caller: c.Caller, Callee: c.Callee, Count: c.Count})
+	}
+	for _, r := range doc.Refs {
+		rm.Refs = append(rm.Refs, model.Ref{File: r.File, Name: r.Name, Line: r.Line})
+	}
+	for _, p := range doc.Packages {
+		rm.Packages = append(rm.Packages, model.Package{Dir: p.Dir, Name: p.Name, Doc: p.Doc})
+	}
+	return rm, nil
+}
+
+// DirMap is the JSON document shape of the collapsed directory view.
+type DirMap struct {
+	Repo         string       `json:"repo"`
+	Root         string       `json:"root"`
+	Dirs         []Dir        `json:"dirs"`
+	Dependencies []Dependency `json:"dependencies"`
+	Cycles       [][]string   `json:"cycles"`
+}
+
+// Dir is a directory with the summed rank and count of its files.
+type Dir struct {
+	Path  string  `json:"path"`
+	Rank  float64 `json:"rank"`
+	Files int     `json:"files"`
+}
+
+// EncodeDirs renders the collapsed directory view built by
+// ranking.CollapseDirs as indented JSON. As in Encode, every slice is an
+// array and ranks are rounded to four decimal places.
+func EncodeDirs(rm *model.RepoMap) (string, error) {
+	out := &DirMap{
+		Repo:         rm.RepoName,
+		Root:         rm.Root,
+		Dirs:         make([]Dir, 0, len(rm.Dirs)),
+		Dependencies: make([]Dependency, 0, len(rm.Dependencies)),
+		Cycles:       make([][]string, 0, len(rm.Cycles)),
+	}
+	for _, d := range rm.Dirs {
+		out.Dirs = append(out.Dirs, Dir{Path: d.Path, Rank: math.Round(d.Rank*1e4) / 1e4, Files: d.Files})
+	}
+	for i := range rm.Dependencies {
+		d := &rm.Dependencies[i]
+		out.Dependencies = append(out.Dependencies, Dependency{Source: d.Source, Target: d.Target, Symbols: append([]string{}, d.Symbols...)})
+	}
+	for _, c := range rm.Cycles {
+		out.Cycles = append(out.Cycles, append([]string(nil), c...))
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// SearchResults is the JSON document for a --search query.
+type SearchResults struct {
+	Query   string `json:"query"`
+	Results []Hit  `json:"results"`
+}
+
+// Hit is one definition matched by a --search query.
+type Hit struct {
+	Score     float64 `json:"score"`
+	File      string  `json:"file"`
+	Line      int     `json:"line"`
+	Kind      string  `json:"kind"`
+	Name      string  `json:"name"`
+	Signature string  `json:"signature"`
+}
+
+// EncodeSearch renders the hits of a --search query, best first, as indented
+// JSON. Results is always an array, and scores are rounded to three decimal
+// places as in TOON.
+func EncodeSearch(query string, hits []model.Hit) (string, error) {
+	out := SearchResults{Query: query, Results: make([]Hit, 0, len(hits))}
+	for i := range hits {
+		t := &hits[i].Tag
+		out.Results = append(out.Results, Hit{
+			Score:     math.Round(hits[i].Score*1e3) / 1e3,
+			File:      t.File,
+			Line:      t.Line,
+			Kind:      string(t.SymbolKind),
+			Name:      t.Name,
+			Signature: t.Signature,
+		})
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Symbol is one definition in the flat symbol index. Name is qualified
+// ("Server.Handle") so that methods are directly searchable.
+type Symbol struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Kind string `json:"kind"`
+}
+
+// EncodeSymbols renders every definition in files as a compact, flat JSON
+// array for jump-to-definition lookups, in file order and then source order.
+// It is always an array, never null.
+func EncodeSymbols(files []model.FileInfo) (string, error) {
+	symbols := []Symbol{}
+	for i := range files {
+		fi := &files[i]
+		for j := range fi.Tags {
+			t := &fi.Tags[j]
+			if t.Kind == model.Definition {
+				symbols = append(symbols, Symbol{Name: t.Name, File: fi.Path, Line: t.Line, Kind: string(t.SymbolKind)})
+			}
+		}
+	}
+	data, err := json.Marshal(symbols)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// FileLine is one line of the JSON Lines stream: a parsed file with its
+// definitions. It has no rank, since the stream is written before the
+// dependency graph exists.
+type FileLine struct {
+	Path         string `json:"path"`
+	Language     string `json:"language"`
+	Package      string `json:"package,omitempty"`
+	Tags         []Tag  `json:"tags"`
+	SyntaxErrors bool   `json:"syntax_errors,omitempty"`
+}
+
+// EncodeFileLine renders fi as a single line of compact JSON, without a
+// trailing newline. As in Encode, only definition tags are included and Tags
+// is always an array.
+func EncodeFileLine(fi *model.FileInfo) (string, error) {
+	line := FileLine{Path: fi.Path, Language: fi.Language, Package: fi.Package, Tags: []Tag{}, SyntaxErrors: fi.SyntaxErrors}
+	for j := range fi.Tags {
+		t := &fi.Tags[j]
+		if t.Kind == model.Definition {
+			line.Tags = append(line.Tags, convertTag(t))
+		}
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func convertTag(t *model.Tag) Tag {
+	return Tag{
+		Name:      t.Name,
+		Kind:      string(t.SymbolKind),
+		Line:      t.Line,
+		Signature: t.Signature,
+	}
+}
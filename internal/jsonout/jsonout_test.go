@@ -0,0 +1,239 @@
+package jsonout
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "myrepo",
+		Root:     "myrepo",
+		Files: []model.FileInfo{
+			{
+				Path:     "models.py",
+				Language: "python",
+				Rank:     0.123456789,
+				Tags: []model.Tag{
+					{Name: "User", Kind: model.Definition, SymbolKind: model.Class, Line: 1, Signature: "User"},
+					{Name: "helper", Kind: model.Reference, SymbolKind: model.Function, Line: 3},
+				},
+			},
+		},
+		Dependencies: []model.Dependency{
+			{Source: "main.py", Target: "models.py", Symbols: []string{"User"}},
+		},
+		CallEdges: []model.CallEdge{{Caller: "greet", Callee: "User"}},
+	}
+
+	out, err := Encode(rm)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	var got RepoMap
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if got.Repo != "myrepo" {
+		t.Errorf("repo = %q", got.Repo)
+	}
+	if len(got.Files) != 1 || got.Files[0].Rank != 0.1235 {
+		t.Fatalf("files = %+v", got.Files)
+	}
+	// Reference tags are not serialized.
+	if len(got.Files[0].Tags) != 1 || got.Files[0].Tags[0].Kind != "class" {
+		t.Errorf("tags = %+v", got.Files[0].Tags)
+	}
+	if len(got.Dependencies) != 1 || got.Dependencies[0].Symbols[0] != "User" {
+		t.Errorf("dependencies = %+v", got.Dependencies)
+	}
+	if len(got.Calls) != 1 || got.Calls[0].Caller != "greet" {
+		t.Errorf("calls = %+v", got.Calls)
+	}
+	for _, key := range []string{`"repo"`, `"files"`, `"tags"`, `"signature"`, `"callsites"`, `"members"`, `"externals"`} {
+		if !strings.Contains(out, key) {
+			t.Errorf("output missing key %s:\n%s", key, out)
+		}
+	}
+}
+
+func TestEncodeEmptyArrays(t *testing.T) {
+	t.Parallel()
+
+	out, err := Encode(&model.RepoMap{RepoName: "empty", Root: "empty"})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if strings.Contains(out, "null") {
+		t.Errorf("empty slices should encode as [], got:\n%s", out)
+	}
+}
+
+func TestEncodeDirs(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Dirs:     []model.Dir{{Path: "pkg", Rank: 0.61234, Files: 3}, {Path: ".", Rank: 0.2, Files: 1}},
+		Dependencies: []model.Dependency{
+			{Source: ".", Target: "pkg", Symbols: []string{"Run"}},
+		},
+	}
+	out, err := EncodeDirs(rm)
+	if err != nil {
+		t.Fatalf("EncodeDirs: %v", err)
+	}
+	var got DirMap
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, out)
+	}
+	if len(got.Dirs) != 2 || got.Dirs[0] != (Dir{Path: "pkg", Rank: 0.6123, Files: 3}) {
+		t.Errorf("dirs = %+v", got.Dirs)
+	}
+	if len(got.Dependencies) != 1 || got.Dependencies[0].Target != "pkg" {
+		t.Errorf("dependencies = %+v", got.Dependencies)
+	}
+	if strings.Contains(out, "null") {
+		t.Errorf("empty cycles should encode as [], got:\n%s", out)
+	}
+}
+
+func TestEncodeSymbols(t *testing.T) {
+	t.Parallel()
+
+	files := []model.FileInfo{
+		{Path: "server.go", Language: "go", Tags: []model.Tag{
+			{Name: "Server", Kind: model.Definition, SymbolKind: model.Class, Line: 3},
+			{Name: "Server.Handle", Kind: model.Definition, SymbolKind: model.Method, Line: 7},
+			{Name: "fmt", Kind: model.Reference, SymbolKind: model.Module, Line: 1},
+		}},
+		{Path: "main.go", Language: "go", Tags: []model.Tag{
+			{Name: "main", Kind: model.Definition, SymbolKind: model.Function, Line: 5},
+			{Name: "main", Kind: model.Entry, SymbolKind: model.Function, Line: 5},
+		}},
+	}
+	got, err := EncodeSymbols(files)
+	if err != nil {
+		t.Fatalf("EncodeSymbols: %v", err)
+	}
+	want := `[{"name":"Server","file":"server.go","line":3,"kind":"class"},` +
+		`{"name":"Server.Handle","file":"server.go","line":7,"kind":"method"},` +
+		`{"name":"main","file":"main.go","line":5,"kind":"function"}]`
+	if got != want {
+		t.Errorf("EncodeSymbols:\ngot:  %s\nwant: %s", got, want)
+	}
+
+	if got, _ := EncodeSymbols(nil); got != "[]" {
+		t.Errorf("EncodeSymbols(nil) = %s, want []", got)
+	}
+}
+
+func TestEncodeFileLine(t *testing.T) {
+	t.Parallel()
+
+	fi := &model.FileInfo{Path: "server.go", Language: "go", Tags: []model.Tag{
+		{Name: "Server", Kind: model.Definition, SymbolKind: model.Class, Line: 3, Signature: "type Server struct"},
+		{Name: "fmt", Kind: model.Reference, SymbolKind: model.Module, Line: 1},
+	}}
+	got, err := EncodeFileLine(fi)
+	if err != nil {
+		t.Fatalf("EncodeFileLine: %v", err)
+	}
+	want := `{"path":"server.go","language":"go","tags":[{"name":"Server","kind":"class","line":3,"signature":"type Server struct"}]}`
+	if got != want {
+		t.Errorf("EncodeFileLine:\ngot:  %s\nwant: %s", got, want)
+	}
+
+	broken := &model.FileInfo{Path: "bad.go", Language: "go", SyntaxErrors: true}
+	got, _ = EncodeFileLine(broken)
+	want = `{"path":"bad.go","language":"go","tags":[],"syntax_errors":true}`
+	if got != want {
+		t.Errorf("EncodeFileLine(broken):\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Files: []model.FileInfo{{Path: "server.go", Language: "go", Package: "srv", Rank: 0.5, Tags: []model.Tag{
+			{Name: "Server", Kind: model.Definition, SymbolKind: model.Class, Line: 3, Signature: "type Server struct", File: "server.go"},
+		}}},
+		Dependencies: []model.Dependency{{Source: "main.go", Target: "server.go", Symbols: []string{"Server"}}},
+		CallEdges:    []model.CallEdge{{Caller: "main", Callee: "Server.Run"}},
+		Refs:         []model.Ref{{File: "main.go", Name: "Server", Line: 4}},
+		Packages:     []model.Package{{Dir: ".", Name: "srv", Doc: "Package srv serves."}},
+	}
+	data, err := Encode(rm)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	got, err := Decode([]byte(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !reflect.DeepEqual(got, rm) {
+		t.Errorf("Decode(Encode(rm)):\ngot:  %+v\nwant: %+v", got, rm)
+	}
+
+	if _, err := Decode([]byte("not json")); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	out, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema: %v", err)
+	}
+	var schema struct {
+		Required []string                  `json:"required"`
+		Defs     map[string]map[string]any `json:"$defs"`
+	}
+	if err := json.Unmarshal([]byte(out), &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+
+	// Every top-level key Encode writes is a required property.
+	doc, err := Encode(&model.RepoMap{})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var keys map[string]any
+	if err := json.Unmarshal([]byte(doc), &keys); err != nil {
+		t.Fatal(err)
+	}
+	if len(schema.Required) != len(keys) {
+		t.Errorf("required = %v, want the %d keys of an encoded map", schema.Required, len(keys))
+	}
+	for _, name := range schema.Required {
+		if _, ok := keys[name]; !ok {
+			t.Errorf("required property %q is not in an encoded map", name)
+		}
+	}
+
+	file := schema.Defs["File"]
+	if file == nil {
+		t.Fatalf("no File definition in %v", schema.Defs)
+	}
+	if got, want := file["required"], []any{"path", "language", "rank", "tags"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("File required = %v, want %v (package is omitempty)", got, want)
+	}
+	for _, ref := range []string{"Tag", "CallSite", "Package", "Unused"} {
+		if schema.Defs[ref] == nil {
+			t.Errorf("no %s definition", ref)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package lang
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/cpp"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func init() {
+	Languages["cpp"] = &Language{
+		Name: "cpp",
+		// .h is ambiguous between C and C++; it is parsed as C++, which
+		// accepts nearly all C headers.
+		Extensions:        []string{".cpp", ".cc", ".cxx", ".hpp", ".hh", ".hxx", ".h"},
+		lang:              cpp.GetLanguage(),
+		Separator:         "::",
+		NameText:          cppNameText,
+		FindMethodClass:   cppFindMethodClass,
+		ExtractSignature:  cppExtractSignature,
+		FindEnclosingDef:  cppFindEnclosingDef,
+		FindEnclosingType: cppFindEnclosingType,
+		QualifyClass:      cppQualifyClass,
+	}
+}
+
+// cppNameText returns the unqualified name for a captured name node:
+// "draw" for Widget::draw, "make" for make<T>, and "vector" for <vector>.
+func cppNameText(node *sitter.Node, source []byte) string {
+	switch node.Type() {
+	case "qualified_identifier", "template_function":
+		if name := node.ChildByFieldName("name"); name != nil {
+			return cppNameText(name, source)
+		}
+	case "system_lib_string":
+		return strings.Trim(NodeText(node, source), "<>")
+	}
+	return NodeText(node, source)
+}
+
+// cppIsClass reports whether a node type declares a class-like type.
+func cppIsClass(nodeType string) bool {
+	switch nodeType {
+	case "class_specifier", "struct_specifier", "union_specifier":
+		return true
+	}
+	return false
+}
+
+// cppScopePath returns the "::"-joined names of the namespaces and classes
+// enclosing node, including node itself if it is a class or namespace.
+// Anonymous namespaces and classes contribute nothing.
+func cppScopePath(node *sitter.Node, source []byte) string {
+	var parts []string
+	for n := node; n != nil; n = n.Parent() {
+		if n.Type() != "namespace_definition" && !cppIsClass(n.Type()) {
+			continue
+		}
+		if name := n.ChildByFieldName("name"); name != nil {
+			parts = append(parts, NodeText(name, source))
+		}
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, "::")
+}
+
+// cppQualifyClass returns the namespace- and class-qualified name of a class,
+// e.g. "engine::Widget::Inner".
+func cppQualifyClass(node *sitter.Node, source []byte) string {
+	return cppScopePath(node, source)
+}
+
+// cppMemberClass returns the class path for a member declared directly in a
+// class body (skipping a template_declaration wrapper), or "" if node is not
+// a class member.
+func cppMemberClass(node *sitter.Node, source []byte) string {
+	body := node.Parent()
+	if body != nil && body.Type() == "template_declaration" {
+		body = body.Parent()
+	}
+	if body == nil || body.Type() != "field_declaration_list" {
+		return ""
+	}
+	cls := body.Parent()
+	if cls == nil || !cppIsClass(cls.Type()) || cls.ChildByFieldName("name") == nil {
+		return ""
+	}
+	return cppScopePath(cls, source)
+}
+
+// cppFunctionDeclarator returns the function_declarator of a function
+// definition or declaration, looking through pointer and reference return
+// types.
+func cppFunctionDeclarator(node *sitter.Node) *sitter.Node {
+	decl := node.ChildByFieldName("declarator")
+	for decl != nil && decl.Type() != "function_declarator" {
+		switch decl.Type() {
+		case "pointer_declarator", "reference_declarator":
+			next := decl.ChildByFieldName("declarator")
+			if next == nil && decl.NamedChildCount() > 0 {
+				next = decl.NamedChild(int(decl.NamedChildCount()) - 1)
+			}
+			decl = next
+		default:
+			return nil
+		}
+	}
+	return decl
+}
+
+// cppQualifierScopes returns the scope names of a qualified declarator name,
+// e.g. ["ns", "Widget"] for ns::Widget::draw. Template arguments are dropped
+// (Box<T>::get yields ["Box"]).
+func cppQualifierScopes(name *sitter.Node, source []byte) []string {
+	var scopes []string
+	for name != nil && name.Type() == "qualified_identifier" {
+		if scope := name.ChildByFieldName("scope"); scope != nil {
+			if scope.Type() == "template_type" {
+				if n := scope.ChildByFieldName("name"); n != nil {
+					scope = n
+				}
+			}
+			scopes = append(scopes, NodeText(scope, source))
+		}
+		name = name.ChildByFieldName("name")
+	}
+	return scopes
+}
+
+// cppFindMethodClass returns the owning class path of a function definition
+// or declaration: the qualifier of an out-of-line definition
+// (engine::Widget for void Widget::draw() inside namespace engine), or the
+// enclosing class for one in a class body. Returns "" for free functions,
+// which stay unqualified so that calls to them resolve.
+func cppFindMethodClass(node *sitter.Node, source []byte) string {
+	if fd := cppFunctionDeclarator(node); fd != nil {
+		name := fd.ChildByFieldName("declarator")
+		if scopes := cppQualifierScopes(name, source); len(scopes) > 0 {
+			if outer := cppScopePath(node, source); outer != "" {
+				scopes = append([]string{outer}, scopes...)
+			}
+			return strings.Join(scopes, "::")
+		}
+	}
+	return cppMemberClass(node, source)
+}
+
+// cppFindEnclosingType returns the class path owning a data member.
+func cppFindEnclosingType(node *sitter.Node, source []byte) string {
+	return cppMemberClass(node, source)
+}
+
+// cppFindEnclosingDef returns the qualified name of the function containing
+// the given call-site node (e.g., "engine::Widget::draw" or "main"). Lambdas
+// are transparent. Returns "" for calls outside any function.
+func cppFindEnclosingDef(node *sitter.Node, source []byte) string {
+	for current := node.Parent(); current != nil; current = current.Parent() {
+		if current.Type() != "function_definition" {
+			continue
+		}
+		fd := cppFunctionDeclarator(current)
+		if fd == nil {
+			return ""
+		}
+		name := fd.ChildByFieldName("declarator")
+		if name == nil {
+			return ""
+		}
+		funcName := cppNameText(name, source)
+		if cls := cppFindMethodClass(current, source); cls != "" {
+			return cls + "::" + funcName
+		}
+		return funcName
+	}
+	return ""
+}
+
+func cppExtractSignature(defNode *sitter.Node, kind model.SymbolKind, source []byte) string {
+	switch kind {
+	case model.Class:
+		return cppExtractClassSignature(defNode, source)
+	case model.Field:
+		return cppExtractFieldSignature(defNode, source)
+	}
+	return cppExtractFunctionSignature(defNode, source)
+}
+
+// cppExtractClassSignature returns the name and base classes, e.g.
+// "Widget : public Base".
+func cppExtractClassSignature(node *sitter.Node, source []byte) string {
+	var sig string
+	if name := node.ChildByFieldName("name"); name != nil {
+		sig = NodeText(name, source)
+	}
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		if child := node.NamedChild(i); child.Type() == "base_class_clause" {
+			sig += " " + CollapseWhitespace(NodeText(child, source))
+		}
+	}
+	return sig
+}
+
+// cppExtractFieldSignature returns a data member declaration up to its last
+// declarator ("static int w_, h_", "Node* next"), dropping a trailing
+// initializer.
+func cppExtractFieldSignature(node *sitter.Node, source []byte) string {
+	end := node.EndByte()
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if node.FieldNameForChild(i) == "declarator" {
+			end = node.Child(i).EndByte()
+		}
+	}
+	return CollapseWhitespace(string(source[node.StartByte():end]))
+}
+
+// cppExtractFunctionSignature returns everything before the body of a
+// definition, or the whole declaration without its ";", e.g.
+// "virtual void draw() const = 0".
+func cppExtractFunctionSignature(node *sitter.Node, source []byte) string {
+	end := node.EndByte()
+	if body := node.ChildByFieldName("body"); body != nil {
+		end = body.StartByte()
+	}
+	text := string(source[node.StartByte():end])
+	return CollapseWhitespace(strings.TrimSuffix(strings.TrimSpace(text), ";"))
+}
@@ -1,6 +1,10 @@
 package lang
 
 import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/smacker/go-tree-sitter/golang"
 
@@ -14,11 +18,28 @@ func init() {
 		lang:              golang.GetLanguage(),
 		FindReceiverType:  goFindReceiverType,
 		ExtractSignature:  goExtractSignature,
+		ExtractDoc:        goExtractDoc,
+		PackageClause:     goPackageClause,
+		IsEntrypoint:      goIsEntrypoint,
+		IsExported:        goIsExported,
 		FindEnclosingDef:  goFindEnclosingDef,
 		FindEnclosingType: goFindEnclosingType,
+		QualifyCall:       goQualifyCall,
 	}
 }
 
+// goIsExported reports whether every part of a qualified name starts with an
+// upper-case letter, so a method is exported only on an exported type.
+func goIsExported(_ *sitter.Node, name string, _ []byte) bool {
+	for _, part := range strings.Split(name, ".") {
+		r, _ := utf8.DecodeRuneInString(part)
+		if !unicode.IsUpper(r) {
+			return false
+		}
+	}
+	return true
+}
+
 // goFindReceiverType extracts the receiver type name from a method_declaration node.
 // Navigates: method_declaration → parameter_list (receiver) → parameter_declaration → type.
 func goFindReceiverType(node *sitter.Node, source []byte) string {
@@ -43,19 +64,16 @@ func goFindReceiverType(node *sitter.Node, source []byte) string {
 }
 
 // goExtractTypeName extracts the type name from a parameter_declaration,
-// unwrapping pointer_type if present.
+// unwrapping pointer_type and generic_type (Set[T], *Set[T]) if present.
 func goExtractTypeName(param *sitter.Node, source []byte) string {
 	for i := 0; i < int(param.ChildCount()); i++ {
 		child := param.Child(i)
 		switch child.Type() {
 		case "type_identifier":
 			return NodeText(child, source)
-		case "pointer_type":
-			for k := 0; k < int(child.ChildCount()); k++ {
-				inner := child.Child(k)
-				if inner.Type() == "type_identifier" {
-					return NodeText(inner, source)
-				}
+		case "pointer_type", "generic_type":
+			if name := goExtractTypeName(child, source); name != "" {
+				return name
 			}
 		}
 	}
@@ -64,28 +82,48 @@ func goExtractTypeName(param *sitter.Node, source []byte) string {
 
 func goExtractSignature(defNode *sitter.Node, kind model.SymbolKind, source []byte) string {
 	if kind == model.Class {
-		// Type definition: just the type name
+		// Type definition: the type name plus any type parameters (Set[T comparable])
+		var name, typeParams string
 		for i := 0; i < int(defNode.ChildCount()); i++ {
 			child := defNode.Child(i)
-			if child.Type() == "type_identifier" {
-				return NodeText(child, source)
+			switch child.Type() {
+			case "type_identifier":
+				if name == "" {
+					name = NodeText(child, source)
+				}
+			case "type_parameter_list":
+				typeParams = CollapseWhitespace(NodeText(child, source))
 			}
 		}
-		return ""
+		return name + typeParams
+	}
+
+	if kind == model.Field || defNode.Type() == "method_elem" {
+		// Struct field or interface method: return the full declaration text
+		// collapsed, tidying the parameter lists of func types.
+		return goTidyParams(CollapseWhitespace(NodeText(defNode, source)))
 	}
 
-	if kind == model.Field {
-		// Struct field or interface method: return the full declaration text collapsed.
-		return CollapseWhitespace(NodeText(defNode, source))
+	if kind == model.Constant && defNode.ChildByFieldName("type") == nil && defNode.ChildByFieldName("value") == nil {
+		// An implicitly repeated spec in a const group: show the type it
+		// inherits ("Green Color") rather than the bare name.
+		if typ := goConstType(defNode, source); typ != "" {
+			return goExtractSpecSignature(defNode, source) + " " + typ
+		}
+	}
+	if kind == model.Constant || kind == model.Variable {
+		return goExtractSpecSignature(defNode, source)
 	}
 
 	// Function or method
-	var name, params, result string
+	var name, typeParams, params, result string
 	for i := 0; i < int(defNode.ChildCount()); i++ {
 		child := defNode.Child(i)
 		switch child.Type() {
 		case "identifier", "field_identifier":
 			name = NodeText(child, source)
+		case "type_parameter_list":
+			typeParams = CollapseWhitespace(NodeText(child, source))
 		case "parameter_list":
 			// For methods, the first parameter_list is the receiver — skip it
 			if kind == model.Method && params == "" && isReceiverList(defNode, child) {
@@ -93,20 +131,48 @@ func goExtractSignature(defNode *sitter.Node, kind model.SymbolKind, source []by
 			}
 			params = CollapseWhitespace(NodeText(child, source))
 		case "simple_type", "pointer_type", "qualified_type",
-			"slice_type", "map_type", "channel_type",
+			"slice_type", "array_type", "map_type", "channel_type",
 			"interface_type", "struct_type", "function_type",
-			"type_identifier":
+			"generic_type", "type_identifier":
 			result = CollapseWhitespace(NodeText(child, source))
 		}
 	}
 
-	sig := name + params
+	sig := name + typeParams + params
 	if result != "" {
 		sig += " " + result
 	}
 	return sig
 }
 
+// maxSpecSignature caps const/var signatures; longer specs drop their value
+// (e.g. a large composite literal) and keep only names and type.
+const maxSpecSignature = 80
+
+// goExtractSpecSignature returns the collapsed text of a const_spec or
+// var_spec ("ErrNotFound = errors.New(\"not found\")"), omitting the value
+// when it would make the signature unwieldy.
+func goExtractSpecSignature(spec *sitter.Node, source []byte) string {
+	full := CollapseWhitespace(NodeText(spec, source))
+	if len(full) <= maxSpecSignature {
+		return full
+	}
+	var sig string
+	for i := 0; i < int(spec.ChildCount()); i++ {
+		child := spec.Child(i)
+		switch spec.FieldNameForChild(i) {
+		case "name":
+			sig += NodeText(child, source)
+		case "type":
+			sig += " " + CollapseWhitespace(NodeText(child, source))
+		}
+		if child.Type() == "," {
+			sig += ", "
+		}
+	}
+	return sig
+}
+
 // goFindEnclosingDef returns the qualified name of the function or method containing
 // the given call-site node. Returns "" if the call is at package level or inside a
 // func literal (closure), since those should not be attributed to a named function.
@@ -144,20 +210,112 @@ func goFindEnclosingDef(node *sitter.Node, source []byte) string {
 	return ""
 }
 
-// goFindEnclosingType walks up from a field_declaration or method_elem node to
-// its parent type_spec and returns the type name. Returns "" if not found.
-func goFindEnclosingType(node *sitter.Node, source []byte) string {
-	current := node.Parent()
-	for current != nil {
-		if current.Type() == "type_spec" {
-			for i := 0; i < int(current.ChildCount()); i++ {
-				child := current.Child(i)
-				if child.Type() == "type_identifier" {
-					return NodeText(child, source)
+// goQualifyCall resolves a method call on the receiver of the enclosing
+// method (s.flush() inside func (s *Server) Handle) to "Server.flush".
+// Closures inside the method see the same receiver. Calls on any other
+// operand return "", since their type is unknown without type checking.
+func goQualifyCall(node *sitter.Node, name string, source []byte) string {
+	fn := node.ChildByFieldName("function")
+	if fn == nil || fn.Type() != "selector_expression" {
+		return ""
+	}
+	operand := fn.ChildByFieldName("operand")
+	if operand == nil || operand.Type() != "identifier" {
+		return ""
+	}
+	for current := node.Parent(); current != nil; current = current.Parent() {
+		switch current.Type() {
+		case "function_declaration":
+			return ""
+		case "method_declaration":
+			recv := current.ChildByFieldName("receiver")
+			if recv == nil {
+				return ""
+			}
+			for i := 0; i < int(recv.NamedChildCount()); i++ {
+				param := recv.NamedChild(i)
+				if param.Type() != "parameter_declaration" {
+					continue
+				}
+				if pname := param.ChildByFieldName("name"); pname != nil && NodeText(pname, source) == NodeText(operand, source) {
+					if typ := goFindReceiverType(current, source); typ != "" {
+						return typ + "." + name
+					}
 				}
 			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// goTidyParams removes the padding that collapsing a multi-line parameter
+// list leaves behind, so "func( b []byte, ) error" reads "func(b []byte) error".
+var goTidyParams = strings.NewReplacer("( ", "(", ", )", ")", " )", ")").Replace
+
+// goFindEnclosingType returns the name of the type_spec that declares a
+// field_declaration or method_elem node. Only members of the type's own
+// struct or interface count: a field of an anonymous struct nested inside it
+// (Inner struct{ Depth int }) belongs to no named type, and gives "". For a
+// const_spec it returns the constant's declared type (see goConstType).
+func goFindEnclosingType(node *sitter.Node, source []byte) string {
+	if node.Type() == "const_spec" {
+		return goConstType(node, source)
+	}
+	list := node.Parent() // field_declaration_list or interface_type
+	if list == nil {
+		return ""
+	}
+	body := list
+	if list.Type() == "field_declaration_list" {
+		body = list.Parent() // struct_type
+	}
+	if body == nil {
+		return ""
+	}
+	spec := body.Parent()
+	if spec == nil || spec.Type() != "type_spec" {
+		return ""
+	}
+	if name := spec.ChildByFieldName("name"); name != nil {
+		return NodeText(name, source)
+	}
+	return ""
+}
+
+// goConstType returns the named type of a const_spec, following Go's implicit
+// repetition in const groups: a spec with neither type nor value repeats the
+// one before it, so Green and Blue in
+//
+//	const (
+//		Red Color = iota
+//		Green
+//		Blue
+//	)
+//
+// are all Color. Untyped constants and qualified types (time.Duration) give "".
+func goConstType(spec *sitter.Node, source []byte) string {
+	decl := spec.Parent()
+	if decl == nil {
+		return ""
+	}
+	var typ string
+	for i := 0; i < int(decl.NamedChildCount()); i++ {
+		s := decl.NamedChild(i)
+		if s.Type() != "const_spec" {
+			continue
+		}
+		if t := s.ChildByFieldName("type"); t != nil {
+			typ = ""
+			if t.Type() == "type_identifier" {
+				typ = NodeText(t, source)
+			}
+		} else if s.ChildByFieldName("value") != nil {
+			typ = ""
+		}
+		if s.Equal(spec) {
+			return typ
 		}
-		current = current.Parent()
 	}
 	return ""
 }
@@ -180,3 +338,107 @@ func isReceiverList(parent, paramList *sitter.Node) bool {
 	}
 	return false
 }
+
+// goExtractDoc returns the first sentence of the // comments directly above a
+// declaration. For a spec that has no comment of its own and is the only one
+// in its declaration (type Foo struct{...}), the comment above the
+// declaration keyword is used.
+func goExtractDoc(node *sitter.Node, source []byte) string {
+	for n := node; n != nil; n = n.Parent() {
+		if doc := goDocComment(n, source); doc != "" {
+			return FirstSentence(doc)
+		}
+		switch p := n.Parent(); {
+		case p == nil:
+			return ""
+		case p.Type() == "type_declaration", p.Type() == "const_declaration", p.Type() == "var_declaration":
+			if p.NamedChildCount() != 1 {
+				return ""
+			}
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// goPackageClause returns the name in a file's package clause and the first
+// sentence of its doc comment, which by convention documents the package.
+func goPackageClause(root *sitter.Node, source []byte) (name, doc string) {
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		c := root.NamedChild(i)
+		if c.Type() != "package_clause" {
+			continue
+		}
+		for j := 0; j < int(c.NamedChildCount()); j++ {
+			if id := c.NamedChild(j); id.Type() == "package_identifier" {
+				name = NodeText(id, source)
+			}
+		}
+		return name, FirstSentence(goDocComment(c, source))
+	}
+	return "", ""
+}
+
+// goDocComment returns the text of the contiguous // comment lines ending on
+// the line above node, with the comment markers removed. A comment trailing
+// code on its line is not part of a doc comment.
+func goDocComment(node *sitter.Node, source []byte) string {
+	var lines []string
+	next := node
+	for c := node.PrevNamedSibling(); c != nil && c.Type() == "comment"; c = c.PrevNamedSibling() {
+		text := NodeText(c, source)
+		if !strings.HasPrefix(text, "//") || c.EndPoint().Row+1 != next.StartPoint().Row {
+			break
+		}
+		if prev := c.PrevNamedSibling(); prev != nil && prev.EndPoint().Row == c.StartPoint().Row {
+			break
+		}
+		lines = append(lines, strings.TrimPrefix(text, "//"))
+		next = c
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// goEntrypointParams are parameter types that mark a Go function as an HTTP
+// handler or a CLI command.
+var goEntrypointParams = []string{
+	"http.ResponseWriter", "*gin.Context", "echo.Context", "*fiber.Ctx", // HTTP
+	"*cobra.Command", "*cli.Context", // CLI
+}
+
+// goIsEntrypoint reports whether a function is main in package main, or
+// takes a parameter of an HTTP handler or CLI command type.
+func goIsEntrypoint(node *sitter.Node, name string, source []byte) bool {
+	if node.Type() == "function_declaration" && name == "main" && goPackageName(node, source) == "main" {
+		return true
+	}
+	params := node.ChildByFieldName("parameters")
+	if params == nil {
+		return false
+	}
+	text := NodeText(params, source)
+	for _, p := range goEntrypointParams {
+		if strings.Contains(text, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// goPackageName returns the package clause name of the file containing node.
+func goPackageName(node *sitter.Node, source []byte) string {
+	root := node
+	for root.Parent() != nil {
+		root = root.Parent()
+	}
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		if c := root.NamedChild(i); c.Type() == "package_clause" && c.NamedChildCount() > 0 {
+			return NodeText(c.NamedChild(0), source)
+		}
+	}
+	return ""
+}
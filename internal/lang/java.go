@@ -0,0 +1,189 @@
+package lang
+
+import (
+	"regexp"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/java"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func init() {
+	Languages["java"] = &Language{
+		Name:              "java",
+		Extensions:        []string{".java"},
+		lang:              java.GetLanguage(),
+		FindMethodClass:   javaFindMemberOwner,
+		ExtractSignature:  javaExtractSignature,
+		FindEnclosingDef:  javaFindEnclosingDef,
+		FindEnclosingType: javaFindMemberOwner,
+		QualifyClass:      javaTypePath,
+		IsEntrypoint:      javaIsEntrypoint,
+	}
+}
+
+// javaIsType reports whether a node type declares a named type.
+func javaIsType(nodeType string) bool {
+	switch nodeType {
+	case "class_declaration", "interface_declaration", "enum_declaration",
+		"record_declaration", "annotation_type_declaration":
+		return true
+	}
+	return false
+}
+
+// javaTypePath returns the dotted name of a type declaration including all
+// enclosing types, e.g. "Outer.Inner".
+func javaTypePath(typeNode *sitter.Node, source []byte) string {
+	var parts []string
+	for n := typeNode; n != nil; n = n.Parent() {
+		if javaIsType(n.Type()) {
+			if name := n.ChildByFieldName("name"); name != nil {
+				parts = append(parts, NodeText(name, source))
+			}
+		}
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, ".")
+}
+
+// javaFindMemberOwner returns the dotted path of the type that directly
+// declares a method, constructor, field, or enum constant. Returns "" for
+// members of anonymous classes.
+func javaFindMemberOwner(node *sitter.Node, source []byte) string {
+	body := node.Parent()
+	if body != nil && body.Type() == "enum_body_declarations" {
+		body = body.Parent()
+	}
+	if body == nil {
+		return ""
+	}
+	switch body.Type() {
+	case "class_body", "interface_body", "enum_body", "annotation_type_body":
+	default:
+		return ""
+	}
+	decl := body.Parent()
+	if decl == nil || !javaIsType(decl.Type()) {
+		return ""
+	}
+	return javaTypePath(decl, source)
+}
+
+// javaFindEnclosingDef returns the qualified name of the method or constructor
+// containing the given call-site node (e.g., "Outer.Inner.run"). Lambdas and
+// methods of anonymous classes are transparent: their calls are attributed to
+// the nearest method of a named type. Returns "" for calls in field
+// initializers and static blocks.
+func javaFindEnclosingDef(node *sitter.Node, source []byte) string {
+	for current := node.Parent(); current != nil; current = current.Parent() {
+		switch current.Type() {
+		case "method_declaration", "constructor_declaration", "compact_constructor_declaration":
+			owner := javaFindMemberOwner(current, source)
+			name := current.ChildByFieldName("name")
+			if owner != "" && name != nil {
+				return owner + "." + NodeText(name, source)
+			}
+		}
+	}
+	return ""
+}
+
+func javaExtractSignature(defNode *sitter.Node, kind model.SymbolKind, source []byte) string {
+	switch kind {
+	case model.Class:
+		return javaExtractClassSignature(defNode, source)
+	case model.Field:
+		return javaExtractFieldSignature(defNode, source)
+	}
+	return javaExtractMethodSignature(defNode, source)
+}
+
+// javaExtractClassSignature returns the name, type parameters, record
+// components, and supertypes, e.g. "Repo<T> extends Base implements Store".
+func javaExtractClassSignature(node *sitter.Node, source []byte) string {
+	sig := javaNameText(node, source)
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "type_parameters", "formal_parameters":
+			sig += CollapseWhitespace(NodeText(child, source))
+		case "superclass", "super_interfaces", "extends_interfaces":
+			sig += " " + CollapseWhitespace(NodeText(child, source))
+		}
+	}
+	return sig
+}
+
+// javaExtractFieldSignature returns "Type name" for a field or interface
+// constant ("int a, b" when one declaration declares several) and the bare
+// name for an enum constant. Initializers are omitted.
+func javaExtractFieldSignature(node *sitter.Node, source []byte) string {
+	if node.Type() == "enum_constant" {
+		return javaNameText(node, source)
+	}
+	var names []string
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if child.Type() == "variable_declarator" {
+			names = append(names, javaNameText(child, source))
+		}
+	}
+	sig := strings.Join(names, ", ")
+	if t := node.ChildByFieldName("type"); t != nil {
+		sig = CollapseWhitespace(NodeText(t, source)) + " " + sig
+	}
+	return sig
+}
+
+// javaExtractMethodSignature returns type parameters, return type, name, and
+// parameters, e.g. "<T> List<T> wrap(T item)". Constructors have no return
+// type; compact record constructors have no parameter list.
+func javaExtractMethodSignature(node *sitter.Node, source []byte) string {
+	sig := javaNameText(node, source)
+	if params := node.ChildByFieldName("parameters"); params != nil {
+		sig += CollapseWhitespace(NodeText(params, source))
+	}
+	if ret := node.ChildByFieldName("type"); ret != nil {
+		sig = CollapseWhitespace(NodeText(ret, source)) + " " + sig
+	}
+	if tp := node.ChildByFieldName("type_parameters"); tp != nil {
+		sig = CollapseWhitespace(NodeText(tp, source)) + " " + sig
+	}
+	return sig
+}
+
+// javaNameText returns the text of a node's "name" field, or "" if absent.
+func javaNameText(node *sitter.Node, source []byte) string {
+	if name := node.ChildByFieldName("name"); name != nil {
+		return NodeText(name, source)
+	}
+	return ""
+}
+
+// javaMappingAnnotation matches Spring request mapping annotations.
+var javaMappingAnnotation = regexp.MustCompile(`@(Get|Post|Put|Patch|Delete|Request)Mapping\b`)
+
+// javaIsEntrypoint reports whether a method is a static main or a Spring
+// request handler (@GetMapping, @RequestMapping, and so on).
+func javaIsEntrypoint(node *sitter.Node, name string, source []byte) bool {
+	if node.Type() != "method_declaration" {
+		return false
+	}
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		c := node.NamedChild(i)
+		if c.Type() != "modifiers" {
+			continue
+		}
+		mods := NodeText(c, source)
+		if name == "main" && strings.Contains(mods, "static") {
+			return true
+		}
+		return javaMappingAnnotation.MatchString(mods)
+	}
+	return false
+}
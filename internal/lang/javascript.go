@@ -0,0 +1,290 @@
+package lang
+
+import (
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/javascript"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func init() {
+	Languages["javascript"] = &Language{
+		Name:              "javascript",
+		Extensions:        []string{".js", ".jsx", ".mjs", ".cjs"},
+		lang:              javascript.GetLanguage(),
+		FindMethodClass:   jsFindMethodClass,
+		ExtractSignature:  jsExtractSignature,
+		FindEnclosingDef:  jsFindEnclosingDef,
+		FindEnclosingType: jsFindEnclosingType,
+		IsExported:        jsIsExported,
+	}
+}
+
+// The hooks below are shared by JavaScript and TypeScript: the TypeScript
+// grammar extends the JavaScript one, so the node types used here are the
+// same in both. TypeScript-only nodes (type annotations, interfaces) simply
+// never appear in JavaScript trees.
+
+// jsIsClass reports whether a node type is a named class-like container.
+func jsIsClass(nodeType string) bool {
+	switch nodeType {
+	case "class_declaration", "class", "abstract_class_declaration":
+		return true
+	}
+	return false
+}
+
+// jsNameText returns the text of a node's "name" field, or "" if absent.
+func jsNameText(node *sitter.Node, source []byte) string {
+	if name := node.ChildByFieldName("name"); name != nil {
+		return NodeText(name, source)
+	}
+	return ""
+}
+
+// jsFindMethodClass returns the enclosing class name for a method_definition
+// or abstract_method_signature node. Returns "" for plain functions and for
+// methods of object literals or anonymous class expressions.
+func jsFindMethodClass(funcNode *sitter.Node, source []byte) string {
+	switch funcNode.Type() {
+	case "method_definition", "abstract_method_signature":
+	default:
+		return ""
+	}
+	body := funcNode.Parent()
+	if body == nil || body.Type() != "class_body" {
+		return ""
+	}
+	cls := body.Parent()
+	if cls == nil || !jsIsClass(cls.Type()) {
+		return ""
+	}
+	return jsNameText(cls, source)
+}
+
+// jsFindEnclosingDef returns the qualified name of the function or method
+// containing the given call-site node (e.g., "Server.handle" or "greet").
+// Anonymous arrow functions and function expressions (callbacks) are
+// transparent: calls inside them are attributed to the nearest named
+// function, since callbacks are how most JavaScript work gets done. Arrow
+// functions assigned to a variable are named definitions in their own right.
+// Returns "" if the call is at module top-level.
+func jsFindEnclosingDef(node *sitter.Node, source []byte) string {
+	current := node.Parent()
+	for current != nil {
+		switch current.Type() {
+		case "function_declaration", "generator_function_declaration":
+			return jsNameText(current, source)
+		case "method_definition":
+			name := jsNameText(current, source)
+			if cls := jsFindMethodClass(current, source); cls != "" && name != "" {
+				return cls + "." + name
+			}
+			return name
+		case "arrow_function", "function_expression":
+			if parent := current.Parent(); parent != nil && parent.Type() == "variable_declarator" {
+				return jsNameText(parent, source)
+			}
+		}
+		current = current.Parent()
+	}
+	return ""
+}
+
+// jsFindEnclosingType walks up from a field node to the class or interface
+// that declares it and returns its name. Returns "" if not found.
+func jsFindEnclosingType(node *sitter.Node, source []byte) string {
+	current := node.Parent()
+	for current != nil {
+		if jsIsClass(current.Type()) || current.Type() == "interface_declaration" {
+			return jsNameText(current, source)
+		}
+		current = current.Parent()
+	}
+	return ""
+}
+
+// jsIsExported reports whether a definition is part of its module's public
+// surface: a declaration under an export statement (including export
+// default), or one named in a local "export { ... }" list. A class or
+// interface member is exported when its owner is, unless it is private
+// (#name, or a TypeScript private or protected modifier). Declarations nested
+// in functions are never exported. A file with no export statements at all is
+// a script or a CommonJS module, whose top-level definitions are all treated
+// as exported.
+func jsIsExported(node *sitter.Node, _ string, source []byte) bool {
+	if body := node.Parent(); body != nil {
+		switch body.Type() {
+		case "class_body", "interface_body", "object_type":
+			if jsIsPrivateMember(node, source) {
+				return false
+			}
+			if owner := body.Parent(); owner != nil {
+				return jsIsExported(owner, "", source)
+			}
+			return true
+		}
+	}
+	name := jsNameText(node, source)
+	for decl := node; ; {
+		parent := decl.Parent()
+		if parent == nil {
+			return true
+		}
+		switch parent.Type() {
+		case "variable_declarator":
+			// const Foo = class { ... }: the class takes the variable's name.
+			if name == "" {
+				name = jsNameText(parent, source)
+			}
+			decl = parent
+			continue
+		case "lexical_declaration", "variable_declaration", "ambient_declaration":
+			decl = parent
+			continue
+		case "export_statement":
+			return true
+		case "program":
+			return jsExportedByName(parent, name, source)
+		}
+		return false
+	}
+}
+
+// jsIsPrivateMember reports whether a class member is private: a #name, or
+// one with a TypeScript private or protected accessibility modifier.
+func jsIsPrivateMember(node *sitter.Node, source []byte) bool {
+	name := node.ChildByFieldName("name")
+	if name == nil {
+		name = node.ChildByFieldName("property") // JavaScript field_definition
+	}
+	if name != nil && name.Type() == "private_property_identifier" {
+		return true
+	}
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if child.Type() == "accessibility_modifier" {
+			switch NodeText(child, source) {
+			case "private", "protected":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsExportedByName reports whether a top-level name is exported by a local
+// "export { name }" list or "export default name" in program, or whether
+// program has no export statements at all (see jsIsExported). Re-exports
+// from other modules ("export { x } from ...") export that module's names,
+// not this one's.
+func jsExportedByName(program *sitter.Node, name string, source []byte) bool {
+	hasExports := false
+	for i := 0; i < int(program.NamedChildCount()); i++ {
+		stmt := program.NamedChild(i)
+		if stmt.Type() != "export_statement" {
+			continue
+		}
+		hasExports = true
+		if stmt.ChildByFieldName("source") != nil {
+			continue
+		}
+		if value := stmt.ChildByFieldName("value"); value != nil && value.Type() == "identifier" && NodeText(value, source) == name {
+			return true
+		}
+		for j := 0; j < int(stmt.NamedChildCount()); j++ {
+			clause := stmt.NamedChild(j)
+			if clause.Type() != "export_clause" {
+				continue
+			}
+			for k := 0; k < int(clause.NamedChildCount()); k++ {
+				if local := clause.NamedChild(k).ChildByFieldName("name"); local != nil && NodeText(local, source) == name {
+					return true
+				}
+			}
+		}
+	}
+	return !hasExports
+}
+
+func jsExtractSignature(defNode *sitter.Node, kind model.SymbolKind, source []byte) string {
+	switch kind {
+	case model.Class:
+		return jsExtractClassSignature(defNode, source)
+	case model.Field:
+		if defNode.Type() == "method_signature" {
+			return jsExtractFunctionSignature(defNode, source)
+		}
+		return jsExtractFieldSignature(defNode, source)
+	}
+	return jsExtractFunctionSignature(defNode, source)
+}
+
+// jsExtractClassSignature returns the name, type parameters, and heritage of a
+// class, interface, type alias, or enum, e.g. "Server extends Base implements I".
+func jsExtractClassSignature(node *sitter.Node, source []byte) string {
+	sig := jsNameText(node, source)
+	if tp := node.ChildByFieldName("type_parameters"); tp != nil {
+		sig += CollapseWhitespace(NodeText(tp, source))
+	}
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "class_heritage", "extends_type_clause":
+			sig += " " + CollapseWhitespace(NodeText(child, source))
+		}
+	}
+	return sig
+}
+
+// jsExtractFieldSignature returns "name: type" for a class field or interface
+// property, or just the name when there is no type annotation. Initializers
+// are omitted.
+func jsExtractFieldSignature(node *sitter.Node, source []byte) string {
+	name := node.ChildByFieldName("name")
+	if name == nil {
+		name = node.ChildByFieldName("property") // JavaScript field_definition
+	}
+	if name == nil {
+		return CollapseWhitespace(NodeText(node, source))
+	}
+	sig := NodeText(name, source)
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if node.Child(i).Type() == "?" {
+			sig += "?"
+			break
+		}
+	}
+	if t := node.ChildByFieldName("type"); t != nil {
+		sig += CollapseWhitespace(NodeText(t, source))
+	}
+	return sig
+}
+
+// jsExtractFunctionSignature returns name, type parameters, parameters, and
+// return type. For a variable_declarator the name comes from the variable and
+// the rest from the assigned arrow function or function expression.
+func jsExtractFunctionSignature(node *sitter.Node, source []byte) string {
+	name := jsNameText(node, source)
+	fn := node
+	if node.Type() == "variable_declarator" {
+		if value := node.ChildByFieldName("value"); value != nil {
+			fn = value
+		}
+	}
+
+	sig := name
+	if tp := fn.ChildByFieldName("type_parameters"); tp != nil {
+		sig += CollapseWhitespace(NodeText(tp, source))
+	}
+	if params := fn.ChildByFieldName("parameters"); params != nil {
+		sig += CollapseWhitespace(NodeText(params, source))
+	} else if param := fn.ChildByFieldName("parameter"); param != nil {
+		// Single-parameter arrow function without parentheses: x => ...
+		sig += "(" + NodeText(param, source) + ")"
+	}
+	if ret := fn.ChildByFieldName("return_type"); ret != nil {
+		sig += CollapseWhitespace(NodeText(ret, source))
+	}
+	return sig
+}
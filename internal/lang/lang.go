@@ -5,6 +5,7 @@ package lang
 import (
 	"embed"
 	"fmt"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
@@ -28,6 +29,20 @@ type Language struct {
 	query      *sitter.Query
 	queryErr   error
 
+	// dialects maps file extensions to alternate grammars of this language
+	// (e.g. TSX for .tsx files). Each dialect shares the language's name,
+	// hooks, and query file but compiles its own query.
+	dialects map[string]*Language
+
+	// Separator joins an owner and a member name in qualified names
+	// ("Server.Handle"). Empty means ".".
+	Separator string
+
+	// NameText returns the symbol name for a captured @name node, for
+	// languages whose name nodes need more than their source text (e.g. the
+	// member name of a C++ qualified_identifier). Nil uses the node text.
+	NameText func(node *sitter.Node, source []byte) string
+
 	// FindMethodClass returns the enclosing class name if a @definition.function
 	// is actually a method (Python/Ruby style). Returns "" if not a method.
 	FindMethodClass func(node *sitter.Node, source []byte) string
@@ -44,9 +59,47 @@ type Language struct {
 	// Returns "" if the call is at top-level or inside an anonymous function.
 	FindEnclosingDef func(node *sitter.Node, source []byte) string
 
+	// QualifyCall returns the qualified name of the method a call-site node
+	// invokes when the receiver's type is known from context (in Go, a call
+	// through the enclosing method's own receiver, such as s.flush() in a
+	// *Server method, gives "Server.flush"). Returns "" to keep the bare name.
+	QualifyCall func(node *sitter.Node, name string, source []byte) string
+
+	// QualifyClass returns the fully qualified name of a class definition
+	// node, including its enclosing namespaces (e.g. "Billing::Invoice" for a
+	// Ruby class nested in a module). Returns "" to keep the captured name.
+	QualifyClass func(node *sitter.Node, source []byte) string
+
+	// ExtractDoc returns the first sentence of a definition's documentation
+	// (a Python docstring, a Go doc comment), or "" if it has none.
+	ExtractDoc func(node *sitter.Node, source []byte) string
+
+	// PackageClause returns the package a file declares and the first
+	// sentence of the doc comment on its package clause (Go), given the root
+	// of the syntax tree. Nil means the language has no package clause.
+	PackageClause func(root *sitter.Node, source []byte) (name, doc string)
+
+	// FileImports returns the files a file loads by path (Ruby's require and
+	// require_relative), given the root of the syntax tree. Nil means the
+	// language's dependencies come from symbol references alone.
+	FileImports func(root *sitter.Node, source []byte) []model.Import
+
+	// IsEntrypoint reports whether a function or method definition node
+	// (with its unqualified name) is a likely entrypoint: a main function,
+	// an HTTP route handler, or a CLI command.
+	IsEntrypoint func(node *sitter.Node, name string, source []byte) bool
+
+	// IsExported reports whether a definition node, with its qualified name,
+	// is part of the public API under the language's visibility rules (Go
+	// capitalization, Python's leading underscore, Ruby's private, the
+	// export keyword in JavaScript and TypeScript). Nil treats every
+	// definition as exported.
+	IsExported func(node *sitter.Node, name string, source []byte) bool
+
 	// FindEnclosingType returns the type name that owns a field/member node
-	// (e.g. the struct or class containing a field declaration). Returns ""
-	// if the node is not inside a named type definition.
+	// (e.g. the struct or class containing a field declaration), or the type
+	// a constant belongs to. Returns "" if the node is not inside a named
+	// type definition.
 	FindEnclosingType func(node *sitter.Node, source []byte) string
 }
 
@@ -55,6 +108,15 @@ func (l *Language) GetLanguage() *sitter.Language {
 	return l.lang
 }
 
+// ForFile returns the Language to use for parsing path: the dialect registered
+// for its extension if there is one, otherwise l itself.
+func (l *Language) ForFile(path string) *Language {
+	if d, ok := l.dialects[filepath.Ext(path)]; ok {
+		return d
+	}
+	return l
+}
+
 // NewParser creates a fresh tree-sitter parser for this language.
 // Each goroutine must use its own parser (not thread-safe).
 func (l *Language) NewParser() *sitter.Parser {
@@ -63,12 +125,22 @@ func (l *Language) NewParser() *sitter.Parser {
 	return p
 }
 
+// QuerySource returns the text of the embedded tree-sitter query file that
+// GetTagQuery compiles.
+func (l *Language) QuerySource() ([]byte, error) {
+	data, err := queryFS.ReadFile(fmt.Sprintf("queries/%s.scm", l.Name))
+	if err != nil {
+		return nil, fmt.Errorf("reading query file: %w", err)
+	}
+	return data, nil
+}
+
 // GetTagQuery returns the compiled tree-sitter query (safe to share across goroutines).
 func (l *Language) GetTagQuery() (*sitter.Query, error) {
 	l.queryOnce.Do(func() {
-		data, err := queryFS.ReadFile(fmt.Sprintf("queries/%s.scm", l.Name))
+		data, err := l.QuerySource()
 		if err != nil {
-			l.queryErr = fmt.Errorf("reading query file: %w", err)
+			l.queryErr = err
 			return
 		}
 		q, err := sitter.NewQuery(data, l.lang)
@@ -86,8 +158,10 @@ func (l *Language) GetTagQuery() (*sitter.Query, error) {
 var Languages = map[string]*Language{}
 
 // extensionMap is built lazily after all init() functions have run.
+// MapExtension may extend it later, so access is guarded by extensionMu.
 var extensionMap map[string]string
 var extensionOnce sync.Once
+var extensionMu sync.RWMutex
 
 func getExtensionMap() map[string]string {
 	extensionOnce.Do(func() {
@@ -103,7 +177,26 @@ func getExtensionMap() map[string]string {
 
 // ForExtension returns the language name for a file extension, or "" if unsupported.
 func ForExtension(ext string) string {
-	return getExtensionMap()[ext]
+	m := getExtensionMap()
+	extensionMu.RLock()
+	defer extensionMu.RUnlock()
+	return m[ext]
+}
+
+// MapExtension maps a file extension (".pyi") to a registered language for
+// the rest of the process, adding to or overriding the built-in mapping.
+func MapExtension(ext, name string) error {
+	if len(ext) < 2 || ext[0] != '.' || strings.ContainsAny(ext[1:], `./\`) {
+		return fmt.Errorf("invalid extension %q (want e.g. .pyi)", ext)
+	}
+	if _, ok := Languages[name]; !ok {
+		return fmt.Errorf("unsupported language %q", name)
+	}
+	m := getExtensionMap()
+	extensionMu.Lock()
+	defer extensionMu.Unlock()
+	m[ext] = name
+	return nil
 }
 
 // NodeText returns the source text of a tree-sitter node.
@@ -115,3 +208,22 @@ func NodeText(node *sitter.Node, source []byte) string {
 func CollapseWhitespace(s string) string {
 	return strings.TrimSpace(whitespaceRe.ReplaceAllString(s, " "))
 }
+
+// FirstSentence returns the first sentence of a doc comment's first
+// paragraph, with whitespace collapsed: everything up to and including the
+// first ". ", or the whole paragraph if it has none.
+func FirstSentence(doc string) string {
+	var para []string
+	for _, line := range strings.Split(strings.TrimSpace(doc), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		para = append(para, line)
+	}
+	text := strings.Join(para, " ")
+	if i := strings.Index(text, ". "); i >= 0 {
+		text = text[:i+1]
+	}
+	return CollapseWhitespace(text)
+}
@@ -14,7 +14,16 @@ func TestForExtension(t *testing.T) {
 		{".py", "python"},
 		{".go", "go"},
 		{".rb", "ruby"},
-		{".js", ""},
+		{".ts", "typescript"},
+		{".tsx", "typescript"},
+		{".js", "javascript"},
+		{".jsx", "javascript"},
+		{".java", "java"},
+		{".cpp", "cpp"},
+		{".cc", "cpp"},
+		{".hpp", "cpp"},
+		{".h", "cpp"},
+		{".kt", ""},
 		{"", ""},
 	}
 
@@ -32,7 +41,7 @@ func TestForExtension(t *testing.T) {
 func TestLanguagesRegistered(t *testing.T) {
 	t.Parallel()
 
-	for _, name := range []string{"python", "go", "ruby"} {
+	for _, name := range []string{"python", "go", "ruby", "typescript", "javascript", "java", "cpp"} {
 		l, ok := Languages[name]
 		if !ok {
 			t.Errorf("%s language not registered", name)
@@ -47,7 +56,7 @@ func TestLanguagesRegistered(t *testing.T) {
 func TestNewParser(t *testing.T) {
 	t.Parallel()
 
-	for _, name := range []string{"python", "go", "ruby"} {
+	for _, name := range []string{"python", "go", "ruby", "typescript", "javascript", "java", "cpp"} {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 			l := Languages[name]
@@ -62,7 +71,7 @@ func TestNewParser(t *testing.T) {
 func TestGetTagQuery(t *testing.T) {
 	t.Parallel()
 
-	for _, name := range []string{"python", "go", "ruby"} {
+	for _, name := range []string{"python", "go", "ruby", "typescript", "javascript", "java", "cpp"} {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 			l := Languages[name]
@@ -76,3 +85,67 @@ func TestGetTagQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestForFileDialect(t *testing.T) {
+	t.Parallel()
+
+	ts := Languages["typescript"]
+	if got := ts.ForFile("src/app.ts"); got != ts {
+		t.Error("ForFile(.ts) should return the base language")
+	}
+	tsx := ts.ForFile("src/App.tsx")
+	if tsx == ts {
+		t.Fatal("ForFile(.tsx) should return the TSX dialect")
+	}
+	if tsx.Name != "typescript" {
+		t.Errorf("dialect name = %q, want typescript", tsx.Name)
+	}
+	if _, err := tsx.GetTagQuery(); err != nil {
+		t.Errorf("GetTagQuery for TSX dialect: %v", err)
+	}
+}
+
+func TestFirstSentence(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{"Encode converts a map. It is fast.", "Encode converts a map."},
+		{"  Spans\n  two lines. Then more.", "Spans two lines."},
+		{"No period here\n\nSecond paragraph.", "No period here"},
+		{"Ends with a period.", "Ends with a period."},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := FirstSentence(tt.doc); got != tt.want {
+			t.Errorf("FirstSentence(%q) = %q, want %q", tt.doc, got, tt.want)
+		}
+	}
+}
+
+func TestMapExtension(t *testing.T) {
+	t.Parallel()
+
+	if err := MapExtension(".rgstub", "python"); err != nil {
+		t.Fatalf("MapExtension: %v", err)
+	}
+	if got := ForExtension(".rgstub"); got != "python" {
+		t.Errorf("ForExtension(.rgstub) = %q, want python", got)
+	}
+	if got := ForExtension(".py"); got != "python" {
+		t.Errorf("built-in mapping lost: ForExtension(.py) = %q", got)
+	}
+
+	for _, tt := range []struct{ ext, name string }{
+		{".rgbad", "cobol"},
+		{"pyi", "python"},
+		{".", "python"},
+		{".d.ts", "typescript"},
+	} {
+		if err := MapExtension(tt.ext, tt.name); err == nil {
+			t.Errorf("MapExtension(%q, %q) should fail", tt.ext, tt.name)
+		}
+	}
+}
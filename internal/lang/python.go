@@ -1,6 +1,9 @@
 package lang
 
 import (
+	"regexp"
+	"strings"
+
 	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/smacker/go-tree-sitter/python"
 
@@ -14,9 +17,26 @@ func init() {
 		lang:              python.GetLanguage(),
 		FindMethodClass:   pythonFindMethodClass,
 		ExtractSignature:  pythonExtractSignature,
+		ExtractDoc:        pythonExtractDoc,
+		IsEntrypoint:      pythonIsEntrypoint,
+		IsExported:        pythonIsExported,
 		FindEnclosingDef:  pythonFindEnclosingDef,
 		FindEnclosingType: pythonFindEnclosingType,
+		QualifyClass:      pythonClassPath,
+	}
+}
+
+// pythonIsExported reports whether no part of a qualified name is private by
+// convention: a leading underscore marks it private, except for dunder
+// names such as __init__, which are part of a class's interface.
+func pythonIsExported(_ *sitter.Node, name string, _ []byte) bool {
+	for _, part := range strings.Split(name, ".") {
+		dunder := len(part) > 4 && strings.HasPrefix(part, "__") && strings.HasSuffix(part, "__")
+		if strings.HasPrefix(part, "_") && !dunder {
+			return false
+		}
 	}
+	return true
 }
 
 // pythonFindEnclosingDef returns the qualified name of the function or method
@@ -38,11 +58,8 @@ func pythonFindEnclosingDef(node *sitter.Node, source []byte) string {
 				return ""
 			}
 			if cls := pythonFindEnclosingClass(current); cls != nil {
-				for i := 0; i < int(cls.ChildCount()); i++ {
-					child := cls.Child(i)
-					if child.Type() == "identifier" {
-						return NodeText(child, source) + "." + funcName
-					}
+				if path := pythonClassPath(cls, source); path != "" {
+					return path + "." + funcName
 				}
 			}
 			return funcName
@@ -52,18 +69,37 @@ func pythonFindEnclosingDef(node *sitter.Node, source []byte) string {
 	return ""
 }
 
+// pythonFindMethodClass returns the dotted class path of the class a function
+// is defined in (e.g. "Outer.Inner"), or "" if it is not a method.
 func pythonFindMethodClass(funcNode *sitter.Node, source []byte) string {
 	classNode := pythonFindEnclosingClass(funcNode)
 	if classNode == nil {
 		return ""
 	}
-	for i := 0; i < int(classNode.ChildCount()); i++ {
-		child := classNode.Child(i)
-		if child.Type() == "identifier" {
-			return NodeText(child, source)
+	return pythonClassPath(classNode, source)
+}
+
+// pythonClassPath returns the name of a class_definition prefixed with the
+// names of every class it is nested in, e.g. "Outer.Inner". Enclosing
+// functions are skipped: a class defined inside a method of Outer is
+// "Outer.Local".
+func pythonClassPath(classNode *sitter.Node, source []byte) string {
+	var path string
+	for n := classNode; n != nil; n = n.Parent() {
+		if n.Type() != "class_definition" {
+			continue
+		}
+		name := n.ChildByFieldName("name")
+		if name == nil {
+			return ""
+		}
+		if path == "" {
+			path = NodeText(name, source)
+		} else {
+			path = NodeText(name, source) + "." + path
 		}
 	}
-	return ""
+	return path
 }
 
 func pythonFindEnclosingClass(funcNode *sitter.Node) *sitter.Node {
@@ -89,6 +125,10 @@ func pythonFindEnclosingClass(funcNode *sitter.Node) *sitter.Node {
 }
 
 func pythonExtractSignature(defNode *sitter.Node, kind model.SymbolKind, source []byte) string {
+	if kind == model.Class && defNode.Type() != "class_definition" {
+		// A type alias statement or TypeAlias assignment: the whole line.
+		return CollapseWhitespace(NodeText(defNode, source))
+	}
 	if kind == model.Class {
 		return pythonExtractClassSignature(defNode, source)
 	}
@@ -116,19 +156,14 @@ func pythonExtractClassSignature(node *sitter.Node, source []byte) string {
 }
 
 // pythonFindEnclosingType walks up from a field node to find the enclosing
-// class_definition and returns its name. Returns "" if not inside a class,
+// class_definition and returns its dotted class path. Returns "" if not inside a class,
 // or if inside a function/method body (not a class-level attribute).
 func pythonFindEnclosingType(node *sitter.Node, source []byte) string {
 	current := node.Parent()
 	for current != nil {
 		switch current.Type() {
 		case "class_definition":
-			for i := 0; i < int(current.ChildCount()); i++ {
-				child := current.Child(i)
-				if child.Type() == "identifier" {
-					return NodeText(child, source)
-				}
-			}
+			return pythonClassPath(current, source)
 		case "function_definition":
 			// Inside a method body — not a class-level attribute.
 			return ""
@@ -166,11 +201,16 @@ func pythonExtractFieldSignature(node *sitter.Node, source []byte) string {
 	return CollapseWhitespace(NodeText(node, source))
 }
 
+// pythonExtractFunctionSignature returns "name(params) -> return", prefixed
+// with "async " for coroutines (the grammar marks them with an "async" child
+// token on the same function_definition node).
 func pythonExtractFunctionSignature(node *sitter.Node, source []byte) string {
-	var name, params, returnType string
+	var prefix, name, params, returnType string
 	for i := 0; i < int(node.ChildCount()); i++ {
 		child := node.Child(i)
 		switch child.Type() {
+		case "async":
+			prefix = "async "
 		case "identifier":
 			name = NodeText(child, source)
 		case "parameters":
@@ -179,9 +219,53 @@ func pythonExtractFunctionSignature(node *sitter.Node, source []byte) string {
 			returnType = NodeText(child, source)
 		}
 	}
-	sig := name + params
+	sig := prefix + name + params
 	if returnType != "" {
 		sig += " -> " + returnType
 	}
 	return sig
 }
+
+// pythonExtractDoc returns the first sentence of a class or function
+// docstring: a string literal that is the first statement of its body.
+func pythonExtractDoc(node *sitter.Node, source []byte) string {
+	body := node.ChildByFieldName("body")
+	if body == nil || body.NamedChildCount() == 0 {
+		return ""
+	}
+	stmt := body.NamedChild(0)
+	if stmt.Type() != "expression_statement" || stmt.NamedChildCount() == 0 {
+		return ""
+	}
+	str := stmt.NamedChild(0)
+	if str.Type() != "string" {
+		return ""
+	}
+	var doc strings.Builder
+	for i := 0; i < int(str.NamedChildCount()); i++ {
+		if c := str.NamedChild(i); c.Type() == "string_content" {
+			doc.WriteString(NodeText(c, source))
+		}
+	}
+	return FirstSentence(doc.String())
+}
+
+// pythonEntrypointDecorator matches decorators of web route handlers
+// (@app.route("/"), @router.get("/items")) and CLI commands (@click.command(),
+// @app.command()).
+var pythonEntrypointDecorator = regexp.MustCompile(`^@(\w[\w.]*\.(route|get|post|put|patch|delete|websocket|command|group)\(|(click\.)?(command|group)\b)`)
+
+// pythonIsEntrypoint reports whether a function is a module-level main, a
+// route handler, or a CLI command, judged by its decorators.
+func pythonIsEntrypoint(node *sitter.Node, name string, source []byte) bool {
+	parent := node.Parent()
+	if parent != nil && parent.Type() == "decorated_definition" {
+		for i := 0; i < int(parent.NamedChildCount()); i++ {
+			if d := parent.NamedChild(i); d.Type() == "decorator" && pythonEntrypointDecorator.MatchString(NodeText(d, source)) {
+				return true
+			}
+		}
+		parent = parent.Parent()
+	}
+	return name == "main" && parent != nil && parent.Type() == "module"
+}
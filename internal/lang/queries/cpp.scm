@@ -0,0 +1,89 @@
+;; Classes, structs, and unions with a body (forward declarations are skipped)
+(class_specifier
+  name: (type_identifier) @name
+  body: (field_declaration_list)) @definition.class
+
+(struct_specifier
+  name: (type_identifier) @name
+  body: (field_declaration_list)) @definition.class
+
+(union_specifier
+  name: (type_identifier) @name
+  body: (field_declaration_list)) @definition.class
+
+;; Function definitions: free functions, methods defined in a class body, and
+;; out-of-line definitions (void Widget::draw() {}), whose qualified_identifier
+;; name is reduced to the member name. Pointer and reference return types wrap
+;; the function_declarator.
+(function_definition
+  declarator: [
+    (function_declarator declarator: (_) @name)
+    (pointer_declarator declarator: (function_declarator declarator: (_) @name))
+    (reference_declarator (function_declarator declarator: (_) @name))
+  ]) @definition.function
+
+;; Method declarations in a class body (defined out of line or pure virtual)
+(field_declaration_list
+  (field_declaration
+    declarator: [
+      (function_declarator declarator: (_) @name)
+      (pointer_declarator declarator: (function_declarator declarator: (_) @name))
+      (reference_declarator (function_declarator declarator: (_) @name))
+    ]) @definition.function)
+
+;; Constructors and destructors declared in a class body
+(field_declaration_list
+  (declaration
+    declarator: (function_declarator declarator: (_) @name)) @definition.function)
+
+;; Free function prototypes at file or namespace scope
+(translation_unit
+  (declaration
+    declarator: [
+      (function_declarator declarator: (identifier) @name)
+      (pointer_declarator declarator: (function_declarator declarator: (identifier) @name))
+      (reference_declarator (function_declarator declarator: (identifier) @name))
+    ]) @definition.function)
+
+(declaration_list
+  (declaration
+    declarator: [
+      (function_declarator declarator: (identifier) @name)
+      (pointer_declarator declarator: (function_declarator declarator: (identifier) @name))
+      (reference_declarator (function_declarator declarator: (identifier) @name))
+    ]) @definition.function)
+
+;; Data members
+(field_declaration_list
+  (field_declaration
+    declarator: [
+      (field_identifier) @name
+      (pointer_declarator declarator: (field_identifier) @name)
+      (reference_declarator (field_identifier) @name)
+      (array_declarator declarator: (field_identifier) @name)
+    ]) @definition.field)
+
+;; Includes
+(preproc_include
+  path: (string_literal (string_content) @name)) @reference.import
+
+(preproc_include
+  path: (system_lib_string) @name) @reference.import
+
+;; Calls: free (helper()), member (obj.draw(), ptr->draw()), qualified
+;; (ns::helper()), and template (make<T>()) calls, plus new expressions
+(call_expression
+  function: (identifier) @name) @reference.call
+
+(call_expression
+  function: (field_expression
+    field: (field_identifier) @name)) @reference.call
+
+(call_expression
+  function: (qualified_identifier) @name) @reference.call
+
+(call_expression
+  function: (template_function) @name) @reference.call
+
+(new_expression
+  type: (type_identifier) @name) @reference.call
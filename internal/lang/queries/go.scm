@@ -9,11 +9,52 @@
       (field_declaration
         name: (field_identifier) @name) @definition.field)))
 
+;; Embedded struct fields, named after the embedded type (io.Reader → Reader)
+(type_spec
+  (struct_type
+    (field_declaration_list
+      (field_declaration
+        !name
+        type: [
+          (type_identifier) @name
+          (qualified_type
+            name: (type_identifier) @name)
+          (generic_type
+            type: (type_identifier) @name)
+        ]) @definition.field)))
+
+;; Embedding also references the embedded type, so it creates a dependency
+(field_declaration
+  !name
+  type: [
+    (type_identifier) @name
+    (generic_type
+      type: (type_identifier) @name)
+  ]) @reference.type
+
 ;; Interface methods
 (type_spec
   (interface_type
     (method_elem
-      name: (field_identifier) @name) @definition.field))
+      name: (field_identifier) @name) @definition.interface_method))
+
+;; Package-level constants (each name in a group gets its own tag)
+(source_file
+  (const_declaration
+    (const_spec
+      name: (identifier) @name) @definition.constant))
+
+;; Package-level variables
+(source_file
+  (var_declaration
+    (var_spec
+      name: (identifier) @name) @definition.variable))
+
+(source_file
+  (var_declaration
+    (var_spec_list
+      (var_spec
+        name: (identifier) @name) @definition.variable)))
 
 ;; Function declarations
 (function_declaration
@@ -31,6 +72,17 @@
       field: (field_identifier) @name)
   ]) @reference.call
 
+;; Generic calls with a single type argument, parse[int](x) or pkg.Do[T](y),
+;; are indistinguishable from conversions to a generic type and parse as one;
+;; the base identifier is the callee either way
+(type_conversion_expression
+  type: (generic_type
+    type: [
+      (type_identifier) @name
+      (qualified_type
+        name: (type_identifier) @name)
+    ])) @reference.call
+
 ;; Import paths
 (import_spec
   path: (interpreted_string_literal) @name) @reference.import
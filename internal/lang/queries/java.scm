@@ -0,0 +1,81 @@
+;; Class, interface, enum, and record declarations
+(class_declaration
+  name: (identifier) @name) @definition.class
+
+(interface_declaration
+  name: (identifier) @name) @definition.class
+
+(enum_declaration
+  name: (identifier) @name) @definition.class
+
+(record_declaration
+  name: (identifier) @name) @definition.class
+
+;; Fields, interface constants, and enum constants
+(class_body
+  (field_declaration
+    declarator: (variable_declarator
+      name: (identifier) @name)) @definition.field)
+
+(enum_body_declarations
+  (field_declaration
+    declarator: (variable_declarator
+      name: (identifier) @name)) @definition.field)
+
+(interface_body
+  (constant_declaration
+    declarator: (variable_declarator
+      name: (identifier) @name)) @definition.field)
+
+(enum_body
+  (enum_constant
+    name: (identifier) @name) @definition.field)
+
+;; Methods and constructors (qualified with the class path by FindMethodClass).
+;; Only members of named types are matched; anonymous class bodies are skipped.
+(class_declaration
+  body: (class_body
+    [
+      (method_declaration name: (identifier) @name)
+      (constructor_declaration name: (identifier) @name)
+    ] @definition.function))
+
+(record_declaration
+  body: (class_body
+    [
+      (method_declaration name: (identifier) @name)
+      (constructor_declaration name: (identifier) @name)
+      (compact_constructor_declaration name: (identifier) @name)
+    ] @definition.function))
+
+(enum_declaration
+  body: (enum_body
+    (enum_body_declarations
+      [
+        (method_declaration name: (identifier) @name)
+        (constructor_declaration name: (identifier) @name)
+      ] @definition.function)))
+
+(interface_declaration
+  body: (interface_body
+    (method_declaration
+      name: (identifier) @name) @definition.function))
+
+;; Method calls
+(method_invocation
+  name: (identifier) @name) @reference.call
+
+;; Constructor calls: new Foo(), new Foo<T>()
+(object_creation_expression
+  type: (type_identifier) @name) @reference.call
+
+(object_creation_expression
+  type: (generic_type
+    (type_identifier) @name)) @reference.call
+
+;; Import references: import com.foo.Bar; yields "Bar".
+;; Static imports yield the member name; wildcard imports yield the package's
+;; last segment, which never resolves to a definition.
+(import_declaration
+  (scoped_identifier
+    name: (identifier) @name)) @reference.import
@@ -2,17 +2,47 @@
 (class_definition
   name: (identifier) @name) @definition.class
 
+;; Module-level type aliases: type Point = tuple[float, float] (Python 3.12)
+;; and Vector: TypeAlias = list[float]
+(module
+  (type_alias_statement
+    .
+    (type [
+      (identifier) @name
+      (generic_type (identifier) @name)
+    ])) @definition.class)
+
+(module
+  (expression_statement
+    (assignment
+      left: (identifier) @name
+      type: (type [
+        (identifier) @_alias
+        (attribute attribute: (identifier) @_alias)
+      ])
+      (#eq? @_alias "TypeAlias")) @definition.class))
+
 ;; Function/method definitions
 (function_definition
   name: (identifier) @name) @definition.function
 
-;; Class attribute assignments: x = value  OR  x: Type = value
+;; Class attribute assignments: x = value, x: Type = value, or a bare x: Type
+;; (dataclass and TypedDict fields)
 (class_definition
   body: (block
     (expression_statement
       (assignment
         left: (identifier) @name) @definition.field)))
 
+;; Script entrypoint: if __name__ == "__main__":
+(module
+  (if_statement
+    condition: (comparison_operator
+      (identifier) @_lhs
+      (string (string_content) @name))
+    (#eq? @_lhs "__name__")
+    (#eq? @name "__main__")) @entrypoint)
+
 ;; Function and method calls
 (call
   function: [
@@ -21,12 +51,23 @@
       attribute: (identifier) @name)
   ]) @reference.call
 
-;; Import references: from x import y
+;; Import references: from x import y, one per imported name. An aliased
+;; import (from x import y as z) references the real name y, not the alias.
 (import_from_statement
-  name: (dotted_name
-    (identifier) @name)) @reference.import
+  name: [
+    (dotted_name
+      (identifier) @name)
+    (aliased_import
+      name: (dotted_name
+        (identifier) @name))
+  ]) @reference.import
 
-;; Import references: import x
+;; Import references: import x, or import x as z (the real name x)
 (import_statement
-  name: (dotted_name
-    (identifier) @name)) @reference.import
+  name: [
+    (dotted_name
+      (identifier) @name)
+    (aliased_import
+      name: (dotted_name
+        (identifier) @name))
+  ]) @reference.import
@@ -1,11 +1,29 @@
-;; Class definitions
+;; Class definitions (qualified with enclosing namespaces by QualifyClass)
 (class
   name: (constant) @name) @definition.class
 
+;; Compact namespaced classes: class Billing::Invoice
+(class
+  name: (scope_resolution
+    name: (constant) @name)) @definition.class
+
 ;; Module definitions
 (module
   name: (constant) @name) @definition.class
 
+(module
+  name: (scope_resolution
+    name: (constant) @name)) @definition.class
+
+;; Constant assignments (MAX_RETRIES = 3, Billing::RATE = 2), qualified with
+;; enclosing namespaces by QualifyClass
+(assignment
+  left: (constant) @name) @definition.constant
+
+(assignment
+  left: (scope_resolution
+    name: (constant) @name)) @definition.constant
+
 ;; Method definitions
 (method
   name: (identifier) @name) @definition.function
@@ -18,8 +36,8 @@
 (call
   method: (identifier) @_attr_method
   arguments: (argument_list
-    (simple_symbol) @name)) @definition.field
-(#match? @_attr_method "^attr_(accessor|reader|writer)$")
+    (simple_symbol) @name)
+  (#match? @_attr_method "^attr_(accessor|reader|writer)$")) @definition.field
 
 ;; Method calls
 (call
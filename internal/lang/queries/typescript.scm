@@ -0,0 +1,83 @@
+;; Class definitions
+(class_declaration
+  name: (type_identifier) @name) @definition.class
+
+(abstract_class_declaration
+  name: (type_identifier) @name) @definition.class
+
+;; Interfaces, type aliases, and enums
+(interface_declaration
+  name: (type_identifier) @name) @definition.class
+
+(type_alias_declaration
+  name: (type_identifier) @name) @definition.class
+
+(enum_declaration
+  name: (identifier) @name) @definition.class
+
+;; Class fields
+(class_body
+  (public_field_definition
+    name: (property_identifier) @name) @definition.field)
+
+;; Interface properties and methods
+(interface_body
+  (property_signature
+    name: (property_identifier) @name) @definition.field)
+
+(interface_body
+  (method_signature
+    name: (property_identifier) @name) @definition.field)
+
+;; Function declarations
+(function_declaration
+  name: (identifier) @name) @definition.function
+
+(generator_function_declaration
+  name: (identifier) @name) @definition.function
+
+;; Class methods (qualified with the class name by FindMethodClass)
+(method_definition
+  name: [
+    (property_identifier)
+    (private_property_identifier)
+  ] @name) @definition.function
+
+(abstract_method_signature
+  name: (property_identifier) @name) @definition.function
+
+;; Arrow functions and function expressions assigned to a variable:
+;; const foo = () => {}
+(variable_declarator
+  name: (identifier) @name
+  value: [
+    (arrow_function)
+    (function_expression)
+  ]) @definition.function
+
+;; Function and method calls
+(call_expression
+  function: [
+    (identifier) @name
+    (member_expression
+      property: (property_identifier) @name)
+  ]) @reference.call
+
+;; Constructor calls: new Foo()
+(new_expression
+  constructor: (identifier) @name) @reference.call
+
+;; Import references: import { foo, bar as baz } from "x"
+(import_specifier
+  name: (identifier) @name) @reference.import
+
+;; Import references: import Foo from "x"
+(import_clause
+  (identifier) @name) @reference.import
+
+;; Re-exports: export { foo, bar as baz } from "x"
+(export_statement
+  (export_clause
+    (export_specifier
+      name: (identifier) @name) @reference.import)
+  source: (string))
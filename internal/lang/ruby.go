@@ -1,6 +1,8 @@
 package lang
 
 import (
+	"strings"
+
 	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/smacker/go-tree-sitter/ruby"
 
@@ -16,7 +18,152 @@ func init() {
 		ExtractSignature:  rubyExtractSignature,
 		FindEnclosingDef:  rubyFindEnclosingDef,
 		FindEnclosingType: rubyFindEnclosingType,
+		QualifyClass:      rubyQualifiedName,
+		IsExported:        rubyIsExported,
+		FileImports:       rubyFileImports,
+	}
+}
+
+// rubyFileImports returns the paths a file loads with require or
+// require_relative and a plain string literal. Requires of computed paths
+// (interpolation, File.expand_path) are skipped.
+func rubyFileImports(root *sitter.Node, source []byte) []model.Import {
+	var imports []model.Import
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		if n.Type() == "call" && n.ChildByFieldName("receiver") == nil {
+			if imp, ok := rubyRequire(n, source); ok {
+				imports = append(imports, imp)
+				return
+			}
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			walk(n.NamedChild(i))
+		}
+	}
+	walk(root)
+	return imports
+}
+
+// rubyRequire returns the import made by a require or require_relative call
+// whose only argument is a plain string.
+func rubyRequire(call *sitter.Node, source []byte) (model.Import, bool) {
+	method := call.ChildByFieldName("method")
+	if method == nil {
+		return model.Import{}, false
+	}
+	relative := false
+	switch NodeText(method, source) {
+	case "require":
+	case "require_relative":
+		relative = true
+	default:
+		return model.Import{}, false
+	}
+	args := call.ChildByFieldName("arguments")
+	if args == nil || args.NamedChildCount() != 1 {
+		return model.Import{}, false
+	}
+	str := args.NamedChild(0)
+	if str.Type() != "string" || str.NamedChildCount() != 1 || str.NamedChild(0).Type() != "string_content" {
+		return model.Import{}, false
+	}
+	return model.Import{Path: NodeText(str.NamedChild(0), source), Relative: relative}, true
+}
+
+// rubyIsExported reports whether a method or attribute definition is public.
+// It is private or protected when wrapped in "private def ...", when it
+// follows a bare private or protected line in the class body (until a bare
+// public), or when it is named in a "private :name" call anywhere in the
+// body. Singleton methods and other definitions are always public.
+func rubyIsExported(node *sitter.Node, _ string, source []byte) bool {
+	if node.Type() != "method" && node.Type() != "call" {
+		return true
+	}
+	stmt := node
+	if args := node.Parent(); args != nil && args.Type() == "argument_list" {
+		if call := args.Parent(); call != nil && call.Type() == "call" {
+			if m := call.ChildByFieldName("method"); m != nil {
+				switch NodeText(m, source) {
+				case "private", "protected":
+					return false
+				case "public":
+					return true
+				}
+			}
+			stmt = call // e.g. memoize def ..., still subject to a bare private
+		}
+	}
+	body := stmt.Parent()
+	if body == nil || body.Type() != "body_statement" {
+		return true
+	}
+
+	var names []string
+	if node.Type() == "method" {
+		if name := node.ChildByFieldName("name"); name != nil {
+			names = append(names, NodeText(name, source))
+		}
+	} else if args := node.ChildByFieldName("arguments"); args != nil {
+		for i := 0; i < int(args.NamedChildCount()); i++ {
+			names = append(names, strings.TrimPrefix(NodeText(args.NamedChild(i), source), ":"))
+		}
+	}
+
+	exported := true
+	before := true
+	for i := 0; i < int(body.NamedChildCount()); i++ {
+		child := body.NamedChild(i)
+		if child.Equal(stmt) {
+			before = false
+			continue
+		}
+		switch child.Type() {
+		case "identifier":
+			// A bare visibility keyword applies to the definitions after it.
+			if before {
+				switch NodeText(child, source) {
+				case "private", "protected":
+					exported = false
+				case "public":
+					exported = true
+				}
+			}
+		case "call":
+			if rubyHidesName(child, names, source) {
+				return false
+			}
+		}
 	}
+	return exported
+}
+
+// rubyHidesName reports whether call is "private :name" or
+// "protected :name" naming any of names.
+func rubyHidesName(call *sitter.Node, names []string, source []byte) bool {
+	m := call.ChildByFieldName("method")
+	if m == nil || call.ChildByFieldName("receiver") != nil {
+		return false
+	}
+	if text := NodeText(m, source); text != "private" && text != "protected" {
+		return false
+	}
+	args := call.ChildByFieldName("arguments")
+	if args == nil {
+		return false
+	}
+	for i := 0; i < int(args.NamedChildCount()); i++ {
+		arg := args.NamedChild(i)
+		if arg.Type() != "simple_symbol" {
+			continue
+		}
+		for _, name := range names {
+			if strings.TrimPrefix(NodeText(arg, source), ":") == name {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // rubyFindEnclosingDef returns the qualified name of the method containing
@@ -42,7 +189,7 @@ func rubyFindEnclosingDef(node *sitter.Node, source []byte) string {
 			ancestor := current.Parent()
 			for ancestor != nil {
 				if ancestor.Type() == "class" || ancestor.Type() == "module" {
-					cls := rubyClassName(ancestor, source)
+					cls := rubyQualifiedName(ancestor, source)
 					if cls != "" {
 						return cls + "." + methodName
 					}
@@ -67,7 +214,7 @@ func rubyFindEnclosingDef(node *sitter.Node, source []byte) string {
 			ancestor := current.Parent()
 			for ancestor != nil {
 				if ancestor.Type() == "class" || ancestor.Type() == "module" {
-					cls := rubyClassName(ancestor, source)
+					cls := rubyQualifiedName(ancestor, source)
 					if cls != "" {
 						return cls + "." + methodName
 					}
@@ -82,18 +229,54 @@ func rubyFindEnclosingDef(node *sitter.Node, source []byte) string {
 	return ""
 }
 
-// rubyFindMethodClass walks the parent chain looking for a class or module node.
+// rubyFindMethodClass walks the parent chain looking for a class or module
+// node and returns its namespace-qualified name (e.g. "Billing::Invoice").
 func rubyFindMethodClass(funcNode *sitter.Node, source []byte) string {
 	node := funcNode.Parent()
 	for node != nil {
 		if node.Type() == "class" || node.Type() == "module" {
-			return rubyClassName(node, source)
+			return rubyQualifiedName(node, source)
 		}
 		node = node.Parent()
 	}
 	return ""
 }
 
+// rubyQualifiedName returns the name of a class or module node prefixed with
+// every enclosing class and module, joined with "::" as Ruby writes it:
+// "class Invoice" inside "module Billing" becomes "Billing::Invoice". A
+// constant assignment is qualified the same way, so RATE = 2 inside
+// "class Invoice" becomes "Billing::Invoice::RATE".
+func rubyQualifiedName(node *sitter.Node, source []byte) string {
+	var parts []string
+	if node.Type() == "assignment" {
+		left := node.ChildByFieldName("left")
+		if left == nil {
+			return ""
+		}
+		parts = append(parts, NodeText(left, source))
+		node = node.Parent()
+	}
+	for n := node; n != nil; n = n.Parent() {
+		if n.Type() != "class" && n.Type() != "module" {
+			continue
+		}
+		name := rubyClassName(n, source)
+		if name == "" {
+			return ""
+		}
+		parts = append(parts, name)
+	}
+	var qualified string
+	for i := len(parts) - 1; i >= 0; i-- {
+		if qualified != "" {
+			qualified += "::"
+		}
+		qualified += parts[i]
+	}
+	return qualified
+}
+
 // rubyClassName extracts the name from a class or module node.
 func rubyClassName(node *sitter.Node, source []byte) string {
 	for i := 0; i < int(node.ChildCount()); i++ {
@@ -106,12 +289,12 @@ func rubyClassName(node *sitter.Node, source []byte) string {
 }
 
 // rubyFindEnclosingType walks up from a call node (attr_accessor etc.) to find
-// the enclosing class or module name. Returns "" if not inside a class/module.
+// the enclosing class or module's qualified name. Returns "" if not inside a class/module.
 func rubyFindEnclosingType(node *sitter.Node, source []byte) string {
 	current := node.Parent()
 	for current != nil {
 		if current.Type() == "class" || current.Type() == "module" {
-			return rubyClassName(current, source)
+			return rubyQualifiedName(current, source)
 		}
 		current = current.Parent()
 	}
@@ -125,9 +308,26 @@ func rubyExtractSignature(defNode *sitter.Node, kind model.SymbolKind, source []
 	if kind == model.Field {
 		return rubyExtractFieldSignature(defNode, source)
 	}
+	if kind == model.Constant {
+		return rubyExtractConstantSignature(defNode, source)
+	}
 	return rubyExtractMethodSignature(defNode, source)
 }
 
+// rubyExtractConstantSignature returns the collapsed assignment text
+// ("MAX_RETRIES = 3"), or only the constant's name when the value would make
+// the signature unwieldy (a large hash or array literal).
+func rubyExtractConstantSignature(node *sitter.Node, source []byte) string {
+	full := CollapseWhitespace(NodeText(node, source))
+	if len(full) <= maxSpecSignature {
+		return full
+	}
+	if left := node.ChildByFieldName("left"); left != nil {
+		return NodeText(left, source)
+	}
+	return full
+}
+
 func rubyExtractClassSignature(node *sitter.Node, source []byte) string {
 	var name, superclass string
 	for i := 0; i < int(node.ChildCount()); i++ {
@@ -0,0 +1,32 @@
+package lang
+
+import (
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/typescript/tsx"
+	"github.com/smacker/go-tree-sitter/typescript/typescript"
+)
+
+func init() {
+	ts := newTypeScript([]string{".ts", ".mts", ".cts", ".tsx"}, typescript.GetLanguage())
+	// .tsx needs the TSX grammar: the plain TypeScript grammar cannot parse JSX,
+	// and the TSX grammar misparses <T>expr casts in .ts files.
+	ts.dialects = map[string]*Language{
+		".tsx": newTypeScript([]string{".tsx"}, tsx.GetLanguage()),
+	}
+	Languages["typescript"] = ts
+}
+
+// newTypeScript builds a TypeScript Language for the given grammar. TypeScript
+// shares its hooks with JavaScript (see javascript.go).
+func newTypeScript(extensions []string, grammar *sitter.Language) *Language {
+	return &Language{
+		Name:              "typescript",
+		Extensions:        extensions,
+		lang:              grammar,
+		FindMethodClass:   jsFindMethodClass,
+		ExtractSignature:  jsExtractSignature,
+		FindEnclosingDef:  jsFindEnclosingDef,
+		FindEnclosingType: jsFindEnclosingType,
+		IsExported:        jsIsExported,
+	}
+}
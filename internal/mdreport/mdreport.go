@@ -0,0 +1,93 @@
+// Package mdreport renders the repository map as a human-readable Markdown
+// document, for sharing an overview in a PR description or wiki.
+package mdreport
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+// Encode renders rm as Markdown: a "Top Files by Centrality" table in rank
+// order, a "###" section per file listing its definitions with their
+// signatures in code spans, and a dependencies list. Files appear in the
+// order of rm.Files, which is ranked, so the caller bounds the document by
+// selecting files first.
+func Encode(rm *model.RepoMap) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", rm.RepoName)
+
+	b.WriteString("\n## Top Files by Centrality\n\n")
+	b.WriteString("| # | File | Language | Rank | Symbols |\n")
+	b.WriteString("|---|------|----------|------|---------|\n")
+	for i := range rm.Files {
+		fi := &rm.Files[i]
+		fmt.Fprintf(&b, "| %d | %s | %s | %.4f | %d |\n",
+			i+1, cell(code(fi.Path)), cell(fi.Language), fi.Rank, len(definitions(fi)))
+	}
+
+	b.WriteString("\n## Symbols\n")
+	for i := range rm.Files {
+		fi := &rm.Files[i]
+		defs := definitions(fi)
+		if len(defs) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", code(fi.Path))
+		for _, tag := range defs {
+			fmt.Fprintf(&b, "- **%s** (%s, line %d)", escape(tag.Name), tag.SymbolKind, tag.Line)
+			if tag.Signature != "" {
+				fmt.Fprintf(&b, ": %s", code(tag.Signature))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\n## Dependencies\n\n")
+	if len(rm.Dependencies) == 0 {
+		b.WriteString("None between the files shown.\n")
+	}
+	for i := range rm.Dependencies {
+		dep := &rm.Dependencies[i]
+		fmt.Fprintf(&b, "- %s → %s", code(dep.Source), code(dep.Target))
+		if len(dep.Symbols) > 0 {
+			fmt.Fprintf(&b, ": %s", escape(strings.Join(dep.Symbols, ", ")))
+		}
+		b.WriteString("\n")
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// definitions returns the file's definition tags in source order.
+func definitions(fi *model.FileInfo) []model.Tag {
+	var defs []model.Tag
+	for _, tag := range fi.Tags {
+		if tag.Kind == model.Definition {
+			defs = append(defs, tag)
+		}
+	}
+	return defs
+}
+
+// code wraps s in a code span, using a double-backtick fence when s itself
+// contains a backtick (as Go struct tags do).
+func code(s string) string {
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+// cell escapes pipes so that s stays in one table cell.
+func cell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;", "[", `\[`, "]", `\]`)
+
+// escape makes s render literally in Markdown prose.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
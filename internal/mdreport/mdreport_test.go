@@ -0,0 +1,65 @@
+package mdreport
+
+import (
+	"testing"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "repo",
+		Files: []model.FileInfo{
+			{Path: "models.py", Language: "python", Rank: 0.6, Tags: []model.Tag{
+				{Name: "User", Kind: model.Definition, SymbolKind: model.Class, Line: 1, Signature: "class User"},
+				{Name: "User.__init__", Kind: model.Definition, SymbolKind: model.Method, Line: 2, Signature: "def __init__(self, name: str)"},
+				{Name: "dataclass", Kind: model.Reference, SymbolKind: model.Function, Line: 1},
+			}},
+			{Path: "main.go", Language: "go", Rank: 0.3, Tags: []model.Tag{
+				{Name: "Config", Kind: model.Definition, SymbolKind: model.Class, Line: 5, Signature: "type Config struct { Name string `json:\"name\"` }"},
+			}},
+			{Path: "empty.py", Language: "python", Rank: 0.1},
+		},
+		Dependencies: []model.Dependency{
+			{Source: "main.go", Target: "models.py", Symbols: []string{"User", "User.__init__"}},
+		},
+	}
+
+	got := Encode(rm)
+	want := "# repo\n" +
+		"\n## Top Files by Centrality\n\n" +
+		"| # | File | Language | Rank | Symbols |\n" +
+		"|---|------|----------|------|---------|\n" +
+		"| 1 | `models.py` | python | 0.6000 | 2 |\n" +
+		"| 2 | `main.go` | go | 0.3000 | 1 |\n" +
+		"| 3 | `empty.py` | python | 0.1000 | 0 |\n" +
+		"\n## Symbols\n" +
+		"\n### `models.py`\n\n" +
+		"- **User** (class, line 1): `class User`\n" +
+		"- **User.\\_\\_init\\_\\_** (method, line 2): `def __init__(self, name: str)`\n" +
+		"\n### `main.go`\n\n" +
+		"- **Config** (class, line 5): `` type Config struct { Name string `json:\"name\"` } ``\n" +
+		"\n## Dependencies\n\n" +
+		"- `main.go` → `models.py`: User, User.\\_\\_init\\_\\_"
+	if got != want {
+		t.Errorf("Encode:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEncodeNoDependencies(t *testing.T) {
+	t.Parallel()
+
+	got := Encode(&model.RepoMap{RepoName: "repo"})
+	want := "# repo\n" +
+		"\n## Top Files by Centrality\n\n" +
+		"| # | File | Language | Rank | Symbols |\n" +
+		"|---|------|----------|------|---------|\n" +
+		"\n## Symbols\n" +
+		"\n## Dependencies\n\n" +
+		"None between the files shown."
+	if got != want {
+		t.Errorf("Encode(empty):\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+// Package mermaid renders the call graph as a Mermaid flowchart.
+package mermaid
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+var invalidIDChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Encode renders rm.CallEdges as a Mermaid "graph LR" flowchart. Each symbol
+// becomes a node whose id is a sanitized form of its name and whose label is
+// the name itself; nodes are declared in sorted order, followed by one
+// "caller --> callee" line per edge.
+func Encode(rm *model.RepoMap) string {
+	names := make(map[string]struct{})
+	for i := range rm.CallEdges {
+		names[rm.CallEdges[i].Caller] = struct{}{}
+		names[rm.CallEdges[i].Callee] = struct{}{}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	ids := assignIDs(sorted)
+
+	var b strings.Builder
+	b.WriteString("graph LR")
+	for _, name := range sorted {
+		fmt.Fprintf(&b, "\n    %s[\"%s\"]", ids[name], escapeLabel(name))
+	}
+	for i := range rm.CallEdges {
+		ce := &rm.CallEdges[i]
+		fmt.Fprintf(&b, "\n    %s --> %s", ids[ce.Caller], ids[ce.Callee])
+	}
+	return b.String()
+}
+
+// assignIDs maps each name to a unique Mermaid node id. Characters outside
+// [A-Za-z0-9_] become "_"; names that sanitize to the same id, or to the
+// reserved word "end", get a numeric suffix.
+func assignIDs(names []string) map[string]string {
+	ids := make(map[string]string, len(names))
+	used := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		base := invalidIDChars.ReplaceAllString(name, "_")
+		if base == "" || strings.EqualFold(base, "end") {
+			base += "_"
+		}
+		id := base
+		for n := 2; ; n++ {
+			if _, taken := used[id]; !taken {
+				break
+			}
+			id = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[id] = struct{}{}
+		ids[name] = id
+	}
+	return ids
+}
+
+// escapeLabel makes a name safe inside a double-quoted Mermaid label.
+func escapeLabel(name string) string {
+	return strings.ReplaceAll(name, `"`, "#quot;")
+}
@@ -0,0 +1,58 @@
+package mermaid
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		CallEdges: []model.CallEdge{
+			{Caller: "Server.Handle", Callee: "helper"},
+			{Caller: "main", Callee: "Server.Handle"},
+		},
+	}
+
+	got := Encode(rm)
+	want := `graph LR
+    Server_Handle["Server.Handle"]
+    helper["helper"]
+    main["main"]
+    Server_Handle --> helper
+    main --> Server_Handle`
+	if got != want {
+		t.Errorf("Encode:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEncodeEmpty(t *testing.T) {
+	t.Parallel()
+
+	if got := Encode(&model.RepoMap{}); got != "graph LR" {
+		t.Errorf("Encode(empty) = %q", got)
+	}
+}
+
+func TestAssignIDs(t *testing.T) {
+	t.Parallel()
+
+	ids := assignIDs([]string{"Billing::Invoice.create", "a.b", "a_b", "end", "<init>"})
+	if ids["Billing::Invoice.create"] != "Billing__Invoice_create" {
+		t.Errorf("Billing::Invoice.create → %q", ids["Billing::Invoice.create"])
+	}
+	if ids["a.b"] == ids["a_b"] {
+		t.Errorf("colliding ids: %q", ids["a.b"])
+	}
+	if ids["end"] == "end" {
+		t.Error(`reserved word "end" must not be used as an id`)
+	}
+	for name, id := range ids {
+		if strings.ContainsAny(id, ".:<>") {
+			t.Errorf("%s: invalid id %q", name, id)
+		}
+	}
+}
@@ -1,12 +1,19 @@
 // Package model defines core data structures for repoguide.
 package model
 
-// TagKind indicates whether a tag is a definition or a reference.
+import "strings"
+
+// TagKind indicates whether a tag is a definition or a reference, or marks
+// a likely program entrypoint.
 type TagKind string
 
 const (
 	Definition TagKind = "def"
 	Reference  TagKind = "ref"
+	// Entry marks where execution likely starts: a definition such as Go's
+	// func main or an HTTP handler (the tag repeats its name and line), or
+	// a block such as Python's if __name__ == "__main__".
+	Entry TagKind = "entry"
 )
 
 // SymbolKind indicates the syntactic kind of a symbol.
@@ -14,10 +21,12 @@ type SymbolKind string
 
 const (
 	Class    SymbolKind = "class"
+	Constant SymbolKind = "constant"
 	Field    SymbolKind = "field"
 	Function SymbolKind = "function"
 	Method   SymbolKind = "method"
 	Module   SymbolKind = "module"
+	Variable SymbolKind = "variable"
 )
 
 // Tag represents a single symbol occurrence extracted from source code.
@@ -28,15 +37,32 @@ type Tag struct {
 	Line       int
 	File       string
 	Signature  string
+	Doc        string // first sentence of the definition's docstring or doc comment
 	Enclosing  string // qualified name of enclosing func/method for reference tags; "" if top-level
+	Interface  bool   // method definition declared by an interface; calls resolve to it by bare method name
+	Unexported bool   // definition is private by its language's visibility rules
+	Owner      string // type a constant belongs to (a Go enum-style const group); "" if none
 }
 
 // FileInfo holds metadata and extracted tags for a single source file.
 type FileInfo struct {
-	Path     string
-	Language string
-	Tags     []Tag
-	Rank     float64
+	Path         string
+	Language     string
+	Tags         []Tag
+	Rank         float64
+	SyntaxErrors bool   // the parse recovered from syntax errors; Tags may be incomplete
+	Package      string // package declared by the file (Go); "" for languages without one
+	PackageDoc   string // first sentence of the package doc comment, if this file carries it
+	Imports      []Import
+	Owners       []string // CODEOWNERS owners of the file (--with-owners only)
+}
+
+// Import is a file a source file loads by path rather than by symbol name
+// (Ruby's require and require_relative). graph.BuildGraph resolves it to a
+// file in the repo when it can.
+type Import struct {
+	Path     string // as written, usually without an extension
+	Relative bool   // relative to the importing file's directory, not the load path
 }
 
 // Dependency represents an edge in the dependency graph:
@@ -49,18 +75,99 @@ type Dependency struct {
 
 // CallEdge represents a function-level call: Caller calls Callee.
 // Both names are the qualified symbol names as they appear in definitions
-// (e.g., "Server.Handle", "greet").
+// (e.g., "Server.Handle", "greet"). Count is the number of call sites behind
+// the edge, so a one-off call can be told from a hot path.
 type CallEdge struct {
 	Caller string
 	Callee string
+	Count  int
 }
 
+// CallSiteKind tells a call from a file-level import in the callsites table.
+type CallSiteKind string
+
+const (
+	// SiteCall is a reference from inside a function or method, its Caller.
+	SiteCall CallSiteKind = "call"
+	// SiteImport is a reference outside any function, such as an import
+	// statement; its Caller is ImportCaller.
+	SiteImport CallSiteKind = "import"
+)
+
+// ImportCaller is the Caller of a SiteImport call site, which has no
+// enclosing symbol.
+const ImportCaller = "<import>"
+
 // CallSite records a specific call occurrence with its source location.
 type CallSite struct {
 	Caller string
 	Callee string
 	File   string
 	Line   int
+	Lines  []int // every line when sites are deduplicated (Line is the first); nil otherwise
+	Kind   CallSiteKind
+}
+
+// External is a referenced symbol with no definition in the repo (a stdlib
+// or third-party name), with the number of references to it.
+type External struct {
+	Name  string
+	Count int
+}
+
+// Ref is a name a file references (a call or import), at its first
+// occurrence in that file.
+type Ref struct {
+	File string
+	Name string
+	Line int
+}
+
+// Entrypoint is a likely place where execution starts: a main function, an
+// HTTP route handler, a CLI command, or a script's main block.
+type Entrypoint struct {
+	File string
+	Name string
+	Line int
+}
+
+// Metric is a file's position in the dependency graph: how many files
+// import it (InDegree), how many it imports (OutDegree), and its rank.
+type Metric struct {
+	File      string
+	InDegree  int
+	OutDegree int
+	Rank      float64
+}
+
+// Diagnostic flags a file whose map entry may be wrong or incomplete, such
+// as one parsed with syntax errors.
+type Diagnostic struct {
+	File    string
+	Message string
+}
+
+// Dir is a directory in the collapsed (--collapse-dirs) view: the summed
+// rank of the files directly inside it and how many there are.
+type Dir struct {
+	Path  string
+	Rank  float64
+	Files int
+}
+
+// Package is a Go package among the files shown, identified by its
+// directory, with the first sentence of its package doc comment.
+type Package struct {
+	Dir  string
+	Name string
+	Doc  string
+}
+
+// Hit is a definition matched by a --search query, with File set, and its
+// score: match quality blended with its file's rank.
+type Hit struct {
+	Tag   Tag
+	Score float64
 }
 
 // RepoMap is the complete analyzed repository map, ready for serialization.
@@ -71,7 +178,48 @@ type RepoMap struct {
 	Dependencies []Dependency
 	CallEdges    []CallEdge
 	CallSites    []CallSite
+	// Cycles lists groups of files that import each other circularly. Each
+	// group is sorted; empty when the dependency graph is acyclic.
+	Cycles [][]string
+	// Externals lists unresolved references by frequency (--externals only).
+	Externals []External
+	// Refs lists the names each shown file references (--include-refs only).
+	Refs []Ref
+	// Entrypoints lists likely entrypoints in the shown files
+	// (--entrypoints only).
+	Entrypoints []Entrypoint
+	// Metrics lists the degree metrics of each shown file (--metrics only).
+	Metrics []Metric
+	// Diagnostics lists parsed files with problems (--diagnostics only).
+	Diagnostics []Diagnostic
+	// Unused lists exported definitions no other file references, with File
+	// set (--unused only).
+	Unused []Tag
+	// Unreachable lists the functions and methods no entrypoint reaches
+	// through the call graph, with File set (--prune-unreachable-from only).
+	Unreachable []Tag
+	// Packages lists the documented Go packages among the shown files.
+	Packages []Package
 	// Members holds field/method tags for focused --symbol --members queries.
 	// Empty in full-map mode.
 	Members []Tag
+	// Dirs replaces Files in the collapsed directory view (--collapse-dirs),
+	// where Dependencies and Cycles are between directories.
+	Dirs []Dir
+}
+
+// SplitMember splits a qualified member name at its last "." or "::" into
+// the owning type and the member name: "Server.Handle" gives "Server" and
+// "Handle", "ns::Widget::draw" gives "ns::Widget" and "draw". owner is ""
+// for an unqualified name.
+func SplitMember(name string) (owner, member string) {
+	dot := strings.LastIndex(name, ".")
+	colons := strings.LastIndex(name, "::")
+	switch {
+	case colons > dot:
+		return name[:colons], name[colons+2:]
+	case dot >= 0:
+		return name[:dot], name[dot+1:]
+	}
+	return "", name
 }
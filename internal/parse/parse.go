@@ -10,38 +10,80 @@ import (
 	"github.com/phobologic/repoguide/internal/model"
 )
 
-var captureMap = map[string]struct {
+// captureMap maps the capture names used in query files to what they tag.
+var captureMap = map[string]Capture{
+	"definition.class":            {model.Definition, model.Class, false},
+	"definition.constant":         {model.Definition, model.Constant, false},
+	"definition.field":            {model.Definition, model.Field, false},
+	"definition.function":         {model.Definition, model.Function, false},
+	"definition.interface_method": {model.Definition, model.Method, true},
+	"definition.method":           {model.Definition, model.Method, false},
+	"definition.variable":         {model.Definition, model.Variable, false},
+	"entrypoint":                  {model.Entry, model.Module, false},
+	"reference.call":              {model.Reference, model.Function, false},
+	"reference.import":            {model.Reference, model.Module, false},
+	"reference.type":              {model.Reference, model.Class, false},
+}
+
+// Capture is what a query capture tags: a definition, reference, or
+// entrypoint of some symbol kind. Interface marks a method declared by an
+// interface, which calls resolve to by its bare name.
+type Capture struct {
 	Kind       model.TagKind
 	SymbolKind model.SymbolKind
-}{
-	"definition.class":    {model.Definition, model.Class},
-	"definition.field":    {model.Definition, model.Field},
-	"definition.function": {model.Definition, model.Function},
-	"definition.method":   {model.Definition, model.Method},
-	"reference.call":      {model.Reference, model.Function},
-	"reference.import":    {model.Reference, model.Module},
+	Interface  bool
+}
+
+// LookupCapture returns what the query capture name (without its @) tags.
+// ok is false for captures that are not tags: @name, which marks the symbol
+// name within a match, and helper captures used only by predicates.
+func LookupCapture(name string) (c Capture, ok bool) {
+	c, ok = captureMap[name]
+	return c, ok
 }
 
 // ExtractTags parses a source file and returns definition and reference tags.
 // The parser must be created for the correct language.
 // filePath is used only for Tag.File and should be the repo-relative path.
-func ExtractTags(l *lang.Language, parser *sitter.Parser, query *sitter.Query, source []byte, filePath string) []model.Tag {
+//
+// A syntax error does not abandon the file: tree-sitter recovers a partial
+// tree, and the tags in its well-formed parts are returned with syntaxErrors
+// set. If ctx is done before the parse finishes, no tags are returned.
+func ExtractTags(ctx context.Context, l *lang.Language, parser *sitter.Parser, query *sitter.Query, source []byte, filePath string) (tags []model.Tag, syntaxErrors bool) {
+	fi, _ := ExtractFile(ctx, l, parser, query, source, filePath)
+	return fi.Tags, fi.SyntaxErrors
+}
+
+// ExtractFile is like ExtractTags but returns the whole parse result for the
+// file, including the package its package clause declares. It returns
+// ctx.Err() if ctx is done before the parse finishes, which cancels it.
+func ExtractFile(ctx context.Context, l *lang.Language, parser *sitter.Parser, query *sitter.Query, source []byte, filePath string) (model.FileInfo, error) {
+	fi := model.FileInfo{Path: filePath, Language: l.Name}
+	if err := ctx.Err(); err != nil {
+		return fi, err
+	}
 	if len(source) == 0 {
-		return nil
+		return fi, nil
 	}
 
-	tree, err := parser.ParseCtx(context.Background(), nil, source)
+	tree, err := parser.ParseCtx(ctx, nil, source)
 	if err != nil {
-		return nil
+		return fi, ctx.Err()
 	}
 	defer tree.Close()
+	fi.SyntaxErrors = tree.RootNode().HasError()
+	if l.PackageClause != nil {
+		fi.Package, fi.PackageDoc = l.PackageClause(tree.RootNode(), source)
+	}
+	if l.FileImports != nil {
+		fi.Imports = l.FileImports(tree.RootNode(), source)
+	}
+	var tags []model.Tag
 
 	qc := sitter.NewQueryCursor()
 	defer qc.Close()
 	qc.Exec(query, tree.RootNode())
 
-	var tags []model.Tag
-
 	for {
 		match, ok := qc.NextMatch()
 		if !ok {
@@ -71,14 +113,30 @@ func ExtractTags(l *lang.Language, parser *sitter.Parser, query *sitter.Query, s
 		cm := captureMap[captureName]
 		tagKind := cm.Kind
 		symbolKind := cm.SymbolKind
-		nameText := lang.NodeText(nameNode, source)
+		var nameText string
+		if l.NameText != nil {
+			nameText = l.NameText(nameNode, source)
+		} else {
+			nameText = lang.NodeText(nameNode, source)
+		}
 
 		// Ruby attr_accessor: the name node is a simple_symbol like ":foo" — strip the colon.
 		if nameNode.Type() == "simple_symbol" && len(nameText) > 0 && nameText[0] == ':' {
 			nameText = nameText[1:]
 		}
 
+		// A name that extracts to nothing (an anonymous struct has no type
+		// identifier) would only produce a garbled tag.
+		if nameText == "" {
+			continue
+		}
+
 		effectiveName := nameText
+		var owner string
+		sep := "."
+		if l.Separator != "" {
+			sep = l.Separator
+		}
 
 		switch {
 		case tagKind == model.Definition && symbolKind == model.Field:
@@ -91,13 +149,51 @@ func ExtractTags(l *lang.Language, parser *sitter.Parser, query *sitter.Query, s
 			if typeName == "" {
 				continue
 			}
-			effectiveName = typeName + "." + nameText
+			effectiveName = typeName + sep + nameText
+
+		case tagKind == model.Reference && symbolKind == model.Function:
+			// A method call whose receiver type is known resolves to that
+			// type's method rather than to every method of the same name.
+			if l.QualifyCall != nil {
+				if qualified := l.QualifyCall(defNode, nameText, source); qualified != "" {
+					effectiveName = qualified
+				}
+			}
+
+		case tagKind == model.Definition && symbolKind == model.Constant:
+			// Constants are namespaced like classes where the language
+			// nests them (Ruby's "Billing::RATE"), and record the type they
+			// belong to, so a query for the type can surface them.
+			if l.QualifyClass != nil {
+				if qualified := l.QualifyClass(defNode, source); qualified != "" {
+					effectiveName = qualified
+				}
+			}
+			if l.FindEnclosingType != nil {
+				owner = l.FindEnclosingType(defNode, source)
+			}
+
+		case tagKind == model.Definition && symbolKind == model.Class:
+			// Nested classes: prefix enclosing namespaces (e.g. "Billing::Invoice").
+			if l.QualifyClass != nil {
+				if qualified := l.QualifyClass(defNode, source); qualified != "" {
+					effectiveName = qualified
+				}
+			}
+
+		case tagKind == model.Definition && symbolKind == model.Method && cm.Interface:
+			// Interface method: qualify with the interface name ("Writer.Write").
+			if l.FindEnclosingType != nil {
+				if typeName := l.FindEnclosingType(defNode, source); typeName != "" {
+					effectiveName = typeName + sep + nameText
+				}
+			}
 
 		case tagKind == model.Definition && symbolKind == model.Method:
 			// Go-style: query captured @definition.method directly
 			if l.FindReceiverType != nil {
 				if recv := l.FindReceiverType(defNode, source); recv != "" {
-					effectiveName = recv + "." + nameText
+					effectiveName = recv + sep + nameText
 				}
 			}
 
@@ -106,15 +202,21 @@ func ExtractTags(l *lang.Language, parser *sitter.Parser, query *sitter.Query, s
 			if l.FindMethodClass != nil {
 				if cls := l.FindMethodClass(defNode, source); cls != "" {
 					symbolKind = model.Method
-					effectiveName = cls + "." + nameText
+					effectiveName = cls + sep + nameText
 				}
 			}
 		}
 
-		var signature string
+		var signature, doc string
 		if tagKind == model.Definition && l.ExtractSignature != nil {
 			signature = l.ExtractSignature(defNode, symbolKind, source)
 		}
+		if tagKind == model.Definition && l.ExtractDoc != nil {
+			doc = l.ExtractDoc(defNode, source)
+		}
+
+		unexported := tagKind == model.Definition && l.IsExported != nil &&
+			!l.IsExported(defNode, effectiveName, source)
 
 		var enclosing string
 		if tagKind == model.Reference && symbolKind == model.Function {
@@ -130,9 +232,25 @@ func ExtractTags(l *lang.Language, parser *sitter.Parser, query *sitter.Query, s
 			Line:       int(nameNode.StartPoint().Row) + 1,
 			File:       filePath,
 			Signature:  signature,
+			Doc:        doc,
 			Enclosing:  enclosing,
+			Interface:  cm.Interface,
+			Unexported: unexported,
+			Owner:      owner,
 		})
+
+		if tagKind == model.Definition && (symbolKind == model.Function || symbolKind == model.Method) &&
+			l.IsEntrypoint != nil && l.IsEntrypoint(defNode, nameText, source) {
+			tags = append(tags, model.Tag{
+				Name:       effectiveName,
+				Kind:       model.Entry,
+				SymbolKind: symbolKind,
+				Line:       int(nameNode.StartPoint().Row) + 1,
+				File:       filePath,
+			})
+		}
 	}
 
-	return tags
+	fi.Tags = tags
+	return fi, nil
 }
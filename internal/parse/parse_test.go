@@ -1,6 +1,9 @@
 package parse
 
 import (
+	"context"
+	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/phobologic/repoguide/internal/lang"
@@ -20,7 +23,8 @@ func setup(t *testing.T, langName string) (*lang.Language, func(source string) [
 	ext := l.Extensions[0]
 	return l, func(source string) []model.Tag {
 		p := l.NewParser()
-		return ExtractTags(l, p, q, []byte(source), "test"+ext)
+		tags, _ := ExtractTags(context.Background(), l, p, q, []byte(source), "test"+ext)
+		return tags
 	}
 }
 
@@ -104,6 +108,65 @@ func TestPythonExtractMethod(t *testing.T) {
 	}
 }
 
+func TestPythonAsyncFunction(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "python")
+
+	defs := filterDefs(extract("async def fetch(url: str) -> bytes:\n    pass\n"))
+	if len(defs) != 1 {
+		t.Fatalf("expected 1 def, got %d: %+v", len(defs), defs)
+	}
+	d := defs[0]
+	if d.Name != "fetch" || d.SymbolKind != model.Function {
+		t.Errorf("got %s (%s), want fetch (function)", d.Name, d.SymbolKind)
+	}
+	if d.Signature != "async fetch(url: str) -> bytes" {
+		t.Errorf("sig = %q", d.Signature)
+	}
+}
+
+func TestPythonAsyncMethod(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "python")
+
+	source := `class Client:
+    async def get(self) -> Data:
+        return await fetch(self.url)
+
+    @retry
+    async def post(self, body):
+        pass
+`
+	tags := extract(source)
+	byName := map[string]model.Tag{}
+	for _, tag := range filterDefs(tags) {
+		byName[tag.Name] = tag
+	}
+	for _, tc := range []struct{ name, sig string }{
+		{"Client.get", "async get(self) -> Data"},
+		{"Client.post", "async post(self, body)"},
+	} {
+		tag, ok := byName[tc.name]
+		if !ok {
+			t.Errorf("missing %s; got %v", tc.name, byName)
+			continue
+		}
+		if tag.SymbolKind != model.Method {
+			t.Errorf("%s: kind = %q, want method", tc.name, tag.SymbolKind)
+		}
+		if tag.Signature != tc.sig {
+			t.Errorf("%s: sig = %q, want %q", tc.name, tag.Signature, tc.sig)
+		}
+	}
+
+	// Calls inside async methods are attributed to the method.
+	for _, r := range filterRefs(tags) {
+		if r.Name == "fetch" && r.Enclosing != "Client.get" {
+			t.Errorf("fetch enclosing = %q, want Client.get", r.Enclosing)
+		}
+	}
+}
+
 func TestPythonExtractImport(t *testing.T) {
 	t.Parallel()
 	_, extract := setup(t, "python")
@@ -126,6 +189,27 @@ func TestPythonExtractImport(t *testing.T) {
 	}
 }
 
+func TestPythonExtractMultiAndAliasedImports(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "python")
+
+	tags := extract("from models import User, Order, Invoice\nfrom util import helper as h\nimport numpy as np\n")
+	names := make(map[string]int)
+	for _, r := range filterRefs(tags) {
+		names[r.Name]++
+	}
+	for _, want := range []string{"User", "Order", "Invoice", "helper", "numpy"} {
+		if names[want] != 1 {
+			t.Errorf("import %s: got %d references, want 1 (refs: %v)", want, names[want], names)
+		}
+	}
+	for _, alias := range []string{"h", "np"} {
+		if names[alias] != 0 {
+			t.Errorf("alias %s should not be a reference (refs: %v)", alias, names)
+		}
+	}
+}
+
 func TestPythonExtractCall(t *testing.T) {
 	t.Parallel()
 	_, extract := setup(t, "python")
@@ -239,6 +323,213 @@ type Server struct {
 	}
 }
 
+func TestGoGenericSignatures(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "go")
+
+	src := `package p
+
+func Map[T, U any](s []T, f func(T) U) []U { return nil }
+
+type Set[T comparable] struct{}
+
+func New[T comparable]() Set[T] { return Set[T]{} }
+
+func (s *Set[T]) Add(v T) *Set[T] { return s }
+`
+	byName := map[string]model.Tag{}
+	for _, tag := range filterDefs(extract(src)) {
+		byName[tag.Name] = tag
+	}
+	for _, tc := range []struct {
+		name string
+		sig  string
+	}{
+		{"Map", "Map[T, U any](s []T, f func(T) U) []U"},
+		{"Set", "Set[T comparable]"},
+		{"New", "New[T comparable]() Set[T]"},
+		{"Set.Add", "Add(v T) *Set[T]"},
+	} {
+		tag, ok := byName[tc.name]
+		if !ok {
+			t.Errorf("missing definition %q; got %v", tc.name, byName)
+			continue
+		}
+		if tag.Signature != tc.sig {
+			t.Errorf("%s: sig = %q, want %q", tc.name, tag.Signature, tc.sig)
+		}
+	}
+}
+
+func TestGoConstAndVar(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "go")
+
+	src := `package p
+
+const Version = "1.0"
+
+const (
+	A = iota
+	B
+)
+
+var ErrNotFound = errors.New("not found")
+
+var (
+	count int
+	table = map[string]int{"alpha": 1, "beta": 2, "gamma": 3, "delta": 4, "epsilon": 5}
+)
+
+func f() {
+	var local = 1
+	const inner = 2
+}
+`
+	byName := map[string]model.Tag{}
+	for _, tag := range filterDefs(extract(src)) {
+		byName[tag.Name] = tag
+	}
+	for _, tc := range []struct {
+		name string
+		kind model.SymbolKind
+		sig  string
+	}{
+		{"Version", model.Constant, `Version = "1.0"`},
+		{"A", model.Constant, "A = iota"},
+		{"B", model.Constant, "B"},
+		{"ErrNotFound", model.Variable, `ErrNotFound = errors.New("not found")`},
+		{"count", model.Variable, "count int"},
+		{"table", model.Variable, "table"},
+	} {
+		tag, ok := byName[tc.name]
+		if !ok {
+			t.Errorf("missing definition %q; got %v", tc.name, byName)
+			continue
+		}
+		if tag.SymbolKind != tc.kind {
+			t.Errorf("%s: kind = %q, want %q", tc.name, tag.SymbolKind, tc.kind)
+		}
+		if tag.Signature != tc.sig {
+			t.Errorf("%s: sig = %q, want %q", tc.name, tag.Signature, tc.sig)
+		}
+	}
+	for _, local := range []string{"local", "inner"} {
+		if _, ok := byName[local]; ok {
+			t.Errorf("function-local %q should not be captured", local)
+		}
+	}
+}
+
+func TestGoAnonymousStructsAndFuncFields(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "go")
+
+	src := `package p
+
+var cfg = struct {
+	Name string
+}{Name: "x"}
+
+type Conn struct {
+	OnClose func() error
+	OnData  func(
+		b []byte,
+	) (int, error)
+	Inner struct {
+		Depth int
+	}
+}
+`
+	byName := map[string]model.Tag{}
+	for _, tag := range filterDefs(extract(src)) {
+		if tag.Name == "" {
+			t.Errorf("empty-named tag: %+v", tag)
+		}
+		byName[tag.Name] = tag
+	}
+	for _, tc := range []struct {
+		name string
+		kind model.SymbolKind
+		sig  string
+	}{
+		{"cfg", model.Variable, `cfg = struct { Name string }{Name: "x"}`},
+		{"Conn.OnClose", model.Field, "OnClose func() error"},
+		{"Conn.OnData", model.Field, "OnData func(b []byte) (int, error)"},
+		{"Conn.Inner", model.Field, "Inner struct { Depth int }"},
+	} {
+		tag, ok := byName[tc.name]
+		if !ok {
+			t.Errorf("missing definition %q; got %v", tc.name, byName)
+			continue
+		}
+		if tag.SymbolKind != tc.kind {
+			t.Errorf("%s: kind = %q, want %q", tc.name, tag.SymbolKind, tc.kind)
+		}
+		if tag.Signature != tc.sig {
+			t.Errorf("%s: sig = %q, want %q", tc.name, tag.Signature, tc.sig)
+		}
+	}
+	// Fields of anonymous structs belong to no named type.
+	for _, name := range []string{"Name", "cfg.Name", "Depth", "Conn.Depth"} {
+		if _, ok := byName[name]; ok {
+			t.Errorf("anonymous struct field %q should not be captured", name)
+		}
+	}
+}
+
+func TestGoEnumConstGroups(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "go")
+
+	src := `package p
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+)
+
+const (
+	KB = 1 << (10 * (iota + 1))
+	MB
+	Timeout time.Duration = 5
+	Retry
+	Low, High Level = 0, 9
+)
+`
+	byName := map[string]model.Tag{}
+	for _, tag := range filterDefs(extract(src)) {
+		byName[tag.Name] = tag
+	}
+	for _, tc := range []struct {
+		name, owner, sig string
+	}{
+		{"Red", "Color", "Red Color = iota"},
+		{"Green", "Color", "Green Color"},
+		{"Blue", "Color", "Blue Color"},
+		{"KB", "", "KB = 1 << (10 * (iota + 1))"},
+		{"MB", "", "MB"},
+		{"Timeout", "", "Timeout time.Duration = 5"},
+		{"Retry", "", "Retry"},
+		{"Low", "Level", "Low, High Level = 0, 9"},
+	} {
+		tag, ok := byName[tc.name]
+		if !ok {
+			t.Errorf("missing definition %q", tc.name)
+			continue
+		}
+		if tag.Owner != tc.owner {
+			t.Errorf("%s: owner = %q, want %q", tc.name, tag.Owner, tc.owner)
+		}
+		if tag.Signature != tc.sig {
+			t.Errorf("%s: sig = %q, want %q", tc.name, tag.Signature, tc.sig)
+		}
+	}
+}
+
 func TestGoExtractCall(t *testing.T) {
 	t.Parallel()
 	_, extract := setup(t, "go")
@@ -265,6 +556,31 @@ func main() {
 	}
 }
 
+func TestGoExtractGenericCall(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "go")
+
+	source := `package main
+
+func main() {
+	parse[int](x)
+	convert[int, string](x)
+	slices.Sorted[[]int](seq)
+	parse[int](x, y)
+}
+`
+	var got []string
+	for _, r := range filterRefs(extract(source)) {
+		got = append(got, r.Name+"@"+r.Enclosing)
+	}
+	// The base identifier, not the instantiation, with or without a
+	// package qualifier, and however many type arguments.
+	want := []string{"parse@main", "convert@main", "Sorted@main", "parse@main"}
+	if !slices.Equal(got, want) {
+		t.Errorf("call refs = %q, want %q", got, want)
+	}
+}
+
 func TestGoExtractImport(t *testing.T) {
 	t.Parallel()
 	_, extract := setup(t, "go")
@@ -471,7 +787,7 @@ func (s *Server) Handle() {
 `)
 	refs := filterRefs(tags)
 	for _, r := range refs {
-		if r.Name == "parse" {
+		if r.Name == "Server.parse" {
 			if r.Enclosing != "Server.Handle" {
 				t.Errorf("Enclosing = %q, want Server.Handle", r.Enclosing)
 			}
@@ -481,6 +797,33 @@ func (s *Server) Handle() {
 	t.Error("parse call not found")
 }
 
+func TestGoReceiverCallQualified(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "go")
+
+	tags := extract(`package main
+
+func (s *Server) Handle(c *Client) {
+	s.parse()
+	c.Send()
+	go func() { s.flush() }()
+	helper()
+}
+
+func run(s *Server) {
+	s.Handle(nil)
+}
+`)
+	var names []string
+	for _, r := range filterRefs(tags) {
+		names = append(names, r.Name)
+	}
+	want := []string{"Server.parse", "Send", "Server.flush", "helper", "Handle"}
+	if !slices.Equal(names, want) {
+		t.Errorf("call names = %v, want %v", names, want)
+	}
+}
+
 func TestGoTopLevelCallNoEnclosing(t *testing.T) {
 	t.Parallel()
 	_, extract := setup(t, "go")
@@ -652,6 +995,54 @@ type Beat struct {
 	}
 }
 
+func TestGoEmbeddedAndTaggedFields(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "go")
+
+	src := `package p
+
+type Server struct {
+	io.Reader
+	*Base
+	Name string ` + "`json:\"name\"`" + `
+}
+`
+	tags := extract(src)
+	byName := map[string]model.Tag{}
+	for _, tag := range filterFields(tags) {
+		byName[tag.Name] = tag
+	}
+	for _, tc := range []struct {
+		name string
+		sig  string
+	}{
+		{"Server.Reader", "io.Reader"},
+		{"Server.Base", "*Base"},
+		{"Server.Name", "Name string `json:\"name\"`"},
+	} {
+		tag, ok := byName[tc.name]
+		if !ok {
+			t.Errorf("missing field %q; got %v", tc.name, byName)
+			continue
+		}
+		if tag.Signature != tc.sig {
+			t.Errorf("%s: sig = %q, want %q", tc.name, tag.Signature, tc.sig)
+		}
+	}
+	if len(byName) != 3 {
+		t.Errorf("expected 3 fields, got %v", byName)
+	}
+
+	// Embedding a local type references it, so BuildGraph links the files.
+	var refs []string
+	for _, tag := range filterRefs(tags) {
+		refs = append(refs, tag.Name)
+	}
+	if len(refs) != 1 || refs[0] != "Base" {
+		t.Errorf("refs = %v, want [Base]", refs)
+	}
+}
+
 func TestGoInterfaceMethods(t *testing.T) {
 	t.Parallel()
 	_, extract := setup(t, "go")
@@ -663,16 +1054,29 @@ type Writer interface {
 	Close() error
 }
 `
-	tags := filterFields(extract(src))
-	if len(tags) != 2 {
-		t.Fatalf("expected 2 interface method tags, got %d: %+v", len(tags), tags)
+	tags := extract(src)
+	if fields := filterFields(tags); len(fields) != 0 {
+		t.Errorf("interface methods should not be fields, got %+v", fields)
 	}
-	names := map[string]bool{}
-	for _, tag := range tags {
-		names[tag.Name] = true
+	byName := map[string]model.Tag{}
+	for _, tag := range filterDefs(tags) {
+		byName[tag.Name] = tag
 	}
-	if !names["Writer.Write"] || !names["Writer.Close"] {
-		t.Errorf("unexpected field names: %v", names)
+	for name, sig := range map[string]string{
+		"Writer.Write": "Write(p []byte) (n int, err error)",
+		"Writer.Close": "Close() error",
+	} {
+		tag, ok := byName[name]
+		if !ok {
+			t.Errorf("missing interface method %q; got %v", name, byName)
+			continue
+		}
+		if tag.SymbolKind != model.Method || !tag.Interface {
+			t.Errorf("%s: kind = %q, interface = %v; want method, true", name, tag.SymbolKind, tag.Interface)
+		}
+		if tag.Signature != sig {
+			t.Errorf("%s: signature = %q, want %q", name, tag.Signature, sig)
+		}
 	}
 }
 
@@ -719,29 +1123,128 @@ func TestPythonClassFields(t *testing.T) {
 	}
 }
 
-func TestPythonFieldsNotCapturedInMethod(t *testing.T) {
+func TestPythonDataclassFields(t *testing.T) {
 	t.Parallel()
 	_, extract := setup(t, "python")
 
-	// Assignments inside a method should NOT be captured as fields.
-	src := `class Foo:
-    def bar(self):
-        x = 1
-        return x
+	src := `from dataclasses import dataclass, field
+
+@dataclass(frozen=True)
+class User:
+    id: int
+    name: str = "anonymous"
+    tags: list[str] = field(default_factory=list)
+
+    def greet(self) -> str:
+        return self.name
 `
-	tags := filterFields(extract(src))
-	if len(tags) != 0 {
-		t.Errorf("expected no field tags for method-local assignments, got %d: %+v", len(tags), tags)
+	tags := extract(src)
+	fields := filterFields(tags)
+	want := map[string]string{
+		"User.id":   "id: int",
+		"User.name": "name: str",
+		"User.tags": "tags: list[str]",
+	}
+	if len(fields) != len(want) {
+		t.Fatalf("expected %d field tags, got %d: %+v", len(want), len(fields), fields)
+	}
+	for _, tag := range fields {
+		if sig, ok := want[tag.Name]; !ok {
+			t.Errorf("unexpected field %q", tag.Name)
+		} else if tag.Signature != sig {
+			t.Errorf("%s sig = %q, want %q", tag.Name, tag.Signature, sig)
+		}
+	}
+
+	found := false
+	for _, tag := range filterDefs(tags) {
+		if tag.Name == "User.greet" && tag.SymbolKind == model.Method {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("missing method User.greet in decorated class")
 	}
 }
 
-func TestRubyAttrFields(t *testing.T) {
+func TestPythonTypedDictFields(t *testing.T) {
 	t.Parallel()
-	_, extract := setup(t, "ruby")
+	_, extract := setup(t, "python")
 
-	src := `class Beat
-  attr_accessor :id, :name
-  attr_reader :status
+	src := `class Config(TypedDict, total=False):
+    host: str
+    port: int
+`
+	fields := filterFields(extract(src))
+	if len(fields) != 2 {
+		t.Fatalf("expected 2 field tags, got %d: %+v", len(fields), fields)
+	}
+	if fields[0].Name != "Config.host" || fields[0].Signature != "host: str" {
+		t.Errorf("field 0 = %s %q", fields[0].Name, fields[0].Signature)
+	}
+	if fields[1].Name != "Config.port" || fields[1].Signature != "port: int" {
+		t.Errorf("field 1 = %s %q", fields[1].Name, fields[1].Signature)
+	}
+}
+
+func TestPythonTypeAliases(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "python")
+
+	src := `from typing import TypeAlias
+import typing
+
+UserId = int
+Vector: TypeAlias = list[float]
+Matrix: typing.TypeAlias = list[Vector]
+count: int = 0
+type Point[T] = tuple[T, T]
+`
+	got := map[string]string{}
+	for _, tag := range filterDefs(extract(src)) {
+		if tag.SymbolKind != model.Class {
+			t.Errorf("%s kind = %s, want class", tag.Name, tag.SymbolKind)
+		}
+		got[tag.Name] = tag.Signature
+	}
+	want := map[string]string{
+		"Vector": "Vector: TypeAlias = list[float]",
+		"Matrix": "Matrix: typing.TypeAlias = list[Vector]",
+		"Point":  "type Point[T] = tuple[T, T]",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got definitions %v, want %v", got, want)
+	}
+	for name, sig := range want {
+		if got[name] != sig {
+			t.Errorf("%s sig = %q, want %q", name, got[name], sig)
+		}
+	}
+}
+
+func TestPythonFieldsNotCapturedInMethod(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "python")
+
+	// Assignments inside a method should NOT be captured as fields.
+	src := `class Foo:
+    def bar(self):
+        x = 1
+        return x
+`
+	tags := filterFields(extract(src))
+	if len(tags) != 0 {
+		t.Errorf("expected no field tags for method-local assignments, got %d: %+v", len(tags), tags)
+	}
+}
+
+func TestRubyAttrFields(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "ruby")
+
+	src := `class Beat
+  attr_accessor :id, :name
+  attr_reader :status
 end
 `
 	tags := filterFields(extract(src))
@@ -758,3 +1261,1113 @@ end
 		}
 	}
 }
+
+// --- TypeScript / JavaScript tests ---
+
+func TestTypeScriptDefinitions(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "typescript")
+
+	src := `export class Server extends Base implements Handler {
+  private name: string;
+  handle(req: Request): Response {
+    return respond(req);
+  }
+}
+
+export interface Handler {
+  serve(x: number): void;
+  id?: string;
+}
+
+export function greet<T>(name: string): string {
+  return name;
+}
+
+export const arrow = async (x: number): Promise<void> => {};
+`
+	byName := map[string]model.Tag{}
+	for _, tag := range filterDefs(extract(src)) {
+		byName[tag.Name] = tag
+	}
+	for _, tc := range []struct {
+		name string
+		kind model.SymbolKind
+		sig  string
+	}{
+		{"Server", model.Class, "Server extends Base implements Handler"},
+		{"Server.name", model.Field, "name: string"},
+		{"Server.handle", model.Method, "handle(req: Request): Response"},
+		{"Handler", model.Class, "Handler"},
+		{"Handler.serve", model.Field, "serve(x: number): void"},
+		{"Handler.id", model.Field, "id?: string"},
+		{"greet", model.Function, "greet<T>(name: string): string"},
+		{"arrow", model.Function, "arrow(x: number): Promise<void>"},
+	} {
+		tag, ok := byName[tc.name]
+		if !ok {
+			t.Errorf("missing definition %q; got %v", tc.name, byName)
+			continue
+		}
+		if tag.SymbolKind != tc.kind {
+			t.Errorf("%s: kind = %q, want %q", tc.name, tag.SymbolKind, tc.kind)
+		}
+		if tag.Signature != tc.sig {
+			t.Errorf("%s: sig = %q, want %q", tc.name, tag.Signature, tc.sig)
+		}
+	}
+}
+
+func TestTypeScriptImportsAndCalls(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "typescript")
+
+	src := `import Default, { foo, bar as baz } from "./util";
+
+class Server {
+  handle() {
+    items.forEach((i) => foo(i));
+  }
+}
+
+export const run = () => {
+  new Server().handle();
+};
+`
+	refs := filterRefs(extract(src))
+	enclosing := map[string]string{}
+	imports := map[string]bool{}
+	for _, r := range refs {
+		if r.SymbolKind == model.Module {
+			imports[r.Name] = true
+		} else {
+			enclosing[r.Name] = r.Enclosing
+		}
+	}
+	for _, want := range []string{"Default", "foo", "bar"} {
+		if !imports[want] {
+			t.Errorf("missing import %q; got %v", want, imports)
+		}
+	}
+	// Calls inside anonymous callbacks are attributed to the enclosing method.
+	if got := enclosing["foo"]; got != "Server.handle" {
+		t.Errorf("foo enclosing = %q, want Server.handle", got)
+	}
+	// Calls inside an arrow function assigned to a const use the const name.
+	if got := enclosing["Server"]; got != "run" {
+		t.Errorf("Server enclosing = %q, want run", got)
+	}
+	if got := enclosing["handle"]; got != "run" {
+		t.Errorf("handle enclosing = %q, want run", got)
+	}
+}
+
+func TestTSXUsesTSXGrammar(t *testing.T) {
+	t.Parallel()
+	l := lang.Languages["typescript"].ForFile("App.tsx")
+	q, err := l.GetTagQuery()
+	if err != nil {
+		t.Fatalf("GetTagQuery: %v", err)
+	}
+
+	src := `export const App = ({ title }: Props) => {
+  return <Header title={format(title)} />;
+};
+`
+	tags, _ := ExtractTags(context.Background(), l, l.NewParser(), q, []byte(src), "App.tsx")
+	var found bool
+	for _, tag := range tags {
+		if tag.Kind == model.Reference && tag.Name == "format" {
+			found = true
+			if tag.Enclosing != "App" {
+				t.Errorf("format enclosing = %q, want App", tag.Enclosing)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("format call inside JSX not found: %+v", tags)
+	}
+}
+
+func TestJavaScriptDefinitions(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "javascript")
+
+	src := `export class App extends Component {
+  count = 0;
+  render() {
+    return helper(this.count);
+  }
+}
+
+export const Button = label => label;
+`
+	byName := map[string]model.Tag{}
+	for _, tag := range filterDefs(extract(src)) {
+		byName[tag.Name] = tag
+	}
+	for _, tc := range []struct {
+		name string
+		kind model.SymbolKind
+		sig  string
+	}{
+		{"App", model.Class, "App extends Component"},
+		{"App.count", model.Field, "count"},
+		{"App.render", model.Method, "render()"},
+		{"Button", model.Function, "Button(label)"},
+	} {
+		tag, ok := byName[tc.name]
+		if !ok {
+			t.Errorf("missing definition %q; got %v", tc.name, byName)
+			continue
+		}
+		if tag.SymbolKind != tc.kind {
+			t.Errorf("%s: kind = %q, want %q", tc.name, tag.SymbolKind, tc.kind)
+		}
+		if tag.Signature != tc.sig {
+			t.Errorf("%s: sig = %q, want %q", tc.name, tag.Signature, tc.sig)
+		}
+	}
+}
+
+func TestJavaDefinitions(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "java")
+
+	src := `package com.example;
+
+public class Outer<T> extends Base implements Runnable {
+    private final String name;
+    int a, b;
+
+    public Outer(String name) { this.name = name; }
+
+    public <R> List<R> greet(User user, int count) { return null; }
+
+    static class Inner {
+        void work() {}
+    }
+
+    interface Handler extends Base {
+        int LIMIT = 10;
+        void handle(String s);
+    }
+
+    enum Color { RED, GREEN; String label() { return ""; } }
+
+    record Point(int x, int y) { int sum() { return x + y; } }
+
+    void anon() {
+        new Runnable() { public void run() {} };
+    }
+}
+`
+	byName := map[string]model.Tag{}
+	for _, tag := range filterDefs(extract(src)) {
+		byName[tag.Name] = tag
+	}
+	for _, tc := range []struct {
+		name string
+		kind model.SymbolKind
+		sig  string
+	}{
+		{"Outer", model.Class, "Outer<T> extends Base implements Runnable"},
+		{"Outer.name", model.Field, "String name"},
+		{"Outer.a", model.Field, "int a, b"},
+		{"Outer.Outer", model.Method, "Outer(String name)"},
+		{"Outer.greet", model.Method, "<R> List<R> greet(User user, int count)"},
+		{"Outer.Inner", model.Class, "Inner"},
+		{"Outer.Inner.work", model.Method, "void work()"},
+		{"Outer.Handler", model.Class, "Handler extends Base"},
+		{"Outer.Handler.LIMIT", model.Field, "int LIMIT"},
+		{"Outer.Handler.handle", model.Method, "void handle(String s)"},
+		{"Outer.Color", model.Class, "Color"},
+		{"Outer.Color.RED", model.Field, "RED"},
+		{"Outer.Color.label", model.Method, "String label()"},
+		{"Outer.Point", model.Class, "Point(int x, int y)"},
+		{"Outer.Point.sum", model.Method, "int sum()"},
+	} {
+		tag, ok := byName[tc.name]
+		if !ok {
+			t.Errorf("missing definition %q; got %v", tc.name, byName)
+			continue
+		}
+		if tag.SymbolKind != tc.kind {
+			t.Errorf("%s: kind = %q, want %q", tc.name, tag.SymbolKind, tc.kind)
+		}
+		if tag.Signature != tc.sig {
+			t.Errorf("%s: sig = %q, want %q", tc.name, tag.Signature, tc.sig)
+		}
+	}
+	// Methods of anonymous classes are not definitions.
+	if _, ok := byName["run"]; ok {
+		t.Error("anonymous class method should not be captured")
+	}
+}
+
+func TestJavaImportsAndCalls(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "java")
+
+	src := `import com.foo.Bar;
+import static com.foo.Util.helper;
+
+class Service {
+    class Worker {
+        void process() {
+            helper();
+            items.forEach(i -> store.save(i));
+            new Runnable() { public void run() { log(); } };
+        }
+    }
+
+    Service() { new Bar<String>(); }
+}
+`
+	refs := filterRefs(extract(src))
+	enclosing := map[string]string{}
+	imports := map[string]bool{}
+	for _, r := range refs {
+		if r.SymbolKind == model.Module {
+			imports[r.Name] = true
+		} else {
+			enclosing[r.Name] = r.Enclosing
+		}
+	}
+	for _, want := range []string{"Bar", "helper"} {
+		if !imports[want] {
+			t.Errorf("missing import %q; got %v", want, imports)
+		}
+	}
+	for name, want := range map[string]string{
+		"helper": "Service.Worker.process",
+		"save":   "Service.Worker.process", // inside a lambda
+		"log":    "Service.Worker.process", // inside an anonymous class
+		"Bar":    "Service.Service",
+	} {
+		if got, ok := enclosing[name]; !ok || got != want {
+			t.Errorf("%s enclosing = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRubyNestedNamespaces(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "ruby")
+
+	src := `module Billing
+  class Invoice < ApplicationRecord
+    attr_reader :total
+
+    def self.create(attrs)
+      validate(attrs)
+    end
+  end
+end
+
+class Admin::Invoice
+  def show
+  end
+end
+`
+	tags := extract(src)
+	byName := map[string]model.Tag{}
+	for _, tag := range filterDefs(tags) {
+		byName[tag.Name] = tag
+	}
+	for name, kind := range map[string]model.SymbolKind{
+		"Billing":                 model.Class,
+		"Billing::Invoice":        model.Class,
+		"Billing::Invoice.total":  model.Field,
+		"Billing::Invoice.create": model.Method,
+		"Admin::Invoice":          model.Class,
+		"Admin::Invoice.show":     model.Method,
+	} {
+		tag, ok := byName[name]
+		if !ok {
+			t.Errorf("missing definition %q; got %v", name, byName)
+			continue
+		}
+		if tag.SymbolKind != kind {
+			t.Errorf("%s: kind = %q, want %q", name, tag.SymbolKind, kind)
+		}
+	}
+	if _, ok := byName["Invoice"]; ok {
+		t.Error("nested class should not be defined under its bare name")
+	}
+
+	for _, ref := range filterRefs(tags) {
+		if ref.Name == "validate" && ref.Enclosing != "Billing::Invoice.create" {
+			t.Errorf("validate enclosing = %q, want Billing::Invoice.create", ref.Enclosing)
+		}
+	}
+}
+
+func TestRubyConstants(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "ruby")
+
+	src := `MAX_RETRIES = 3
+
+ROUTES = {
+  "/" => :home,
+  "/about" => :about,
+  "/contact" => :contact,
+  "/pricing" => :pricing,
+}
+
+module Billing
+  class Invoice
+    TAX_RATE = 0.2
+
+    def total
+      amount = 1
+    end
+  end
+end
+
+Billing::CURRENCY = "USD"
+`
+	byName := map[string]model.Tag{}
+	for _, tag := range filterDefs(extract(src)) {
+		byName[tag.Name] = tag
+	}
+	for _, tc := range []struct {
+		name, sig, owner string
+	}{
+		{"MAX_RETRIES", "MAX_RETRIES = 3", ""},
+		{"ROUTES", "ROUTES", ""},
+		{"Billing::Invoice::TAX_RATE", "TAX_RATE = 0.2", "Billing::Invoice"},
+		{"Billing::CURRENCY", `Billing::CURRENCY = "USD"`, ""},
+	} {
+		tag, ok := byName[tc.name]
+		if !ok {
+			t.Errorf("missing definition %q; got %v", tc.name, byName)
+			continue
+		}
+		if tag.SymbolKind != model.Constant {
+			t.Errorf("%s: kind = %q, want constant", tc.name, tag.SymbolKind)
+		}
+		if tag.Signature != tc.sig {
+			t.Errorf("%s: sig = %q, want %q", tc.name, tag.Signature, tc.sig)
+		}
+		if tag.Owner != tc.owner {
+			t.Errorf("%s: owner = %q, want %q", tc.name, tag.Owner, tc.owner)
+		}
+	}
+	if _, ok := byName["amount"]; ok {
+		t.Error("local variable assignments should not be captured")
+	}
+}
+
+func TestPythonNestedClassQualification(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "python")
+
+	src := `class Outer:
+    class Inner:
+        limit = 3
+
+        def m(self):
+            helper()
+
+    def m(self):
+        pass
+`
+	tags := extract(src)
+	byName := map[string]model.Tag{}
+	for _, tag := range filterDefs(tags) {
+		byName[tag.Name] = tag
+	}
+	for name, kind := range map[string]model.SymbolKind{
+		"Outer":             model.Class,
+		"Outer.Inner":       model.Class,
+		"Outer.Inner.limit": model.Field,
+		"Outer.Inner.m":     model.Method,
+		"Outer.m":           model.Method,
+	} {
+		tag, ok := byName[name]
+		if !ok {
+			t.Errorf("missing definition %q; got %v", name, byName)
+			continue
+		}
+		if tag.SymbolKind != kind {
+			t.Errorf("%s: kind = %q, want %q", name, tag.SymbolKind, kind)
+		}
+	}
+	if _, ok := byName["Inner.m"]; ok {
+		t.Error("nested method should not be qualified with only its innermost class")
+	}
+
+	for _, ref := range filterRefs(tags) {
+		if ref.Name == "helper" && ref.Enclosing != "Outer.Inner.m" {
+			t.Errorf("helper enclosing = %q, want Outer.Inner.m", ref.Enclosing)
+		}
+	}
+}
+
+func TestJavaNestedTypeQualification(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "java")
+
+	src := `class Bar {
+    static class Inner {
+        void run() {}
+    }
+
+    enum Mode { A, B }
+}
+`
+	byName := map[string]model.Tag{}
+	for _, tag := range filterDefs(extract(src)) {
+		byName[tag.Name] = tag
+	}
+	// A nested type is named like the owner of its members, so a query for
+	// Bar.Mode finds Bar.Mode.A.
+	for name, kind := range map[string]model.SymbolKind{
+		"Bar":           model.Class,
+		"Bar.Inner":     model.Class,
+		"Bar.Inner.run": model.Method,
+		"Bar.Mode":      model.Class,
+		"Bar.Mode.A":    model.Field,
+	} {
+		tag, ok := byName[name]
+		if !ok {
+			t.Errorf("missing definition %q; got %v", name, byName)
+			continue
+		}
+		if tag.SymbolKind != kind {
+			t.Errorf("%s: kind = %q, want %q", name, tag.SymbolKind, kind)
+		}
+	}
+	for _, name := range []string{"Inner", "Mode"} {
+		if _, ok := byName[name]; ok {
+			t.Errorf("nested type %s should be qualified with its enclosing type", name)
+		}
+	}
+}
+
+func TestCppDefinitions(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "cpp")
+
+	src := `#include "widget.h"
+#include <vector>
+
+namespace engine { namespace render {
+class Widget : public Base {
+public:
+  Widget(int w);
+  ~Widget();
+  virtual void draw() const = 0;
+  int area() { return w_ * h_; }
+  static Widget* make();
+  int w_, h_ = 0;
+  Widget* next;
+  struct Inner { int x; };
+};
+int helper(int x);
+} }
+
+namespace engine::render {
+void Widget::draw() const {}
+Widget* Widget::make() { return nullptr; }
+Widget::~Widget() {}
+bool Widget::operator==(const Widget& o) const { return true; }
+}
+
+template <typename T> T Box<T>::get() { return v; }
+void engine::render::Widget::reset() {}
+int helper(int x) { return x; }
+`
+	byName := map[string][]model.Tag{}
+	for _, tag := range filterDefs(extract(src)) {
+		byName[tag.Name] = append(byName[tag.Name], tag)
+	}
+	for _, tc := range []struct {
+		name string
+		kind model.SymbolKind
+		sig  string
+	}{
+		{"engine::render::Widget", model.Class, "Widget : public Base"},
+		{"engine::render::Widget::Inner", model.Class, "Inner"},
+		{"engine::render::Widget::Widget", model.Method, "Widget(int w)"},
+		{"engine::render::Widget::~Widget", model.Method, "~Widget()"},
+		{"engine::render::Widget::draw", model.Method, "virtual void draw() const = 0"},
+		{"engine::render::Widget::area", model.Method, "int area()"},
+		{"engine::render::Widget::make", model.Method, "static Widget* make()"},
+		{"engine::render::Widget::w_", model.Field, "int w_, h_"},
+		{"engine::render::Widget::h_", model.Field, "int w_, h_"},
+		{"engine::render::Widget::next", model.Field, "Widget* next"},
+		{"engine::render::Widget::Inner::x", model.Field, "int x"},
+		{"engine::render::Widget::operator==", model.Method, "bool Widget::operator==(const Widget& o) const"},
+		{"engine::render::Widget::reset", model.Method, "void engine::render::Widget::reset()"},
+		{"Box::get", model.Method, "T Box<T>::get()"},
+		{"helper", model.Function, "int helper(int x)"},
+	} {
+		tags, ok := byName[tc.name]
+		if !ok {
+			t.Errorf("missing definition %q; got %v", tc.name, byName)
+			continue
+		}
+		// Declarations come first, so check the first tag.
+		tag := tags[0]
+		if tag.SymbolKind != tc.kind {
+			t.Errorf("%s: kind = %q, want %q", tc.name, tag.SymbolKind, tc.kind)
+		}
+		if tag.Signature != tc.sig {
+			t.Errorf("%s: sig = %q, want %q", tc.name, tag.Signature, tc.sig)
+		}
+	}
+
+	// Out-of-line definitions carry the same qualified name as the
+	// in-class declaration.
+	for name, want := range map[string]string{
+		"engine::render::Widget::draw":    "void Widget::draw() const",
+		"engine::render::Widget::make":    "Widget* Widget::make()",
+		"engine::render::Widget::~Widget": "Widget::~Widget()",
+		"helper":                          "int helper(int x)",
+	} {
+		tags := byName[name]
+		if len(tags) != 2 || tags[1].Signature != want {
+			t.Errorf("%s: want declaration and definition %q, got %v", name, want, tags)
+		}
+	}
+}
+
+func TestCppIncludesAndCalls(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "cpp")
+
+	src := `#include "widget.h"
+#include <vector>
+
+namespace ui {
+void Widget::draw() const {
+  canvas.paint();
+  ptr->flush();
+  gfx::clear(1);
+  auto f = [this]() { render(); };
+  new Shape();
+}
+}
+
+int main() { return make<int>(); }
+`
+	refs := filterRefs(extract(src))
+	enclosing := map[string]string{}
+	imports := map[string]bool{}
+	for _, r := range refs {
+		if r.SymbolKind == model.Module {
+			imports[r.Name] = true
+		} else {
+			enclosing[r.Name] = r.Enclosing
+		}
+	}
+	for _, want := range []string{"widget.h", "vector"} {
+		if !imports[want] {
+			t.Errorf("missing include %q; got %v", want, imports)
+		}
+	}
+	for name, want := range map[string]string{
+		"paint":  "ui::Widget::draw",
+		"flush":  "ui::Widget::draw",
+		"clear":  "ui::Widget::draw",
+		"render": "ui::Widget::draw", // inside a lambda
+		"Shape":  "ui::Widget::draw",
+		"make":   "main",
+	} {
+		if got, ok := enclosing[name]; !ok || got != want {
+			t.Errorf("%s enclosing = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPythonDocstrings(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "python")
+
+	src := `class Store:
+    """Persist users to disk. Thread-safe.
+
+    Longer description.
+    """
+
+    limit = 10
+
+    def save(self, user):
+        r'''Write user
+        atomically.'''
+        pass
+
+def helper():
+    x = "not a docstring"
+`
+	docs := map[string]string{}
+	for _, tag := range filterDefs(extract(src)) {
+		docs[tag.Name] = tag.Doc
+	}
+	for name, want := range map[string]string{
+		"Store":       "Persist users to disk.",
+		"Store.save":  "Write user atomically.",
+		"Store.limit": "",
+		"helper":      "",
+	} {
+		if got := docs[name]; got != want {
+			t.Errorf("%s doc = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGoDocComments(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "go")
+
+	src := `package store
+
+// Store persists users
+// to disk. It is safe for concurrent use.
+type Store struct {
+	// Dir is the storage root.
+	Dir string
+}
+
+var count = 1 // not a doc comment
+func Helper() {}
+
+type (
+	// ID identifies a user.
+	ID int
+	Name string
+)
+
+// Save writes a user.
+//
+// Details follow.
+func (s *Store) Save() {}
+
+// Limit caps the store size.
+const Limit = 10
+`
+	docs := map[string]string{}
+	for _, tag := range filterDefs(extract(src)) {
+		docs[tag.Name] = tag.Doc
+	}
+	for name, want := range map[string]string{
+		"Store":      "Store persists users to disk.",
+		"Store.Dir":  "Dir is the storage root.",
+		"Helper":     "",
+		"ID":         "ID identifies a user.",
+		"Name":       "",
+		"Store.Save": "Save writes a user.",
+		"Limit":      "Limit caps the store size.",
+	} {
+		if got := docs[name]; got != want {
+			t.Errorf("%s doc = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRubyFileImports(t *testing.T) {
+	t.Parallel()
+	l := lang.Languages["ruby"]
+	q, err := l.GetTagQuery()
+	if err != nil {
+		t.Fatalf("GetTagQuery: %v", err)
+	}
+
+	src := `require "json"
+require_relative "billing/invoice"
+require File.expand_path("../config", __FILE__)
+require "gem/#{name}"
+
+module Shop
+  begin
+    require 'shop/cart'
+  rescue LoadError
+  end
+end
+`
+	fi, _ := ExtractFile(context.Background(), l, l.NewParser(), q, []byte(src), "lib/shop.rb")
+	want := []model.Import{
+		{Path: "json"},
+		{Path: "billing/invoice", Relative: true},
+		{Path: "shop/cart"},
+	}
+	if !reflect.DeepEqual(fi.Imports, want) {
+		t.Errorf("Imports = %+v, want %+v", fi.Imports, want)
+	}
+}
+
+func TestGoPackageClause(t *testing.T) {
+	t.Parallel()
+	l := lang.Languages["go"]
+	q, err := l.GetTagQuery()
+	if err != nil {
+		t.Fatalf("GetTagQuery: %v", err)
+	}
+
+	documented := `// Copyright 2024 The Authors.
+
+// Package server answers requests. It is safe for concurrent use.
+package server
+
+func Serve() {}
+`
+	fi, _ := ExtractFile(context.Background(), l, l.NewParser(), q, []byte(documented), "server/server.go")
+	if fi.Package != "server" {
+		t.Errorf("Package = %q, want server", fi.Package)
+	}
+	if want := "Package server answers requests."; fi.PackageDoc != want {
+		t.Errorf("PackageDoc = %q, want %q", fi.PackageDoc, want)
+	}
+	if len(filterDefs(fi.Tags)) != 1 {
+		t.Errorf("tags = %v, want the one definition", fi.Tags)
+	}
+
+	fi, _ = ExtractFile(context.Background(), l, l.NewParser(), q, []byte("package server\n\nfunc Handle() {}\n"), "server/handle.go")
+	if fi.Package != "server" || fi.PackageDoc != "" {
+		t.Errorf("undocumented file: Package = %q, PackageDoc = %q", fi.Package, fi.PackageDoc)
+	}
+
+	py := lang.Languages["python"]
+	pq, err := py.GetTagQuery()
+	if err != nil {
+		t.Fatalf("GetTagQuery: %v", err)
+	}
+	if fi, _ := ExtractFile(context.Background(), py, py.NewParser(), pq, []byte("def f():\n    pass\n"), "f.py"); fi.Package != "" {
+		t.Errorf("Python file has Package %q", fi.Package)
+	}
+}
+
+func TestExtractFileCanceled(t *testing.T) {
+	t.Parallel()
+	l := lang.Languages["go"]
+	q, err := l.GetTagQuery()
+	if err != nil {
+		t.Fatalf("GetTagQuery: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fi, err := ExtractFile(ctx, l, l.NewParser(), q, []byte("package p\n\nfunc F() {}\n"), "p.go")
+	if err == nil || len(fi.Tags) != 0 {
+		t.Errorf("canceled parse: tags %v, err %v; want no tags and an error", fi.Tags, err)
+	}
+}
+
+func TestGoPartialParse(t *testing.T) {
+	t.Parallel()
+	l := lang.Languages["go"]
+	q, err := l.GetTagQuery()
+	if err != nil {
+		t.Fatalf("GetTagQuery: %v", err)
+	}
+
+	src := `//go:build windows
+
+package win
+
+/*
+#include <windows.h>
+*/
+import "C"
+
+func Before() {}
+
+func Broken( {
+	x :=
+}
+
+type After struct {
+	Name string
+}
+
+func Last() { Before() }
+`
+	tags, syntaxErrors := ExtractTags(context.Background(), l, l.NewParser(), q, []byte(src), "win.go")
+	if !syntaxErrors {
+		t.Error("expected syntaxErrors for a malformed declaration")
+	}
+	names := map[string]bool{}
+	for _, tag := range filterDefs(tags) {
+		names[tag.Name] = true
+	}
+	for _, want := range []string{"Before", "After", "After.Name", "Last"} {
+		if !names[want] {
+			t.Errorf("missing %q recovered around the syntax error; got %v", want, names)
+		}
+	}
+
+	if _, syntaxErrors := ExtractTags(context.Background(), l, l.NewParser(), q, []byte("package ok\n\nfunc F() {}\n"), "ok.go"); syntaxErrors {
+		t.Error("valid file reported syntax errors")
+	}
+}
+
+func filterEntries(tags []model.Tag) map[string]int {
+	out := map[string]int{}
+	for _, t := range tags {
+		if t.Kind == model.Entry {
+			out[t.Name] = t.Line
+		}
+	}
+	return out
+}
+
+func TestGoEntrypoints(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "go")
+
+	src := `package main
+
+func main() {}
+
+func helper() {}
+
+func handleUsers(w http.ResponseWriter, r *http.Request) {}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+func runServe(cmd *cobra.Command, args []string) error { return nil }
+`
+	got := filterEntries(extract(src))
+	want := map[string]int{"main": 3, "handleUsers": 7, "Server.ServeHTTP": 9, "runServe": 11}
+	if len(got) != len(want) {
+		t.Errorf("entrypoints = %v, want %v", got, want)
+	}
+	for name, line := range want {
+		if got[name] != line {
+			t.Errorf("%s: line %d, want %d (got %v)", name, got[name], line, got)
+		}
+	}
+
+	// main outside package main is an ordinary function.
+	if got := filterEntries(extract("package lib\n\nfunc main() {}\n")); len(got) != 0 {
+		t.Errorf("library main should not be an entrypoint: %v", got)
+	}
+}
+
+func TestPythonEntrypoints(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "python")
+
+	src := `import click
+
+@app.route("/users")
+def list_users():
+    pass
+
+@click.command()
+def cli():
+    pass
+
+@functools.cache
+def cached():
+    pass
+
+def main():
+    cli()
+
+class Tool:
+    def main(self):
+        pass
+
+if __name__ == "__main__":
+    main()
+`
+	got := filterEntries(extract(src))
+	want := map[string]int{"list_users": 4, "cli": 8, "main": 15, "__main__": 22}
+	if len(got) != len(want) {
+		t.Errorf("entrypoints = %v, want %v", got, want)
+	}
+	for name, line := range want {
+		if got[name] != line {
+			t.Errorf("%s: line %d, want %d (got %v)", name, got[name], line, got)
+		}
+	}
+}
+
+func TestJavaEntrypoints(t *testing.T) {
+	t.Parallel()
+	_, extract := setup(t, "java")
+
+	src := `class App {
+    public static void main(String[] args) {}
+    void main() {}
+    @GetMapping("/users")
+    public List<User> users() { return null; }
+}
+`
+	got := filterEntries(extract(src))
+	want := map[string]int{"App.main": 2, "App.users": 5}
+	if len(got) != len(want) || got["App.main"] != 2 || got["App.users"] != 5 {
+		t.Errorf("entrypoints = %v, want %v", got, want)
+	}
+}
+
+func TestExportedVisibility(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		lang string
+		src  string
+		want map[string]bool // definition name → exported
+	}{
+		{"go", `package p
+
+type Server struct {
+	Addr string
+	conn int
+}
+
+type server struct{}
+
+func (s *Server) Handle() {}
+func (s *Server) handle() {}
+func (s server) Handle()  {}
+func New() *Server        { return nil }
+func helper()             {}
+`, map[string]bool{
+			"Server": true, "Server.Addr": true, "Server.conn": false, "server": false,
+			"Server.Handle": true, "Server.handle": false, "server.Handle": false,
+			"New": true, "helper": false,
+		}},
+		{"python", `class User:
+    _count = 0
+    name: str = ""
+
+    def __init__(self):
+        pass
+
+    def _validate(self):
+        pass
+
+    def save(self):
+        pass
+
+class _Cache:
+    def get(self):
+        pass
+
+def _helper():
+    pass
+
+def load():
+    pass
+`, map[string]bool{
+			"User": true, "User._count": false, "User.name": true,
+			"User.__init__": true, "User._validate": false, "User.save": true,
+			"_Cache": false, "_Cache.get": false, "_helper": false, "load": true,
+		}},
+		{"ruby", `class Account
+  attr_reader :id
+
+  def deposit; end
+
+  private def audit; end
+
+  def reconcile; end
+  private :reconcile
+
+  def self.create; end
+
+  protected
+
+  def compare; end
+
+  public
+
+  def balance; end
+
+  private
+
+  attr_accessor :secret
+
+  def fee; end
+end
+`, map[string]bool{
+			"Account": true, "Account.id": true, "Account.deposit": true,
+			"Account.audit": false, "Account.reconcile": false, "Account.create": true,
+			"Account.compare": false, "Account.balance": true,
+			"Account.secret": false, "Account.fee": false,
+		}},
+		{"javascript", `export class Store {
+  #cache = null;
+  size = 0;
+
+  get(key) {}
+
+  #evict() {}
+}
+
+class Queue {
+  push() {}
+}
+
+export const load = () => {};
+const helper = () => {};
+
+function parse() {
+  function inner() {}
+}
+
+function render() {}
+
+export { render };
+export default Queue;
+`, map[string]bool{
+			"Store": true, "Store.size": true,
+			"Store.get": true, "Store.#evict": false,
+			"Queue": true, "Queue.push": true,
+			"load": true, "helper": false, "parse": false, "inner": false, "render": true,
+		}},
+		{"typescript", `export interface Options {
+  verbose: boolean;
+}
+
+interface Internal {
+  id: number;
+}
+
+export class Client {
+  private token = "";
+  protected retries = 0;
+  timeout = 0;
+
+  send(): void {}
+
+  private sign(): void {}
+}
+
+export default function connect(): void {}
+
+function helper(): void {}
+`, map[string]bool{
+			"Options": true, "Options.verbose": true, "Internal": false, "Internal.id": false,
+			"Client": true, "Client.token": false, "Client.retries": false, "Client.timeout": true,
+			"Client.send": true, "Client.sign": false,
+			"connect": true, "helper": false,
+		}},
+		{"javascript", `function handler() {}
+
+module.exports = { handler };
+`, map[string]bool{"handler": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			t.Parallel()
+			_, extract := setup(t, tt.lang)
+			got := make(map[string]bool)
+			for _, tag := range filterDefs(extract(tt.src)) {
+				got[tag.Name] = !tag.Unexported
+			}
+			for name, exported := range tt.want {
+				if e, ok := got[name]; !ok {
+					t.Errorf("missing definition %s (got %v)", name, got)
+				} else if e != exported {
+					t.Errorf("%s exported = %v, want %v", name, e, exported)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Package pbout implements protobuf encoding of a repository map, for
+// machine-to-machine transport where JSON is too bulky.
+//
+// The schema is repomap.proto in this directory, and repomap.pb.go holds the
+// Go types generated from it. Encode fills them from the JSON document
+// (jsonout.Convert), so the two formats carry the same content.
+package pbout
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative repomap.proto
+
+import (
+	"google.golang.org/protobuf/proto"
+
+	"github.com/phobologic/repoguide/internal/jsonout"
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+// Encode returns rm serialized as a repoguide.v1.RepoMap message. As with
+// JSON, only definition tags are included and ranks are rounded to four
+// decimal places. Marshaling is deterministic, so the same map always
+// gives the same bytes.
+func Encode(rm *model.RepoMap) ([]byte, error) {
+	return proto.MarshalOptions{Deterministic: true}.Marshal(Convert(rm))
+}
+
+// Convert builds the protobuf message for rm.
+func Convert(rm *model.RepoMap) *RepoMap {
+	doc := jsonout.Convert(rm)
+	out := &RepoMap{Repo: doc.Repo, Root: doc.Root}
+	for i := range doc.Files {
+		f := &doc.Files[i]
+		pf := &File{Path: f.Path, Language: f.Language, Package: f.Package, Rank: f.Rank, Owners: f.Owners}
+		for j := range f.Tags {
+			pf.Tags = append(pf.Tags, convertTag(&f.Tags[j]))
+		}
+		out.Files = append(out.Files, pf)
+	}
+	for _, d := range doc.Dependencies {
+		out.Dependencies = append(out.Dependencies, &Dependency{Source: d.Source, Target: d.Target, Symbols: d.Symbols})
+	}
+	for _, c := range doc.Calls {
+		out.Calls = append(out.Calls, &CallEdge{Caller: c.Caller, Callee: c.Callee, Count: int32(c.Count)})
+	}
+	for _, cs := range doc.CallSites {
+		site := &CallSite{Caller: cs.Caller, Callee: cs.Callee, File: cs.File, Line: int32(cs.Line), Kind: cs.Kind}
+		for _, l := range cs.Lines {
+			site.Lines = append(site.Lines, int32(l))
+		}
+		out.Callsites = append(out.Callsites, site)
+	}
+	for i := range doc.Members {
+		out.Members = append(out.Members, convertTag(&doc.Members[i]))
+	}
+	for _, c := range doc.Cycles {
+		out.Cycles = append(out.Cycles, &Cycle{Files: c})
+	}
+	for _, e := range doc.Externals {
+		out.Externals = append(out.Externals, &External{Name: e.Name, Count: int32(e.Count)})
+	}
+	for _, r := range doc.Refs {
+		out.Refs = append(out.Refs, &Ref{File: r.File, Name: r.Name, Line: int32(r.Line)})
+	}
+	for _, e := range doc.Entrypoints {
+		out.Entrypoints = append(out.Entrypoints, &Entrypoint{File: e.File, Name: e.Name, Line: int32(e.Line)})
+	}
+	for _, m := range doc.Metrics {
+		out.Metrics = append(out.Metrics, &Metric{File: m.File, InDegree: int32(m.InDegree), OutDegree: int32(m.OutDegree), Rank: m.Rank})
+	}
+	for _, d := range doc.Diagnostics {
+		out.Diagnostics = append(out.Diagnostics, &Diagnostic{File: d.File, Message: d.Message})
+	}
+	for i := range doc.Unused {
+		out.Unused = append(out.Unused, convertUnused(&doc.Unused[i]))
+	}
+	for _, p := range doc.Packages {
+		out.Packages = append(out.Packages, &Package{Dir: p.Dir, Name: p.Name, Doc: p.Doc})
+	}
+	for i := range doc.Unreachable {
+		out.Unreachable = append(out.Unreachable, convertUnused(&doc.Unreachable[i]))
+	}
+	return out
+}
+
+// convertTag returns the Tag message for t.
+func convertTag(t *jsonout.Tag) *Tag {
+	return &Tag{Name: t.Name, Kind: t.Kind, Line: int32(t.Line), Signature: t.Signature}
+}
+
+// convertUnused returns the Unused message for u.
+func convertUnused(u *jsonout.Unused) *Unused {
+	return &Unused{File: u.File, Name: u.Name, Kind: u.Kind, Line: int32(u.Line)}
+}
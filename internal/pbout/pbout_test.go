@@ -0,0 +1,129 @@
+package pbout
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "myproject",
+		Root:     "myproject",
+		Files: []model.FileInfo{
+			{
+				Path: "models.py", Language: "python", Rank: 0.275512,
+				Tags: []model.Tag{
+					{Name: "User", Kind: model.Definition, SymbolKind: model.Class, Line: 10, Signature: "User"},
+					{Name: "json", Kind: model.Reference, SymbolKind: model.Module, Line: 1},
+				},
+			},
+		},
+		Dependencies: []model.Dependency{{Source: "main.py", Target: "models.py", Symbols: []string{"User", "save"}}},
+		CallEdges:    []model.CallEdge{{Caller: "greet", Callee: "User", Count: 2}},
+		CallSites:    []model.CallSite{{Caller: "greet", Callee: "User", File: "main.py", Line: 3, Lines: []int{3, 300}, Kind: model.SiteCall}},
+		Cycles:       [][]string{{"a.py", "b.py"}},
+		Unreachable:  []model.Tag{{Name: "legacy", SymbolKind: model.Function, File: "old.py", Line: 4}},
+	}
+
+	data, err := Encode(rm)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var got RepoMap
+	if err := proto.Unmarshal(data, &got); err != nil {
+		t.Fatalf("output does not decode as repoguide.v1.RepoMap: %v", err)
+	}
+
+	if got.Repo != "myproject" || len(got.Files) != 1 {
+		t.Fatalf("decoded = %v", &got)
+	}
+	f := got.Files[0]
+	if f.Path != "models.py" || f.Rank != 0.2755 || len(f.Tags) != 1 || f.Tags[0].Kind != "class" || f.Tags[0].Line != 10 {
+		t.Errorf("file = %v, want models.py ranked 0.2755 with one class definition", f)
+	}
+	if len(got.Dependencies) != 1 || len(got.Dependencies[0].Symbols) != 2 {
+		t.Errorf("dependencies = %v", got.Dependencies)
+	}
+	if len(got.Calls) != 1 || got.Calls[0].Count != 2 {
+		t.Errorf("calls = %v", got.Calls)
+	}
+	if len(got.Callsites) != 1 || got.Callsites[0].Kind != "call" || len(got.Callsites[0].Lines) != 2 || got.Callsites[0].Lines[1] != 300 {
+		t.Errorf("callsites = %v", got.Callsites)
+	}
+	if len(got.Cycles) != 1 || len(got.Cycles[0].Files) != 2 {
+		t.Errorf("cycles = %v", got.Cycles)
+	}
+	if len(got.Unreachable) != 1 || got.Unreachable[0].Name != "legacy" {
+		t.Errorf("unreachable = %v", got.Unreachable)
+	}
+
+	again, err := Encode(rm)
+	if err != nil || string(again) != string(data) {
+		t.Error("Encode is not deterministic")
+	}
+}
+
+func TestEncodeEmpty(t *testing.T) {
+	t.Parallel()
+
+	got, err := Encode(&model.RepoMap{})
+	if err != nil || len(got) != 0 {
+		t.Errorf("empty map = %x (%v), want no bytes", got, err)
+	}
+}
+
+// TestGeneratedMatchesProto guards against editing repomap.proto without
+// regenerating repomap.pb.go: every message and field declared in the
+// .proto must be in the generated descriptor with the same number and type,
+// and the other way around.
+func TestGeneratedMatchesProto(t *testing.T) {
+	t.Parallel()
+
+	src, err := os.ReadFile("repomap.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageRe := regexp.MustCompile(`(?s)message (\w+) \{(.*?)\n\}`)
+	fieldRe := regexp.MustCompile(`(?m)^\s*(repeated )?(\w+) (\w+) = (\d+);`)
+
+	fd := File_repomap_proto
+	declared := messageRe.FindAllStringSubmatch(string(src), -1)
+	if len(declared) != fd.Messages().Len() {
+		t.Errorf("repomap.proto declares %d messages, repomap.pb.go has %d: regenerate with go generate", len(declared), fd.Messages().Len())
+	}
+	for _, m := range declared {
+		md := fd.Messages().ByName(protoreflect.Name(m[1]))
+		if md == nil {
+			t.Errorf("message %s is missing from repomap.pb.go: regenerate with go generate", m[1])
+			continue
+		}
+		fields := fieldRe.FindAllStringSubmatch(m[2], -1)
+		if len(fields) != md.Fields().Len() {
+			t.Errorf("message %s: repomap.proto has %d fields, repomap.pb.go %d: regenerate with go generate", m[1], len(fields), md.Fields().Len())
+		}
+		for _, f := range fields {
+			num, _ := strconv.Atoi(f[4])
+			fdesc := md.Fields().ByName(protoreflect.Name(f[3]))
+			if fdesc == nil || int(fdesc.Number()) != num || (f[1] != "") != fdesc.IsList() || typeName(fdesc) != f[2] {
+				t.Errorf("field %s.%s = %s differs in repomap.pb.go: regenerate with go generate", m[1], f[3], f[4])
+			}
+		}
+	}
+}
+
+// typeName returns the type of fd as written in a .proto file.
+func typeName(fd protoreflect.FieldDescriptor) string {
+	if fd.Kind() == protoreflect.MessageKind {
+		return string(fd.Message().Name())
+	}
+	return fd.Kind().String()
+}
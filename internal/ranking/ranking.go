@@ -2,8 +2,15 @@
 package ranking
 
 import (
+	"cmp"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 
+	"github.com/phobologic/repoguide/internal/discover"
 	"github.com/phobologic/repoguide/internal/model"
 )
 
@@ -13,9 +20,106 @@ func SelectFiles(rm *model.RepoMap, maxFiles int) *model.RepoMap {
 	if maxFiles <= 0 || maxFiles >= len(rm.Files) {
 		return rm
 	}
+	return pruneToFiles(rm, rm.Files[:maxFiles])
+}
+
+// SelectByMinRank returns a new RepoMap without files ranked below minRank.
+// rm.Files must be sorted by rank descending (as graph.Rank leaves them).
+// Dropped files are pruned like SelectFiles prunes them: they no longer
+// appear as dependency targets, even when a kept file imports them.
+func SelectByMinRank(rm *model.RepoMap, minRank float64) *model.RepoMap {
+	n := 0
+	for n < len(rm.Files) && rm.Files[n].Rank >= minRank {
+		n++
+	}
+	if n == len(rm.Files) {
+		return rm
+	}
+	return pruneToFiles(rm, rm.Files[:n])
+}
+
+// SelectTopPerDir returns a new RepoMap with only the perDir top-ranked
+// files of each directory, so that every subtree is represented rather than
+// just the most central package. rm.Files must be sorted by rank descending;
+// the kept files stay in that global order. If perDir is <= 0, all files are
+// returned.
+func SelectTopPerDir(rm *model.RepoMap, perDir int) *model.RepoMap {
+	if perDir <= 0 {
+		return rm
+	}
+	taken := make(map[string]int)
+	var selected []model.FileInfo
+	for i := range rm.Files {
+		dir := filepath.Dir(rm.Files[i].Path)
+		if taken[dir] < perDir {
+			taken[dir]++
+			selected = append(selected, rm.Files[i])
+		}
+	}
+	if len(selected) == len(rm.Files) {
+		return rm
+	}
+	return pruneToFiles(rm, selected)
+}
+
+// SelectByTokenBudget returns a new RepoMap with the top-ranked files whose
+// estimated encoded size fits within maxTokens, along with that estimate.
+// Files are taken in rank order until the next one would exceed the budget.
+// The estimate is rough (about four characters per token) and covers each
+// file's row, symbol rows, and outgoing dependency rows. If maxTokens is <= 0,
+// all files are returned.
+func SelectByTokenBudget(rm *model.RepoMap, maxTokens int) (*model.RepoMap, int) {
+	depChars := make(map[string]int)
+	for i := range rm.Dependencies {
+		d := &rm.Dependencies[i]
+		depChars[d.Source] += rowChars(d.Source, d.Target, strings.Join(d.Symbols, " "))
+	}
+
+	total := 0
+	n := 0
+	for i := range rm.Files {
+		cost := estimateTokens(&rm.Files[i], depChars[rm.Files[i].Path])
+		if maxTokens > 0 && total+cost > maxTokens {
+			break
+		}
+		total += cost
+		n++
+	}
+
+	if n == len(rm.Files) {
+		return rm, total
+	}
+	return pruneToFiles(rm, rm.Files[:n]), total
+}
 
-	selected := rm.Files[:maxFiles]
-	selectedPaths := make(map[string]struct{}, maxFiles)
+// estimateTokens approximates the encoded token cost of a file: its files
+// row, its symbol rows, and depChars worth of dependency rows.
+func estimateTokens(fi *model.FileInfo, depChars int) int {
+	chars := rowChars(fi.Path, fi.Language, "0.0000") + depChars
+	for j := range fi.Tags {
+		tag := &fi.Tags[j]
+		if tag.Kind == model.Definition {
+			chars += rowChars(fi.Path, tag.Name, string(tag.SymbolKind), "0000", tag.Signature)
+		}
+	}
+	return (chars + 3) / 4
+}
+
+// rowChars returns the length of a TOON row: two-space indent, the cells,
+// comma separators, and the trailing newline.
+func rowChars(cells ...string) int {
+	n := 2 + len(cells)
+	for _, c := range cells {
+		n += len(c)
+	}
+	return n
+}
+
+// pruneToFiles returns a new RepoMap restricted to selected, keeping only the
+// dependencies between selected files and the call edges and call sites whose
+// caller is defined in them, so dropped files leave no dangling edges.
+func pruneToFiles(rm *model.RepoMap, selected []model.FileInfo) *model.RepoMap {
+	selectedPaths := make(map[string]struct{}, len(selected))
 	for i := range selected {
 		selectedPaths[selected[i].Path] = struct{}{}
 	}
@@ -68,17 +172,68 @@ func SelectFiles(rm *model.RepoMap, maxFiles int) *model.RepoMap {
 }
 
 // FilterBySymbol returns a new RepoMap containing only symbols whose name
-// contains substr (case-insensitive), the files that define those symbols,
-// files that define their direct callers and callees, and the edges that
-// connect them.
+// contains any of substrs (case-insensitive), the files that define those symbols,
+// files that define callers and callees within depth hops of them in the call
+// graph, and the edges that connect them. Depth 1 means direct callers and
+// callees; depth 0 keeps only the matched symbols' own files. An entry with
+// glob syntax ("Handle*") is matched as a glob instead of a substring.
 //
 // When withMembers is true and a matched symbol is a class/struct, the members
 // table of the returned RepoMap is populated with that class's field tags.
 // If no top-level definitions match, withMembers triggers a fallback search
 // over member names (the unqualified part after ".").
-func FilterBySymbol(rm *model.RepoMap, substr string, withMembers bool) *model.RepoMap {
-	lower := strings.ToLower(substr)
+//
+// Files are ordered by relevance to the query (see sortByRelevance), with a
+// definition named exactly like an entry (ignoring case, qualified or not)
+// counting as the strongest match.
+func FilterBySymbol(rm *model.RepoMap, substrs []string, withMembers bool, depth int) *model.RepoMap {
+	lower := make([]string, len(substrs))
+	for i, s := range substrs {
+		lower[i] = strings.ToLower(s)
+	}
+	return filterBySymbol(rm, func(name string) bool {
+		name = strings.ToLower(name)
+		for _, s := range lower {
+			if discover.IsGlob(s) {
+				if matchSymbolGlob(s, name) {
+					return true
+				}
+			} else if strings.Contains(name, s) {
+				return true
+			}
+		}
+		return false
+	}, func(name string) bool {
+		_, bare := model.SplitMember(name)
+		name, bare = strings.ToLower(name), strings.ToLower(bare)
+		return slices.Contains(lower, name) || slices.Contains(lower, bare)
+	}, withMembers, depth)
+}
+
+// matchSymbolGlob reports whether a symbol name matches a glob, either as a
+// whole ("Server.*") or by its unqualified name ("Handle*" matches
+// "Server.HandleRequest").
+func matchSymbolGlob(pattern, name string) bool {
+	if discover.MatchGlob(pattern, name) {
+		return true
+	}
+	if i := strings.LastIndexAny(name, ".:"); i >= 0 {
+		return discover.MatchGlob(pattern, name[i+1:])
+	}
+	return false
+}
 
+// FilterBySymbolRegex is FilterBySymbol with symbol names matched against re
+// instead of a substring. Qualified names are matched whole (e.g.
+// "Server.Handle"); the member fallback matches unqualified member names.
+// No match counts as exact when files are ordered by relevance.
+func FilterBySymbolRegex(rm *model.RepoMap, re *regexp.Regexp, withMembers bool, depth int) *model.RepoMap {
+	return filterBySymbol(rm, re.MatchString, nil, withMembers, depth)
+}
+
+// filterBySymbol implements FilterBySymbol for an arbitrary name predicate.
+// exact, which may be nil, reports which matched names are exact matches.
+func filterBySymbol(rm *model.RepoMap, match, exact func(name string) bool, withMembers bool, depth int) *model.RepoMap {
 	// Find matched symbols and their files, excluding field tags from the primary
 	// symbol match (fields are handled separately via the members mechanism).
 	matchedSymbols := make(map[string]struct{})
@@ -86,16 +241,32 @@ func FilterBySymbol(rm *model.RepoMap, substr string, withMembers bool) *model.R
 	for i := range rm.Files {
 		for j := range rm.Files[i].Tags {
 			tag := &rm.Files[i].Tags[j]
-			if tag.Kind == model.Definition && tag.SymbolKind != model.Field &&
-				strings.Contains(strings.ToLower(tag.Name), lower) {
+			if tag.Kind == model.Definition && tag.SymbolKind != model.Field && match(tag.Name) {
 				matchedSymbols[tag.Name] = struct{}{}
 				matchedFiles[rm.Files[i].Path] = struct{}{}
 			}
 		}
 	}
 
+	// Constants belonging to a matched type (Go enum-style const groups) are
+	// shown with it, as its values.
+	if len(matchedSymbols) > 0 {
+		for i := range rm.Files {
+			for j := range rm.Files[i].Tags {
+				tag := &rm.Files[i].Tags[j]
+				if tag.Kind != model.Definition || tag.Owner == "" {
+					continue
+				}
+				if _, ok := matchedSymbols[tag.Owner]; ok {
+					matchedSymbols[tag.Name] = struct{}{}
+					matchedFiles[rm.Files[i].Path] = struct{}{}
+				}
+			}
+		}
+	}
+
 	// Member fallback: if no top-level defs matched and withMembers is requested,
-	// search field tags whose unqualified name (part after ".") contains substr.
+	// search field tags whose unqualified name (part after ".") matches.
 	// Include the owning class in matched symbols for context.
 	if withMembers && len(matchedSymbols) == 0 {
 		for i := range rm.Files {
@@ -104,11 +275,8 @@ func FilterBySymbol(rm *model.RepoMap, substr string, withMembers bool) *model.R
 				if tag.Kind != model.Definition || tag.SymbolKind != model.Field {
 					continue
 				}
-				unqualified := tag.Name
-				if dot := strings.LastIndex(tag.Name, "."); dot >= 0 {
-					unqualified = tag.Name[dot+1:]
-				}
-				if strings.Contains(strings.ToLower(unqualified), lower) {
+				_, unqualified := model.SplitMember(tag.Name)
+				if match(unqualified) {
 					matchedSymbols[tag.Name] = struct{}{}
 					matchedFiles[rm.Files[i].Path] = struct{}{}
 				}
@@ -117,16 +285,21 @@ func FilterBySymbol(rm *model.RepoMap, substr string, withMembers bool) *model.R
 	}
 
 	// Expand to include files that define callers/callees of matched symbols.
+	dist := expandCallGraph(rm.CallEdges, matchedSymbols, depth)
 	relatedSymbols := make(map[string]struct{})
-	for i := range rm.CallEdges {
-		ce := &rm.CallEdges[i]
-		if _, ok := matchedSymbols[ce.Caller]; ok {
-			relatedSymbols[ce.Callee] = struct{}{}
-		}
-		if _, ok := matchedSymbols[ce.Callee]; ok {
-			relatedSymbols[ce.Caller] = struct{}{}
+	for name := range dist {
+		if _, ok := matchedSymbols[name]; !ok {
+			relatedSymbols[name] = struct{}{}
 		}
 	}
+	// traversed reports whether a call edge was followed during expansion:
+	// one endpoint lies inside the neighborhood (fewer than depth hops out)
+	// and the other was reached.
+	traversed := func(caller, callee string) bool {
+		dCaller, callerOK := dist[caller]
+		dCallee, calleeOK := dist[callee]
+		return callerOK && calleeOK && (dCaller < depth || dCallee < depth)
+	}
 	for i := range rm.Files {
 		for j := range rm.Files[i].Tags {
 			tag := &rm.Files[i].Tags[j]
@@ -162,6 +335,7 @@ func FilterBySymbol(rm *model.RepoMap, substr string, withMembers bool) *model.R
 			files = append(files, fi)
 		}
 	}
+	sortByRelevance(files, matchedSymbols, exact)
 
 	// Collect members when requested.
 	var members []model.Tag
@@ -174,11 +348,10 @@ func FilterBySymbol(rm *model.RepoMap, substr string, withMembers bool) *model.R
 					continue
 				}
 				// Check if the owning type (prefix before ".") is a matched class.
-				dot := strings.LastIndex(tag.Name, ".")
-				if dot < 0 {
+				ownerName, _ := model.SplitMember(tag.Name)
+				if ownerName == "" {
 					continue
 				}
-				ownerName := tag.Name[:dot]
 				if _, ok := matchedSymbols[ownerName]; ok {
 					members = append(members, *tag)
 				}
@@ -214,9 +387,7 @@ func FilterBySymbol(rm *model.RepoMap, substr string, withMembers bool) *model.R
 	var callEdges []model.CallEdge
 	for i := range rm.CallEdges {
 		ce := &rm.CallEdges[i]
-		_, callerOK := matchedSymbols[ce.Caller]
-		_, calleeOK := matchedSymbols[ce.Callee]
-		if callerOK || calleeOK {
+		if traversed(ce.Caller, ce.Callee) {
 			callEdges = append(callEdges, *ce)
 		}
 	}
@@ -224,9 +395,9 @@ func FilterBySymbol(rm *model.RepoMap, substr string, withMembers bool) *model.R
 	var callSites []model.CallSite
 	for i := range rm.CallSites {
 		cs := &rm.CallSites[i]
-		_, callerOK := matchedSymbols[cs.Caller]
-		_, calleeOK := matchedSymbols[cs.Callee]
-		if callerOK || calleeOK {
+		// Import sites have no caller symbol; keep those that name a matched symbol.
+		_, calleeMatched := matchedSymbols[cs.Callee]
+		if traversed(cs.Caller, cs.Callee) || (cs.Kind == model.SiteImport && calleeMatched && depth > 0) {
 			callSites = append(callSites, *cs)
 		}
 	}
@@ -242,16 +413,158 @@ func FilterBySymbol(rm *model.RepoMap, substr string, withMembers bool) *model.R
 	}
 }
 
+// sortByRelevance orders the files of a symbol query so that those defining
+// what was asked for come first: files with more exact matches, then files
+// with more matched definitions, ahead of files shown only for the callers
+// and callees the query expanded to. Ties keep their order, which is global
+// rank; ranks themselves are unchanged.
+func sortByRelevance(files []model.FileInfo, matched map[string]struct{}, exact func(name string) bool) {
+	type relevance struct{ exact, matched int }
+	scores := make(map[string]relevance, len(files))
+	for i := range files {
+		var r relevance
+		for j := range files[i].Tags {
+			name := files[i].Tags[j].Name
+			if _, ok := matched[name]; !ok {
+				continue
+			}
+			r.matched++
+			if exact != nil && exact(name) {
+				r.exact++
+			}
+		}
+		scores[files[i].Path] = r
+	}
+	slices.SortStableFunc(files, func(a, b model.FileInfo) int {
+		ra, rb := scores[a.Path], scores[b.Path]
+		return cmp.Or(cmp.Compare(rb.exact, ra.exact), cmp.Compare(rb.matched, ra.matched))
+	})
+}
+
+// expandCallGraph walks the call graph breadth-first from seeds, following
+// edges in both directions (callers and callees), for up to depth hops. It
+// returns every reached symbol with its hop distance (0 for seeds). Symbols
+// are visited at most once, so cycles terminate.
+func expandCallGraph(edges []model.CallEdge, seeds map[string]struct{}, depth int) map[string]int {
+	dist := make(map[string]int, len(seeds))
+	frontier := make(map[string]struct{}, len(seeds))
+	for name := range seeds {
+		dist[name] = 0
+		frontier[name] = struct{}{}
+	}
+
+	for hop := 1; hop <= depth && len(frontier) > 0; hop++ {
+		next := make(map[string]struct{})
+		for i := range edges {
+			ce := &edges[i]
+			if _, ok := frontier[ce.Caller]; ok {
+				if _, seen := dist[ce.Callee]; !seen {
+					next[ce.Callee] = struct{}{}
+				}
+			}
+			if _, ok := frontier[ce.Callee]; ok {
+				if _, seen := dist[ce.Caller]; !seen {
+					next[ce.Caller] = struct{}{}
+				}
+			}
+		}
+		for name := range next {
+			dist[name] = hop
+		}
+		frontier = next
+	}
+
+	return dist
+}
+
+// FilterByCallers returns a new RepoMap answering "who calls X": the call
+// edges and call sites whose callee contains substr (case-insensitive), and
+// the files where those calls are made, with their symbols trimmed to the
+// calling functions. Import sites are not calls and are left out, and unlike
+// FilterBySymbol nothing is expanded past the direct callers.
+func FilterByCallers(rm *model.RepoMap, substr string) *model.RepoMap {
+	lower := strings.ToLower(substr)
+	return filterByCall(rm, func(caller, callee string) (bool, string) {
+		return strings.Contains(strings.ToLower(callee), lower), caller
+	})
+}
+
+// FilterByCallees is the outbound counterpart of FilterByCallers: the calls
+// made by functions whose name contains substr, and the files defining the
+// functions they call.
+func FilterByCallees(rm *model.RepoMap, substr string) *model.RepoMap {
+	lower := strings.ToLower(substr)
+	return filterByCall(rm, func(caller, callee string) (bool, string) {
+		return strings.Contains(strings.ToLower(caller), lower), callee
+	})
+}
+
+// filterByCall keeps the call edges and sites for which match reports true,
+// along with the definitions of the symbol it names for each of them (the
+// caller or the callee) and the files containing those definitions.
+func filterByCall(rm *model.RepoMap, match func(caller, callee string) (bool, string)) *model.RepoMap {
+	shown := make(map[string]struct{})
+
+	var callEdges []model.CallEdge
+	for i := range rm.CallEdges {
+		ce := &rm.CallEdges[i]
+		if ok, sym := match(ce.Caller, ce.Callee); ok {
+			callEdges = append(callEdges, *ce)
+			shown[sym] = struct{}{}
+		}
+	}
+
+	var callSites []model.CallSite
+	for i := range rm.CallSites {
+		cs := &rm.CallSites[i]
+		if cs.Kind == model.SiteImport {
+			continue
+		}
+		if ok, _ := match(cs.Caller, cs.Callee); ok {
+			callSites = append(callSites, *cs)
+		}
+	}
+
+	var files []model.FileInfo
+	for i := range rm.Files {
+		fi := rm.Files[i]
+		var filteredTags []model.Tag
+		for j := range fi.Tags {
+			tag := &fi.Tags[j]
+			if tag.Kind != model.Definition || tag.SymbolKind == model.Field {
+				continue
+			}
+			if _, ok := shown[tag.Name]; ok {
+				filteredTags = append(filteredTags, *tag)
+			}
+		}
+		if len(filteredTags) > 0 {
+			fi.Tags = filteredTags
+			files = append(files, fi)
+		}
+	}
+
+	return &model.RepoMap{
+		RepoName:  rm.RepoName,
+		Root:      rm.Root,
+		Files:     files,
+		CallEdges: callEdges,
+		CallSites: callSites,
+	}
+}
+
 // FilterByFile returns a new RepoMap containing only files whose path
 // contains substr (case-insensitive), with all dependency edges touching
-// those files and call edges from functions defined in those files.
+// those files and call edges from functions defined in those files. If substr
+// contains glob syntax (see discover.IsGlob), it is instead matched against
+// the whole relative path with discover.MatchGlob, still case-insensitively.
 func FilterByFile(rm *model.RepoMap, substr string) *model.RepoMap {
-	lower := strings.ToLower(substr)
+	match := pathMatcher(substr)
 
 	matchedFiles := make(map[string]struct{})
 	var files []model.FileInfo
 	for i := range rm.Files {
-		if strings.Contains(strings.ToLower(rm.Files[i].Path), lower) {
+		if match(rm.Files[i].Path) {
 			matchedFiles[rm.Files[i].Path] = struct{}{}
 			files = append(files, rm.Files[i])
 		}
@@ -303,3 +616,222 @@ func FilterByFile(rm *model.RepoMap, substr string) *model.RepoMap {
 		CallSites:    callSites,
 	}
 }
+
+// pathMatcher returns the --file path match for substr: a case-insensitive
+// substring match, or a whole-path glob match if substr has glob syntax.
+func pathMatcher(substr string) func(path string) bool {
+	lower := strings.ToLower(substr)
+	if discover.IsGlob(lower) {
+		return func(p string) bool { return discover.MatchGlob(lower, strings.ToLower(p)) }
+	}
+	return func(p string) bool { return strings.Contains(strings.ToLower(p), lower) }
+}
+
+// FilterByImporters returns a new RepoMap answering "who imports X": the
+// dependency edges into files whose path matches substr (as in
+// FilterByFile) from files that don't match, the importing files, and the
+// matched files with their symbols trimmed to the ones imported. Call sites
+// are kept where an importer uses one of the symbols it imports, so every
+// line that breaks when that API changes is listed.
+func FilterByImporters(rm *model.RepoMap, substr string) *model.RepoMap {
+	match := pathMatcher(substr)
+
+	var deps []model.Dependency
+	imported := make(map[string]struct{})              // symbols imported from matched files
+	importedBy := make(map[string]map[string]struct{}) // importer path -> symbols it imports
+	for i := range rm.Dependencies {
+		d := &rm.Dependencies[i]
+		if !match(d.Target) || match(d.Source) {
+			continue
+		}
+		deps = append(deps, *d)
+		if importedBy[d.Source] == nil {
+			importedBy[d.Source] = make(map[string]struct{})
+		}
+		for _, sym := range d.Symbols {
+			imported[sym] = struct{}{}
+			importedBy[d.Source][sym] = struct{}{}
+		}
+	}
+
+	var files []model.FileInfo
+	for i := range rm.Files {
+		fi := rm.Files[i]
+		switch {
+		case match(fi.Path):
+			var tags []model.Tag
+			for j := range fi.Tags {
+				tag := &fi.Tags[j]
+				if tag.Kind != model.Definition {
+					continue
+				}
+				if _, ok := imported[tag.Name]; ok {
+					tags = append(tags, *tag)
+				}
+			}
+			fi.Tags = tags
+		case importedBy[fi.Path] != nil:
+			fi.Tags = nil
+		default:
+			continue
+		}
+		files = append(files, fi)
+	}
+
+	var callSites []model.CallSite
+	for i := range rm.CallSites {
+		cs := &rm.CallSites[i]
+		if _, ok := importedBy[cs.File][cs.Callee]; ok {
+			callSites = append(callSites, *cs)
+		}
+	}
+
+	return &model.RepoMap{
+		RepoName:     rm.RepoName,
+		Root:         rm.Root,
+		Files:        files,
+		Dependencies: deps,
+		CallSites:    callSites,
+	}
+}
+
+// FilterExported returns a copy of rm without unexported definitions (see
+// model.Tag.Unexported) in its files and members, leaving the symbols table
+// a public-API surface. Files, references, and edges are kept as they are.
+func FilterExported(rm *model.RepoMap) *model.RepoMap {
+	unexported := func(t model.Tag) bool {
+		return t.Kind == model.Definition && t.Unexported
+	}
+	out := *rm
+	out.Files = make([]model.FileInfo, len(rm.Files))
+	for i, fi := range rm.Files {
+		fi.Tags = slices.DeleteFunc(slices.Clone(fi.Tags), unexported)
+		out.Files[i] = fi
+	}
+	out.Members = slices.DeleteFunc(slices.Clone(rm.Members), unexported)
+	return &out
+}
+
+// PruneUnreachable returns a new RepoMap without the functions and methods
+// that are not in reached (see graph.Reachable), along with those pruned
+// definitions, File set, sorted by file and then line. Other definitions,
+// such as types and constants, stay in the files that are kept, but a file
+// is dropped, like a file below -n, when none of its definitions is reached.
+func PruneUnreachable(rm *model.RepoMap, reached map[string]struct{}) (*model.RepoMap, []model.Tag) {
+	var (
+		kept        []model.FileInfo
+		unreachable []model.Tag
+	)
+	for _, fi := range rm.Files {
+		live := false
+		tags := make([]model.Tag, 0, len(fi.Tags))
+		for _, t := range fi.Tags {
+			if t.Kind != model.Definition {
+				tags = append(tags, t)
+				continue
+			}
+			_, ok := reached[t.Name]
+			if !ok && (t.SymbolKind == model.Function || t.SymbolKind == model.Method) {
+				t.File = fi.Path
+				unreachable = append(unreachable, t)
+				continue
+			}
+			live = live || ok
+			tags = append(tags, t)
+		}
+		if live {
+			fi.Tags = tags
+			kept = append(kept, fi)
+		}
+	}
+	sort.SliceStable(unreachable, func(i, j int) bool {
+		if unreachable[i].File != unreachable[j].File {
+			return unreachable[i].File < unreachable[j].File
+		}
+		return unreachable[i].Line < unreachable[j].Line
+	})
+	return pruneToFiles(rm, kept), unreachable
+}
+
+// CollapseDirs returns a directory-level view of rm: each file's rank is
+// added to its containing directory ("." for top-level files), and file
+// dependencies become edges between directories, with the symbols of every
+// file edge they merge. Edges within a directory are dropped, as are call
+// edges and call sites. Directories are sorted by rank descending, then
+// path; if maxDirs is positive, only the top maxDirs are kept, along with
+// the edges between them.
+func CollapseDirs(rm *model.RepoMap, maxDirs int) *model.RepoMap {
+	byPath := make(map[string]*model.Dir)
+	dirOf := make(map[string]string, len(rm.Files))
+	for i := range rm.Files {
+		fi := &rm.Files[i]
+		dir := filepath.Dir(fi.Path)
+		dirOf[fi.Path] = dir
+		d, ok := byPath[dir]
+		if !ok {
+			d = &model.Dir{Path: dir}
+			byPath[dir] = d
+		}
+		d.Rank += fi.Rank
+		d.Files++
+	}
+
+	dirs := make([]model.Dir, 0, len(byPath))
+	for _, d := range byPath {
+		dirs = append(dirs, *d)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].Rank != dirs[j].Rank {
+			return dirs[i].Rank > dirs[j].Rank
+		}
+		return dirs[i].Path < dirs[j].Path
+	})
+	if maxDirs > 0 && maxDirs < len(dirs) {
+		dirs = dirs[:maxDirs]
+	}
+	kept := make(map[string]struct{}, len(dirs))
+	for _, d := range dirs {
+		kept[d.Path] = struct{}{}
+	}
+
+	type edge struct{ source, target string }
+	symbols := make(map[edge]map[string]struct{})
+	for i := range rm.Dependencies {
+		d := &rm.Dependencies[i]
+		src, srcOK := dirOf[d.Source]
+		tgt, tgtOK := dirOf[d.Target]
+		if !srcOK || !tgtOK || src == tgt {
+			continue
+		}
+		if _, ok := kept[src]; !ok {
+			continue
+		}
+		if _, ok := kept[tgt]; !ok {
+			continue
+		}
+		e := edge{src, tgt}
+		if symbols[e] == nil {
+			symbols[e] = make(map[string]struct{})
+		}
+		for _, sym := range d.Symbols {
+			symbols[e][sym] = struct{}{}
+		}
+	}
+	deps := make([]model.Dependency, 0, len(symbols))
+	for e, syms := range symbols {
+		deps = append(deps, model.Dependency{Source: e.source, Target: e.target, Symbols: slices.Sorted(maps.Keys(syms))})
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i].Source != deps[j].Source {
+			return deps[i].Source < deps[j].Source
+		}
+		return deps[i].Target < deps[j].Target
+	})
+
+	return &model.RepoMap{
+		RepoName:     rm.RepoName,
+		Root:         rm.Root,
+		Dirs:         dirs,
+		Dependencies: deps,
+	}
+}
@@ -1,6 +1,10 @@
 package ranking
 
 import (
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
 	"testing"
 
 	"github.com/phobologic/repoguide/internal/model"
@@ -125,11 +129,99 @@ func TestSelectFilesOne(t *testing.T) {
 	}
 }
 
+func TestSelectByMinRank(t *testing.T) {
+	t.Parallel()
+
+	rm := makeRepoMap()
+	got := SelectByMinRank(rm, 0.25)
+	if names := fileNames(got); len(names) != 2 || names[0] != "a.py" || names[1] != "b.py" {
+		t.Errorf("expected a.py and b.py, got %v", names)
+	}
+	// Only a.py→b.py survives; both edges into c.py are pruned.
+	if len(got.Dependencies) != 1 || got.Dependencies[0].Target != "b.py" {
+		t.Errorf("unexpected deps: %+v", got.Dependencies)
+	}
+
+	if got := SelectByMinRank(rm, 0); len(got.Files) != 3 {
+		t.Errorf("threshold 0 should keep all files, got %d", len(got.Files))
+	}
+	if got := SelectByMinRank(rm, 0.9); len(got.Files) != 0 {
+		t.Errorf("threshold above every rank should drop all files, got %d", len(got.Files))
+	}
+}
+
+func TestSelectTopPerDir(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		Files: []model.FileInfo{
+			{Path: "core/a.go", Rank: 0.4},
+			{Path: "core/b.go", Rank: 0.3},
+			{Path: "core/c.go", Rank: 0.15},
+			{Path: "main.go", Rank: 0.1},
+			{Path: "tools/x.go", Rank: 0.05},
+		},
+		Dependencies: []model.Dependency{
+			{Source: "tools/x.go", Target: "core/a.go"},
+			{Source: "main.go", Target: "core/c.go"},
+		},
+	}
+	got := SelectTopPerDir(rm, 1)
+	if names := fileNames(got); !slices.Equal(names, []string{"core/a.go", "main.go", "tools/x.go"}) {
+		t.Errorf("per dir 1 = %v, want core/a.go main.go tools/x.go", names)
+	}
+	if len(got.Dependencies) != 1 || got.Dependencies[0].Source != "tools/x.go" {
+		t.Errorf("unexpected deps: %+v", got.Dependencies)
+	}
+
+	if names := fileNames(SelectTopPerDir(rm, 2)); len(names) != 4 || names[1] != "core/b.go" {
+		t.Errorf("per dir 2 = %v, want core/a.go core/b.go main.go tools/x.go", names)
+	}
+	if got := SelectTopPerDir(rm, 0); got != rm {
+		t.Error("perDir 0 should return rm unchanged")
+	}
+}
+
+func TestSelectByTokenBudget(t *testing.T) {
+	t.Parallel()
+
+	rm := makeFilterRepoMap()
+
+	// Unlimited budget keeps everything and reports the full estimate.
+	all, total := SelectByTokenBudget(rm, 0)
+	if len(all.Files) != 3 || total <= 0 {
+		t.Fatalf("unlimited: files=%d total=%d", len(all.Files), total)
+	}
+
+	// A budget that fits only the first file's cost.
+	first := estimateTokens(&rm.Files[0], rowChars("a.go", "b.go", "Baz"))
+	got, used := SelectByTokenBudget(rm, first)
+	if names := fileNames(got); len(names) != 1 || names[0] != "a.go" {
+		t.Errorf("expected only a.go, got %v", names)
+	}
+	if used != first {
+		t.Errorf("used = %d, want %d", used, first)
+	}
+	// Dependencies to dropped files are pruned.
+	if len(got.Dependencies) != 0 {
+		t.Errorf("expected no dependencies, got %+v", got.Dependencies)
+	}
+	// Call edges from a.go's functions are kept.
+	if len(got.CallEdges) != 1 || got.CallEdges[0].Caller != "Foo" {
+		t.Errorf("expected Foo→Baz call edge, got %+v", got.CallEdges)
+	}
+
+	// A budget smaller than the top file selects nothing.
+	if none, used := SelectByTokenBudget(rm, 1); len(none.Files) != 0 || used != 0 {
+		t.Errorf("tiny budget: files=%d used=%d", len(none.Files), used)
+	}
+}
+
 func TestFilterBySymbolMatch(t *testing.T) {
 	t.Parallel()
 
 	rm := makeFilterRepoMap()
-	got := FilterBySymbol(rm, "Foo", false)
+	got := FilterBySymbol(rm, []string{"Foo"}, false, 1)
 
 	// Foo is in a.go; Foo calls Baz (b.go) and is called by Qux (c.go) — all 3 files included.
 	if len(got.Files) != 3 {
@@ -145,11 +237,136 @@ func TestFilterBySymbolMatch(t *testing.T) {
 	}
 }
 
+func TestFilterBySymbolRegex(t *testing.T) {
+	t.Parallel()
+
+	rm := makeFilterRepoMap()
+
+	// Anchored alternation: Bar and Baz, but not Foo.
+	got := FilterBySymbolRegex(rm, regexp.MustCompile(`^Ba[rz]$`), false, 0)
+	if names := fileNames(got); len(names) != 2 || names[0] != "a.go" || names[1] != "b.go" {
+		t.Errorf("expected a.go and b.go, got %v", names)
+	}
+
+	// Expansion is unchanged: Qux's callee Foo pulls in a.go.
+	got = FilterBySymbolRegex(rm, regexp.MustCompile(`^Q`), false, 1)
+	if names := fileNames(got); len(names) != 2 {
+		t.Errorf("expected c.go and a.go, got %v", names)
+	}
+
+	// Regexes are case-sensitive unless the pattern says otherwise.
+	if got := FilterBySymbolRegex(rm, regexp.MustCompile(`^foo$`), false, 1); len(got.Files) != 0 {
+		t.Errorf("expected no match for ^foo$, got %v", fileNames(got))
+	}
+}
+
+func TestFilterByCallers(t *testing.T) {
+	t.Parallel()
+
+	rm := makeFilterRepoMap()
+	rm.CallSites = append(rm.CallSites, model.CallSite{Caller: "<import>", Callee: "Baz", File: "c.go", Line: 1, Kind: model.SiteImport})
+
+	got := FilterByCallers(rm, "baz")
+	// Only Foo calls Baz: a.go with just Foo, both call lines, no import site.
+	if names := fileNames(got); len(names) != 1 || names[0] != "a.go" {
+		t.Fatalf("expected only a.go, got %v", names)
+	}
+	if tags := got.Files[0].Tags; len(tags) != 1 || tags[0].Name != "Foo" {
+		t.Errorf("expected only Foo's definition, got %+v", tags)
+	}
+	if len(got.CallEdges) != 1 || got.CallEdges[0].Caller != "Foo" {
+		t.Errorf("unexpected call edges: %+v", got.CallEdges)
+	}
+	if len(got.CallSites) != 2 {
+		t.Errorf("expected 2 call sites, got %+v", got.CallSites)
+	}
+	if len(got.Dependencies) != 0 {
+		t.Errorf("expected no dependencies, got %+v", got.Dependencies)
+	}
+}
+
+func TestFilterByImporters(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "test",
+		Root:     "test",
+		Files: []model.FileInfo{
+			{Path: "pkg/api.go", Tags: []model.Tag{
+				{Name: "Open", Kind: model.Definition, SymbolKind: model.Function, Line: 3},
+				{Name: "Close", Kind: model.Definition, SymbolKind: model.Function, Line: 7},
+				{Name: "internal", Kind: model.Definition, SymbolKind: model.Function, Line: 9},
+			}},
+			{Path: "pkg/impl.go", Tags: []model.Tag{{Name: "helper", Kind: model.Definition, Line: 2}}},
+			{Path: "cmd/main.go", Tags: []model.Tag{{Name: "main", Kind: model.Definition, Line: 1}}},
+			{Path: "cmd/tool.go", Tags: []model.Tag{{Name: "tool", Kind: model.Definition, Line: 1}}},
+			{Path: "other.go", Tags: []model.Tag{{Name: "other", Kind: model.Definition, Line: 1}}},
+		},
+		Dependencies: []model.Dependency{
+			{Source: "cmd/main.go", Target: "pkg/api.go", Symbols: []string{"Open"}},
+			{Source: "cmd/tool.go", Target: "pkg/api.go", Symbols: []string{"Close", "Open"}},
+			{Source: "pkg/impl.go", Target: "pkg/api.go", Symbols: []string{"internal"}},
+			{Source: "cmd/main.go", Target: "other.go", Symbols: []string{"other"}},
+		},
+		CallSites: []model.CallSite{
+			{Caller: "main", Callee: "Open", File: "cmd/main.go", Line: 4},
+			{Caller: "main", Callee: "other", File: "cmd/main.go", Line: 5},
+			{Caller: "tool", Callee: "Close", File: "cmd/tool.go", Line: 6},
+		},
+	}
+
+	// "PKG" matches both pkg files case-insensitively, so the edge between
+	// them is internal and left out.
+	got := FilterByImporters(rm, "PKG")
+	if names := fileNames(got); !slices.Equal(names, []string{"pkg/api.go", "pkg/impl.go", "cmd/main.go", "cmd/tool.go"}) {
+		t.Fatalf("files = %v", names)
+	}
+	var api []string
+	for _, tag := range got.Files[0].Tags {
+		api = append(api, tag.Name)
+	}
+	if !slices.Equal(api, []string{"Open", "Close"}) {
+		t.Errorf("api.go symbols = %v, want only the imported Open and Close", api)
+	}
+	if len(got.Files[2].Tags) != 0 {
+		t.Errorf("importer symbols should be trimmed: %+v", got.Files[2].Tags)
+	}
+	if len(got.Dependencies) != 2 || got.Dependencies[0].Source != "cmd/main.go" || got.Dependencies[1].Source != "cmd/tool.go" {
+		t.Errorf("dependencies = %+v", got.Dependencies)
+	}
+	if len(got.CallSites) != 2 || got.CallSites[0].Callee != "Open" || got.CallSites[1].Callee != "Close" {
+		t.Errorf("call sites = %+v, want the uses of Open and Close", got.CallSites)
+	}
+
+	// A glob matches the whole path, so only api.go is the target here.
+	got = FilterByImporters(rm, "pkg/api.*")
+	if len(got.Dependencies) != 3 {
+		t.Errorf("glob: dependencies = %+v, want all three importers of api.go", got.Dependencies)
+	}
+}
+
+func TestFilterByCallees(t *testing.T) {
+	t.Parallel()
+
+	rm := makeFilterRepoMap()
+	got := FilterByCallees(rm, "Qux")
+	// Qux calls Foo, defined in a.go; Baz (called by Foo) is not expanded to.
+	if names := fileNames(got); len(names) != 1 || names[0] != "a.go" {
+		t.Fatalf("expected only a.go, got %v", names)
+	}
+	if tags := got.Files[0].Tags; len(tags) != 1 || tags[0].Name != "Foo" {
+		t.Errorf("expected only Foo's definition, got %+v", tags)
+	}
+	if len(got.CallSites) != 1 || got.CallSites[0].File != "c.go" || got.CallSites[0].Line != 5 {
+		t.Errorf("unexpected call sites: %+v", got.CallSites)
+	}
+}
+
 func TestFilterBySymbolNoMatch(t *testing.T) {
 	t.Parallel()
 
 	rm := makeFilterRepoMap()
-	got := FilterBySymbol(rm, "NoSuchSymbol", false)
+	got := FilterBySymbol(rm, []string{"NoSuchSymbol"}, false, 1)
 
 	if len(got.Files) != 0 {
 		t.Errorf("expected 0 files, got %d", len(got.Files))
@@ -166,7 +383,7 @@ func TestFilterBySymbolCaseInsensitive(t *testing.T) {
 	t.Parallel()
 
 	rm := makeFilterRepoMap()
-	got := FilterBySymbol(rm, "foo", false) // lowercase matches "Foo"
+	got := FilterBySymbol(rm, []string{"foo"}, false, 1) // lowercase matches "Foo"
 
 	if len(got.Files) == 0 {
 		t.Fatal("expected matches for lowercase 'foo'")
@@ -188,19 +405,68 @@ func TestFilterBySymbolSubstring(t *testing.T) {
 
 	rm := makeFilterRepoMap()
 	// "ba" matches both "Bar" (a.go) and "Baz" (b.go).
-	got := FilterBySymbol(rm, "ba", false)
+	got := FilterBySymbol(rm, []string{"ba"}, false, 1)
 
 	if len(got.Files) < 2 {
 		t.Fatalf("expected at least 2 files for 'ba', got %d: %v", len(got.Files), fileNames(got))
 	}
 }
 
+func TestFilterBySymbolMultiple(t *testing.T) {
+	t.Parallel()
+
+	rm := makeFilterRepoMap()
+	// Depth 0 keeps only the matched symbols' own files: the union of
+	// Baz (b.go) and Qux (c.go), without Foo's a.go between them.
+	got := FilterBySymbol(rm, []string{"baz", "Qux"}, false, 0)
+	names := fileNames(got)
+	if len(names) != 2 || names[0] != "b.go" || names[1] != "c.go" {
+		t.Errorf("expected [b.go c.go], got %v", names)
+	}
+
+	// With expansion, each match brings in its own neighbors.
+	got = FilterBySymbol(rm, []string{"Baz", "Qux"}, false, 1)
+	if len(got.Files) != 3 {
+		t.Errorf("expected 3 files, got %v", fileNames(got))
+	}
+}
+
+func TestFilterBySymbolOwnedConstants(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		Files: []model.FileInfo{
+			{Path: "color.go", Tags: []model.Tag{
+				{Name: "Color", Kind: model.Definition, SymbolKind: model.Class},
+				{Name: "Red", Kind: model.Definition, SymbolKind: model.Constant, Owner: "Color"},
+				{Name: "Green", Kind: model.Definition, SymbolKind: model.Constant, Owner: "Color"},
+				{Name: "MaxLen", Kind: model.Definition, SymbolKind: model.Constant},
+			}},
+			{Path: "paint.go", Tags: []model.Tag{
+				{Name: "Blue", Kind: model.Definition, SymbolKind: model.Constant, Owner: "Color"},
+			}},
+		},
+	}
+	got := FilterBySymbol(rm, []string{"Color"}, false, 0)
+
+	var names []string
+	for _, f := range got.Files {
+		for _, tag := range f.Tags {
+			names = append(names, tag.Name)
+		}
+	}
+	want := []string{"Color", "Red", "Green", "Blue"}
+	if !slices.Equal(names, want) {
+		t.Errorf("symbols = %v, want %v", names, want)
+	}
+}
+
 func TestFilterBySymbolCallExpansion(t *testing.T) {
 	t.Parallel()
 
 	rm := makeFilterRepoMap()
 	// Filter for Baz (defined in b.go). Foo calls Baz, so a.go should be included.
-	got := FilterBySymbol(rm, "Baz", false)
+	got := FilterBySymbol(rm, []string{"Baz"}, false, 1)
 
 	paths := make(map[string]bool)
 	for _, f := range got.Files {
@@ -214,13 +480,98 @@ func TestFilterBySymbolCallExpansion(t *testing.T) {
 	}
 }
 
+func TestFilterBySymbolRelevance(t *testing.T) {
+	t.Parallel()
+
+	def := func(name string) model.Tag {
+		return model.Tag{Name: name, Kind: model.Definition, SymbolKind: model.Function}
+	}
+	rm := &model.RepoMap{
+		Files: []model.FileInfo{
+			{Path: "app.go", Rank: 0.4, Tags: []model.Tag{def("Login")}},
+			{Path: "middleware.go", Rank: 0.3, Tags: []model.Tag{def("AuthMiddleware")}},
+			{Path: "tokens.go", Rank: 0.2, Tags: []model.Tag{def("AuthToken"), def("RefreshAuth")}},
+			{Path: "auth.go", Rank: 0.1, Tags: []model.Tag{def("Auth")}},
+		},
+		CallEdges: []model.CallEdge{{Caller: "Login", Callee: "Auth"}},
+	}
+
+	got := FilterBySymbol(rm, []string{"auth"}, false, 1)
+	// The exact match first, then by number of matches, then the expansion;
+	// ties keep rank order.
+	want := []string{"auth.go", "tokens.go", "middleware.go", "app.go"}
+	if names := fileNames(got); !slices.Equal(names, want) {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+	if got.Files[0].Rank != 0.1 {
+		t.Errorf("ranks should be unchanged, got %v for auth.go", got.Files[0].Rank)
+	}
+
+	// A regex has no exact matches, so only match counts reorder.
+	got = FilterBySymbolRegex(rm, regexp.MustCompile("Auth"), false, 1)
+	want = []string{"tokens.go", "middleware.go", "auth.go", "app.go"}
+	if names := fileNames(got); !slices.Equal(names, want) {
+		t.Errorf("regex files = %v, want %v", names, want)
+	}
+}
+
+func TestFilterBySymbolDepthZero(t *testing.T) {
+	t.Parallel()
+
+	rm := makeFilterRepoMap()
+	got := FilterBySymbol(rm, []string{"Baz"}, false, 0)
+
+	names := fileNames(got)
+	if len(names) != 1 || names[0] != "b.go" {
+		t.Errorf("depth 0 should include only b.go, got %v", names)
+	}
+	if len(got.CallEdges) != 0 {
+		t.Errorf("depth 0 should include no call edges, got %+v", got.CallEdges)
+	}
+}
+
+func TestFilterBySymbolDepthTwo(t *testing.T) {
+	t.Parallel()
+
+	rm := makeFilterRepoMap()
+	// Qux→Foo→Baz: at depth 1 only Foo is related; at depth 2 Qux is too.
+	got := FilterBySymbol(rm, []string{"Baz"}, false, 1)
+	if names := fileNames(got); len(names) != 2 {
+		t.Errorf("depth 1: expected a.go and b.go, got %v", names)
+	}
+
+	got = FilterBySymbol(rm, []string{"Baz"}, false, 2)
+	names := fileNames(got)
+	if len(names) != 3 {
+		t.Errorf("depth 2: expected a.go, b.go, c.go, got %v", names)
+	}
+	if len(got.CallEdges) != 2 {
+		t.Errorf("depth 2: expected both call edges, got %+v", got.CallEdges)
+	}
+	if len(got.CallSites) != 3 {
+		t.Errorf("depth 2: expected all 3 call sites, got %+v", got.CallSites)
+	}
+}
+
+func TestFilterBySymbolDepthCycle(t *testing.T) {
+	t.Parallel()
+
+	rm := makeFilterRepoMap()
+	rm.CallEdges = append(rm.CallEdges, model.CallEdge{Caller: "Baz", Callee: "Qux"})
+	// A cycle Foo→Baz→Qux→Foo must terminate even with a large depth.
+	got := FilterBySymbol(rm, []string{"Baz"}, false, 50)
+	if names := fileNames(got); len(names) != 3 {
+		t.Errorf("expected all 3 files, got %v", names)
+	}
+}
+
 func TestFilterBySymbolDepsEitherEndpoint(t *testing.T) {
 	t.Parallel()
 
 	rm := makeFilterRepoMap()
 	// Filter for Baz (b.go). a.go→b.go dep should be included even though a.go
 	// is included only via expansion (its caller Foo calls Baz).
-	got := FilterBySymbol(rm, "Baz", false)
+	got := FilterBySymbol(rm, []string{"Baz"}, false, 1)
 
 	found := false
 	for _, d := range got.Dependencies {
@@ -277,6 +628,62 @@ func TestFilterByFileCaseInsensitive(t *testing.T) {
 	}
 }
 
+func TestFilterByFileGlob(t *testing.T) {
+	t.Parallel()
+
+	rm := makeFilterRepoMap()
+	rm.Files[0].Path = "internal/srv/a.go"
+	rm.Files[1].Path = "internal/srv/b.py"
+	rm.Files[2].Path = "cmd/c.go"
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"internal/**/*.go", []string{"internal/srv/a.go"}},
+		{"INTERNAL/*/*", []string{"internal/srv/a.go", "internal/srv/b.py"}},
+		{"*.{go,py}", []string{"internal/srv/a.go", "internal/srv/b.py", "cmd/c.go"}},
+		{"srv/*.go", nil}, // globs with a slash are anchored at the root
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			t.Parallel()
+			got := fileNames(FilterByFile(rm, tt.pattern))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FilterByFile(%q) = %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterBySymbolGlob(t *testing.T) {
+	t.Parallel()
+
+	rm := makeFilterRepoMap()
+	rm.Files[0].Tags[1].Name = "Server.HandleRequest"
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"ba*", []string{"b.go"}},               // prefix glob: Baz
+		{"handle*", []string{"a.go"}},           // unqualified name of a method
+		{"server.*", []string{"a.go"}},          // whole qualified name
+		{"{foo,qux}", []string{"a.go", "c.go"}}, // braces list exact names
+		{"q?x", []string{"c.go"}},
+		{"request*", nil}, // globs are anchored, unlike substrings
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			t.Parallel()
+			got := fileNames(FilterBySymbol(rm, []string{tt.pattern}, false, 0))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FilterBySymbol(%q) = %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestFilterByFileSubstring(t *testing.T) {
 	t.Parallel()
 
@@ -302,7 +709,7 @@ func TestFilterBySymbolCallSites(t *testing.T) {
 	t.Parallel()
 
 	rm := makeFilterRepoMap()
-	got := FilterBySymbol(rm, "Foo", false)
+	got := FilterBySymbol(rm, []string{"Foo"}, false, 1)
 
 	// Foo is caller in Foo→Baz (lines 10, 20) and callee in Qux→Foo (line 5)
 	if len(got.CallSites) != 3 {
@@ -317,7 +724,7 @@ func TestFilterBySymbolCallSitesNoMatch(t *testing.T) {
 
 	rm := makeFilterRepoMap()
 	// Bar has no call edges or sites in the fixture.
-	got := FilterBySymbol(rm, "Bar", false)
+	got := FilterBySymbol(rm, []string{"Bar"}, false, 1)
 
 	if len(got.CallSites) != 0 {
 		t.Fatalf("expected 0 call sites, got %d: %+v", len(got.CallSites), got.CallSites)
@@ -358,7 +765,7 @@ func TestFilterBySymbolWithMembersClass(t *testing.T) {
 	t.Parallel()
 
 	rm := makeFieldRepoMap()
-	got := FilterBySymbol(rm, "MyStruct", true)
+	got := FilterBySymbol(rm, []string{"MyStruct"}, true, 1)
 
 	// Symbols table should show MyStruct (class only, no fields).
 	if len(got.Files) != 1 || got.Files[0].Path != "models.go" {
@@ -389,7 +796,7 @@ func TestFilterBySymbolWithoutMembersClass(t *testing.T) {
 	t.Parallel()
 
 	rm := makeFieldRepoMap()
-	got := FilterBySymbol(rm, "MyStruct", false)
+	got := FilterBySymbol(rm, []string{"MyStruct"}, false, 1)
 
 	if len(got.Members) != 0 {
 		t.Fatalf("expected no members with withMembers=false, got %d", len(got.Members))
@@ -403,7 +810,7 @@ func TestFilterBySymbolMemberFallback(t *testing.T) {
 
 	rm := makeFieldRepoMap()
 	// "Count" is not a top-level symbol — it's OtherStruct.Count.
-	got := FilterBySymbol(rm, "Count", true)
+	got := FilterBySymbol(rm, []string{"Count"}, true, 1)
 
 	if len(got.Members) != 1 {
 		t.Fatalf("expected 1 member from fallback, got %d: %+v", len(got.Members), got.Members)
@@ -419,7 +826,7 @@ func TestFilterBySymbolMemberFallbackNoMatch(t *testing.T) {
 	t.Parallel()
 
 	rm := makeFieldRepoMap()
-	got := FilterBySymbol(rm, "NonExistent", true)
+	got := FilterBySymbol(rm, []string{"NonExistent"}, true, 1)
 
 	if len(got.Members) != 0 {
 		t.Errorf("expected no members, got %d", len(got.Members))
@@ -447,3 +854,139 @@ func TestFilterByFileCallSites(t *testing.T) {
 		}
 	}
 }
+
+func TestPruneUnreachable(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		Files: []model.FileInfo{
+			{Path: "main.go", Tags: []model.Tag{
+				{Name: "main", Kind: model.Definition, SymbolKind: model.Function, Line: 3},
+				{Name: "unusedHelper", Kind: model.Definition, SymbolKind: model.Function, Line: 9},
+				{Name: "Config", Kind: model.Definition, SymbolKind: model.Class, Line: 1},
+				{Name: "run", Kind: model.Reference, SymbolKind: model.Function, Line: 4, Enclosing: "main"},
+			}},
+			{Path: "run.go", Tags: []model.Tag{{Name: "run", Kind: model.Definition, SymbolKind: model.Function, Line: 2}}},
+			{Path: "tool.go", Tags: []model.Tag{
+				{Name: "tool", Kind: model.Definition, SymbolKind: model.Function, Line: 4},
+				{Name: "Limit", Kind: model.Definition, SymbolKind: model.Constant, Line: 1},
+			}},
+		},
+		Dependencies: []model.Dependency{
+			{Source: "main.go", Target: "run.go", Symbols: []string{"run"}},
+			{Source: "tool.go", Target: "run.go", Symbols: []string{"run"}},
+		},
+		CallEdges: []model.CallEdge{{Caller: "main", Callee: "run"}, {Caller: "tool", Callee: "run"}},
+	}
+	got, unreachable := PruneUnreachable(rm, map[string]struct{}{"main": {}, "run": {}})
+
+	if names := fileNames(got); !slices.Equal(names, []string{"main.go", "run.go"}) {
+		t.Errorf("files = %v, want main.go run.go", names)
+	}
+	var kept []string
+	for _, tag := range got.Files[0].Tags {
+		kept = append(kept, tag.Name)
+	}
+	if !slices.Equal(kept, []string{"main", "Config", "run"}) {
+		t.Errorf("main.go tags = %v, want main Config run (types and references stay)", kept)
+	}
+	if len(got.Dependencies) != 1 || len(got.CallEdges) != 1 || got.CallEdges[0].Caller != "main" {
+		t.Errorf("edges from tool.go should be pruned: deps %+v, calls %+v", got.Dependencies, got.CallEdges)
+	}
+	if len(unreachable) != 2 || unreachable[0].Name != "unusedHelper" || unreachable[0].File != "main.go" || unreachable[1].Name != "tool" {
+		t.Errorf("unreachable = %+v, want main.go unusedHelper and tool.go tool", unreachable)
+	}
+}
+
+func TestFilterExported(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		Files: []model.FileInfo{{
+			Path: "a.go",
+			Tags: []model.Tag{
+				{Name: "Server", Kind: model.Definition, SymbolKind: model.Class},
+				{Name: "helper", Kind: model.Definition, SymbolKind: model.Function, Unexported: true},
+				{Name: "helper", Kind: model.Reference, SymbolKind: model.Function},
+			},
+		}},
+		Members: []model.Tag{
+			{Name: "Server.Addr", Kind: model.Definition, SymbolKind: model.Field},
+			{Name: "Server.conn", Kind: model.Definition, SymbolKind: model.Field, Unexported: true},
+		},
+	}
+	got := FilterExported(rm)
+
+	if n := len(got.Files[0].Tags); n != 2 {
+		t.Errorf("expected Server and the helper reference, got %+v", got.Files[0].Tags)
+	}
+	for _, tag := range got.Files[0].Tags {
+		if tag.Kind == model.Definition && tag.Name == "helper" {
+			t.Error("unexported helper definition should be dropped")
+		}
+	}
+	if len(got.Members) != 1 || got.Members[0].Name != "Server.Addr" {
+		t.Errorf("expected only Server.Addr member, got %+v", got.Members)
+	}
+	if len(rm.Files[0].Tags) != 3 || len(rm.Members) != 2 {
+		t.Error("input RepoMap must not be modified")
+	}
+}
+
+func TestCollapseDirs(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "test",
+		Root:     "test",
+		Files: []model.FileInfo{
+			{Path: "pkg/a.go", Rank: 0.3},
+			{Path: "pkg/b.go", Rank: 0.2},
+			{Path: "util/u.go", Rank: 0.4},
+			{Path: "main.go", Rank: 0.1},
+		},
+		Dependencies: []model.Dependency{
+			{Source: "main.go", Target: "pkg/a.go", Symbols: []string{"Run"}},
+			{Source: "pkg/a.go", Target: "util/u.go", Symbols: []string{"Log"}},
+			{Source: "pkg/b.go", Target: "util/u.go", Symbols: []string{"Fmt", "Log"}},
+			{Source: "pkg/b.go", Target: "pkg/a.go", Symbols: []string{"Helper"}},
+		},
+		CallEdges: []model.CallEdge{{Caller: "main", Callee: "Run"}},
+	}
+
+	got := CollapseDirs(rm, 0)
+	wantDirs := []model.Dir{
+		{Path: "pkg", Rank: 0.5, Files: 2},
+		{Path: "util", Rank: 0.4, Files: 1},
+		{Path: ".", Rank: 0.1, Files: 1},
+	}
+	if len(got.Dirs) != len(wantDirs) {
+		t.Fatalf("dirs = %+v, want %+v", got.Dirs, wantDirs)
+	}
+	for i, d := range got.Dirs {
+		w := wantDirs[i]
+		if d.Path != w.Path || d.Files != w.Files || math.Abs(d.Rank-w.Rank) > 1e-9 {
+			t.Errorf("dirs[%d] = %+v, want %+v", i, d, w)
+		}
+	}
+	// pkg → util merges two file edges; pkg/b → pkg/a stays inside pkg.
+	wantDeps := []model.Dependency{
+		{Source: ".", Target: "pkg", Symbols: []string{"Run"}},
+		{Source: "pkg", Target: "util", Symbols: []string{"Fmt", "Log"}},
+	}
+	if !reflect.DeepEqual(got.Dependencies, wantDeps) {
+		t.Errorf("dependencies = %+v, want %+v", got.Dependencies, wantDeps)
+	}
+	if len(got.Files) != 0 || len(got.CallEdges) != 0 {
+		t.Errorf("collapsed view should drop files and call edges: %+v", got)
+	}
+
+	// -n keeps the top directories and the edges between them.
+	top := CollapseDirs(rm, 2)
+	if len(top.Dirs) != 2 || top.Dirs[1].Path != "util" {
+		t.Fatalf("top 2 dirs = %+v", top.Dirs)
+	}
+	if len(top.Dependencies) != 1 || top.Dependencies[0].Source != "pkg" {
+		t.Errorf("top 2 dependencies = %+v, want only pkg → util", top.Dependencies)
+	}
+}
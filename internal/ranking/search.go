@@ -0,0 +1,182 @@
+package ranking
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+// searchField is one piece of text a definition is searched by, and how much
+// a match in it counts towards the definition's score.
+type searchField struct {
+	words  []string
+	weight float64
+}
+
+// Weights of a match in each searched field: the name says most about what
+// a definition is, then its doc comment, the type owning it (Server in
+// Server.Handle), its signature, and its file path.
+const (
+	nameWeight      = 1.0
+	ownerWeight     = 0.5
+	docWeight       = 0.6
+	signatureWeight = 0.4
+	pathWeight      = 0.3
+)
+
+// rankShare is the share of a hit's score given to its file's rank; the
+// rest is match quality. Rank only orders hits that match about as well, and
+// never turns a non-match into a hit.
+const rankShare = 0.2
+
+// stopWords are dropped from queries, so "parse the config" is searched as
+// "parse config".
+var stopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "as": {}, "by": {}, "for": {}, "from": {}, "in": {},
+	"is": {}, "of": {}, "on": {}, "or": {}, "the": {}, "to": {}, "with": {},
+}
+
+// Search scores every definition in files against query and returns the
+// best limit hits (all of them if limit <= 0), best first. The query is split
+// into words, and each word is matched against the words of a definition's
+// name, doc comment, owning type, signature, and file path, where identifiers split at
+// case changes and underscores (parseConfig and parse_config are both
+// "parse config"). A word matches fully when equal, give or take a plural s,
+// and half when the two share most of a prefix (auth and authentication). A definition's match
+// quality is the mean over query words of its best weighted match, and its
+// score blends that with its file's rank relative to the top-ranked file.
+// Ties go to the higher-ranked file, then path and line.
+func Search(files []model.FileInfo, query string, limit int) []model.Hit {
+	var terms []string
+	for _, w := range searchWords(query) {
+		if _, stop := stopWords[w]; !stop && !slices.Contains(terms, w) {
+			terms = append(terms, w)
+		}
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var maxRank float64
+	for i := range files {
+		maxRank = max(maxRank, files[i].Rank)
+	}
+	rankOf := make(map[string]float64, len(files))
+	var hits []model.Hit
+	for i := range files {
+		fi := &files[i]
+		rankOf[fi.Path] = fi.Rank
+		path := searchField{searchWords(fi.Path), pathWeight}
+		for j := range fi.Tags {
+			tag := fi.Tags[j]
+			if tag.Kind != model.Definition {
+				continue
+			}
+			owner, member := model.SplitMember(tag.Name)
+			fields := []searchField{
+				{searchWords(member), nameWeight},
+				{searchWords(owner), ownerWeight},
+				{searchWords(tag.Doc), docWeight},
+				{searchWords(tag.Signature), signatureWeight},
+				path,
+			}
+			quality := matchQuality(terms, fields)
+			if quality == 0 {
+				continue
+			}
+			relRank := 1.0
+			if maxRank > 0 {
+				relRank = fi.Rank / maxRank
+			}
+			tag.File = fi.Path
+			hits = append(hits, model.Hit{Tag: tag, Score: quality * (1 - rankShare + rankShare*relRank)})
+		}
+	}
+
+	slices.SortFunc(hits, func(a, b model.Hit) int {
+		return cmp.Or(
+			cmp.Compare(b.Score, a.Score),
+			cmp.Compare(rankOf[b.Tag.File], rankOf[a.Tag.File]),
+			strings.Compare(a.Tag.File, b.Tag.File),
+			cmp.Compare(a.Tag.Line, b.Tag.Line),
+		)
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// matchQuality returns the mean over terms of each term's best weighted
+// match in fields, between 0 (no term matches) and 1 (every term is a word
+// of the name).
+func matchQuality(terms []string, fields []searchField) float64 {
+	var total float64
+	for _, term := range terms {
+		var best float64
+		for _, f := range fields {
+			for _, w := range f.words {
+				best = max(best, f.weight*wordMatch(term, w))
+			}
+		}
+		total += best
+	}
+	return total / float64(len(terms))
+}
+
+// wordMatch reports how well a query term matches a word: 1 when equal or
+// equal but for a plural s (file and files), 0.5 when they share a prefix of
+// at least three letters covering most of the shorter one (auth and
+// authentication, parse and parsing), and 0 otherwise.
+func wordMatch(term, word string) float64 {
+	if term == word || term+"s" == word || term == word+"s" {
+		return 1
+	}
+	n := 0
+	for n < len(term) && n < len(word) && term[n] == word[n] {
+		n++
+	}
+	if n >= 3 && 4*n >= 3*min(len(term), len(word)) {
+		return 0.5
+	}
+	return 0
+}
+
+// searchWords splits s into lower-case words at anything that is not a
+// letter or digit and at case changes inside identifiers, keeping acronyms
+// whole: "HTTPServer.handle_request" gives http, server, handle, request.
+func searchWords(s string) []string {
+	var words []string
+	runes := []rune(s)
+	start := -1
+	flush := func(end int) {
+		if start >= 0 && end > start {
+			words = append(words, strings.ToLower(string(runes[start:end])))
+		}
+		start = -1
+	}
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush(i)
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+		prev := runes[i-1]
+		// A word starts at an upper-case letter after a lower-case one
+		// (parseConfig), or before a lower-case one ending an acronym
+		// (HTTPServer: the S).
+		if unicode.IsUpper(r) && (unicode.IsLower(prev) ||
+			unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			flush(i)
+			start = i
+		}
+	}
+	flush(len(runes))
+	return words
+}
@@ -0,0 +1,84 @@
+package ranking
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func TestSearchWords(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"parseConfig", []string{"parse", "config"}},
+		{"HTTPServer.handle_request", []string{"http", "server", "handle", "request"}},
+		{"internal/auth/session.go", []string{"internal", "auth", "session", "go"}},
+		{"user authentication", []string{"user", "authentication"}},
+		{"", nil},
+	} {
+		if got := searchWords(tc.in); !slices.Equal(got, tc.want) {
+			t.Errorf("searchWords(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+	def := func(name string, line int, sig, doc string) model.Tag {
+		return model.Tag{Name: name, Kind: model.Definition, SymbolKind: model.Function, Line: line, Signature: sig, Doc: doc}
+	}
+	files := []model.FileInfo{
+		{Path: "server.go", Rank: 0.6, Tags: []model.Tag{
+			def("Serve", 3, "Serve()", "Serve accepts connections."),
+			def("checkUser", 9, "checkUser(token string) bool", "checkUser authenticates a request's user."),
+			{Name: "authenticateUser", Kind: model.Reference, SymbolKind: model.Function, Line: 10},
+		}},
+		{Path: "internal/auth/auth.go", Rank: 0.3, Tags: []model.Tag{
+			def("AuthenticateUser", 5, "AuthenticateUser(name, password string) (*User, error)", ""),
+			def("Session.User", 12, "User() *User", ""),
+			def("hash", 20, "hash(s string) string", ""),
+		}},
+	}
+
+	hits := Search(files, "the user authentication", 0)
+	var got []string
+	for _, h := range hits {
+		got = append(got, h.Tag.File+":"+h.Tag.Name)
+	}
+	// Both words in the name beat a name-and-doc match, which beats a match
+	// of the member name alone; hash matches only by path, so comes last.
+	want := []string{
+		"internal/auth/auth.go:AuthenticateUser",
+		"server.go:checkUser",
+		"internal/auth/auth.go:Session.User",
+		"internal/auth/auth.go:hash",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("hits = %q, want %q", got, want)
+	}
+	if hits[0].Tag.Line != 5 || hits[0].Score <= hits[1].Score {
+		t.Errorf("top hit = %+v, scores %v, %v", hits[0].Tag, hits[0].Score, hits[1].Score)
+	}
+
+	if hits := Search(files, "user authentication", 2); len(hits) != 2 {
+		t.Errorf("limit 2: got %d hits", len(hits))
+	}
+	if hits := Search(files, "the of", 0); hits != nil {
+		t.Errorf("stop words only: got %v", hits)
+	}
+	if hits := Search(files, "database", 0); len(hits) != 0 {
+		t.Errorf("no match: got %v", hits)
+	}
+
+	// Equal matches are ordered by file rank.
+	tied := []model.FileInfo{
+		{Path: "low.go", Rank: 0.1, Tags: []model.Tag{def("Load", 1, "", "")}},
+		{Path: "high.go", Rank: 0.9, Tags: []model.Tag{def("Load", 1, "", "")}},
+	}
+	if hits := Search(tied, "load", 0); len(hits) != 2 || hits[0].Tag.File != "high.go" || hits[0].Score <= hits[1].Score {
+		t.Errorf("tied hits = %+v", hits)
+	}
+}
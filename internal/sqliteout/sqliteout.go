@@ -0,0 +1,172 @@
+// Package sqliteout writes a repository map to a SQLite database, for ad-hoc
+// SQL queries over files, symbols, and the dependency and call graphs.
+package sqliteout
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite" // registers the pure-Go "sqlite" driver
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+// Schema is the DDL of the database Write creates. Tables mirror the model:
+// symbols, dependencies, and call sites refer to their files by id, and
+// calls name their symbols as the symbols table does (qualified, e.g.
+// "Server.Handle"), so they join on symbols.name.
+const Schema = `CREATE TABLE repo (
+	name TEXT NOT NULL,
+	root TEXT NOT NULL
+);
+CREATE TABLE files (
+	id       INTEGER PRIMARY KEY,
+	path     TEXT NOT NULL UNIQUE,
+	language TEXT NOT NULL,
+	package  TEXT,
+	rank     REAL NOT NULL
+);
+CREATE TABLE symbols (
+	id        INTEGER PRIMARY KEY,
+	file_id   INTEGER NOT NULL REFERENCES files(id),
+	name      TEXT NOT NULL,
+	kind      TEXT NOT NULL,
+	line      INTEGER NOT NULL,
+	signature TEXT NOT NULL,
+	doc       TEXT
+);
+CREATE INDEX symbols_name ON symbols(name);
+CREATE INDEX symbols_file ON symbols(file_id);
+CREATE TABLE dependencies (
+	source_id INTEGER NOT NULL REFERENCES files(id),
+	target_id INTEGER NOT NULL REFERENCES files(id),
+	symbols   TEXT NOT NULL
+);
+CREATE TABLE call_edges (
+	caller TEXT NOT NULL,
+	callee TEXT NOT NULL,
+	count  INTEGER NOT NULL
+);
+CREATE INDEX call_edges_caller ON call_edges(caller);
+CREATE INDEX call_edges_callee ON call_edges(callee);
+CREATE TABLE call_sites (
+	caller  TEXT NOT NULL,
+	callee  TEXT NOT NULL,
+	file_id INTEGER NOT NULL REFERENCES files(id),
+	line    INTEGER NOT NULL,
+	kind    TEXT NOT NULL
+);
+CREATE INDEX call_sites_callee ON call_sites(callee);
+`
+
+// Write creates a SQLite database at path holding rm, replacing any file
+// already there. The database is built in a temporary file beside path and
+// renamed into place, so a failed write leaves an existing database intact.
+// Only definition tags become symbols; dependency symbols are space-separated
+// as in TOON, and package and doc are NULL when empty. Dependencies and call
+// sites on files not in rm.Files are left out.
+func Write(path string, rm *model.RepoMap) (err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	_ = os.Remove(tmp)
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	db, err := sql.Open("sqlite", tmp)
+	if err != nil {
+		return err
+	}
+	if err := insert(db, rm); err != nil {
+		_ = db.Close()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// insert creates the schema in db and fills it from rm in one transaction.
+func insert(db *sql.DB, rm *model.RepoMap) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(Schema); err != nil {
+		return fmt.Errorf("creating schema: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO repo (name, root) VALUES (?, ?)`, rm.RepoName, rm.Root); err != nil {
+		return err
+	}
+
+	fileIDs := make(map[string]int, len(rm.Files))
+	for i := range rm.Files {
+		fi := &rm.Files[i]
+		id := i + 1
+		fileIDs[fi.Path] = id
+		if _, err := tx.Exec(`INSERT INTO files (id, path, language, package, rank) VALUES (?, ?, ?, ?, ?)`,
+			id, fi.Path, fi.Language, nullable(fi.Package), fi.Rank); err != nil {
+			return fmt.Errorf("inserting file %s: %w", fi.Path, err)
+		}
+		for j := range fi.Tags {
+			t := &fi.Tags[j]
+			if t.Kind != model.Definition {
+				continue
+			}
+			if _, err := tx.Exec(`INSERT INTO symbols (file_id, name, kind, line, signature, doc) VALUES (?, ?, ?, ?, ?, ?)`,
+				id, t.Name, string(t.SymbolKind), t.Line, t.Signature, nullable(t.Doc)); err != nil {
+				return fmt.Errorf("inserting symbol %s: %w", t.Name, err)
+			}
+		}
+	}
+
+	for i := range rm.Dependencies {
+		d := &rm.Dependencies[i]
+		src, srcOK := fileIDs[d.Source]
+		tgt, tgtOK := fileIDs[d.Target]
+		if !srcOK || !tgtOK {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO dependencies (source_id, target_id, symbols) VALUES (?, ?, ?)`,
+			src, tgt, strings.Join(d.Symbols, " ")); err != nil {
+			return err
+		}
+	}
+	for i := range rm.CallEdges {
+		ce := &rm.CallEdges[i]
+		if _, err := tx.Exec(`INSERT INTO call_edges (caller, callee, count) VALUES (?, ?, ?)`,
+			ce.Caller, ce.Callee, ce.Count); err != nil {
+			return err
+		}
+	}
+	for i := range rm.CallSites {
+		cs := &rm.CallSites[i]
+		id, ok := fileIDs[cs.File]
+		if !ok {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO call_sites (caller, callee, file_id, line, kind) VALUES (?, ?, ?, ?, ?)`,
+			cs.Caller, cs.Callee, id, cs.Line, string(cs.Kind)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// nullable maps "" to NULL, for optional text columns.
+func nullable(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
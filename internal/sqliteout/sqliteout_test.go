@@ -0,0 +1,135 @@
+package sqliteout
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "myproject",
+		Root:     "myproject",
+		Files: []model.FileInfo{
+			{
+				Path: "models.py", Language: "python", Rank: 0.6,
+				Tags: []model.Tag{
+					{Name: "User", Kind: model.Definition, SymbolKind: model.Class, Line: 10, Signature: "User", Doc: "A user."},
+					{Name: "User.save", Kind: model.Definition, SymbolKind: model.Method, Line: 12, Signature: "save(self)"},
+					{Name: "json", Kind: model.Reference, SymbolKind: model.Module, Line: 1},
+				},
+			},
+			{
+				Path: "main.py", Language: "python", Rank: 0.4,
+				Tags: []model.Tag{{Name: "main", Kind: model.Definition, SymbolKind: model.Function, Line: 3, Signature: "main()"}},
+			},
+		},
+		Dependencies: []model.Dependency{
+			{Source: "main.py", Target: "models.py", Symbols: []string{"User", "save"}},
+			{Source: "main.py", Target: "hidden.py", Symbols: []string{"x"}},
+		},
+		CallEdges: []model.CallEdge{{Caller: "main", Callee: "User", Count: 2}},
+		CallSites: []model.CallSite{{Caller: "main", Callee: "User", File: "main.py", Line: 4, Kind: model.SiteCall}},
+	}
+
+	path := filepath.Join(t.TempDir(), "sub", "map.db")
+	if err := Write(path, rm); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	count := func(query string) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return n
+	}
+	if n := count(`SELECT COUNT(*) FROM files`); n != 2 {
+		t.Errorf("files = %d, want 2", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM symbols`); n != 3 {
+		t.Errorf("symbols = %d, want 3 (definitions only)", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM dependencies`); n != 1 {
+		t.Errorf("dependencies = %d, want 1 (edges to unlisted files dropped)", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM symbols WHERE doc IS NULL`); n != 2 {
+		t.Errorf("symbols without doc = %d, want 2 (empty doc is NULL)", n)
+	}
+
+	var file, syms string
+	if err := db.QueryRow(`SELECT f.path, d.symbols FROM dependencies d JOIN files f ON f.id = d.target_id`).Scan(&file, &syms); err != nil {
+		t.Fatal(err)
+	}
+	if file != "models.py" || syms != "User save" {
+		t.Errorf("dependency = %s %q, want models.py \"User save\"", file, syms)
+	}
+
+	// Symbols that nothing calls, in the highest-ranked file.
+	rows, err := db.Query(`SELECT s.name FROM symbols s JOIN files f ON f.id = s.file_id
+		WHERE s.name NOT IN (SELECT callee FROM call_edges) ORDER BY f.rank DESC, s.line LIMIT 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rows.Close() }()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if len(names) != 1 || names[0] != "User.save" {
+		t.Errorf("uncalled symbols = %v, want [User.save]", names)
+	}
+
+	var caller, callFile string
+	var line, n int
+	if err := db.QueryRow(`SELECT s.caller, f.path, s.line, e.count FROM call_sites s
+		JOIN files f ON f.id = s.file_id JOIN call_edges e ON e.caller = s.caller AND e.callee = s.callee
+		WHERE s.kind = 'call'`).Scan(&caller, &callFile, &line, &n); err != nil {
+		t.Fatal(err)
+	}
+	if caller != "main" || callFile != "main.py" || line != 4 || n != 2 {
+		t.Errorf("call site = %s %s:%d count %d, want main main.py:4 count 2", caller, callFile, line, n)
+	}
+}
+
+func TestWriteReplaces(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "map.db")
+	if err := os.WriteFile(path, []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rm := &model.RepoMap{RepoName: "r", Root: "r", Files: []model.FileInfo{{Path: "a.go", Language: "go"}}}
+	if err := Write(path, rm); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM files`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("files = %d (%v), want 1", n, err)
+	}
+}
@@ -0,0 +1,101 @@
+package toon
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+// compactKey explains the abbreviated tables of EncodeCompact in one line,
+// since compact output carries no agent context header.
+const compactKey = "f=files(i=id,p=path,l=lang,r=rank) s=symbols(f=file id,n=name,l=line) d=deps(s=source id,t=target id)"
+
+// langCodes abbreviates language names to one character for EncodeCompact.
+// Languages missing here keep their full name.
+var langCodes = map[string]string{
+	"cpp":        "c",
+	"go":         "g",
+	"java":       "J",
+	"javascript": "j",
+	"python":     "p",
+	"ruby":       "r",
+	"typescript": "t",
+}
+
+// EncodeCompact renders rm in the smallest useful form, for tight context
+// budgets. It is lossy: files are numbered and referred to by id, symbols
+// keep only their name and line, dependencies lose their symbol lists,
+// languages are abbreviated (with a legend), ranks have three decimals, and
+// the call graph and every optional table are omitted:
+//
+//	repo: r
+//	root: r
+//	key: "f=files(i=id,...) ..."
+//	langs: "g=go"
+//	f[2]{i,p,l,r}:
+//	  0,util.go,g,0.612
+//	  1,main.go,g,0.388
+//	s[2]{f,n,l}:
+//	  0,Helper,3
+//	  1,main,5
+//	d[1]{s,t}:
+//	  1,0
+func EncodeCompact(rm *model.RepoMap) string {
+	parts := []string{
+		fmt.Sprintf("repo: %s", encodeValue(rm.RepoName)),
+		fmt.Sprintf("root: %s", encodeValue(rm.Root)),
+		fmt.Sprintf("key: %s", encodeValue(compactKey)),
+	}
+
+	ids := make(map[string]string, len(rm.Files))
+	used := make(map[string]struct{})
+	fileRows := make([][]string, len(rm.Files))
+	var symbolRows [][]string
+	for i := range rm.Files {
+		fi := &rm.Files[i]
+		id := strconv.Itoa(i)
+		ids[fi.Path] = id
+		code, ok := langCodes[fi.Language]
+		if !ok {
+			code = fi.Language
+		}
+		used[fi.Language] = struct{}{}
+		fileRows[i] = []string{id, fi.Path, code, fmt.Sprintf("%.3f", fi.Rank)}
+		for j := range fi.Tags {
+			tag := &fi.Tags[j]
+			if tag.Kind == model.Definition {
+				symbolRows = append(symbolRows, []string{id, tag.Name, strconv.Itoa(tag.Line)})
+			}
+		}
+	}
+
+	var legend []string
+	for name := range used {
+		if code, ok := langCodes[name]; ok {
+			legend = append(legend, code+"="+name)
+		}
+	}
+	if len(legend) > 0 {
+		sort.Strings(legend)
+		parts = append(parts, fmt.Sprintf("langs: %s", encodeValue(strings.Join(legend, ","))))
+	}
+
+	parts = append(parts, formatTabular("f", []string{"i", "p", "l", "r"}, fileRows, false))
+	parts = append(parts, formatTabular("s", []string{"f", "n", "l"}, symbolRows, false))
+
+	var depRows [][]string
+	for i := range rm.Dependencies {
+		d := &rm.Dependencies[i]
+		src, srcOK := ids[d.Source]
+		tgt, tgtOK := ids[d.Target]
+		if srcOK && tgtOK {
+			depRows = append(depRows, []string{src, tgt})
+		}
+	}
+	parts = append(parts, formatTabular("d", []string{"s", "t"}, depRows, false))
+
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,53 @@
+package toon
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func TestEncodeCompact(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Files: []model.FileInfo{
+			{Path: "util.go", Language: "go", Rank: 0.61234, Tags: []model.Tag{
+				{Name: "Helper", Kind: model.Definition, SymbolKind: model.Function, Line: 3, Signature: "Helper(x int) int"},
+				{Name: "fmt", Kind: model.Reference, Line: 1},
+			}},
+			{Path: "main.py", Language: "python", Rank: 0.38766, Tags: []model.Tag{
+				{Name: "main", Kind: model.Definition, SymbolKind: model.Function, Line: 5},
+			}},
+		},
+		Dependencies: []model.Dependency{
+			{Source: "main.py", Target: "util.go", Symbols: []string{"Helper"}},
+			{Source: "main.py", Target: "dropped.go", Symbols: []string{"X"}},
+		},
+		CallEdges: []model.CallEdge{{Caller: "main", Callee: "Helper"}},
+	}
+	want := `repo: r
+root: r
+key: "` + compactKey + `"
+langs: "g=go,p=python"
+f[2]{i,p,l,r}:
+  0,util.go,g,0.612
+  1,main.py,p,0.388
+s[2]{f,n,l}:
+  0,Helper,3
+  1,main,5
+d[1]{s,t}:
+  1,0`
+	if got := EncodeCompact(rm); got != want {
+		t.Errorf("EncodeCompact:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Languages without a code keep their name and stay out of the legend.
+	rm.Files = []model.FileInfo{{Path: "schema.proto", Language: "protobuf"}}
+	got := EncodeCompact(rm)
+	if !strings.Contains(got, "0,schema.proto,protobuf,0.000") || strings.Contains(got, "langs:") {
+		t.Errorf("unknown language:\n%s", got)
+	}
+}
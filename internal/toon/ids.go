@@ -0,0 +1,55 @@
+package toon
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+// symbolID returns the stable id of a definition: 12 hex digits of a hash of
+// its file, name, and line, so the same definition gets the same id in every
+// run until it moves.
+func symbolID(file, name string, line int) string {
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%s\x00%s\x00%d", file, name, line)
+	return fmt.Sprintf("%012x", h.Sum64()>>16)
+}
+
+// idIndex finds the ids of the definitions in a map, for tables that refer
+// to symbols by id (Options.WithIDs) instead of by name.
+type idIndex struct {
+	byName map[string][]string // name → ids of every definition with that name
+	byFile map[string][]string // file + "\x00" + name → ids of its definitions in that file
+}
+
+func newIDIndex(files []model.FileInfo) *idIndex {
+	idx := &idIndex{byName: make(map[string][]string), byFile: make(map[string][]string)}
+	for i := range files {
+		fi := &files[i]
+		for j := range fi.Tags {
+			tag := &fi.Tags[j]
+			if tag.Kind != model.Definition {
+				continue
+			}
+			id := symbolID(fi.Path, tag.Name, tag.Line)
+			idx.byName[tag.Name] = append(idx.byName[tag.Name], id)
+			key := fi.Path + "\x00" + tag.Name
+			idx.byFile[key] = append(idx.byFile[key], id)
+		}
+	}
+	return idx
+}
+
+// named returns the space-separated ids of every definition of name, or ""
+// if none is in the map.
+func (idx *idIndex) named(name string) string {
+	return strings.Join(idx.byName[name], " ")
+}
+
+// inFile returns the space-separated ids of the definitions of name in file,
+// or "" if none is in the map.
+func (idx *idIndex) inFile(file, name string) string {
+	return strings.Join(idx.byFile[file+"\x00"+name], " ")
+}
@@ -2,8 +2,10 @@
 package toon
 
 import (
+	"cmp"
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/phobologic/repoguide/internal/model"
@@ -17,117 +19,664 @@ var (
 		"false": {},
 		"null":  {},
 	}
+	// identColumns hold file paths and symbol names. Strict mode always
+	// quotes them so a typed TOON reader never coerces "0" or "true".
+	identColumns = map[string]struct{}{
+		"path": {}, "file": {}, "files": {}, "source": {}, "target": {},
+		"name": {}, "caller": {}, "callee": {},
+		"id": {}, "caller_id": {}, "callee_id": {}, "symbol_ids": {}, "owners": {},
+	}
 )
 
-// Encode converts a RepoMap into TOON format.
-// When focused is true (--symbol or --file query), callsites are emitted
-// immediately after files so truncation cuts noise rather than the primary
-// deliverable.
-func Encode(rm *model.RepoMap, focused bool) string {
-	var parts []string
+// Options controls TOON encoding.
+type Options struct {
+	// Focused (a --symbol or --file query) emits callsites and members
+	// immediately after files so truncation cuts noise rather than the
+	// primary deliverable.
+	Focused bool
+	// Strict quotes every path and name cell regardless of content, so
+	// structural columns are unambiguously strings. Numeric columns such as
+	// rank and line stay bare.
+	Strict bool
+	// GroupSymbols nests each file's symbols under a single file entry
+	// instead of repeating the path on every row (see encodeGroupedSymbols).
+	GroupSymbols bool
+	// FileMetrics adds symbols (definition count) and calls (outbound call
+	// edges) columns to the files table.
+	FileMetrics bool
+	// WithOwners adds an owners column (each file's CODEOWNERS owners,
+	// space-separated; empty for none) to the files table.
+	WithOwners bool
+	// WithDocs adds a doc column (the first sentence of each definition's
+	// docstring or doc comment) to the symbols table.
+	WithDocs bool
+	// WithIDs adds an id column to the symbols table (see symbolID), and
+	// makes the calls, callsites, and dependencies tables refer to symbols
+	// by those ids instead of by name.
+	WithIDs bool
+	// GroupByLanguage splits the files, symbols, and dependencies tables
+	// into one section per language, with cross-language dependencies in a
+	// shared section (see encodeLanguages).
+	GroupByLanguage bool
+	// SymbolsByName sorts the flat symbols table by name (ignoring case)
+	// across all files, instead of in file order, making it an alphabetical
+	// index. The files table stays in rank order.
+	SymbolsByName bool
+}
+
+// Section is one top-level entry of an encoded map: a table such as files
+// or calls, or with GroupByLanguage a language section.
+type Section struct {
+	Name string
+	Body string
+}
+
+// Encode converts a RepoMap into TOON format: the repo and root, followed by
+// every section from Sections.
+func Encode(rm *model.RepoMap, opts Options) string {
+	parts := []string{
+		fmt.Sprintf("repo: %s", encodeValue(rm.RepoName)),
+		fmt.Sprintf("root: %s", encodeValue(rm.Root)),
+	}
+	for _, sec := range Sections(rm, opts) {
+		parts = append(parts, sec.Body)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Sections returns the sections of rm's TOON encoding in the order Encode
+// writes them, so that a map can also be consumed one section at a time.
+func Sections(rm *model.RepoMap, opts Options) []Section {
+	focused := opts.Focused
+	var sections []Section
+	add := func(name, body string) {
+		sections = append(sections, Section{Name: name, Body: body})
+	}
+	ids := newIDs(rm, opts)
+
+	var callCounts map[string]int
+	if opts.FileMetrics {
+		callCounts = outboundCalls(rm)
+	}
+
+	if opts.GroupByLanguage {
+		// Callsites and members lead as in the flat layout, since the
+		// language sections are where truncation should cut.
+		if focused && len(rm.CallSites) > 0 {
+			add("callsites", encodeSites(rm.CallSites, ids, opts.Strict))
+		}
+		if focused && len(rm.Members) > 0 {
+			add("members", encodeMembers(rm.Members, opts.Strict))
+		}
+		sections = append(sections, encodeLanguages(rm, ids, callCounts, opts)...)
+	} else {
+		add("files", encodeFiles(rm.Files, callCounts, opts))
+
+		// In focused mode, callsites and members come before symbols — they are the
+		// primary deliverables and must survive truncation.
+		if focused && len(rm.CallSites) > 0 {
+			add("callsites", encodeSites(rm.CallSites, ids, opts.Strict))
+		}
+		if focused && len(rm.Members) > 0 {
+			add("members", encodeMembers(rm.Members, opts.Strict))
+		}
+
+		add("symbols", encodeSymbols(rm.Files, ids, opts))
+		add("dependencies", encodeDependencies(rm.Dependencies, ids, opts))
+	}
+
+	if len(rm.Cycles) > 0 {
+		add("cycles", EncodeCycles(rm.Cycles, opts))
+	}
+
+	// The calls table is omitted when there are no call edges, including when
+	// the call graph was suppressed with --no-calls.
+	if len(rm.CallEdges) > 0 {
+		add("calls", encodeCalls(rm.CallEdges, ids, opts))
+	}
+
+	if len(rm.Externals) > 0 {
+		rows := make([][]string, len(rm.Externals))
+		for i, e := range rm.Externals {
+			rows[i] = []string{e.Name, fmt.Sprintf("%d", e.Count)}
+		}
+		add("externals", formatTabular("externals", []string{"name", "count"}, rows, opts.Strict))
+	}
+
+	if len(rm.Refs) > 0 {
+		rows := make([][]string, len(rm.Refs))
+		for i, r := range rm.Refs {
+			rows[i] = []string{r.File, r.Name, fmt.Sprintf("%d", r.Line)}
+		}
+		add("refs", formatTabular("refs", []string{"file", "name", "line"}, rows, opts.Strict))
+	}
+
+	if len(rm.Entrypoints) > 0 {
+		rows := make([][]string, len(rm.Entrypoints))
+		for i, e := range rm.Entrypoints {
+			rows[i] = []string{e.File, e.Name, fmt.Sprintf("%d", e.Line)}
+		}
+		add("entrypoints", formatTabular("entrypoints", []string{"file", "name", "line"}, rows, opts.Strict))
+	}
+
+	if len(rm.Metrics) > 0 {
+		rows := make([][]string, len(rm.Metrics))
+		for i, m := range rm.Metrics {
+			rows[i] = []string{m.File, fmt.Sprintf("%d", m.InDegree), fmt.Sprintf("%d", m.OutDegree), fmt.Sprintf("%.4f", m.Rank)}
+		}
+		add("metrics", formatTabular("metrics", []string{"file", "in_degree", "out_degree", "rank"}, rows, opts.Strict))
+	}
 
-	parts = append(parts, fmt.Sprintf("repo: %s", encodeValue(rm.RepoName)))
-	parts = append(parts, fmt.Sprintf("root: %s", encodeValue(rm.Root)))
+	if len(rm.Diagnostics) > 0 {
+		rows := make([][]string, len(rm.Diagnostics))
+		for i, d := range rm.Diagnostics {
+			rows[i] = []string{d.File, d.Message}
+		}
+		add("diagnostics", formatTabular("diagnostics", []string{"file", "message"}, rows, opts.Strict))
+	}
 
+	if len(rm.Unused) > 0 {
+		rows := make([][]string, len(rm.Unused))
+		for i := range rm.Unused {
+			u := &rm.Unused[i]
+			rows[i] = []string{u.File, u.Name, string(u.SymbolKind), fmt.Sprintf("%d", u.Line)}
+		}
+		add("unused", formatTabular("unused", []string{"file", "name", "kind", "line"}, rows, opts.Strict))
+	}
+
+	if len(rm.Unreachable) > 0 {
+		rows := make([][]string, len(rm.Unreachable))
+		for i := range rm.Unreachable {
+			u := &rm.Unreachable[i]
+			rows[i] = []string{u.File, u.Name, string(u.SymbolKind), fmt.Sprintf("%d", u.Line)}
+		}
+		add("unreachable", formatTabular("unreachable", []string{"file", "name", "kind", "line"}, rows, opts.Strict))
+	}
+
+	if len(rm.Packages) > 0 {
+		rows := make([][]string, len(rm.Packages))
+		for i, p := range rm.Packages {
+			rows[i] = []string{p.Dir, p.Name, p.Doc}
+		}
+		add("packages", formatTabular("packages", []string{"dir", "package", "doc"}, rows, opts.Strict))
+	}
+
+	// In non-focused mode, callsites and members appear at the end (empty for full maps).
+	if !focused && len(rm.CallSites) > 0 {
+		add("callsites", encodeSites(rm.CallSites, ids, opts.Strict))
+	}
+	if !focused && len(rm.Members) > 0 {
+		add("members", encodeMembers(rm.Members, opts.Strict))
+	}
+
+	return sections
+}
+
+// SectionFile returns the name of the file a split map keeps a section in.
+func SectionFile(name string) string {
+	return name + ".toon"
+}
+
+// EncodeIndex renders the index of a map split into one file per section:
+// the repo and root, and a sections table naming each section's file (see
+// SectionFile) in map order. Only opts.Strict applies.
+//
+//	repo: r
+//	root: r
+//	sections[2]{name,file}:
+//	  files,files.toon
+//	  symbols,symbols.toon
+func EncodeIndex(rm *model.RepoMap, sections []Section, opts Options) string {
+	rows := make([][]string, len(sections))
+	for i, sec := range sections {
+		rows[i] = []string{sec.Name, SectionFile(sec.Name)}
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("repo: %s", encodeValue(rm.RepoName)),
+		fmt.Sprintf("root: %s", encodeValue(rm.Root)),
+		formatTabular("sections", []string{"name", "file"}, rows, opts.Strict),
+	}, "\n")
+}
+
+// EncodeFiles renders the files table of rm, as in Encode.
+func EncodeFiles(rm *model.RepoMap, opts Options) string {
+	var callCounts map[string]int
+	if opts.FileMetrics {
+		callCounts = outboundCalls(rm)
+	}
+	return encodeFiles(rm.Files, callCounts, opts)
+}
+
+// EncodeSymbols renders the symbols table of rm, as in Encode.
+func EncodeSymbols(rm *model.RepoMap, opts Options) string {
+	return encodeSymbols(rm.Files, newIDs(rm, opts), opts)
+}
+
+// EncodeDependencies renders the dependencies table of rm, as in Encode.
+func EncodeDependencies(rm *model.RepoMap, opts Options) string {
+	return encodeDependencies(rm.Dependencies, newIDs(rm, opts), opts)
+}
+
+// EncodeCalls renders the calls table of rm, as in Encode, but also when it
+// is empty.
+func EncodeCalls(rm *model.RepoMap, opts Options) string {
+	return encodeCalls(rm.CallEdges, newIDs(rm, opts), opts)
+}
+
+// newIDs returns the symbol id index for rm if opts.WithIDs is set, or nil.
+func newIDs(rm *model.RepoMap, opts Options) *idIndex {
+	if !opts.WithIDs {
+		return nil
+	}
+	return newIDIndex(rm.Files)
+}
+
+// encodeCalls renders the calls table, with symbol ids in place of names
+// when ids is set. The count column is the number of call sites per edge.
+func encodeCalls(edges []model.CallEdge, ids *idIndex, opts Options) string {
+	var callRows [][]string
+	for i := range edges {
+		ce := &edges[i]
+		count := fmt.Sprintf("%d", ce.Count)
+		if ids != nil {
+			callRows = append(callRows, []string{ids.named(ce.Caller), ids.named(ce.Callee), count})
+		} else {
+			callRows = append(callRows, []string{ce.Caller, ce.Callee, count})
+		}
+	}
+	callColumns := []string{"caller", "callee", "count"}
+	if ids != nil {
+		callColumns = []string{"caller_id", "callee_id", "count"}
+	}
+	return formatTabular("calls", callColumns, callRows, opts.Strict)
+}
+
+// encodeFiles renders the files table. The package column appears only when
+// some file declares a package, so maps without Go files keep their shape;
+// callCounts is used only with opts.FileMetrics.
+func encodeFiles(files []model.FileInfo, callCounts map[string]int, opts Options) string {
+	fileColumns := []string{"path", "language", "rank"}
+	withPackage := slices.ContainsFunc(files, func(fi model.FileInfo) bool { return fi.Package != "" })
+	if withPackage {
+		fileColumns = append(fileColumns, "package")
+	}
+	if opts.FileMetrics {
+		fileColumns = append(fileColumns, "symbols", "calls")
+	}
+	if opts.WithOwners {
+		fileColumns = append(fileColumns, "owners")
+	}
 	var fileRows [][]string
-	for i := range rm.Files {
-		fi := &rm.Files[i]
-		fileRows = append(fileRows, []string{
+	for i := range files {
+		fi := &files[i]
+		row := []string{
 			fi.Path,
 			fi.Language,
 			fmt.Sprintf("%.4f", fi.Rank),
+		}
+		if withPackage {
+			row = append(row, fi.Package)
+		}
+		if opts.FileMetrics {
+			row = append(row, fmt.Sprintf("%d", countDefinitions(fi)), fmt.Sprintf("%d", callCounts[fi.Path]))
+		}
+		if opts.WithOwners {
+			row = append(row, strings.Join(fi.Owners, " "))
+		}
+		fileRows = append(fileRows, row)
+	}
+	return formatTabular("files", fileColumns, fileRows, opts.Strict)
+}
+
+// encodeSymbols renders the definitions of files, as a flat symbols table or
+// grouped by file (see encodeGroupedSymbols).
+func encodeSymbols(files []model.FileInfo, ids *idIndex, opts Options) string {
+	if opts.GroupSymbols {
+		return encodeGroupedSymbols(files, opts)
+	}
+	type def struct {
+		path string
+		tag  *model.Tag
+	}
+	var defs []def
+	for i := range files {
+		fi := &files[i]
+		for j := range fi.Tags {
+			if fi.Tags[j].Kind == model.Definition {
+				defs = append(defs, def{fi.Path, &fi.Tags[j]})
+			}
+		}
+	}
+	if opts.SymbolsByName {
+		slices.SortStableFunc(defs, func(a, b def) int {
+			return cmp.Or(
+				strings.Compare(strings.ToLower(a.tag.Name), strings.ToLower(b.tag.Name)),
+				strings.Compare(a.tag.Name, b.tag.Name),
+				strings.Compare(a.path, b.path),
+				cmp.Compare(a.tag.Line, b.tag.Line),
+			)
 		})
 	}
-	parts = append(parts, formatTabular("files", []string{"path", "language", "rank"}, fileRows))
+	symbolRows := make([][]string, 0, len(defs))
+	for _, d := range defs {
+		row := append([]string{d.path}, symbolRow(d.tag, opts.WithDocs)...)
+		if ids != nil {
+			row = append([]string{symbolID(d.path, d.tag.Name, d.tag.Line)}, row...)
+		}
+		symbolRows = append(symbolRows, row)
+	}
+	columns := append([]string{"file"}, symbolColumns(opts.WithDocs)...)
+	if ids != nil {
+		columns = append([]string{"id"}, columns...)
+	}
+	return formatTabular("symbols", columns, symbolRows, opts.Strict)
+}
 
-	// In focused mode, callsites and members come before symbols — they are the
-	// primary deliverables and must survive truncation.
-	if focused && len(rm.CallSites) > 0 {
-		parts = append(parts, encodeSites(rm.CallSites))
+// encodeDependencies renders the dependencies table, with symbol ids in place
+// of names when ids is set.
+func encodeDependencies(deps []model.Dependency, ids *idIndex, opts Options) string {
+	var depRows [][]string
+	for i := range deps {
+		d := &deps[i]
+		symbols := strings.Join(d.Symbols, " ")
+		if ids != nil {
+			var symbolIDs []string
+			for _, name := range d.Symbols {
+				if id := ids.inFile(d.Target, name); id != "" {
+					symbolIDs = append(symbolIDs, id)
+				}
+			}
+			symbols = strings.Join(symbolIDs, " ")
+		}
+		depRows = append(depRows, []string{d.Source, d.Target, symbols})
 	}
-	if focused && len(rm.Members) > 0 {
-		parts = append(parts, encodeMembers(rm.Members))
+	depColumns := []string{"source", "target", "symbols"}
+	if ids != nil {
+		depColumns[2] = "symbol_ids"
 	}
+	return formatTabular("dependencies", depColumns, depRows, opts.Strict)
+}
 
-	var symbolRows [][]string
-	for i := range rm.Files {
-		fi := &rm.Files[i]
+// encodeLanguages renders the files, symbols, and dependencies of rm as one
+// section per language, in order of each language's highest-ranked file.
+// Dependencies between files of different languages go in a trailing shared
+// section, present only when there are any:
+//
+//	go:
+//	  files[1]{path,language,rank}:
+//	    main.go,go,0.6000
+//	  symbols[1]{file,name,kind,line,signature}:
+//	    main.go,main,function,3,func main()
+//	  dependencies[0]{source,target,symbols}:
+//	python:
+//	  ...
+//	shared:
+//	  dependencies[1]{source,target,symbols}:
+//	    web/app.ts,api/schema.py,Schema
+func encodeLanguages(rm *model.RepoMap, ids *idIndex, callCounts map[string]int, opts Options) []Section {
+	var langs []string
+	files := make(map[string][]model.FileInfo)
+	langOf := make(map[string]string, len(rm.Files))
+	for _, fi := range rm.Files {
+		if _, ok := files[fi.Language]; !ok {
+			langs = append(langs, fi.Language)
+		}
+		files[fi.Language] = append(files[fi.Language], fi)
+		langOf[fi.Path] = fi.Language
+	}
+
+	deps := make(map[string][]model.Dependency)
+	var shared []model.Dependency
+	for _, d := range rm.Dependencies {
+		lang, ok := langOf[d.Source]
+		if !ok || langOf[d.Target] != lang {
+			shared = append(shared, d)
+			continue
+		}
+		deps[lang] = append(deps[lang], d)
+	}
+
+	sections := make([]Section, 0, len(langs)+1)
+	for _, lang := range langs {
+		body := strings.Join([]string{
+			encodeFiles(files[lang], callCounts, opts),
+			encodeSymbols(files[lang], ids, opts),
+			encodeDependencies(deps[lang], ids, opts),
+		}, "\n")
+		sections = append(sections, Section{Name: lang, Body: encodeValue(lang) + ":\n" + indent(body, "  ")})
+	}
+	if len(shared) > 0 {
+		sections = append(sections, Section{Name: "shared", Body: "shared:\n" + indent(encodeDependencies(shared, ids, opts), "  ")})
+	}
+	return sections
+}
+
+// EncodeCycles renders the cycles table: one row per import cycle, with the
+// participating files space-separated. Only opts.Strict applies.
+func EncodeCycles(cycles [][]string, opts Options) string {
+	rows := make([][]string, len(cycles))
+	for i, c := range cycles {
+		rows[i] = []string{strings.Join(c, " ")}
+	}
+	return formatTabular("cycles", []string{"files"}, rows, opts.Strict)
+}
+
+// EncodeSearch renders the hits of a --search query, best first, as a
+// results table after the query itself. Scores have three decimals; only
+// opts.Strict applies.
+func EncodeSearch(query string, hits []model.Hit, opts Options) string {
+	rows := make([][]string, len(hits))
+	for i := range hits {
+		t := &hits[i].Tag
+		rows[i] = []string{fmt.Sprintf("%.3f", hits[i].Score), t.File, fmt.Sprintf("%d", t.Line), string(t.SymbolKind), t.Name, t.Signature}
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("query: %s", encodeValue(query)),
+		formatTabular("results", []string{"score", "file", "line", "kind", "name", "signature"}, rows, opts.Strict),
+	}, "\n")
+}
+
+// EncodeDirs renders the collapsed directory view built by
+// ranking.CollapseDirs: a dirs table in rank order, the dependencies between
+// directories, and a cycles table of directories when they import each
+// other circularly. Only opts.Strict applies.
+func EncodeDirs(rm *model.RepoMap, opts Options) string {
+	parts := []string{
+		fmt.Sprintf("repo: %s", encodeValue(rm.RepoName)),
+		fmt.Sprintf("root: %s", encodeValue(rm.Root)),
+	}
+
+	dirRows := make([][]string, len(rm.Dirs))
+	for i, d := range rm.Dirs {
+		dirRows[i] = []string{d.Path, fmt.Sprintf("%.4f", d.Rank), fmt.Sprintf("%d", d.Files)}
+	}
+	parts = append(parts, formatTabular("dirs", []string{"path", "rank", "files"}, dirRows, opts.Strict))
+
+	depRows := make([][]string, len(rm.Dependencies))
+	for i := range rm.Dependencies {
+		d := &rm.Dependencies[i]
+		depRows[i] = []string{d.Source, d.Target, strings.Join(d.Symbols, " ")}
+	}
+	parts = append(parts, formatTabular("dependencies", []string{"source", "target", "symbols"}, depRows, opts.Strict))
+
+	if len(rm.Cycles) > 0 {
+		rows := make([][]string, len(rm.Cycles))
+		for i, c := range rm.Cycles {
+			rows[i] = []string{strings.Join(c, " ")}
+		}
+		parts = append(parts, formatTabular("cycles", []string{"dirs"}, rows, opts.Strict))
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// encodeGroupedSymbols renders the symbols section as a list with one item
+// per file that defines anything, in file (rank) order. Each item carries
+// the file path once, followed by a nested table of its definitions:
+//
+//	symbols[2]:
+//	  - file: src/main.py
+//	    defs[1]{name,kind,line,signature}:
+//	      main,function,1,main()
+//	  - file: src/util.py
+//	    defs[1]{name,kind,line,signature}:
+//	      helper,function,1,helper(x)
+func encodeGroupedSymbols(files []model.FileInfo, opts Options) string {
+	strict := opts.Strict
+	var items []string
+	for i := range files {
+		fi := &files[i]
+		var rows [][]string
 		for j := range fi.Tags {
 			tag := &fi.Tags[j]
 			if tag.Kind == model.Definition {
-				symbolRows = append(symbolRows, []string{
-					fi.Path,
-					tag.Name,
-					string(tag.SymbolKind),
-					fmt.Sprintf("%d", tag.Line),
-					tag.Signature,
-				})
+				row := symbolRow(tag, opts.WithDocs)
+				if opts.WithIDs {
+					row = append([]string{symbolID(fi.Path, tag.Name, tag.Line)}, row...)
+				}
+				rows = append(rows, row)
 			}
 		}
+		if len(rows) == 0 {
+			continue
+		}
+		path := encodeValue(fi.Path)
+		if strict {
+			path = quote(fi.Path)
+		}
+		columns := symbolColumns(opts.WithDocs)
+		if opts.WithIDs {
+			columns = append([]string{"id"}, columns...)
+		}
+		table := formatTabular("defs", columns, rows, strict)
+		items = append(items, "  - file: "+path+"\n"+indent(table, "    "))
 	}
-	parts = append(parts, formatTabular("symbols", []string{"file", "name", "kind", "line", "signature"}, symbolRows))
+	header := fmt.Sprintf("symbols[%d]:", len(items))
+	if len(items) == 0 {
+		return header
+	}
+	return header + "\n" + strings.Join(items, "\n")
+}
 
-	var depRows [][]string
-	for i := range rm.Dependencies {
-		d := &rm.Dependencies[i]
-		depRows = append(depRows, []string{
-			d.Source,
-			d.Target,
-			strings.Join(d.Symbols, " "),
-		})
+// symbolColumns returns the columns describing a definition, shared by the
+// flat and grouped symbols tables (the flat table prepends file).
+func symbolColumns(withDocs bool) []string {
+	columns := []string{"name", "kind", "line", "signature"}
+	if withDocs {
+		columns = append(columns, "doc")
 	}
-	parts = append(parts, formatTabular("dependencies", []string{"source", "target", "symbols"}, depRows))
+	return columns
+}
 
-	var callRows [][]string
-	for i := range rm.CallEdges {
-		ce := &rm.CallEdges[i]
-		callRows = append(callRows, []string{ce.Caller, ce.Callee})
+// symbolRow returns the cells for a definition, matching symbolColumns.
+func symbolRow(tag *model.Tag, withDocs bool) []string {
+	row := []string{tag.Name, string(tag.SymbolKind), fmt.Sprintf("%d", tag.Line), tag.Signature}
+	if withDocs {
+		row = append(row, tag.Doc)
 	}
-	parts = append(parts, formatTabular("calls", []string{"caller", "callee"}, callRows))
+	return row
+}
 
-	// In non-focused mode, callsites and members appear at the end (empty for full maps).
-	if !focused && len(rm.CallSites) > 0 {
-		parts = append(parts, encodeSites(rm.CallSites))
+// countDefinitions returns the number of definition tags in a file.
+func countDefinitions(fi *model.FileInfo) int {
+	n := 0
+	for j := range fi.Tags {
+		if fi.Tags[j].Kind == model.Definition {
+			n++
+		}
 	}
-	if !focused && len(rm.Members) > 0 {
-		parts = append(parts, encodeMembers(rm.Members))
+	return n
+}
+
+// outboundCalls counts the call edges leaving each file, keyed by path. An
+// edge belongs to every file defining its caller.
+func outboundCalls(rm *model.RepoMap) map[string]int {
+	definedIn := make(map[string][]string)
+	for i := range rm.Files {
+		fi := &rm.Files[i]
+		for j := range fi.Tags {
+			tag := &fi.Tags[j]
+			if tag.Kind == model.Definition && !slices.Contains(definedIn[tag.Name], fi.Path) {
+				definedIn[tag.Name] = append(definedIn[tag.Name], fi.Path)
+			}
+		}
 	}
+	counts := make(map[string]int)
+	for i := range rm.CallEdges {
+		for _, path := range definedIn[rm.CallEdges[i].Caller] {
+			counts[path]++
+		}
+	}
+	return counts
+}
 
-	return strings.Join(parts, "\n")
+// indent prefixes every line of s with prefix.
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
 }
 
 // encodeMembers renders the members table for field/method tags.
-// Names are unqualified (the part after the last ".") since the owning type
+// Names are unqualified (the part after the last "." or "::") since the owning type
 // is shown in the symbols table above.
-func encodeMembers(members []model.Tag) string {
+func encodeMembers(members []model.Tag, strict bool) string {
 	rows := make([][]string, len(members))
 	for i := range members {
 		m := &members[i]
-		name := m.Name
-		if dot := strings.LastIndex(m.Name, "."); dot >= 0 {
-			name = m.Name[dot+1:]
-		}
+		_, name := model.SplitMember(m.Name)
 		rows[i] = []string{name, string(m.SymbolKind), fmt.Sprintf("%d", m.Line), m.Signature}
 	}
-	return formatTabular("members", []string{"name", "kind", "line", "signature"}, rows)
+	return formatTabular("members", []string{"name", "kind", "line", "signature"}, rows, strict)
 }
 
-func encodeSites(sites []model.CallSite) string {
+// encodeSites renders the callsites table. Deduplicated sites (see
+// graph.DedupeCallSites) get a lines column of semicolon-separated line
+// numbers, e.g. "10;20;35", in place of line. The kind column tells calls
+// from file-level imports, whose caller is "<import>". With ids, the caller
+// and callee are given by symbol id, empty when not defined in the map (as
+// for imports).
+func encodeSites(sites []model.CallSite, ids *idIndex, strict bool) string {
+	deduped := len(sites) > 0 && sites[0].Lines != nil
 	rows := make([][]string, len(sites))
 	for i := range sites {
 		cs := &sites[i]
-		rows[i] = []string{cs.Caller, cs.Callee, cs.File, fmt.Sprintf("%d", cs.Line)}
+		line := fmt.Sprintf("%d", cs.Line)
+		if deduped {
+			lines := make([]string, len(cs.Lines))
+			for j, l := range cs.Lines {
+				lines[j] = fmt.Sprintf("%d", l)
+			}
+			line = strings.Join(lines, ";")
+		}
+		if ids != nil {
+			rows[i] = []string{ids.inFile(cs.File, cs.Caller), ids.named(cs.Callee), cs.File, line, string(cs.Kind)}
+		} else {
+			rows[i] = []string{cs.Caller, cs.Callee, cs.File, line, string(cs.Kind)}
+		}
+	}
+	lineColumn := "line"
+	if deduped {
+		lineColumn = "lines"
 	}
-	return formatTabular("callsites", []string{"caller", "callee", "file", "line"}, rows)
+	columns := []string{"caller", "callee", "file", lineColumn, "kind"}
+	if ids != nil {
+		columns[0], columns[1] = "caller_id", "callee_id"
+	}
+	return formatTabular("callsites", columns, rows, strict)
 }
 
-func formatTabular(name string, columns []string, rows [][]string) string {
+// formatTabular renders a tabular array. When strict is set, cells in
+// identColumns are always quoted.
+func formatTabular(name string, columns []string, rows [][]string, strict bool) string {
 	var b strings.Builder
 	fmt.Fprintf(&b, "%s[%d]{%s}:", name, len(rows), strings.Join(columns, ","))
 	for _, row := range rows {
 		encoded := make([]string, len(row))
 		for i, cell := range row {
-			encoded[i] = encodeValue(cell)
+			if _, ok := identColumns[columns[i]]; ok && strict {
+				encoded[i] = quote(cell)
+			} else {
+				encoded[i] = encodeValue(cell)
+			}
 		}
 		fmt.Fprintf(&b, "\n  %s", strings.Join(encoded, ","))
 	}
@@ -1,6 +1,7 @@
 package toon
 
 import (
+	"slices"
 	"strings"
 	"testing"
 
@@ -105,7 +106,7 @@ func TestEncode(t *testing.T) {
 		},
 	}
 
-	got := Encode(rm, false)
+	got := Encode(rm, Options{})
 
 	// Verify structure
 	lines := strings.Split(got, "\n")
@@ -142,6 +143,47 @@ func TestEncode(t *testing.T) {
 	}
 }
 
+func TestSections(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Files: []model.FileInfo{{
+			Path: "main.py", Language: "python", Rank: 1,
+			Tags: []model.Tag{{Name: "main", Kind: model.Definition, SymbolKind: model.Function, Line: 1, Signature: "main()"}},
+		}},
+		Cycles: [][]string{{"a.py", "b.py"}},
+	}
+
+	sections := Sections(rm, Options{})
+	var names, bodies []string
+	for _, sec := range sections {
+		names = append(names, sec.Name)
+		bodies = append(bodies, sec.Body)
+	}
+	if want := []string{"files", "symbols", "dependencies", "cycles"}; !slices.Equal(names, want) {
+		t.Errorf("section names = %v, want %v", names, want)
+	}
+	if got, want := Encode(rm, Options{}), "repo: r\nroot: r\n"+strings.Join(bodies, "\n"); got != want {
+		t.Errorf("Encode is not the joined sections:\n%s\nwant:\n%s", got, want)
+	}
+	if got := EncodeCalls(rm, Options{}); got != "calls[0]{caller,callee,count}:" {
+		t.Errorf("EncodeCalls of no edges = %q", got)
+	}
+
+	want := `repo: r
+root: r
+sections[4]{name,file}:
+  files,files.toon
+  symbols,symbols.toon
+  dependencies,dependencies.toon
+  cycles,cycles.toon`
+	if got := EncodeIndex(rm, sections, Options{}); got != want {
+		t.Errorf("EncodeIndex:\n%s\nwant:\n%s", got, want)
+	}
+}
+
 func TestEncodeEmpty(t *testing.T) {
 	t.Parallel()
 
@@ -150,15 +192,15 @@ func TestEncodeEmpty(t *testing.T) {
 		Root:     "empty",
 	}
 
-	got := Encode(rm, false)
+	got := Encode(rm, Options{})
 	if !strings.Contains(got, "files[0]{path,language,rank}:") {
 		t.Errorf("expected empty files section, got:\n%s", got)
 	}
 	if !strings.Contains(got, "symbols[0]{file,name,kind,line,signature}:") {
 		t.Errorf("expected empty symbols section, got:\n%s", got)
 	}
-	if !strings.Contains(got, "calls[0]{caller,callee}:") {
-		t.Errorf("expected empty calls section, got:\n%s", got)
+	if strings.Contains(got, "calls[") {
+		t.Errorf("empty calls section should be omitted, got:\n%s", got)
 	}
 }
 
@@ -169,19 +211,19 @@ func TestEncodeCallEdges(t *testing.T) {
 		RepoName: "r",
 		Root:     "r",
 		CallEdges: []model.CallEdge{
-			{Caller: "foo", Callee: "bar"},
-			{Caller: "MyClass.method", Callee: "helper"},
+			{Caller: "foo", Callee: "bar", Count: 3},
+			{Caller: "MyClass.method", Callee: "helper", Count: 1},
 		},
 	}
 
-	got := Encode(rm, false)
-	if !strings.Contains(got, "calls[2]{caller,callee}:") {
+	got := Encode(rm, Options{})
+	if !strings.Contains(got, "calls[2]{caller,callee,count}:") {
 		t.Errorf("missing calls header:\n%s", got)
 	}
-	if !strings.Contains(got, "  foo,bar") {
+	if !strings.Contains(got, "  foo,bar,3") {
 		t.Errorf("missing foo,bar edge:\n%s", got)
 	}
-	if !strings.Contains(got, "  MyClass.method,helper") {
+	if !strings.Contains(got, "  MyClass.method,helper,1") {
 		t.Errorf("missing MyClass.method,helper edge:\n%s", got)
 	}
 	// No callsites populated — table must not appear.
@@ -200,20 +242,20 @@ func TestEncodeCallSites(t *testing.T) {
 			{Caller: "foo", Callee: "bar"},
 		},
 		CallSites: []model.CallSite{
-			{Caller: "foo", Callee: "bar", File: "pkg/a.go", Line: 42},
-			{Caller: "foo", Callee: "bar", File: "pkg/a.go", Line: 57},
+			{Caller: "foo", Callee: "bar", File: "pkg/a.go", Line: 42, Kind: model.SiteCall},
+			{Caller: "<import>", Callee: "bar", File: "pkg/b.go", Line: 3, Kind: model.SiteImport},
 		},
 	}
 
-	got := Encode(rm, false)
-	if !strings.Contains(got, "callsites[2]{caller,callee,file,line}:") {
+	got := Encode(rm, Options{})
+	if !strings.Contains(got, "callsites[2]{caller,callee,file,line,kind}:") {
 		t.Errorf("missing callsites header:\n%s", got)
 	}
-	if !strings.Contains(got, "foo,bar,pkg/a.go,42") {
-		t.Errorf("missing first call site:\n%s", got)
+	if !strings.Contains(got, "foo,bar,pkg/a.go,42,call") {
+		t.Errorf("missing call site:\n%s", got)
 	}
-	if !strings.Contains(got, "foo,bar,pkg/a.go,57") {
-		t.Errorf("missing second call site:\n%s", got)
+	if !strings.Contains(got, "<import>,bar,pkg/b.go,3,import") {
+		t.Errorf("missing import site:\n%s", got)
 	}
 }
 
@@ -237,7 +279,7 @@ func TestEncodeMembers(t *testing.T) {
 	}
 
 	// Focused mode: members appear before symbols.
-	got := Encode(rm, true)
+	got := Encode(rm, Options{Focused: true})
 	membersIdx := strings.Index(got, "members[2]")
 	symbolsIdx := strings.Index(got, "symbols[1]")
 	if membersIdx < 0 {
@@ -261,15 +303,547 @@ func TestEncodeMembers(t *testing.T) {
 	}
 
 	// Non-focused mode: members table still appears (at end).
-	got2 := Encode(rm, false)
+	got2 := Encode(rm, Options{})
 	if !strings.Contains(got2, "members[2]{name,kind,line,signature}:") {
 		t.Errorf("members table missing in non-focused mode:\n%s", got2)
 	}
 
 	// No members: table must not appear.
 	rm2 := &model.RepoMap{RepoName: "r", Root: "r"}
-	got3 := Encode(rm2, true)
+	got3 := Encode(rm2, Options{Focused: true})
 	if strings.Contains(got3, "members") {
 		t.Errorf("members table should not appear when Members is empty:\n%s", got3)
 	}
 }
+
+func TestEncodeStrict(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Files: []model.FileInfo{{
+			Path:     "0",
+			Language: "python",
+			Rank:     0.5,
+			Tags: []model.Tag{
+				{Name: "v2", Kind: model.Definition, SymbolKind: model.Variable, Line: 3, Signature: "v2 = 1"},
+			},
+		}},
+		Dependencies: []model.Dependency{{Source: "0", Target: "true", Symbols: []string{"v2"}}},
+		CallEdges:    []model.CallEdge{{Caller: "main", Callee: "v2"}},
+	}
+
+	got := Encode(rm, Options{Strict: true})
+	for _, want := range []string{
+		`files[1]{path,language,rank}:` + "\n" + `  "0",python,0.5000`,
+		`  "0","v2",variable,3,v2 = 1`,
+		`  "0","true",v2`,
+		`  "main","v2"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+
+	// Without Strict, numeric-looking paths stay bare.
+	if got := Encode(rm, Options{}); !strings.Contains(got, "\n  0,python,0.5000") {
+		t.Errorf("default encoding should leave numeric path bare:\n%s", got)
+	}
+}
+
+func TestEncodeGroupSymbols(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Files: []model.FileInfo{
+			{
+				Path: "src/main.py", Language: "python", Rank: 0.6,
+				Tags: []model.Tag{
+					{Name: "main", Kind: model.Definition, SymbolKind: model.Function, Line: 1, Signature: "main()"},
+					{Name: "helper", Kind: model.Reference, SymbolKind: model.Function, Line: 2},
+					{Name: "run", Kind: model.Definition, SymbolKind: model.Function, Line: 4, Signature: "run(a, b)"},
+				},
+			},
+			{Path: "src/empty.py", Language: "python", Rank: 0.3},
+			{
+				Path: "src/util.py", Language: "python", Rank: 0.1,
+				Tags: []model.Tag{
+					{Name: "helper", Kind: model.Definition, SymbolKind: model.Function, Line: 1, Signature: "helper(x)"},
+				},
+			},
+		},
+	}
+
+	got := Encode(rm, Options{GroupSymbols: true})
+	want := `symbols[2]:
+  - file: src/main.py
+    defs[2]{name,kind,line,signature}:
+      main,function,1,main()
+      run,function,4,"run(a, b)"
+  - file: src/util.py
+    defs[1]{name,kind,line,signature}:
+      helper,function,1,helper(x)
+dependencies[0]`
+	if !strings.Contains(got, want) {
+		t.Errorf("grouped symbols:\n%s\nwant substring:\n%s", got, want)
+	}
+	if strings.Contains(got, "{file,name") {
+		t.Errorf("flat symbols table should not be emitted:\n%s", got)
+	}
+
+	// No definitions at all: an empty list.
+	rm.Files = rm.Files[1:2]
+	if got := Encode(rm, Options{GroupSymbols: true}); !strings.Contains(got, "symbols[0]:\ndependencies") {
+		t.Errorf("empty grouped symbols:\n%s", got)
+	}
+}
+
+func TestEncodeGroupByLanguage(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Files: []model.FileInfo{
+			{
+				Path: "api/server.go", Language: "go", Rank: 0.5,
+				Tags: []model.Tag{{Name: "Serve", Kind: model.Definition, SymbolKind: model.Function, Line: 3, Signature: "func Serve()"}},
+			},
+			{
+				Path: "api/schema.py", Language: "python", Rank: 0.3,
+				Tags: []model.Tag{{Name: "Schema", Kind: model.Definition, SymbolKind: model.Class, Line: 1, Signature: "Schema"}},
+			},
+			{Path: "cmd/main.go", Language: "go", Rank: 0.2},
+		},
+		Dependencies: []model.Dependency{
+			{Source: "cmd/main.go", Target: "api/server.go", Symbols: []string{"Serve"}},
+			{Source: "api/server.go", Target: "api/schema.py", Symbols: []string{"Schema"}},
+		},
+		CallEdges: []model.CallEdge{{Caller: "main", Callee: "Serve", Count: 1}},
+	}
+
+	got := Encode(rm, Options{GroupByLanguage: true})
+	want := `repo: r
+root: r
+go:
+  files[2]{path,language,rank}:
+    api/server.go,go,0.5000
+    cmd/main.go,go,0.2000
+  symbols[1]{file,name,kind,line,signature}:
+    api/server.go,Serve,function,3,func Serve()
+  dependencies[1]{source,target,symbols}:
+    cmd/main.go,api/server.go,Serve
+python:
+  files[1]{path,language,rank}:
+    api/schema.py,python,0.3000
+  symbols[1]{file,name,kind,line,signature}:
+    api/schema.py,Schema,class,1,Schema
+  dependencies[0]{source,target,symbols}:
+shared:
+  dependencies[1]{source,target,symbols}:
+    api/server.go,api/schema.py,Schema
+calls[1]{caller,callee,count}:
+  main,Serve,1`
+	if got != want {
+		t.Errorf("Encode with GroupByLanguage:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Without cross-language edges there is no shared section.
+	rm.Dependencies = rm.Dependencies[:1]
+	if got := Encode(rm, Options{GroupByLanguage: true}); strings.Contains(got, "shared:") {
+		t.Errorf("unexpected shared section:\n%s", got)
+	}
+}
+
+func TestEncodeSymbolsByName(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Files: []model.FileInfo{
+			{
+				Path: "b.py", Language: "python", Rank: 0.6,
+				Tags: []model.Tag{
+					{Name: "zeta", Kind: model.Definition, SymbolKind: model.Function, Line: 1, Signature: "zeta()"},
+					{Name: "Alpha", Kind: model.Definition, SymbolKind: model.Class, Line: 4, Signature: "Alpha"},
+				},
+			},
+			{
+				Path: "a.py", Language: "python", Rank: 0.4,
+				Tags: []model.Tag{
+					{Name: "beta", Kind: model.Definition, SymbolKind: model.Function, Line: 1, Signature: "beta()"},
+					{Name: "alpha", Kind: model.Definition, SymbolKind: model.Function, Line: 3, Signature: "alpha()"},
+				},
+			},
+		},
+	}
+
+	got := Encode(rm, Options{SymbolsByName: true})
+	want := `files[2]{path,language,rank}:
+  b.py,python,0.6000
+  a.py,python,0.4000
+symbols[4]{file,name,kind,line,signature}:
+  b.py,Alpha,class,4,Alpha
+  a.py,alpha,function,3,alpha()
+  a.py,beta,function,1,beta()
+  b.py,zeta,function,1,zeta()`
+	if !strings.Contains(got, want) {
+		t.Errorf("Encode with SymbolsByName:\n%s\nwant substring:\n%s", got, want)
+	}
+}
+
+func TestEncodeCycles(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Cycles:   [][]string{{"a.py", "b.py", "c.py"}},
+	}
+
+	got := Encode(rm, Options{})
+	if !strings.Contains(got, "cycles[1]{files}:\n  a.py b.py c.py") {
+		t.Errorf("missing cycles table:\n%s", got)
+	}
+
+	// No cycles: the table is omitted entirely.
+	rm.Cycles = nil
+	if got := Encode(rm, Options{}); strings.Contains(got, "cycles[") {
+		t.Errorf("cycles table should be omitted when empty:\n%s", got)
+	}
+}
+
+func TestEncodeExternals(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName:  "r",
+		Root:      "r",
+		Externals: []model.External{{Name: "Println", Count: 12}, {Name: "Errorf", Count: 3}},
+	}
+
+	got := Encode(rm, Options{})
+	if !strings.Contains(got, "externals[2]{name,count}:\n  Println,12\n  Errorf,3") {
+		t.Errorf("missing externals table:\n%s", got)
+	}
+
+	rm.Externals = nil
+	if got := Encode(rm, Options{}); strings.Contains(got, "externals[") {
+		t.Errorf("externals table should be omitted when empty:\n%s", got)
+	}
+}
+
+func TestEncodeRefs(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Refs:     []model.Ref{{File: "a.py", Name: "helper", Line: 3}, {File: "a.py", Name: "os", Line: 1}},
+	}
+
+	got := Encode(rm, Options{})
+	if !strings.Contains(got, "refs[2]{file,name,line}:\n  a.py,helper,3\n  a.py,os,1") {
+		t.Errorf("missing refs table:\n%s", got)
+	}
+
+	rm.Refs = nil
+	if got := Encode(rm, Options{}); strings.Contains(got, "refs[") {
+		t.Errorf("refs table should be omitted when empty:\n%s", got)
+	}
+}
+
+func TestEncodeFileMetrics(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Files: []model.FileInfo{
+			{
+				Path: "main.py", Language: "python", Rank: 0.6,
+				Tags: []model.Tag{
+					{Name: "main", Kind: model.Definition, SymbolKind: model.Function, Line: 1},
+					{Name: "helper", Kind: model.Reference, SymbolKind: model.Function, Line: 2},
+					{Name: "run", Kind: model.Definition, SymbolKind: model.Function, Line: 4},
+				},
+			},
+			{
+				Path: "util.py", Language: "python", Rank: 0.4,
+				Tags: []model.Tag{
+					{Name: "helper", Kind: model.Definition, SymbolKind: model.Function, Line: 1},
+				},
+			},
+		},
+		CallEdges: []model.CallEdge{
+			{Caller: "main", Callee: "helper"},
+			{Caller: "main", Callee: "run"},
+			{Caller: "run", Callee: "helper"},
+		},
+	}
+
+	got := Encode(rm, Options{FileMetrics: true})
+	want := `files[2]{path,language,rank,symbols,calls}:
+  main.py,python,0.6000,2,3
+  util.py,python,0.4000,1,0`
+	if !strings.Contains(got, want) {
+		t.Errorf("files table:\n%s\nwant substring:\n%s", got, want)
+	}
+
+	if got := Encode(rm, Options{}); !strings.Contains(got, "files[2]{path,language,rank}:") {
+		t.Errorf("metrics columns should be opt-in:\n%s", got)
+	}
+}
+
+func TestEncodeDedupedCallSites(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		CallSites: []model.CallSite{
+			{Caller: "run", Callee: "log", File: "a.py", Line: 10, Lines: []int{10, 20, 35}, Kind: model.SiteCall},
+			{Caller: "run", Callee: "save", File: "a.py", Line: 12, Lines: []int{12}, Kind: model.SiteCall},
+		},
+	}
+	got := Encode(rm, Options{Focused: true})
+	want := `callsites[2]{caller,callee,file,lines,kind}:
+  run,log,a.py,10;20;35,call
+  run,save,a.py,12,call`
+	if !strings.Contains(got, want) {
+		t.Errorf("deduped callsites:\n%s\nwant substring:\n%s", got, want)
+	}
+}
+
+func TestEncodeWithDocs(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Files: []model.FileInfo{
+			{
+				Path: "main.py", Language: "python", Rank: 1,
+				Tags: []model.Tag{
+					{Name: "main", Kind: model.Definition, SymbolKind: model.Function, Line: 1, Signature: "main()", Doc: "Run the app."},
+					{Name: "helper", Kind: model.Definition, SymbolKind: model.Function, Line: 5, Signature: "helper()"},
+				},
+			},
+		},
+	}
+
+	got := Encode(rm, Options{WithDocs: true})
+	want := `symbols[2]{file,name,kind,line,signature,doc}:
+  main.py,main,function,1,main(),Run the app.
+  main.py,helper,function,5,helper(),`
+	if !strings.Contains(got, want) {
+		t.Errorf("symbols with docs:\n%s\nwant substring:\n%s", got, want)
+	}
+
+	got = Encode(rm, Options{WithDocs: true, GroupSymbols: true})
+	if !strings.Contains(got, "defs[2]{name,kind,line,signature,doc}:\n      main,function,1,main(),Run the app.") {
+		t.Errorf("grouped symbols with docs:\n%s", got)
+	}
+
+	if got := Encode(rm, Options{}); strings.Contains(got, "doc") {
+		t.Errorf("doc column should be opt-in:\n%s", got)
+	}
+}
+
+func TestEncodeMetrics(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Metrics: []model.Metric{
+			{File: "util.go", InDegree: 7, OutDegree: 1, Rank: 0.31234},
+			{File: "main.go", InDegree: 0, OutDegree: 5, Rank: 0.05},
+		},
+	}
+	got := Encode(rm, Options{})
+	want := `metrics[2]{file,in_degree,out_degree,rank}:
+  util.go,7,1,0.3123
+  main.go,0,5,0.0500`
+	if !strings.Contains(got, want) {
+		t.Errorf("metrics table:\n%s\nwant substring:\n%s", got, want)
+	}
+
+	rm.Metrics = nil
+	if got := Encode(rm, Options{}); strings.Contains(got, "metrics") {
+		t.Errorf("empty metrics table should be omitted:\n%s", got)
+	}
+}
+
+func TestEncodeDirs(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Dirs: []model.Dir{
+			{Path: "pkg", Rank: 0.61234, Files: 3},
+			{Path: ".", Rank: 0.2, Files: 1},
+		},
+		Dependencies: []model.Dependency{{Source: ".", Target: "pkg", Symbols: []string{"Run", "Stop"}}},
+	}
+	want := `repo: r
+root: r
+dirs[2]{path,rank,files}:
+  pkg,0.6123,3
+  .,0.2000,1
+dependencies[1]{source,target,symbols}:
+  .,pkg,Run Stop`
+	if got := EncodeDirs(rm, Options{}); got != want {
+		t.Errorf("EncodeDirs:\n%s\nwant:\n%s", got, want)
+	}
+
+	rm.Cycles = [][]string{{".", "pkg"}}
+	if got := EncodeDirs(rm, Options{}); !strings.HasSuffix(got, "cycles[1]{dirs}:\n  . pkg") {
+		t.Errorf("cycles table missing:\n%s", got)
+	}
+}
+
+func TestEncodeDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName:    "r",
+		Root:        "r",
+		Diagnostics: []model.Diagnostic{{File: "broken.py", Message: "syntax errors; symbols may be incomplete"}},
+	}
+	got := Encode(rm, Options{})
+	want := "diagnostics[1]{file,message}:\n  broken.py,syntax errors; symbols may be incomplete"
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("diagnostics table:\n%s\nwant suffix:\n%s", got, want)
+	}
+
+	rm.Diagnostics = nil
+	if got := Encode(rm, Options{}); strings.Contains(got, "diagnostics") {
+		t.Errorf("empty diagnostics table should be omitted:\n%s", got)
+	}
+}
+
+func TestEncodeWithIDs(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Files: []model.FileInfo{
+			{Path: "util.py", Language: "python", Tags: []model.Tag{
+				{Name: "helper", Kind: model.Definition, SymbolKind: model.Function, Line: 1, Signature: "helper()"},
+			}},
+			{Path: "main.py", Language: "python", Tags: []model.Tag{
+				{Name: "main", Kind: model.Definition, SymbolKind: model.Function, Line: 3, Signature: "main()"},
+			}},
+		},
+		Dependencies: []model.Dependency{{Source: "main.py", Target: "util.py", Symbols: []string{"helper"}}},
+		CallEdges:    []model.CallEdge{{Caller: "main", Callee: "helper", Count: 1}},
+		CallSites: []model.CallSite{
+			{Caller: "main", Callee: "helper", File: "main.py", Line: 4, Kind: model.SiteCall},
+			{Caller: "<import>", Callee: "util", File: "main.py", Line: 1, Kind: model.SiteImport},
+		},
+	}
+	helper, main := symbolID("util.py", "helper", 1), symbolID("main.py", "main", 3)
+	if len(helper) != 12 || helper == main {
+		t.Fatalf("symbol ids %q and %q should be distinct 12-digit hashes", helper, main)
+	}
+	if again := symbolID("util.py", "helper", 1); again != helper {
+		t.Errorf("symbolID is not deterministic: %q then %q", helper, again)
+	}
+
+	got := Encode(rm, Options{WithIDs: true})
+	for _, want := range []string{
+		"symbols[2]{id,file,name,kind,line,signature}:\n  " + helper + ",util.py,helper,function,1,helper()",
+		"dependencies[1]{source,target,symbol_ids}:\n  main.py,util.py," + helper,
+		"calls[1]{caller_id,callee_id,count}:\n  " + main + "," + helper + ",1",
+		"callsites[2]{caller_id,callee_id,file,line,kind}:\n  " + main + "," + helper + ",main.py,4,call\n  \"\",\"\",main.py,1,import",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+
+	grouped := Encode(rm, Options{WithIDs: true, GroupSymbols: true})
+	if want := "defs[1]{id,name,kind,line,signature}:\n      " + helper + ",helper,"; !strings.Contains(grouped, want) {
+		t.Errorf("missing %q in grouped symbols:\n%s", want, grouped)
+	}
+
+	if got := Encode(rm, Options{}); strings.Contains(got, helper) || strings.Contains(got, "_id") {
+		t.Errorf("ids should need WithIDs:\n%s", got)
+	}
+}
+
+func TestEncodePackages(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Files: []model.FileInfo{
+			{Path: "server/server.go", Language: "go", Rank: 0.6, Package: "server"},
+			{Path: "tool.py", Language: "python", Rank: 0.4},
+		},
+		Packages: []model.Package{{Dir: "server", Name: "server", Doc: "Package server answers requests."}},
+	}
+	got := Encode(rm, Options{})
+	for _, want := range []string{
+		"files[2]{path,language,rank,package}:\n  server/server.go,go,0.6000,server\n  tool.py,python,0.4000,\"\"\n",
+		"packages[1]{dir,package,doc}:\n  server,server,Package server answers requests.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+
+	rm.Files[0].Package = ""
+	rm.Packages = nil
+	got = Encode(rm, Options{})
+	if strings.Contains(got, "package") {
+		t.Errorf("package column and table should be omitted without Go packages:\n%s", got)
+	}
+}
+
+func TestEncodeUnused(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Unused:   []model.Tag{{File: "lib.go", Name: "Dead", Kind: model.Definition, SymbolKind: model.Function, Line: 7}},
+	}
+	want := "unused[1]{file,name,kind,line}:\n  lib.go,Dead,function,7"
+	if got := Encode(rm, Options{}); !strings.HasSuffix(got, want) {
+		t.Errorf("unused table:\n%s\nwant suffix:\n%s", got, want)
+	}
+}
+
+func TestEncodeEntrypoints(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "r",
+		Root:     "r",
+		Entrypoints: []model.Entrypoint{
+			{File: "cmd/main.go", Name: "main", Line: 5},
+			{File: "tool.py", Name: "__main__", Line: 20},
+		},
+	}
+	got := Encode(rm, Options{})
+	want := `entrypoints[2]{file,name,line}:
+  cmd/main.go,main,5
+  tool.py,__main__,20`
+	if !strings.Contains(got, want) {
+		t.Errorf("entrypoints table:\n%s\nwant substring:\n%s", got, want)
+	}
+
+	rm.Entrypoints = nil
+	if got := Encode(rm, Options{}); strings.Contains(got, "entrypoints") {
+		t.Errorf("empty entrypoints table should be omitted:\n%s", got)
+	}
+}
@@ -0,0 +1,73 @@
+// Package treeout renders the ranked file list as an indented directory tree.
+package treeout
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+// node is a directory or file in the tree. rank is the file's PageRank, or
+// for a directory the highest rank of any file below it.
+type node struct {
+	name     string
+	rank     float64
+	file     bool
+	children map[string]*node
+}
+
+// Encode renders rm.Files as a directory tree rooted at rm.RepoName, one
+// entry per line indented two spaces per level, with each file annotated with
+// its PageRank. Entries in a directory are sorted by rank, highest first, and
+// a directory sorts by its most central file, so the most important file in
+// each directory is listed first. Ties are broken by name.
+func Encode(rm *model.RepoMap) string {
+	root := &node{name: rm.RepoName, children: make(map[string]*node)}
+	for i := range rm.Files {
+		fi := &rm.Files[i]
+		parts := strings.Split(fi.Path, "/")
+		dir := root
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := dir.children[part]
+			if !ok {
+				child = &node{name: part, children: make(map[string]*node)}
+				dir.children[part] = child
+			}
+			dir.rank = max(dir.rank, fi.Rank)
+			dir = child
+		}
+		dir.rank = max(dir.rank, fi.Rank)
+		name := parts[len(parts)-1]
+		dir.children[name] = &node{name: name, rank: fi.Rank, file: true}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s/", root.name)
+	writeChildren(&b, root, 1)
+	return b.String()
+}
+
+func writeChildren(b *strings.Builder, dir *node, depth int) {
+	children := make([]*node, 0, len(dir.children))
+	for _, c := range dir.children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].rank != children[j].rank {
+			return children[i].rank > children[j].rank
+		}
+		return children[i].name < children[j].name
+	})
+
+	indent := strings.Repeat("  ", depth)
+	for _, c := range children {
+		if c.file {
+			fmt.Fprintf(b, "\n%s%s (%.4f)", indent, c.name, c.rank)
+			continue
+		}
+		fmt.Fprintf(b, "\n%s%s/", indent, c.name)
+		writeChildren(b, c, depth+1)
+	}
+}
@@ -0,0 +1,44 @@
+package treeout
+
+import (
+	"testing"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "repo",
+		Files: []model.FileInfo{
+			{Path: "main.go", Rank: 0.10},
+			{Path: "internal/model/model.go", Rank: 0.40},
+			{Path: "internal/graph/graph.go", Rank: 0.25},
+			{Path: "internal/graph/pagerank.go", Rank: 0.05},
+			{Path: "README.go", Rank: 0.10},
+		},
+	}
+
+	got := Encode(rm)
+	want := `repo/
+  internal/
+    model/
+      model.go (0.4000)
+    graph/
+      graph.go (0.2500)
+      pagerank.go (0.0500)
+  README.go (0.1000)
+  main.go (0.1000)`
+	if got != want {
+		t.Errorf("Encode:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEncodeEmpty(t *testing.T) {
+	t.Parallel()
+
+	if got := Encode(&model.RepoMap{RepoName: "repo"}); got != "repo/" {
+		t.Errorf("Encode(empty) = %q", got)
+	}
+}
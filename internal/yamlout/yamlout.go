@@ -0,0 +1,281 @@
+// Package yamlout implements YAML encoding of a repository map for
+// YAML-based tooling and human readers.
+package yamlout
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+var (
+	// plainSafe matches scalars that can be written unquoted: no YAML
+	// indicator characters, flow punctuation, or comment/mapping markers.
+	plainSafe   = regexp.MustCompile(`^[A-Za-z0-9_./()<=+^$~-][A-Za-z0-9_./()<>=+*^$~ -]*$`)
+	looksNumber = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9][0-9_]*(\.[0-9]*)?)([eE][-+]?[0-9]+)?$|^0[xo][0-9a-fA-F]+$|^[-+]?\.(inf|Inf|INF)$|^\.(nan|NaN|NAN)$`)
+	keywords    = map[string]struct{}{
+		"true": {}, "false": {}, "yes": {}, "no": {}, "on": {}, "off": {},
+		"y": {}, "n": {}, "null": {}, "~": {},
+	}
+)
+
+// field is one key/value pair of a mapping; value is already encoded.
+type field struct {
+	key, value string
+}
+
+// Encode converts a RepoMap into a YAML document. Top-level keys appear in a
+// fixed order (repo, root, files, symbols, dependencies, calls), followed by
+// callsites, members, cycles, externals, refs, entrypoints, metrics,
+// diagnostics, unused, unreachable, and packages when they are non-empty. Symbols are
+// definition tags only, matching the TOON symbols table.
+func Encode(rm *model.RepoMap) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "repo: %s\n", encodeValue(rm.RepoName))
+	fmt.Fprintf(&b, "root: %s\n", encodeValue(rm.Root))
+
+	var files, symbols [][]field
+	for i := range rm.Files {
+		fi := &rm.Files[i]
+		file := []field{
+			{"path", encodeValue(fi.Path)},
+			{"language", encodeValue(fi.Language)},
+		}
+		if fi.Package != "" {
+			file = append(file, field{"package", encodeValue(fi.Package)})
+		}
+		files = append(files, append(file, field{"rank", fmt.Sprintf("%.4f", fi.Rank)}))
+		for j := range fi.Tags {
+			tag := &fi.Tags[j]
+			if tag.Kind == model.Definition {
+				symbols = append(symbols, tagFields(fi.Path, tag))
+			}
+		}
+	}
+	writeList(&b, "files", files)
+	writeList(&b, "symbols", symbols)
+
+	var deps [][]field
+	for i := range rm.Dependencies {
+		d := &rm.Dependencies[i]
+		deps = append(deps, []field{
+			{"source", encodeValue(d.Source)},
+			{"target", encodeValue(d.Target)},
+			{"symbols", encodeFlow(d.Symbols)},
+		})
+	}
+	writeList(&b, "dependencies", deps)
+
+	var calls [][]field
+	for i := range rm.CallEdges {
+		ce := &rm.CallEdges[i]
+		calls = append(calls, []field{
+			{"caller", encodeValue(ce.Caller)},
+			{"callee", encodeValue(ce.Callee)},
+			{"count", strconv.Itoa(ce.Count)},
+		})
+	}
+	writeList(&b, "calls", calls)
+
+	if len(rm.CallSites) > 0 {
+		var sites [][]field
+		for i := range rm.CallSites {
+			cs := &rm.CallSites[i]
+			site := []field{
+				{"caller", encodeValue(cs.Caller)},
+				{"callee", encodeValue(cs.Callee)},
+				{"file", encodeValue(cs.File)},
+				{"line", strconv.Itoa(cs.Line)},
+			}
+			if cs.Lines != nil {
+				lines := make([]string, len(cs.Lines))
+				for j, l := range cs.Lines {
+					lines[j] = strconv.Itoa(l)
+				}
+				site = append(site, field{"lines", "[" + strings.Join(lines, ", ") + "]"})
+			}
+			site = append(site, field{"kind", encodeValue(string(cs.Kind))})
+			sites = append(sites, site)
+		}
+		writeList(&b, "callsites", sites)
+	}
+
+	if len(rm.Members) > 0 {
+		var members [][]field
+		for i := range rm.Members {
+			m := &rm.Members[i]
+			members = append(members, tagFields(m.File, m))
+		}
+		writeList(&b, "members", members)
+	}
+
+	if len(rm.Cycles) > 0 {
+		b.WriteString("cycles:\n")
+		for _, c := range rm.Cycles {
+			fmt.Fprintf(&b, "  - %s\n", encodeFlow(c))
+		}
+	}
+
+	if len(rm.Externals) > 0 {
+		var externals [][]field
+		for _, e := range rm.Externals {
+			externals = append(externals, []field{
+				{"name", encodeValue(e.Name)},
+				{"count", strconv.Itoa(e.Count)},
+			})
+		}
+		writeList(&b, "externals", externals)
+	}
+
+	if len(rm.Refs) > 0 {
+		var refs [][]field
+		for _, r := range rm.Refs {
+			refs = append(refs, []field{
+				{"file", encodeValue(r.File)},
+				{"name", encodeValue(r.Name)},
+				{"line", strconv.Itoa(r.Line)},
+			})
+		}
+		writeList(&b, "refs", refs)
+	}
+
+	if len(rm.Entrypoints) > 0 {
+		var entries [][]field
+		for _, e := range rm.Entrypoints {
+			entries = append(entries, []field{
+				{"file", encodeValue(e.File)},
+				{"name", encodeValue(e.Name)},
+				{"line", strconv.Itoa(e.Line)},
+			})
+		}
+		writeList(&b, "entrypoints", entries)
+	}
+
+	if len(rm.Metrics) > 0 {
+		var metrics [][]field
+		for _, m := range rm.Metrics {
+			metrics = append(metrics, []field{
+				{"file", encodeValue(m.File)},
+				{"in_degree", strconv.Itoa(m.InDegree)},
+				{"out_degree", strconv.Itoa(m.OutDegree)},
+				{"rank", fmt.Sprintf("%.4f", m.Rank)},
+			})
+		}
+		writeList(&b, "metrics", metrics)
+	}
+
+	if len(rm.Diagnostics) > 0 {
+		var diagnostics [][]field
+		for _, d := range rm.Diagnostics {
+			diagnostics = append(diagnostics, []field{
+				{"file", encodeValue(d.File)},
+				{"message", encodeValue(d.Message)},
+			})
+		}
+		writeList(&b, "diagnostics", diagnostics)
+	}
+
+	if len(rm.Unused) > 0 {
+		var unused [][]field
+		for i := range rm.Unused {
+			u := &rm.Unused[i]
+			unused = append(unused, []field{
+				{"file", encodeValue(u.File)},
+				{"name", encodeValue(u.Name)},
+				{"kind", encodeValue(string(u.SymbolKind))},
+				{"line", strconv.Itoa(u.Line)},
+			})
+		}
+		writeList(&b, "unused", unused)
+	}
+
+	if len(rm.Unreachable) > 0 {
+		var unreachable [][]field
+		for i := range rm.Unreachable {
+			u := &rm.Unreachable[i]
+			unreachable = append(unreachable, []field{
+				{"file", encodeValue(u.File)},
+				{"name", encodeValue(u.Name)},
+				{"kind", encodeValue(string(u.SymbolKind))},
+				{"line", strconv.Itoa(u.Line)},
+			})
+		}
+		writeList(&b, "unreachable", unreachable)
+	}
+
+	if len(rm.Packages) > 0 {
+		var packages [][]field
+		for _, p := range rm.Packages {
+			packages = append(packages, []field{
+				{"dir", encodeValue(p.Dir)},
+				{"name", encodeValue(p.Name)},
+				{"doc", encodeValue(p.Doc)},
+			})
+		}
+		writeList(&b, "packages", packages)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func tagFields(file string, tag *model.Tag) []field {
+	return []field{
+		{"file", encodeValue(file)},
+		{"name", encodeValue(tag.Name)},
+		{"kind", encodeValue(string(tag.SymbolKind))},
+		{"line", strconv.Itoa(tag.Line)},
+		{"signature", encodeValue(tag.Signature)},
+	}
+}
+
+// writeList writes key as a block sequence of mappings, or as [] when empty.
+func writeList(b *strings.Builder, key string, items [][]field) {
+	if len(items) == 0 {
+		fmt.Fprintf(b, "%s: []\n", key)
+		return
+	}
+	fmt.Fprintf(b, "%s:\n", key)
+	for _, item := range items {
+		for i, f := range item {
+			prefix := "    "
+			if i == 0 {
+				prefix = "  - "
+			}
+			fmt.Fprintf(b, "%s%s: %s\n", prefix, f.key, f.value)
+		}
+	}
+}
+
+// encodeFlow renders values as a flow sequence, e.g. [User, greet].
+func encodeFlow(values []string) string {
+	encoded := make([]string, len(values))
+	for i, v := range values {
+		encoded[i] = encodeValue(v)
+	}
+	return "[" + strings.Join(encoded, ", ") + "]"
+}
+
+// encodeValue returns value as a plain scalar when that is unambiguous and
+// otherwise as a double-quoted scalar. Go's quoting escapes are a subset of
+// YAML's, so strconv.Quote produces a valid YAML string.
+func encodeValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	if value != strings.TrimSpace(value) || !plainSafe.MatchString(value) {
+		return strconv.Quote(value)
+	}
+	if _, ok := keywords[strings.ToLower(value)]; ok {
+		return strconv.Quote(value)
+	}
+	if looksNumber.MatchString(value) {
+		return strconv.Quote(value)
+	}
+	if value == "-" || strings.HasPrefix(value, "- ") {
+		return strconv.Quote(value)
+	}
+	return value
+}
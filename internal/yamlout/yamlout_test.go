@@ -0,0 +1,147 @@
+package yamlout
+
+import (
+	"testing"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName: "myproject",
+		Root:     "myproject",
+		Files: []model.FileInfo{
+			{
+				Path: "models.py", Language: "python", Rank: 0.27551,
+				Tags: []model.Tag{
+					{Name: "User", Kind: model.Definition, SymbolKind: model.Class, Line: 10, Signature: "User"},
+					{Name: "User.save", Kind: model.Definition, SymbolKind: model.Method, Line: 12, Signature: "save(self, force: bool)"},
+					{Name: "json", Kind: model.Reference, SymbolKind: model.Module, Line: 1},
+				},
+			},
+		},
+		Dependencies: []model.Dependency{{Source: "main.py", Target: "models.py", Symbols: []string{"User", "save"}}},
+		CallEdges:    []model.CallEdge{{Caller: "greet", Callee: "User", Count: 2}},
+	}
+
+	got := Encode(rm)
+	want := `repo: myproject
+root: myproject
+files:
+  - path: models.py
+    language: python
+    rank: 0.2755
+symbols:
+  - file: models.py
+    name: User
+    kind: class
+    line: 10
+    signature: User
+  - file: models.py
+    name: User.save
+    kind: method
+    line: 12
+    signature: "save(self, force: bool)"
+dependencies:
+  - source: main.py
+    target: models.py
+    symbols: [User, save]
+calls:
+  - caller: greet
+    callee: User
+    count: 2`
+	if got != want {
+		t.Errorf("Encode:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEncodeEmpty(t *testing.T) {
+	t.Parallel()
+
+	got := Encode(&model.RepoMap{RepoName: "r", Root: "r"})
+	want := `repo: r
+root: r
+files: []
+symbols: []
+dependencies: []
+calls: []`
+	if got != want {
+		t.Errorf("Encode(empty):\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEncodeOptionalSections(t *testing.T) {
+	t.Parallel()
+
+	rm := &model.RepoMap{
+		RepoName:  "r",
+		Root:      "r",
+		CallSites: []model.CallSite{{Caller: "<import>", Callee: "User", File: "main.py", Line: 1, Kind: model.SiteImport}},
+		Cycles:    [][]string{{"a.py", "b.py"}},
+		Externals: []model.External{{Name: "print", Count: 3}},
+		Diagnostics: []model.Diagnostic{
+			{File: "broken.py", Message: "syntax errors; symbols may be incomplete"},
+		},
+	}
+	got := Encode(rm)
+	want := `repo: r
+root: r
+files: []
+symbols: []
+dependencies: []
+calls: []
+callsites:
+  - caller: <import>
+    callee: User
+    file: main.py
+    line: 1
+    kind: import
+cycles:
+  - [a.py, b.py]
+externals:
+  - name: print
+    count: 3
+diagnostics:
+  - file: broken.py
+    message: "syntax errors; symbols may be incomplete"`
+	if got != want {
+		t.Errorf("Encode:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEncodeValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"models.py", "models.py"},
+		{"internal/graph/graph.go", "internal/graph/graph.go"},
+		{"", `""`},
+		{"a: b", `"a: b"`},
+		{"C:\\path", `"C:\\path"`},
+		{"# comment", `"# comment"`},
+		{"true", `"true"`},
+		{"No", `"No"`},
+		{"null", `"null"`},
+		{"42", `"42"`},
+		{"1.5", `"1.5"`},
+		{"- item", `"- item"`},
+		{"-flag", "-flag"},
+		{"*alias", `"*alias"`},
+		{"> folded", `"> folded"`},
+		{"it's", `"it's"`},
+		{" padded", `" padded"`},
+		{"line\nbreak", `"line\nbreak"`},
+		{"Server.Handle", "Server.Handle"},
+		{"Map[T]", `"Map[T]"`},
+	}
+	for _, tt := range tests {
+		if got := encodeValue(tt.in); got != tt.want {
+			t.Errorf("encodeValue(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -2,45 +2,108 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	sitter "github.com/smacker/go-tree-sitter"
 
+	"github.com/phobologic/repoguide/internal/cache"
+	"github.com/phobologic/repoguide/internal/config"
+	"github.com/phobologic/repoguide/internal/ctags"
 	"github.com/phobologic/repoguide/internal/discover"
 	"github.com/phobologic/repoguide/internal/graph"
+	"github.com/phobologic/repoguide/internal/jsonout"
 	"github.com/phobologic/repoguide/internal/lang"
+	"github.com/phobologic/repoguide/internal/mdreport"
+	"github.com/phobologic/repoguide/internal/mermaid"
 	"github.com/phobologic/repoguide/internal/model"
 	"github.com/phobologic/repoguide/internal/parse"
+	"github.com/phobologic/repoguide/internal/pbout"
 	"github.com/phobologic/repoguide/internal/ranking"
+	"github.com/phobologic/repoguide/internal/sqliteout"
 	"github.com/phobologic/repoguide/internal/toon"
+	"github.com/phobologic/repoguide/internal/treeout"
+	"github.com/phobologic/repoguide/internal/yamlout"
 )
 
 var version = "dev"
 
 const defaultMaxFileSize = 1_000_000 // 1 MB
 
+// defaultMarkdownFiles bounds --format markdown, which is meant to be read by
+// people, when neither -n nor --max-tokens is given (-n 0 shows every file).
+const defaultMarkdownFiles = 30
+
+// defaultSearchResults bounds the hits of --search when -n is not given
+// (-n 0 lists every hit).
+const defaultSearchResults = 20
+
+// errNoFiles reports that the run completed but found nothing to map. It
+// gets its own exit status so scripts can tell an empty repo from a failure.
+var errNoFiles = errors.New("no parseable files found")
+
+// Exit statuses.
+const (
+	exitOK      = 0
+	exitError   = 1
+	exitNoFiles = 2
+)
+
 func main() {
-	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
-		if errors.Is(err, flag.ErrHelp) {
-			os.Exit(0)
-		}
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
 	}
+	os.Exit(exitCode(err))
 }
 
-func run(args []string, stdout, stderr io.Writer) error {
+// exitCode maps an error returned by run to the process exit status.
+func exitCode(err error) int {
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errNoFiles):
+		return exitNoFiles
+	default:
+		return exitError
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) (err error) {
 	if len(args) > 0 && args[0] == "init" {
 		return runInit(args[1:], stdout, stderr)
 	}
+	if len(args) > 0 && args[0] == "serve" {
+		return runServe(args[1:], stderr)
+	}
+	if len(args) > 0 && args[0] == "merge" {
+		return runMerge(args[1:], stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "schema" {
+		return runSchema(args[1:], stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "queries" {
+		return runQueries(args[1:], stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "search" {
+		return runSearch(args[1:], stdin, stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "export" {
+		return runExport(args[1:], stdin, stdout, stderr)
+	}
 
 	fs := flag.NewFlagSet("repoguide", flag.ContinueOnError)
 	fs.SetOutput(stderr)
@@ -48,29 +111,145 @@ func run(args []string, stdout, stderr io.Writer) error {
 	var (
 		maxFiles     int
 		langs        string
+		languageMap  string
 		cachePath    string
+		cacheKey     string
 		maxFileSize  int
+		maxDepth     int
 		showVersion  bool
 		raw          bool
+		headerFile   string
 		withTests    bool
+		noGitignore  bool
+		findRoot     bool
 		withMembers  bool
 		symbolFilter string
+		symbolRegex  string
+		callersOf    string
+		importersOf  string
+		searchQuery  string
+		calleesOf    string
 		fileFilter   string
+		format       string
+		fromStdin    bool
+		archive      string
+		excludes     stringList
+		testGlobs    stringList
+		rankBy       string
+		rankBoost    string
+		recencyBlend float64
+		hotspotBlend float64
+		hotspotDays  int
+		prAlpha      float64
+		prIterations int
+		cyclesOnly   bool
+		collapseDirs bool
+		depth        int
+		maxTokens    int
+		showStats    bool
+		verbose      bool
+		timeout      time.Duration
+		profile      bool
+		minRank      float64
+		perDir       int
+		noCalls      bool
+		externals    bool
+		outputPath   string
+		outputDir    string
+		since        string
+		neighbors    bool
+		strictToon   bool
+		groupSymbols bool
+		groupByLang  bool
+		sortSymbols  string
+		fileMetrics  bool
+		withOwners   bool
+		dedupeSites  bool
+		withDocs     bool
+		withIDs      bool
+		unused       bool
+		pruneFrom    string
+		onlyExported bool
+		countOnly    bool
+		includeRefs  bool
+		entrypoints  bool
+		metrics      bool
+		diagnostics  bool
+		watchMode    bool
 	)
 
 	fs.IntVar(&maxFiles, "n", 0, "maximum number of files to include")
 	fs.IntVar(&maxFiles, "max-files", 0, "maximum number of files to include")
+	fs.IntVar(&maxTokens, "max-tokens", 0, "include top-ranked files until the estimated output reaches `N` tokens")
+	fs.Float64Var(&minRank, "min-rank", 0, "drop files with PageRank below `threshold` (they also disappear as dependency targets)")
+	fs.IntVar(&perDir, "per-dir", 0, "keep only the top `N` files of each directory, so small subtrees are not crowded out by one central package (applied before -n and --max-tokens)")
 	fs.StringVar(&langs, "l", "", "comma-separated languages to include")
 	fs.StringVar(&langs, "langs", "", "comma-separated languages to include")
-	fs.StringVar(&cachePath, "cache", "", "cache output to `file` (add to .gitignore if used)")
+	fs.StringVar(&languageMap, "language-map", "", "comma-separated `ext=language` pairs mapping extra file extensions to languages (e.g. .pyi=python)")
+	fs.StringVar(&outputPath, "o", "", "write the map to `file` instead of stdout")
+	fs.StringVar(&outputPath, "output", "", "write the map to `file` instead of stdout")
+	fs.StringVar(&outputDir, "output-dir", "", "split the TOON map into one file per section in `dir`, with an index.toon listing them")
+	fs.StringVar(&cachePath, "cache", "", "cache parse results and output in `file`; only changed files are re-parsed (add to .gitignore if used)")
+	fs.StringVar(&cacheKey, "cache-key", "mtime", "decide whether a cached file changed by `key`: mtime (modification time and size) or content (hash of the contents; survives fresh CI checkouts)")
 	fs.IntVar(&maxFileSize, "max-file-size", defaultMaxFileSize, "skip files larger than `bytes`")
+	fs.IntVar(&maxDepth, "max-depth", 0, "only map files at most `N` path components below the root (1 = top-level files only; 0 = no limit)")
 	fs.BoolVar(&showVersion, "V", false, "show version and exit")
 	fs.BoolVar(&showVersion, "version", false, "show version and exit")
 	fs.BoolVar(&raw, "raw", false, "output raw TOON without agent context header")
+	fs.BoolVar(&raw, "no-header", false, "alias for --raw")
+	fs.StringVar(&headerFile, "header-file", "", "replace the agent context header with the contents of `path`")
 	fs.BoolVar(&withTests, "with-tests", false, "include test files in output (excluded by default)")
+	fs.BoolVar(&findRoot, "find-root", false, "map the whole project: walk up from path to the nearest directory with .git, go.mod, package.json, or a repoguide config file")
+	fs.BoolVar(&noGitignore, "no-gitignore", false, "don't consult git or .gitignore: map gitignored files too (may include generated code and build artifacts)")
 	fs.BoolVar(&withMembers, "members", false, "include member fields/methods for matched class symbols (use with --symbol)")
-	fs.StringVar(&symbolFilter, "symbol", "", "filter output to symbols matching this `substring` (case-insensitive)")
-	fs.StringVar(&fileFilter, "file", "", "filter output to files matching this `substring` (case-insensitive)")
+	fs.StringVar(&symbolFilter, "symbol", "", "filter output to symbols matching this `substring` or glob (case-insensitive; comma-separated names match any)")
+	fs.StringVar(&importersOf, "importers-of", "", "show only the files importing from files whose path contains this `substring` or matches this glob, with the symbols each imports")
+	fs.StringVar(&callersOf, "callers-of", "", "show only the calls to symbols matching this `substring` and where they are made")
+	fs.StringVar(&calleesOf, "callees-of", "", "show only the calls made by symbols matching this `substring`")
+	fs.StringVar(&searchQuery, "search", "", "list the definitions best matching the words of `query` in their names, doc comments, signatures, and paths, scored with file rank (-n caps the results; default 20)")
+	fs.StringVar(&symbolRegex, "symbol-regex", "", "filter output to symbols matching this `regex` (like --symbol, but anchorable)")
+	fs.IntVar(&depth, "depth", 1, "call-graph hops to expand around --symbol matches (0 = matched symbols only)")
+	fs.StringVar(&fileFilter, "file", "", "filter output to files matching this `substring`, or this glob against the relative path (case-insensitive)")
+	fs.StringVar(&since, "since", "", "map only files changed between git `ref` and HEAD (git diff ref...HEAD)")
+	fs.BoolVar(&neighbors, "neighbors", false, "with --since, also include files that import or are imported by a changed file")
+	fs.BoolVar(&fromStdin, "stdin", false, "read newline-separated file paths (relative to path) from stdin instead of walking the tree")
+	fs.StringVar(&archive, "archive", "", "map the source files inside a .tar, .tar.gz, .tgz, or .zip `file` instead of a directory")
+	fs.Var(&testGlobs, "test-glob", "also treat paths matching `glob` as test files (repeatable)")
+	fs.Var(&excludes, "exclude", "exclude paths matching `glob` (repeatable; ** matches any number of directories)")
+	fs.StringVar(&rankBy, "rank-by", "imports", "weight PageRank edges by `mode`: imports (symbols referenced) or calls (call-site count)")
+	fs.BoolVar(&cyclesOnly, "cycles-only", false, "print only the import cycles table; exit non-zero if any cycles exist")
+	fs.BoolVar(&collapseDirs, "collapse-dirs", false, "summarize by directory: a dirs table of summed file ranks and the dependencies between directories (-n caps the directories; TOON or JSON)")
+	fs.BoolVar(&noCalls, "no-calls", false, "omit the calls and callsites tables")
+	fs.StringVar(&rankBoost, "rank-boost", "", "blend PageRank with another signal: recency (last git commit per file) or hotspots (distinct recent git authors per file)")
+	fs.Float64Var(&recencyBlend, "recency-weight", 0.3, "share of the final rank given to recency with --rank-boost recency, from 0 to 1")
+	fs.Float64Var(&hotspotBlend, "hotspot-weight", 0.3, "share of the final rank given to author counts with --rank-boost hotspots, from 0 to 1")
+	fs.IntVar(&hotspotDays, "hotspot-days", 90, "count authors of commits from the last `N` days with --rank-boost hotspots")
+	fs.Float64Var(&prAlpha, "pagerank-alpha", graph.DefaultDamping, "PageRank damping factor, between 0 and 1 exclusive: lower values pull ranks toward uniform")
+	fs.IntVar(&prIterations, "pagerank-iterations", graph.DefaultIterations, "maximum PageRank iterations (`N`); fewer is faster on huge graphs but less converged")
+	fs.BoolVar(&dedupeSites, "dedupe-callsites", false, "collapse callsites with the same caller, callee, and file into one row listing every line")
+	fs.BoolVar(&externals, "externals", false, "add a table of referenced symbols with no definition in the repo, by frequency")
+	fs.BoolVar(&strictToon, "strict-toon", false, "always quote path and name columns in TOON output, even when they look numeric")
+	fs.BoolVar(&groupSymbols, "group-symbols", false, "nest TOON symbols under their file instead of repeating the path on every row")
+	fs.StringVar(&sortSymbols, "sort-symbols", "rank", "order the TOON symbols table by `order`: rank (file rank, then position in the file) or name (alphabetical across all files)")
+	fs.BoolVar(&groupByLang, "group-by-language", false, "split the TOON files, symbols, and dependencies tables into one section per language")
+	fs.BoolVar(&unused, "unused", false, "add an unused table of exported definitions that no other file references (approximate: dead-code candidates)")
+	fs.StringVar(&pruneFrom, "prune-unreachable-from", "", "keep only the functions and methods reachable through the call graph from the comma-separated entry `names` (or \"auto\" for the detected entrypoints), listing the rest in an unreachable table (static: calls through dynamic dispatch or reflection are missed)")
+	fs.BoolVar(&withIDs, "with-ids", false, "add a stable id column to the symbols table and refer to symbols by id in the calls, callsites, and dependencies tables")
+	fs.BoolVar(&withDocs, "with-docs", false, "add a doc column with the first sentence of each symbol's docstring or doc comment (Python, Go)")
+	fs.BoolVar(&onlyExported, "only-exported", false, "drop private definitions from the symbols table: lower-case Go names, _-prefixed Python names, private/protected Ruby methods, and JS/TS definitions that are not exported")
+	fs.BoolVar(&fileMetrics, "file-metrics", false, "add symbols (definition count) and calls (outbound call edges) columns to the TOON files table")
+	fs.BoolVar(&withOwners, "with-owners", false, "add an owners column to the files table with each file's owners from the repository's CODEOWNERS file (TOON or JSON)")
+	fs.BoolVar(&entrypoints, "entrypoints", false, "add a table of likely entrypoints: main functions, HTTP route handlers, CLI commands, and script main blocks")
+	fs.BoolVar(&metrics, "metrics", false, "add a table of each file's in-degree (files importing it), out-degree (files it imports), and rank")
+	fs.BoolVar(&diagnostics, "diagnostics", false, "add a table of files parsed with syntax errors, whose symbols may be incomplete")
+	fs.BoolVar(&includeRefs, "include-refs", false, "add a table of the names each file references (calls and imports), one row per file and name")
+	fs.BoolVar(&watchMode, "watch", false, "rebuild the map whenever source files change (requires -o or --output-dir)")
+	fs.BoolVar(&countOnly, "count-only", false, "print the number of files that would be parsed, by language, and exit without parsing")
+	fs.BoolVar(&showStats, "stats", false, "print a summary of file, symbol, and edge counts to stderr")
+	fs.DurationVar(&timeout, "timeout", 0, "stop parsing after `duration` (e.g. 30s) and write the map of the files parsed so far, with a warning (0: no limit)")
+	fs.BoolVar(&verbose, "verbose", false, "log why each directory and file was skipped or kept, and each file's parse result, to stderr")
+	fs.BoolVar(&profile, "profile", false, "print how long each phase (discover, parse, graph, rank, call graph, encode) took to stderr")
+	fs.StringVar(&format, "format", "toon", "output `format`: toon, compact (lossy, smallest), json, jsonl (one line per file, streamed, unranked), yaml, mermaid, tree, markdown, symbols-json, ctags, sqlite (needs -o), or protobuf (binary; non-TOON formats omit the agent context header and bypass the cache; markdown shows the top 30 files unless -n or --max-tokens is given; symbols-json and ctags index the definitions of every parsed file)")
 
 	fs.Usage = func() {
 		_, _ = fmt.Fprintf(stderr, `Usage: repoguide [flags] [path]
@@ -85,20 +264,72 @@ path defaults to the current directory.
 Subcommands:
   init    write a repoguide usage section to a CLAUDE.md file
           run "repoguide init --help" for details
+  serve   serve the map and symbol/file queries over HTTP, rebuilt on change
+          run "repoguide serve --help" for details
+  merge   merge the JSON maps of repository shards into one re-ranked map
+          run "repoguide merge --help" for details
+  schema  print the JSON Schema of the --format json output
+  queries print the tree-sitter queries used to extract symbols
+          run "repoguide queries --help" for details
+  search  list the definitions best matching a plain-words query
+          run "repoguide search --help" for details
+  export  write the map to a SQLite database for ad-hoc SQL queries
+          run "repoguide export --help" for details
 
 Examples:
   repoguide                                  current directory, all languages
   repoguide /path/to/repo                    explicit path
+  repoguide --find-root                      whole project, from any subdirectory
   repoguide -l go,typescript                 filter by language
+  repoguide --count-only                     how many files would be parsed, by language
   repoguide -n 20                            top 20 files (large repos)
+  repoguide --max-tokens 8000                top files that fit an ~8k-token budget
+  repoguide --min-rank 0.001                 drop the low-rank long tail
+  repoguide --per-dir 2 -n 40                top 2 files of each directory, 40 at most
   repoguide --cache .repoguide-cache         cache output for faster re-runs
+  repoguide --cache c.json --cache-key content
+                                             cache keyed on file contents (CI checkouts)
+  repoguide -o .repoguide/map.toon           write the map to a file
+  repoguide --output-dir .repoguide/map      one file per section, with an index
+  repoguide --watch -o .repoguide/map.toon   keep the map file up to date while you edit
   repoguide init                             add repoguide section to ./CLAUDE.md
+  repoguide serve --addr :8080               HTTP server for editor and agent tooling
+  repoguide merge map.toon a.json b.json     combine sharded JSON maps into one
 
   repoguide --with-tests                     include test files (excluded by default)
   repoguide --symbol BuildGraph              show BuildGraph and its callers/callees
   repoguide --symbol encode                  case-insensitive: matches Encode, encodeValue
+  repoguide --symbol BuildGraph --depth 2    callers of callers, callees of callees
+  repoguide --symbol BuildGraph,Rank         union of several symbols' neighborhoods
+  repoguide --symbol-regex '^Handle.*Req$'   regex match (anchors, alternation)
+  repoguide --callers-of BuildGraph          who calls BuildGraph, with call lines
+  repoguide --importers-of internal/model    who imports from model, and which symbols
+  repoguide search "parse config file"       definitions matching words, best first
   repoguide --file internal/toon             symbols and deps for the toon package
+  repoguide --since main --neighbors         files changed on this branch and their deps
   repoguide --symbol Encode --file toon      combined: symbol AND file filter
+  repoguide --format json                    JSON output for programmatic use
+  repoguide --format yaml                    YAML output for yq and YAML tooling
+  repoguide --format mermaid --symbol Foo    Mermaid call graph around Foo
+  repoguide --format tree -n 30              ranked file tree for orientation
+  repoguide --format markdown -n 10          overview to paste into a PR or wiki
+  repoguide --format symbols-json            definition index for go-to-definition
+  repoguide --format jsonl                   stream one JSON object per file
+  repoguide --format ctags -o tags           tags file for editor jump-to-definition
+  repoguide export --sqlite map.db           SQLite database for ad-hoc SQL queries
+  repoguide --format protobuf -o map.pb      compact binary map for RPC pipelines
+  repoguide --exclude 'internal/pb/**'       skip generated code
+  repoguide --max-depth 3                    don't walk into deeply nested directories
+  repoguide --archive dep-1.2.tar.gz         map a source tarball without extracting it
+  repoguide --cycles-only                    CI gate: fail on import cycles
+  repoguide --prune-unreachable-from main    only code reachable from main
+  git diff --name-only main | repoguide --stdin
+                                             map only the listed files
+
+Exit status:
+  0  the map was written
+  1  error (bad path or flag, unreadable input, import cycles with --cycles-only)
+  2  no parseable files found
 
 Flags:
 `)
@@ -114,22 +345,302 @@ Flags:
 		return nil
 	}
 
+	switch format {
+	case "toon", "compact", "json", "jsonl", "yaml", "mermaid", "tree", "markdown", "symbols-json", "ctags", "sqlite", "protobuf":
+	default:
+		return fmt.Errorf("unsupported format %q (want toon, compact, json, jsonl, yaml, mermaid, tree, markdown, symbols-json, ctags, sqlite, or protobuf)", format)
+	}
+
+	if format == "sqlite" {
+		switch {
+		case outputPath == "":
+			return fmt.Errorf("--format sqlite requires -o/--output (a database cannot be written to stdout)")
+		case collapseDirs || cyclesOnly || searchQuery != "" || outputDir != "":
+			return fmt.Errorf("--format sqlite cannot be combined with --collapse-dirs, --cycles-only, --search, or --output-dir")
+		}
+	}
+
+	if collapseDirs {
+		switch {
+		case format != "toon" && format != "json":
+			return fmt.Errorf("--collapse-dirs supports only --format toon or json")
+		case maxTokens > 0:
+			return fmt.Errorf("--collapse-dirs cannot be combined with --max-tokens")
+		case minRank > 0:
+			return fmt.Errorf("--collapse-dirs cannot be combined with --min-rank")
+		case perDir > 0:
+			return fmt.Errorf("--collapse-dirs cannot be combined with --per-dir")
+		}
+	}
+
+	if perDir < 0 {
+		return fmt.Errorf("--per-dir must be >= 0")
+	}
+
+	if depth < 0 {
+		return fmt.Errorf("--depth must be >= 0")
+	}
+	switch {
+	case sortSymbols != "rank" && sortSymbols != "name":
+		return fmt.Errorf("unsupported --sort-symbols %q (want rank or name)", sortSymbols)
+	case sortSymbols == "name" && groupSymbols:
+		return fmt.Errorf("--sort-symbols name cannot be combined with --group-symbols")
+	}
+	if cacheKey != "mtime" && cacheKey != "content" {
+		return fmt.Errorf("unsupported --cache-key %q (want mtime or content)", cacheKey)
+	}
+	if maxDepth < 0 {
+		return fmt.Errorf("--max-depth must be >= 0")
+	}
+
+	queries := 0
+	for _, q := range []string{symbolFilter, symbolRegex, callersOf, calleesOf, importersOf, searchQuery} {
+		if q != "" {
+			queries++
+		}
+	}
+	if queries > 1 {
+		return fmt.Errorf("--symbol, --symbol-regex, --callers-of, --callees-of, --importers-of, and --search are mutually exclusive")
+	}
+	if searchQuery != "" {
+		switch {
+		case format != "toon" && format != "json":
+			return fmt.Errorf("--search supports only --format toon or json")
+		case maxTokens > 0 || minRank > 0 || perDir > 0:
+			return fmt.Errorf("--search cannot be combined with --max-tokens, --min-rank, or --per-dir (use -n to cap the results)")
+		case collapseDirs || cyclesOnly || outputDir != "":
+			return fmt.Errorf("--search cannot be combined with --collapse-dirs, --cycles-only, or --output-dir")
+		}
+	}
+
+	var symbolNames []string
+	if symbolFilter != "" {
+		for _, name := range strings.Split(symbolFilter, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				symbolNames = append(symbolNames, name)
+			}
+		}
+		if len(symbolNames) == 0 {
+			return fmt.Errorf("--symbol needs at least one name")
+		}
+	}
+	var entryNames []string
+	if pruneFrom != "" {
+		for _, name := range strings.Split(pruneFrom, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				entryNames = append(entryNames, name)
+			}
+		}
+		switch {
+		case len(entryNames) == 0:
+			return fmt.Errorf("--prune-unreachable-from needs at least one name, or auto")
+		case noCalls:
+			return fmt.Errorf("--prune-unreachable-from needs the call graph and cannot be combined with --no-calls")
+		case searchQuery != "" || collapseDirs:
+			return fmt.Errorf("--prune-unreachable-from cannot be combined with --search or --collapse-dirs")
+		}
+	}
+	for _, p := range append(slices.Clone(symbolNames), fileFilter, importersOf) {
+		if discover.IsGlob(p) {
+			if err := discover.ValidateGlob(p); err != nil {
+				return err
+			}
+		}
+	}
+
+	var symbolRe *regexp.Regexp
+	if symbolRegex != "" {
+		var err error
+		if symbolRe, err = regexp.Compile(symbolRegex); err != nil {
+			return fmt.Errorf("invalid --symbol-regex: %w", err)
+		}
+	}
+
+	if neighbors && since == "" {
+		return fmt.Errorf("--neighbors requires --since")
+	}
+
+	if rankBy != "imports" && rankBy != "calls" {
+		return fmt.Errorf("unsupported --rank-by %q (want imports or calls)", rankBy)
+	}
+	if rankBoost != "" && rankBoost != "recency" && rankBoost != "hotspots" {
+		return fmt.Errorf("unsupported --rank-boost %q (want recency or hotspots)", rankBoost)
+	}
+	if recencyBlend < 0 || recencyBlend > 1 {
+		return fmt.Errorf("--recency-weight must be between 0 and 1")
+	}
+	if hotspotBlend < 0 || hotspotBlend > 1 {
+		return fmt.Errorf("--hotspot-weight must be between 0 and 1")
+	}
+	if hotspotDays < 1 {
+		return fmt.Errorf("--hotspot-days must be >= 1")
+	}
+	if timeout < 0 {
+		return fmt.Errorf("--timeout must not be negative")
+	}
+	if prAlpha <= 0 || prAlpha >= 1 {
+		return fmt.Errorf("--pagerank-alpha must be between 0 and 1 exclusive")
+	}
+	if prIterations < 1 {
+		return fmt.Errorf("--pagerank-iterations must be >= 1")
+	}
+
+	if languageMap != "" {
+		if err := applyLanguageMap(languageMap); err != nil {
+			return err
+		}
+	}
+
+	if archive != "" {
+		switch {
+		case fs.NArg() > 0:
+			return fmt.Errorf("--archive cannot be combined with a path argument")
+		case fromStdin:
+			return fmt.Errorf("--archive cannot be combined with --stdin")
+		case since != "":
+			return fmt.Errorf("--archive cannot be combined with --since")
+		case watchMode:
+			return fmt.Errorf("--archive cannot be combined with --watch")
+		case cachePath != "":
+			return fmt.Errorf("--archive cannot be combined with --cache")
+		case rankBoost != "":
+			return fmt.Errorf("--archive cannot be combined with --rank-boost")
+		case findRoot:
+			return fmt.Errorf("--archive cannot be combined with --find-root")
+		case withOwners:
+			return fmt.Errorf("--archive cannot be combined with --with-owners")
+		}
+	}
+	if findRoot && fromStdin {
+		return fmt.Errorf("--find-root cannot be combined with --stdin")
+	}
+
+	if outputDir != "" {
+		switch {
+		case format != "toon":
+			return fmt.Errorf("--output-dir supports only --format toon")
+		case outputPath != "":
+			return fmt.Errorf("--output-dir cannot be combined with -o/--output")
+		case collapseDirs:
+			return fmt.Errorf("--output-dir cannot be combined with --collapse-dirs")
+		case cyclesOnly:
+			return fmt.Errorf("--output-dir cannot be combined with --cycles-only")
+		}
+	}
+
+	if watchMode {
+		if outputPath == "" && outputDir == "" {
+			return fmt.Errorf("--watch requires -o/--output or --output-dir")
+		}
+		if fromStdin {
+			return fmt.Errorf("--watch cannot be combined with --stdin")
+		}
+	}
+
+	// --output collects everything that would go to stdout and writes it to
+	// the file once the run finishes, so a failed run never truncates it.
+	if outputPath != "" {
+		var buf bytes.Buffer
+		stdout = &buf
+		defer func() {
+			if buf.Len() == 0 {
+				return
+			}
+			if werr := writeFile(outputPath, buf.Bytes()); werr != nil && err == nil {
+				err = fmt.Errorf("writing output: %w", werr)
+			}
+		}()
+	}
+
+	var prof *runProfile
+	if profile {
+		prof = newRunProfile()
+		defer prof.write(stderr)
+	}
+
 	root := "."
 	if fs.NArg() > 0 {
 		root = fs.Arg(0)
 	}
 
-	root, err := filepath.Abs(root)
+	root, err = filepath.Abs(root)
 	if err != nil {
 		return fmt.Errorf("resolving root: %w", err)
 	}
+	repoName := filepath.Base(root)
 
-	info, err := os.Stat(root)
-	if err != nil {
-		return fmt.Errorf("root path: %w", err)
+	if archive != "" {
+		if !discover.IsArchive(archive) {
+			return fmt.Errorf("%s: unsupported archive type (want .tar, .tar.gz, .tgz, or .zip)", archive)
+		}
+		repoName = discover.ArchiveName(archive)
+	} else {
+		info, err := os.Stat(root)
+		if err != nil {
+			return fmt.Errorf("root path: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s: not a directory", root)
+		}
+		if findRoot {
+			if found := findRepoRoot(root); found != "" {
+				root = found
+				repoName = filepath.Base(root)
+			}
+			_, _ = fmt.Fprintf(stderr, "Using repo root %s\n", root)
+		}
+	}
+
+	if watchMode {
+		return runWatch(root, withoutWatchFlag(args), cachePath, stderr)
+	}
+
+	// --timeout bounds the rest of the run. Parsing is what takes time on a
+	// large repo, so when the deadline passes, in-flight parses are canceled
+	// and the map is built from the files parsed so far.
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
-	if !info.IsDir() {
-		return fmt.Errorf("%s: not a directory", root)
+
+	// Defaults from .repoguide.toml/.yaml apply only to settings that were
+	// not given explicitly on the command line. An archive has no config.
+	cfg := &config.Config{}
+	if archive == "" {
+		if cfg, err = config.Load(root); err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	if cfg.Languages != nil && !explicit["l"] && !explicit["langs"] {
+		langs = strings.Join(cfg.Languages, ",")
+	}
+	if cfg.Exclude != nil && !explicit["exclude"] {
+		excludes = cfg.Exclude
+	}
+	if cfg.MaxFiles != nil && !explicit["n"] && !explicit["max-files"] {
+		maxFiles = *cfg.MaxFiles
+	}
+	if format == "markdown" && maxFiles == 0 && maxTokens == 0 && !explicit["n"] && !explicit["max-files"] {
+		maxFiles = defaultMarkdownFiles
+	}
+	if searchQuery != "" && !explicit["n"] && !explicit["max-files"] {
+		maxFiles = defaultSearchResults
+	}
+	if cfg.MaxFileSize != nil && !explicit["max-file-size"] {
+		maxFileSize = *cfg.MaxFileSize
+	}
+	if cfg.WithTests != nil && !explicit["with-tests"] {
+		withTests = *cfg.WithTests
+	}
+
+	for _, p := range slices.Concat(excludes, testGlobs) {
+		if err := discover.ValidateGlob(p); err != nil {
+			return err
+		}
 	}
 
 	var langFilter []string
@@ -143,126 +654,792 @@ Flags:
 		}
 	}
 
-	// Discover files
-	files, err := discover.Files(root, langFilter)
+	var customHeader string
+	if headerFile != "" && !raw {
+		if customHeader, err = readHeader(headerFile); err != nil {
+			return err
+		}
+	}
+
+	// --verbose logs each discovery, filter, and parse decision to stderr.
+	var vlog io.Writer
+	if verbose {
+		vlog = stderr
+	}
+
+	// Discover files. Archive entries are read up front and parsed from
+	// memory.
+	phaseStart := time.Now()
+	var files []discover.FileEntry
+	sizeOf, read := fileSize(root), readFile(root)
+	if archive != "" {
+		var entries []discover.ArchiveFile
+		entries, err = discover.Archive(archive, langFilter, maxFileSize)
+		archived := make(map[string]discover.ArchiveFile, len(entries))
+		for _, e := range entries {
+			files = append(files, e.FileEntry)
+			archived[e.Path] = e
+		}
+		sizeOf = func(path string) (int64, error) { return archived[path].Size, nil }
+		read = func(path string) ([]byte, error) { return archived[path].Source, nil }
+	} else if fromStdin {
+		files, err = discover.FromList(root, stdin, langFilter, stderr)
+	} else {
+		files, err = discover.Files(root, langFilter, maxDepth, noGitignore, vlog)
+	}
 	if err != nil {
 		return fmt.Errorf("discovering files: %w", err)
 	}
 	if len(files) == 0 {
-		return fmt.Errorf("no parseable files found")
+		return errNoFiles
+	}
+
+	// Drop --exclude matches before parsing so they never become dependency
+	// targets. This applies to every file, so test files included with
+	// --with-tests are still subject to it.
+	kept := discover.Exclude(files, excludes)
+	logDropped(vlog, files, kept, "matches --exclude")
+	files = kept
+	if len(files) == 0 {
+		return fmt.Errorf("%w (all files matched --exclude)", errNoFiles)
 	}
+	stats := runStats{discovered: len(files)}
 
 	// Exclude test files unless --with-tests is set.
 	if !withTests {
 		n := 0
 		for _, f := range files {
-			if !discover.IsTestFile(f.Path) {
+			if !discover.IsTestFileWith(f.Path, testGlobs) {
 				files[n] = f
 				n++
+			} else if vlog != nil {
+				_, _ = fmt.Fprintf(vlog, "filter: skip %s (test file; use --with-tests)\n", f.Path)
 			}
 		}
+		stats.skippedTests = len(files) - n
 		files = files[:n]
 	}
 	if len(files) == 0 {
-		return fmt.Errorf("no parseable files found (all files are test files; use --with-tests to include them)")
+		return fmt.Errorf("%w (all files are test files; use --with-tests to include them)", errNoFiles)
 	}
+	prof.record("discover", phaseStart, "%d files", len(files))
 
-	// Check cache freshness (skip when filter flags are active).
-	// --with-tests bypasses the cache so it never overwrites the default
-	// (test-excluded) cache with test-included output.
-	focused := symbolFilter != "" || fileFilter != ""
-	filterActive := focused || withTests
-	if !filterActive && cachePath != "" && cacheIsFresh(cachePath, root, files) {
-		data, err := os.ReadFile(cachePath)
-		if err == nil {
-			writeOutput(stdout, strings.TrimRight(string(data), "\n"), raw, withTests, focused)
-			return nil
+	// --since keeps only files changed on the branch. With --neighbors every
+	// file is still parsed so that the changed files' dependencies can be
+	// found; the map is narrowed once the graph is built.
+	var changed map[string]struct{}
+	if since != "" {
+		if changed, err = discover.ChangedSince(root, since); err != nil {
+			return err
+		}
+		kept := slices.DeleteFunc(slices.Clone(files), func(f discover.FileEntry) bool {
+			_, ok := changed[f.Path]
+			return !ok
+		})
+		if len(kept) == 0 {
+			return fmt.Errorf("%w (no files changed since %s)", errNoFiles, since)
+		}
+		if !neighbors {
+			logDropped(vlog, files, kept, "not changed since "+since)
+			files = kept
 		}
 	}
 
+	if countOnly {
+		writeFileCounts(stdout, files)
+		return nil
+	}
+
+	// Per-file parse results are reused from the cache in every mode, since
+	// a file's tags don't depend on any flag. The cached map itself and all
+	// cache writes are limited to full, unfiltered runs: --with-tests bypasses
+	// them so it never overwrites the default (test-excluded) cache with
+	// test-included output. The cached map is TOON, so other formats and
+	// --cycles-only bypass it too, as does a --stdin file list (a partial map
+	// must not stand in for the full one), and so do --no-calls,
+	// --externals, --include-refs, --entrypoints, --metrics, --diagnostics,
+	// --unused, --strict-toon, --group-symbols, --group-by-language,
+	// --sort-symbols, --file-metrics, --with-owners, --with-docs, --with-ids,
+	// --only-exported, --collapse-dirs, --per-dir, --min-rank, --max-tokens,
+	// --prune-unreachable-from, --rank-boost, --rank-by calls, and non-default
+	// --pagerank-alpha or --pagerank-iterations, which change its contents.
+	// --output-dir writes sections rather than the map, so it has nothing to
+	// replay either.
+	focused := queries > 0 || fileFilter != "" || since != ""
+	filterActive := focused || withTests
+	useCache := cachePath != "" && !filterActive && format == "toon" && !fromStdin && !cyclesOnly && !noCalls && !externals && !includeRefs && !entrypoints && !metrics && !diagnostics && !unused && !strictToon && !groupSymbols && !groupByLang && sortSymbols == "rank" && !fileMetrics && !withOwners && !withDocs && !withIDs && !onlyExported && !collapseDirs && perDir == 0 && minRank == 0 && maxTokens == 0 && pruneFrom == "" && outputDir == "" && rankBoost == "" && rankBy == "imports" &&
+		prAlpha == graph.DefaultDamping && prIterations == graph.DefaultIterations
+
+	// --raw writes no header; --header-file replaces the default one. The
+	// cached map never includes a header, so neither affects the cache.
+	var hdr string
+	switch {
+	case raw:
+	case headerFile != "":
+		hdr = customHeader
+	default:
+		hdr = header(withTests, focused)
+	}
+
+	var (
+		prevCache *cache.Cache
+		stamps    map[string]cache.Stamp
+	)
+	if cachePath != "" {
+		prevCache = cache.Load(cachePath, cacheVersion())
+		stamps = stampFiles(root, files, cacheKey == "content")
+	}
+	// --stats needs a real parse to count, so it skips the cached map.
+	if useCache && !showStats && prevCache.OutputFresh(stamps) {
+		writeOutput(stdout, prevCache.Output, hdr)
+		return nil
+	}
+
 	// Filter by size
-	files = filterBySize(root, files, maxFileSize, stderr)
+	phaseStart = time.Now()
+	sized := filterBySize(files, maxFileSize, sizeOf, stderr)
+	stats.skippedSize = len(files) - len(sized)
+	files = sized
 	if len(files) == 0 {
-		return fmt.Errorf("no parseable files found (all exceeded size limit)")
+		return fmt.Errorf("%w (all exceeded size limit)", errNoFiles)
 	}
 
-	// Parse files concurrently
-	fileInfos := parseFilesConcurrent(root, files, stderr)
+	// JSON Lines streams each file as soon as it is parsed, so that output
+	// for a huge repository starts at once and is never held in memory
+	// whole. Without the full set of files there is no graph or ranking.
+	if format == "jsonl" {
+		var emitted int
+		var encErr error
+		emit := func(fi model.FileInfo) {
+			if encErr != nil {
+				return
+			}
+			line, err := jsonout.EncodeFileLine(&fi)
+			if err != nil {
+				encErr = fmt.Errorf("encoding %s: %w", fi.Path, err)
+				return
+			}
+			if _, err := fmt.Fprintln(stdout, line); err != nil {
+				encErr = err
+				return
+			}
+			emitted++
+		}
+		if archive != "" {
+			parseFilesStream(ctx, read, files, stderr, emit)
+		} else {
+			streamFilesCached(ctx, root, files, prevCache, stamps, stderr, emit)
+		}
+		if encErr != nil {
+			return encErr
+		}
+		if ctx.Err() != nil {
+			warnTimeout(stderr, timeout, emitted, len(files))
+		}
+		if emitted == 0 {
+			return fmt.Errorf("%w (every file failed to parse)", errNoFiles)
+		}
+		return nil
+	}
+
+	// Parse files concurrently, reusing cached results for unchanged files
+	var fileInfos []model.FileInfo
+	if archive != "" {
+		fileInfos = parseFilesConcurrent(ctx, read, files, stderr)
+	} else {
+		fileInfos = parseFilesCached(ctx, root, files, prevCache, stamps, stderr)
+	}
+	if ctx.Err() != nil {
+		warnTimeout(stderr, timeout, len(fileInfos), len(files))
+		// A partial map must never be replayed as the full one.
+		useCache = false
+	}
 	if len(fileInfos) == 0 {
-		return fmt.Errorf("no files could be parsed")
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w (--timeout reached before any file was parsed)", errNoFiles)
+		}
+		return fmt.Errorf("%w (every file failed to parse)", errNoFiles)
+	}
+	prof.record("parse", phaseStart, "%d files, %d symbols", len(fileInfos), countDefinitions(fileInfos))
+	if vlog != nil {
+		logParsed(vlog, fileInfos)
+	}
+	stats.parsed = len(fileInfos)
+	stats.failed = len(files) - len(fileInfos)
+	for i := range fileInfos {
+		if fileInfos[i].SyntaxErrors {
+			stats.syntaxErrors++
+		}
+	}
+
+	// The symbol index and tags file need only the parse results, so they
+	// skip graph building and ranking, and with them file selection and
+	// queries.
+	if format == "symbols-json" || format == "ctags" {
+		phaseStart = time.Now()
+		var output string
+		if format == "ctags" {
+			output = ctags.Encode(fileInfos)
+		} else if output, err = jsonout.EncodeSymbols(fileInfos); err != nil {
+			return fmt.Errorf("encoding symbol index: %w", err)
+		}
+		prof.record("encode", phaseStart, "%d bytes", len(output))
+		writeOutput(stdout, output, "")
+		return nil
 	}
 
 	// Build graph and rank
+	phaseStart = time.Now()
 	deps := graph.BuildGraph(fileInfos)
-	graph.Rank(fileInfos, deps)
-	callEdges := graph.BuildCallGraph(fileInfos)
+	if neighbors {
+		keep := graph.Neighbors(deps, changed)
+		fileInfos = slices.DeleteFunc(fileInfos, func(fi model.FileInfo) bool {
+			_, ok := keep[fi.Path]
+			return !ok
+		})
+		deps = graph.BuildGraph(fileInfos)
+	}
+	prof.record("graph", phaseStart, "%d dependency edges", len(deps))
+
+	if cyclesOnly {
+		cycles := graph.FindCycles(deps)
+		_, _ = fmt.Fprintln(stdout, toon.EncodeCycles(cycles, toon.Options{Strict: strictToon}))
+		if len(cycles) > 0 {
+			return fmt.Errorf("%d import cycle(s) found", len(cycles))
+		}
+		return nil
+	}
+
+	phaseStart = time.Now()
+	prOpts := graph.PageRankOptions{Alpha: prAlpha, Iterations: prIterations}
+	if rankBy == "calls" {
+		graph.RankWeighted(fileInfos, graph.CallWeights(fileInfos, graph.BuildCallSites(fileInfos)), prOpts)
+	} else {
+		graph.Rank(fileInfos, deps, prOpts)
+	}
+	if rankBoost == "recency" {
+		if times, err := discover.LastModified(root); err != nil {
+			_, _ = fmt.Fprintf(stderr, "Warning: --rank-boost recency: %v; ranking by PageRank only\n", err)
+		} else {
+			graph.BoostRecency(fileInfos, times, recencyBlend)
+		}
+	}
+	if rankBoost == "hotspots" {
+		// Outside git there are no authors to count, so ranking is unchanged.
+		if authors, err := discover.RecentAuthors(root, hotspotDays); err == nil {
+			graph.BoostHotspots(fileInfos, authors, hotspotBlend)
+		}
+	}
+	prof.record("rank", phaseStart, "%d files", len(fileInfos))
+	var callEdges []model.CallEdge
+	if !noCalls {
+		phaseStart = time.Now()
+		callEdges = graph.BuildCallGraph(fileInfos)
+		prof.record("call graph", phaseStart, "%d call edges", len(callEdges))
+	}
 
 	rm := &model.RepoMap{
-		RepoName:     filepath.Base(root),
-		Root:         filepath.Base(root),
+		RepoName:     repoName,
+		Root:         repoName,
 		Files:        fileInfos,
 		Dependencies: deps,
 		CallEdges:    callEdges,
 	}
 
-	// Select top N files
-	if maxFiles > 0 {
+	// A search lists definitions rather than files, so like --collapse-dirs
+	// it replaces the map, and -n caps the results instead of the files.
+	if searchQuery != "" {
+		if fileFilter != "" {
+			rm = ranking.FilterByFile(rm, fileFilter)
+		}
+		hits := ranking.Search(rm.Files, searchQuery, maxFiles)
+		output := toon.EncodeSearch(searchQuery, hits, toon.Options{Strict: strictToon})
+		if format == "json" {
+			if output, err = jsonout.EncodeSearch(searchQuery, hits); err != nil {
+				return fmt.Errorf("encoding JSON: %w", err)
+			}
+		}
+		writeOutput(stdout, output, "")
+		return nil
+	}
+
+	// Reachability is computed over the whole call graph, before selection
+	// and filters drop edges, and prunes the map before they apply.
+	var unreachable []model.Tag
+	if pruneFrom != "" {
+		names := entryNames
+		if slices.Equal(names, []string{"auto"}) {
+			names = nil
+			all := make(map[string]struct{}, len(fileInfos))
+			for i := range fileInfos {
+				all[fileInfos[i].Path] = struct{}{}
+			}
+			for _, e := range graph.FindEntrypoints(fileInfos, all) {
+				names = append(names, e.Name)
+			}
+			if len(names) == 0 {
+				return fmt.Errorf("--prune-unreachable-from auto: no entrypoints detected; name them instead")
+			}
+		}
+		entries := graph.ResolveEntries(fileInfos, names)
+		if len(entries) == 0 {
+			return fmt.Errorf("--prune-unreachable-from: no definition named %s", strings.Join(names, ", "))
+		}
+		rm, unreachable = ranking.PruneUnreachable(rm, graph.Reachable(rm.CallEdges, entries))
+		_, _ = fmt.Fprintf(stderr, "Reachable from %s: %d files (%d functions and methods unreachable)\n", strings.Join(entries, ", "), len(rm.Files), len(unreachable))
+	}
+
+	// Select top N files. The directory view applies -n to directories
+	// instead, after the focused filters.
+	if minRank > 0 {
+		rm = ranking.SelectByMinRank(rm, minRank)
+	}
+	if perDir > 0 {
+		rm = ranking.SelectTopPerDir(rm, perDir)
+	}
+	if maxFiles > 0 && !collapseDirs {
 		rm = ranking.SelectFiles(rm, maxFiles)
 	}
+	if maxTokens > 0 {
+		var used int
+		rm, used = ranking.SelectByTokenBudget(rm, maxTokens)
+		_, _ = fmt.Fprintf(stderr, "Estimated tokens: %d of %d budget (%d files)\n", used, maxTokens, len(rm.Files))
+	}
 
 	// Apply focused query filters; populate per-site call locations for targeted reads.
-	if filterActive {
+	if filterActive && !noCalls {
 		rm.CallSites = graph.BuildCallSites(fileInfos)
 	}
 	if symbolFilter != "" {
-		rm = ranking.FilterBySymbol(rm, symbolFilter, withMembers)
+		rm = ranking.FilterBySymbol(rm, symbolNames, withMembers, depth)
+	}
+	if symbolRe != nil {
+		rm = ranking.FilterBySymbolRegex(rm, symbolRe, withMembers, depth)
+	}
+	if callersOf != "" {
+		rm = ranking.FilterByCallers(rm, callersOf)
+	}
+	if calleesOf != "" {
+		rm = ranking.FilterByCallees(rm, calleesOf)
+	}
+	if importersOf != "" {
+		rm = ranking.FilterByImporters(rm, importersOf)
 	}
 	if fileFilter != "" {
 		rm = ranking.FilterByFile(rm, fileFilter)
 	}
 
-	// Encode to TOON
-	output := toon.Encode(rm, focused)
+	if onlyExported {
+		rm = ranking.FilterExported(rm)
+	}
+
+	if dedupeSites {
+		rm.CallSites = graph.DedupeCallSites(rm.CallSites)
+	}
+
+	// The directory view replaces the file-level tables, so it is encoded on
+	// its own and without the agent context header, like --cycles-only.
+	if collapseDirs {
+		rm = ranking.CollapseDirs(rm, maxFiles)
+		rm.Cycles = graph.FindCycles(rm.Dependencies)
+		output := toon.EncodeDirs(rm, toon.Options{Strict: strictToon})
+		if format == "json" {
+			if output, err = jsonout.EncodeDirs(rm); err != nil {
+				return fmt.Errorf("encoding JSON: %w", err)
+			}
+		}
+		writeOutput(stdout, output, "")
+		return nil
+	}
+
+	// Cycles reflect the dependencies actually shown after selection/filtering.
+	rm.Cycles = graph.FindCycles(rm.Dependencies)
+	if externals {
+		// Resolved against the whole repo, not just the files shown.
+		rm.Externals = graph.FindExternals(fileInfos)
+	}
+	if metrics {
+		// Degrees come from the whole dependency graph, so selection and
+		// filters do not understate how widely a shown file is imported.
+		rm.Metrics = graph.DegreeMetrics(rm.Files, deps)
+	}
+	// Package docs for the shown files are read from every parsed file, since
+	// the one carrying a package's doc comment may not be shown itself.
+	rm.Packages = packageDocs(rm.Files, fileInfos)
+	if diagnostics {
+		// From every parsed file, so a broken file is reported even when
+		// selection or filters leave it out of the map.
+		rm.Diagnostics = syntaxDiagnostics(fileInfos)
+	}
+	if unused {
+		// Like diagnostics, from every parsed file: a definition is in use
+		// if any file references it, shown or not.
+		rm.Unused = graph.FindUnused(fileInfos, func(p string) bool { return discover.IsTestFileWith(p, testGlobs) })
+	}
+	// Like unused definitions, unreachable ones are listed from every file
+	// mapped, whether selection kept the file or not.
+	rm.Unreachable = unreachable
+	if withOwners {
+		// Owners are looked up only for the files shown.
+		co, err := discover.LoadCodeowners(root)
+		switch {
+		case err != nil:
+			return fmt.Errorf("--with-owners: %w", err)
+		case co == nil:
+			_, _ = fmt.Fprintf(stderr, "Warning: --with-owners: no CODEOWNERS file found; the owners column is empty\n")
+		default:
+			files := slices.Clone(rm.Files)
+			for i := range files {
+				files[i].Owners = co.Owners(files[i].Path)
+			}
+			rm.Files = files
+		}
+	}
+	if includeRefs || entrypoints {
+		// Read from the full parse, since focused filters trim tags to
+		// definitions, but only for the files shown.
+		shown := make(map[string]struct{}, len(rm.Files))
+		for i := range rm.Files {
+			shown[rm.Files[i].Path] = struct{}{}
+		}
+		if includeRefs {
+			rm.Refs = graph.FileRefs(fileInfos, shown)
+		}
+		if entrypoints {
+			rm.Entrypoints = graph.FindEntrypoints(fileInfos, shown)
+		}
+	}
+
+	toonOpts := toon.Options{Focused: focused, Strict: strictToon, GroupSymbols: groupSymbols, GroupByLanguage: groupByLang, SymbolsByName: sortSymbols == "name", FileMetrics: fileMetrics, WithOwners: withOwners, WithDocs: withDocs, WithIDs: withIDs}
+	if showStats {
+		stats.write(stderr, rm)
+	}
+
+	if outputDir != "" {
+		return writeSplit(outputDir, rm, toonOpts, hdr, stderr)
+	}
+
+	// The database is written straight to -o rather than through the
+	// buffered stdout, which stays empty and so leaves the file alone.
+	if format == "sqlite" {
+		if rm.CallSites == nil && !noCalls {
+			shown := make(map[string]struct{}, len(rm.Files))
+			for i := range rm.Files {
+				shown[rm.Files[i].Path] = struct{}{}
+			}
+			for _, cs := range graph.BuildCallSites(fileInfos) {
+				if _, ok := shown[cs.File]; ok {
+					rm.CallSites = append(rm.CallSites, cs)
+				}
+			}
+		}
+		phaseStart = time.Now()
+		if err := sqliteout.Write(outputPath, rm); err != nil {
+			return fmt.Errorf("writing SQLite database: %w", err)
+		}
+		prof.record("encode", phaseStart, "%d files", len(rm.Files))
+		_, _ = fmt.Fprintf(stderr, "Wrote %s (%d files)\n", outputPath, len(rm.Files))
+		return nil
+	}
+
+	phaseStart = time.Now()
+	var output string
+	switch format {
+	case "json":
+		if output, err = jsonout.Encode(rm); err != nil {
+			return fmt.Errorf("encoding JSON: %w", err)
+		}
+	case "yaml":
+		output = yamlout.Encode(rm)
+	case "mermaid":
+		output = mermaid.Encode(rm)
+	case "tree":
+		output = treeout.Encode(rm)
+	case "markdown":
+		output = mdreport.Encode(rm)
+	case "compact":
+		output = toon.EncodeCompact(rm)
+	case "protobuf":
+		data, err := pbout.Encode(rm)
+		if err != nil {
+			return fmt.Errorf("encoding protobuf: %w", err)
+		}
+		output = string(data)
+	default:
+		output = toon.Encode(rm, toonOpts)
+	}
+	prof.record("encode", phaseStart, "%d bytes", len(output))
+	if showStats && format == "compact" {
+		writeCompactSavings(stderr, output, toon.Encode(rm, toonOpts))
+	}
+
+	// Serialized protobuf is binary, so it gets no trailing newline either.
+	if format == "protobuf" {
+		_, _ = io.WriteString(stdout, output)
+		return nil
+	}
+
+	// The agent context header describes TOON and would make other formats
+	// invalid, so they are always written raw.
+	if format != "toon" {
+		writeOutput(stdout, output, "")
+		return nil
+	}
 
 	// Write cache (skip when filter flags are active — filtered output must not
 	// overwrite the full-map cache).
-	if cachePath != "" && !filterActive {
-		_ = os.MkdirAll(filepath.Dir(cachePath), 0o755)
-		_ = os.WriteFile(cachePath, []byte(output+"\n"), 0o644)
+	if useCache {
+		_ = newCache(output, fileInfos, stamps).Save(cachePath)
 	}
 
-	writeOutput(stdout, output, raw, withTests, focused)
+	writeOutput(stdout, output, hdr)
 	return nil
 }
 
-func cacheIsFresh(cachePath, root string, files []discover.FileEntry) bool {
-	cacheInfo, err := os.Stat(cachePath)
-	if err != nil {
-		return false
+// rootMarkers are the entries that mark a project root for --find-root, in
+// addition to the repoguide config files.
+var rootMarkers = []string{".git", "go.mod", "package.json"}
+
+// findRepoRoot returns the nearest directory at or above dir (which must be
+// absolute) containing a root marker or a repoguide config file, or "" if
+// there is none up to the filesystem root.
+func findRepoRoot(dir string) string {
+	markers := slices.Concat(rootMarkers, config.FileNames)
+	for {
+		for _, m := range markers {
+			if _, err := os.Stat(filepath.Join(dir, m)); err == nil {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
 	}
-	cacheMtime := cacheInfo.ModTime()
+}
 
+// writeFile writes data to path, creating parent directories as needed and
+// replacing any existing file.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// stampFiles stamps every file, keyed by path: by modification time and size,
+// or by content hash when byContent is set. Files that can't be stat'ed or
+// read are left out and so never match a cache entry.
+func stampFiles(root string, files []discover.FileEntry, byContent bool) map[string]cache.Stamp {
+	stamps := make(map[string]cache.Stamp, len(files))
 	for _, f := range files {
-		fi, err := os.Stat(filepath.Join(root, f.Path))
+		path := filepath.Join(root, f.Path)
+		if byContent {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			stamps[f.Path] = cache.ContentStamp(data)
+			continue
+		}
+		fi, err := os.Stat(path)
 		if err != nil {
-			return false
+			continue
 		}
-		if !fi.ModTime().Before(cacheMtime) {
-			return false
+		stamps[f.Path] = cache.StampOf(fi)
+	}
+	return stamps
+}
+
+// cacheVersion is the cache Version this binary reads and writes: the cache
+// format version together with the release, so that upgrading invalidates
+// every cache an older release wrote.
+func cacheVersion() string {
+	return fmt.Sprintf("%d/%s", cache.FormatVersion, version)
+}
+
+// newCache builds the cache for a full run: the TOON output, the stamps of
+// every input file, and the parse results of every parsed file.
+func newCache(output string, fileInfos []model.FileInfo, stamps map[string]cache.Stamp) *cache.Cache {
+	c := &cache.Cache{
+		Version: cacheVersion(),
+		Output:  output,
+		Inputs:  stamps,
+		Files:   make(map[string]cache.Entry, len(fileInfos)),
+	}
+	for _, fi := range fileInfos {
+		s, ok := stamps[fi.Path]
+		if !ok {
+			continue
 		}
+		c.Files[fi.Path] = cache.Entry{
+			Stamp:        s,
+			Language:     fi.Language,
+			Tags:         fi.Tags,
+			SyntaxErrors: fi.SyntaxErrors,
+			Package:      fi.Package,
+			PackageDoc:   fi.PackageDoc,
+			Imports:      fi.Imports,
+		}
+	}
+	return c
+}
+
+// parseFilesCached returns parse results for files in their original order,
+// taking unchanged files from prev and parsing only the rest. prev may be nil.
+func parseFilesCached(ctx context.Context, root string, files []discover.FileEntry, prev *cache.Cache, stamps map[string]cache.Stamp, stderr io.Writer) []model.FileInfo {
+	var fileInfos []model.FileInfo
+	streamFilesCached(ctx, root, files, prev, stamps, stderr, func(fi model.FileInfo) {
+		fileInfos = append(fileInfos, fi)
+	})
+	return fileInfos
+}
+
+// streamFilesCached is the streaming form of parseFilesCached: each result
+// is passed to emit, in the original order, as soon as it is available.
+// Cached files are emitted as the stale files around them are parsed.
+func streamFilesCached(ctx context.Context, root string, files []discover.FileEntry, prev *cache.Cache, stamps map[string]cache.Stamp, stderr io.Writer, emit func(model.FileInfo)) {
+	cached := make(map[string]cache.Entry)
+	var stale []discover.FileEntry
+	for _, f := range files {
+		if s, ok := stamps[f.Path]; ok {
+			if e, ok := prev.Lookup(f.Path, f.Language, s); ok {
+				cached[f.Path] = e
+				continue
+			}
+		}
+		stale = append(stale, f)
+	}
+
+	// flush emits the cached files before files[end] that were not emitted
+	// yet; stale files among them either were emitted already or failed.
+	next := 0
+	flush := func(end int) {
+		for ; next < end; next++ {
+			f := files[next]
+			if e, ok := cached[f.Path]; ok {
+				emit(model.FileInfo{
+					Path:         f.Path,
+					Language:     f.Language,
+					Tags:         e.Tags,
+					SyntaxErrors: e.SyntaxErrors,
+					Package:      e.Package,
+					PackageDoc:   e.PackageDoc,
+					Imports:      e.Imports,
+				})
+			}
+		}
+	}
+	position := make(map[string]int, len(stale))
+	for i, f := range files {
+		position[f.Path] = i
+	}
+	if len(stale) > 0 {
+		parseFilesStream(ctx, readFile(root), stale, stderr, func(fi model.FileInfo) {
+			i := position[fi.Path]
+			flush(i)
+			emit(fi)
+			next = i + 1
+		})
+	}
+	flush(len(files))
+}
+
+// warnTimeout reports that --timeout cut parsing short, leaving a map of
+// parsed of the total files.
+func warnTimeout(w io.Writer, timeout time.Duration, parsed, total int) {
+	_, _ = fmt.Fprintf(w, "Warning: --timeout %s reached; the map is partial, covering %d of %d files\n", timeout, parsed, total)
+}
+
+// logDropped writes a --verbose line for each file in before that is not in
+// after, giving reason as the cause.
+func logDropped(w io.Writer, before, after []discover.FileEntry, reason string) {
+	if w == nil || len(before) == len(after) {
+		return
+	}
+	keep := make(map[string]struct{}, len(after))
+	for _, f := range after {
+		keep[f.Path] = struct{}{}
+	}
+	for _, f := range before {
+		if _, ok := keep[f.Path]; !ok {
+			_, _ = fmt.Fprintf(w, "filter: skip %s (%s)\n", f.Path, reason)
+		}
+	}
+}
+
+// logParsed writes a --verbose line per parsed file with its definition
+// count, noting files whose parse recovered from syntax errors.
+func logParsed(w io.Writer, fileInfos []model.FileInfo) {
+	for i := range fileInfos {
+		fi := &fileInfos[i]
+		n := countDefinitions(fileInfos[i : i+1])
+		if fi.SyntaxErrors {
+			_, _ = fmt.Fprintf(w, "parse: %s: %d definitions (syntax errors; symbols may be incomplete)\n", fi.Path, n)
+		} else {
+			_, _ = fmt.Fprintf(w, "parse: %s: %d definitions\n", fi.Path, n)
+		}
+	}
+}
+
+// packageDocs returns the documented packages of the shown files, in
+// directory order. A package is its directory, and its doc is taken from the
+// first file in path order (among all of fileInfos) that carries one.
+func packageDocs(shown, fileInfos []model.FileInfo) []model.Package {
+	names := make(map[string]string)
+	for i := range shown {
+		if fi := &shown[i]; fi.Package != "" {
+			names[path.Dir(fi.Path)] = fi.Package
+		}
+	}
+	docFiles := make(map[string]*model.FileInfo)
+	for i := range fileInfos {
+		fi := &fileInfos[i]
+		if fi.PackageDoc == "" {
+			continue
+		}
+		dir := path.Dir(fi.Path)
+		if _, ok := names[dir]; !ok {
+			continue
+		}
+		if prev, ok := docFiles[dir]; !ok || fi.Path < prev.Path {
+			docFiles[dir] = fi
+		}
+	}
+
+	var pkgs []model.Package
+	for dir, fi := range docFiles {
+		pkgs = append(pkgs, model.Package{Dir: dir, Name: names[dir], Doc: fi.PackageDoc})
 	}
-	return true
+	slices.SortFunc(pkgs, func(a, b model.Package) int { return strings.Compare(a.Dir, b.Dir) })
+	return pkgs
 }
 
-func filterBySize(root string, files []discover.FileEntry, maxSize int, stderr io.Writer) []discover.FileEntry {
+// syntaxDiagnostics returns a diagnostic for each file parsed with syntax
+// errors, in path order.
+func syntaxDiagnostics(fileInfos []model.FileInfo) []model.Diagnostic {
+	var diags []model.Diagnostic
+	for i := range fileInfos {
+		if fileInfos[i].SyntaxErrors {
+			diags = append(diags, model.Diagnostic{File: fileInfos[i].Path, Message: "syntax errors; symbols may be incomplete"})
+		}
+	}
+	slices.SortFunc(diags, func(a, b model.Diagnostic) int { return strings.Compare(a.File, b.File) })
+	return diags
+}
+
+// filterBySize drops files larger than maxSize bytes, as measured by size,
+// with a warning. Files whose size can't be read are kept.
+func filterBySize(files []discover.FileEntry, maxSize int, size func(path string) (int64, error), stderr io.Writer) []discover.FileEntry {
 	var kept []discover.FileEntry
 	for _, f := range files {
-		fi, err := os.Stat(filepath.Join(root, f.Path))
+		n, err := size(f.Path)
 		if err != nil {
 			kept = append(kept, f) // keep if can't stat
 			continue
 		}
-		if fi.Size() > int64(maxSize) {
+		if n > int64(maxSize) {
 			_, _ = fmt.Fprintf(stderr, "Warning: %s: skipped (>%d bytes)\n", f.Path, maxSize)
 			continue
 		}
@@ -271,7 +1448,43 @@ func filterBySize(root string, files []discover.FileEntry, maxSize int, stderr i
 	return kept
 }
 
-func parseFilesConcurrent(root string, files []discover.FileEntry, stderr io.Writer) []model.FileInfo {
+// fileSize returns the size of the file at path relative to root.
+func fileSize(root string) func(path string) (int64, error) {
+	return func(path string) (int64, error) {
+		fi, err := os.Stat(filepath.Join(root, path))
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	}
+}
+
+// readFile returns a reader for files relative to root.
+func readFile(root string) func(path string) ([]byte, error) {
+	return func(path string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(root, path))
+	}
+}
+
+// parseFilesConcurrent parses files, whose contents come from read, and
+// returns the results in their original order. Files that can't be read are
+// dropped with a warning. Once ctx is done, parses in flight are canceled and
+// the remaining files are dropped too, so the results may be partial.
+func parseFilesConcurrent(ctx context.Context, read func(path string) ([]byte, error), files []discover.FileEntry, stderr io.Writer) []model.FileInfo {
+	var fileInfos []model.FileInfo
+	parseFilesStream(ctx, read, files, stderr, func(fi model.FileInfo) {
+		fileInfos = append(fileInfos, fi)
+	})
+	return fileInfos
+}
+
+// parseFilesStream parses files concurrently, like parseFilesConcurrent, but
+// passes each result to emit instead of collecting them. Results are emitted
+// in the original order, each as soon as it and every file before it are
+// done, so only the results that finish early are held. Files that can't be
+// read or whose content looks binary, and every file once ctx is done, are
+// dropped.
+func parseFilesStream(ctx context.Context, read func(path string) ([]byte, error), files []discover.FileEntry, stderr io.Writer, emit func(model.FileInfo)) {
 	type result struct {
 		index int
 		info  model.FileInfo
@@ -295,43 +1508,54 @@ func parseFilesConcurrent(root string, files []discover.FileEntry, stderr io.Wri
 			defer wg.Done()
 
 			// Each goroutine gets its own parser
-			parsers := make(map[string]*parserPair)
+			parsers := make(map[*lang.Language]*parserPair)
 
 			for idx := range work {
+				if ctx.Err() != nil {
+					results <- result{index: idx}
+					continue
+				}
 				f := files[idx]
-				pp, ok := parsers[f.Language]
+				l := lang.Languages[f.Language].ForFile(f.Path)
+				pp, ok := parsers[l]
 				if !ok {
-					l := lang.Languages[f.Language]
 					q, err := l.GetTagQuery()
 					if err != nil {
 						stderrMu.Lock()
 						_, _ = fmt.Fprintf(stderr, "Warning: failed to compile query for %s: %v\n", f.Language, err)
 						stderrMu.Unlock()
+						results <- result{index: idx}
 						continue
 					}
 					pp = &parserPair{lang: l, parser: l.NewParser(), query: q}
-					parsers[f.Language] = pp
+					parsers[l] = pp
 				}
 
-				absPath := filepath.Join(root, f.Path)
-				source, err := os.ReadFile(absPath)
+				source, err := read(f.Path)
 				if err != nil {
 					stderrMu.Lock()
 					_, _ = fmt.Fprintf(stderr, "Warning: failed to parse %s: %v\n", f.Path, err)
 					stderrMu.Unlock()
+					results <- result{index: idx}
+					continue
+				}
+				if discover.LooksBinary(source) {
+					stderrMu.Lock()
+					_, _ = fmt.Fprintf(stderr, "Warning: %s: skipped (binary content)\n", f.Path)
+					stderrMu.Unlock()
+					results <- result{index: idx}
 					continue
 				}
 
-				tags := parse.ExtractTags(pp.lang, pp.parser, pp.query, source, f.Path)
-				results <- result{
-					index: idx,
-					info: model.FileInfo{
-						Path:     f.Path,
-						Language: f.Language,
-						Tags:     tags,
-					},
-					ok: true,
+				info, err := parse.ExtractFile(ctx, pp.lang, pp.parser, pp.query, source, f.Path)
+				if err != nil {
+					results <- result{index: idx}
+					continue
 				}
+				// A dialect shares its language's name, but keep the name
+				// discovery assigned.
+				info.Language = f.Language
+				results <- result{index: idx, info: info, ok: true}
 			}
 		}()
 	}
@@ -346,22 +1570,33 @@ func parseFilesConcurrent(root string, files []discover.FileEntry, stderr io.Wri
 		close(results)
 	}()
 
-	// Collect results in original order
-	indexed := make([]model.FileInfo, len(files))
-	valid := make([]bool, len(files))
+	// Every file sends one result, so each index is eventually filled in.
+	pending := make(map[int]result)
+	next := 0
 	for r := range results {
-		indexed[r.index] = r.info
-		valid[r.index] = r.ok
-	}
-
-	var fileInfos []model.FileInfo
-	for i, v := range valid {
-		if v {
-			fileInfos = append(fileInfos, indexed[i])
+		pending[r.index] = r
+		for {
+			p, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if p.ok {
+				emit(p.info)
+			}
 		}
 	}
+}
 
-	return fileInfos
+// stringList is a flag.Value that collects every occurrence of a repeatable flag.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
 }
 
 type parserPair struct {
@@ -374,17 +1609,54 @@ type parserPair struct {
 var flagsWithValue = map[string]bool{
 	"-n": true, "--n": true,
 	"-max-files": true, "--max-files": true,
+	"-max-tokens": true, "--max-tokens": true,
+	"-min-rank": true, "--min-rank": true,
+	"-prune-unreachable-from": true, "--prune-unreachable-from": true,
+	"-per-dir": true, "--per-dir": true,
 	"-l": true, "--l": true,
 	"-langs": true, "--langs": true,
+	"-rank-boost": true, "--rank-boost": true,
+	"-recency-weight": true, "--recency-weight": true,
+	"-hotspot-weight": true, "--hotspot-weight": true,
+	"-hotspot-days": true, "--hotspot-days": true,
+	"-pagerank-alpha": true, "--pagerank-alpha": true,
+	"-pagerank-iterations": true, "--pagerank-iterations": true,
+	"-timeout": true, "--timeout": true,
+	"-language-map": true, "--language-map": true,
+	"-max-depth": true, "--max-depth": true,
+	"-archive": true, "--archive": true,
 	"-cache": true, "--cache": true,
+	"-cache-key": true, "--cache-key": true,
+	"-o": true, "--o": true,
+	"-output": true, "--output": true,
+	"-output-dir": true, "--output-dir": true,
+	"-header-file": true, "--header-file": true,
 	"-max-file-size": true, "--max-file-size": true,
 	"-symbol": true, "--symbol": true,
+	"-symbol-regex": true, "--symbol-regex": true,
+	"-callers-of": true, "--callers-of": true,
+	"-importers-of": true, "--importers-of": true,
+	"-search": true, "--search": true,
+	"-callees-of": true, "--callees-of": true,
 	"-file": true, "--file": true,
+	"-format": true, "--format": true,
+	"-exclude": true, "--exclude": true,
+	"-test-glob": true, "--test-glob": true,
+	"-rank-by": true, "--rank-by": true,
+	"-sort-symbols": true, "--sort-symbols": true,
+	"-depth": true, "--depth": true,
+	"-since": true, "--since": true,
 }
 
 // reorderArgs moves positional arguments after all flags so Go's flag package
 // can parse them correctly (it stops at the first non-flag arg).
 func reorderArgs(args []string) []string {
+	return reorderArgsWith(args, flagsWithValue)
+}
+
+// reorderArgsWith is reorderArgs for a command whose value-taking flags are
+// withValue.
+func reorderArgsWith(args []string, withValue map[string]bool) []string {
 	var flags, positional []string
 	for i := 0; i < len(args); i++ {
 		if args[i] == "--" {
@@ -393,7 +1665,7 @@ func reorderArgs(args []string) []string {
 		}
 		if len(args[i]) > 0 && args[i][0] == '-' {
 			flags = append(flags, args[i])
-			if flagsWithValue[args[i]] && i+1 < len(args) {
+			if withValue[args[i]] && i+1 < len(args) {
 				i++
 				flags = append(flags, args[i])
 			}
@@ -403,3 +1675,18 @@ func reorderArgs(args []string) []string {
 	}
 	return append(flags, positional...)
 }
+
+// applyLanguageMap registers each ext=language pair of a --language-map
+// value with lang.MapExtension.
+func applyLanguageMap(spec string) error {
+	for _, pair := range strings.Split(spec, ",") {
+		ext, name, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return fmt.Errorf("invalid --language-map entry %q (want ext=language)", pair)
+		}
+		if err := lang.MapExtension(strings.TrimSpace(ext), strings.TrimSpace(name)); err != nil {
+			return fmt.Errorf("invalid --language-map entry %q: %w", pair, err)
+		}
+	}
+	return nil
+}
@@ -1,11 +1,26 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/phobologic/repoguide/internal/cache"
+	"github.com/phobologic/repoguide/internal/discover"
+	"github.com/phobologic/repoguide/internal/model"
 )
 
 func writeTestFile(t *testing.T, root, rel, content string) {
@@ -40,7 +55,7 @@ func TestRunBasic(t *testing.T) {
 	dir := createSampleRepo(t)
 
 	var stdout, stderr bytes.Buffer
-	err := run([]string{dir}, &stdout, &stderr)
+	err := run([]string{dir}, nil, &stdout, &stderr)
 	if err != nil {
 		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
 	}
@@ -68,7 +83,7 @@ func TestRunRaw(t *testing.T) {
 	dir := createSampleRepo(t)
 
 	var stdout, stderr bytes.Buffer
-	err := run([]string{"--raw", dir}, &stdout, &stderr)
+	err := run([]string{"--raw", dir}, nil, &stdout, &stderr)
 	if err != nil {
 		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
 	}
@@ -82,384 +97,2144 @@ func TestRunRaw(t *testing.T) {
 	}
 }
 
-func TestRunMaxFiles(t *testing.T) {
+func TestRunNoHeader(t *testing.T) {
 	t.Parallel()
 	dir := createSampleRepo(t)
 
 	var stdout, stderr bytes.Buffer
-	err := run([]string{"-n", "1", dir}, &stdout, &stderr)
-	if err != nil {
-		t.Fatalf("run: %v", err)
+	if err := run([]string{"--no-header", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
 	}
-
-	out := stdout.String()
-	if !strings.Contains(out, "files[1]") {
-		t.Errorf("expected 1 file, got:\n%s", out)
+	if out := stdout.String(); !strings.HasPrefix(out, "repo:") {
+		t.Errorf("--no-header output should start with repo:, got:\n%s", out)
 	}
 }
 
-func TestRunVersion(t *testing.T) {
+func TestRunHeaderFile(t *testing.T) {
 	t.Parallel()
+	dir := createSampleRepo(t)
+	headerPath := filepath.Join(t.TempDir(), "header.md")
+	if err := os.WriteFile(headerPath, []byte("# Acme map\n\nUse the service layer.\n\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"--header-file", headerPath, dir},
+		{"--header-file", headerPath, "--symbol", "greet", dir}, // replaces the focused header too
+	} {
+		var stdout, stderr bytes.Buffer
+		if err := run(args, nil, &stdout, &stderr); err != nil {
+			t.Fatalf("run %v: %v\nstderr: %s", args, err, stderr.String())
+		}
+		out := stdout.String()
+		if !strings.HasPrefix(out, "# Acme map\n\nUse the service layer.\nrepo:") {
+			t.Errorf("%v: expected custom header followed by the map:\n%s", args, out)
+		}
+		if strings.Contains(out, "Repository Map") || strings.Contains(out, "Focused query") {
+			t.Errorf("%v: default header should be replaced:\n%s", args, out)
+		}
+	}
 
 	var stdout, stderr bytes.Buffer
-	err := run([]string{"-V"}, &stdout, &stderr)
-	if err != nil {
+	if err := run([]string{"--header-file", headerPath, "--raw", dir}, nil, &stdout, &stderr); err != nil {
 		t.Fatalf("run: %v", err)
 	}
-	if !strings.Contains(stdout.String(), "repoguide") {
-		t.Errorf("version output: %q", stdout.String())
+	if !strings.HasPrefix(stdout.String(), "repo:") {
+		t.Errorf("--raw should win over --header-file:\n%s", stdout.String())
 	}
-}
-
-func TestRunNoFiles(t *testing.T) {
-	t.Parallel()
-	dir := t.TempDir()
-	writeTestFile(t, dir, "readme.txt", "nothing here")
 
-	var stdout, stderr bytes.Buffer
-	err := run([]string{dir}, &stdout, &stderr)
-	if err == nil {
-		t.Fatal("expected error for no parseable files")
-	}
-	if !strings.Contains(err.Error(), "no parseable files") {
-		t.Errorf("unexpected error: %v", err)
+	if err := run([]string{"--header-file", filepath.Join(dir, "missing.md"), dir}, nil, &stdout, &stderr); err == nil {
+		t.Error("expected error for a missing header file")
 	}
 }
 
-func TestRunUnsupportedLanguage(t *testing.T) {
+func TestHeader(t *testing.T) {
 	t.Parallel()
 
-	var stdout, stderr bytes.Buffer
-	err := run([]string{"-l", "rust", t.TempDir()}, &stdout, &stderr)
-	if err == nil {
-		t.Fatal("expected error for unsupported language")
+	tests := []struct {
+		withTests, focused bool
+		want               string
+	}{
+		{false, false, headerBase},
+		{true, false, headerWithTests},
+		{false, true, headerFocused},
+		{true, true, headerFocused},
 	}
-	if !strings.Contains(err.Error(), "unsupported language") {
-		t.Errorf("unexpected error: %v", err)
+	for _, tt := range tests {
+		if got := header(tt.withTests, tt.focused); got != tt.want {
+			t.Errorf("header(%v, %v) = %.40q..., want %.40q...", tt.withTests, tt.focused, got, tt.want)
+		}
 	}
 }
 
-func TestRunCache(t *testing.T) {
+func TestRunMaxFiles(t *testing.T) {
 	t.Parallel()
 	dir := createSampleRepo(t)
-	cachePath := filepath.Join(t.TempDir(), "test.cache")
 
-	var stdout1, stderr1 bytes.Buffer
-	err := run([]string{"--cache", cachePath, dir}, &stdout1, &stderr1)
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"-n", "1", dir}, nil, &stdout, &stderr)
 	if err != nil {
-		t.Fatalf("first run: %v", err)
+		t.Fatalf("run: %v", err)
 	}
 
-	// Cache file should exist
-	if _, err := os.Stat(cachePath); err != nil {
-		t.Fatalf("cache not created: %v", err)
+	out := stdout.String()
+	if !strings.Contains(out, "files[1]") {
+		t.Errorf("expected 1 file, got:\n%s", out)
 	}
+}
 
-	// Cache file should contain raw TOON (no header)
-	cacheData, err := os.ReadFile(cachePath)
-	if err != nil {
-		t.Fatalf("reading cache: %v", err)
-	}
-	if strings.Contains(string(cacheData), "# Repository Map") {
-		t.Error("cache file should not contain agent header")
-	}
+func TestRunMaxTokens(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
 
-	// Second run should use cache and include header
-	var stdout2, stderr2 bytes.Buffer
-	err = run([]string{"--cache", cachePath, dir}, &stdout2, &stderr2)
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--max-tokens", "100000", dir}, nil, &stdout, &stderr)
 	if err != nil {
-		t.Fatalf("second run: %v", err)
+		t.Fatalf("run: %v", err)
 	}
-
-	if stdout1.String() != stdout2.String() {
-		t.Errorf("cache mismatch:\nfirst:\n%s\nsecond:\n%s", stdout1.String(), stdout2.String())
+	if !strings.Contains(stdout.String(), "files[2]") {
+		t.Errorf("large budget should keep both files, got:\n%s", stdout.String())
 	}
-
-	if !strings.Contains(stdout2.String(), "# Repository Map") {
-		t.Error("cached output should include agent header")
+	if !strings.Contains(stderr.String(), "Estimated tokens:") {
+		t.Errorf("expected token estimate on stderr, got:\n%s", stderr.String())
 	}
 }
 
-func TestRunCacheRaw(t *testing.T) {
+func TestRunStatsSyntaxErrors(t *testing.T) {
 	t.Parallel()
 	dir := createSampleRepo(t)
-	cachePath := filepath.Join(t.TempDir(), "test.cache")
-
-	// First run populates cache (with header in output)
-	var stdout1, stderr1 bytes.Buffer
-	err := run([]string{"--cache", cachePath, dir}, &stdout1, &stderr1)
-	if err != nil {
-		t.Fatalf("first run: %v", err)
-	}
+	writeTestFile(t, dir, "broken.py", "def ok():\n    pass\n\ndef broken(:\n    pass\n\nclass Kept:\n    pass\n")
 
-	// Second run with --raw should use cache but suppress header
-	var stdout2, stderr2 bytes.Buffer
-	err = run([]string{"--raw", "--cache", cachePath, dir}, &stdout2, &stderr2)
-	if err != nil {
-		t.Fatalf("second run: %v", err)
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--stats", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
 	}
-
-	out := stdout2.String()
-	if strings.Contains(out, "# Repository Map") {
-		t.Error("--raw with cache should suppress agent header")
+	for _, want := range []string{"parse failures:      0", "with syntax errors:  1"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stats missing %q:\n%s", want, stderr.String())
+		}
 	}
-	if !strings.HasPrefix(out, "repo:") {
-		t.Errorf("--raw cached output should start with repo:, got:\n%s", out)
+	// Symbols the parser recovered are still in the map.
+	if !strings.Contains(stdout.String(), "broken.py,Kept,class") {
+		t.Errorf("expected recovered class in map:\n%s", stdout.String())
 	}
 }
 
-func TestRunSymbols(t *testing.T) {
+func TestRunLanguageMap(t *testing.T) {
 	t.Parallel()
 	dir := createSampleRepo(t)
+	writeTestFile(t, dir, "build.gyp", "def configure():\n    pass\n")
+	writeTestFile(t, dir, "cmd/main.go", "package main\n\nfunc main() {}\n")
 
 	var stdout, stderr bytes.Buffer
-	err := run([]string{dir}, &stdout, &stderr)
+	err := run([]string{"--language-map", ".gyp=python", "-l", "python", dir}, nil, &stdout, &stderr)
 	if err != nil {
 		t.Fatalf("run: %v", err)
 	}
-
 	out := stdout.String()
-	// Check for class definition
-	if !strings.Contains(out, "User,class") {
-		t.Error("missing User class definition")
+	if !strings.Contains(out, "build.gyp,configure,function") {
+		t.Errorf("expected .gyp file parsed as Python:\n%s", out)
 	}
-	// Check for method
-	if !strings.Contains(out, "User.__init__,method") {
-		t.Error("missing User.__init__ method")
+	if strings.Contains(out, "cmd/main.go") {
+		t.Errorf("-l python should still exclude Go files:\n%s", out)
 	}
-	// Check for function
-	if !strings.Contains(out, "greet,function") {
-		t.Error("missing greet function")
+
+	for _, spec := range []string{".gyp=cobol", ".gyp", "gyp=python"} {
+		err := run([]string{"--language-map", spec, dir}, nil, &stdout, &stderr)
+		if err == nil || !strings.Contains(err.Error(), "--language-map") {
+			t.Errorf("--language-map %q: expected error, got %v", spec, err)
+		}
 	}
 }
 
-func TestRunDependencies(t *testing.T) {
+func TestRunRankBoostRecency(t *testing.T) {
 	t.Parallel()
 	dir := createSampleRepo(t)
 
+	// Outside git, ranking falls back to PageRank with a warning.
 	var stdout, stderr bytes.Buffer
-	err := run([]string{dir}, &stdout, &stderr)
-	if err != nil {
+	if err := run([]string{"--rank-boost", "recency", dir}, nil, &stdout, &stderr); err != nil {
 		t.Fatalf("run: %v", err)
 	}
+	if !strings.Contains(stderr.String(), "ranking by PageRank only") {
+		t.Errorf("expected fallback warning, got %q", stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "files[2]") {
+		t.Errorf("expected a full map:\n%s", stdout.String())
+	}
 
-	out := stdout.String()
-	// main.py references User from models.py
-	if !strings.Contains(out, "main.py,models.py,User") {
-		t.Errorf("missing dependency main.py → models.py:\n%s", out)
+	for _, args := range [][]string{
+		{"--rank-boost", "churn", dir},
+		{"--rank-boost", "recency", "--recency-weight", "1.5", dir},
+	} {
+		if err := run(args, nil, &stdout, &stderr); err == nil {
+			t.Errorf("run %v: expected error", args)
+		}
 	}
 }
 
-func TestRunNotADirectory(t *testing.T) {
+func TestRunRankBoostHotspots(t *testing.T) {
 	t.Parallel()
-	f := filepath.Join(t.TempDir(), "file.txt")
-	if err := os.WriteFile(f, []byte("hi"), 0o644); err != nil {
-		t.Fatal(err)
+	dir := createSampleRepo(t)
+
+	// Outside git, hotspots is a no-op: the map matches a plain run.
+	var plain, stdout, stderr bytes.Buffer
+	if err := run([]string{dir}, nil, &plain, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if err := run([]string{"--rank-boost", "hotspots", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run --rank-boost hotspots: %v", err)
+	}
+	if stdout.String() != plain.String() {
+		t.Errorf("hotspots outside git changed the map:\n%s", stdout.String())
 	}
 
-	var stdout, stderr bytes.Buffer
-	err := run([]string{f}, &stdout, &stderr)
-	if err == nil {
-		t.Fatal("expected error for non-directory")
+	for _, args := range [][]string{
+		{"--rank-boost", "hotspots", "--hotspot-weight", "-0.1", dir},
+		{"--rank-boost", "hotspots", "--hotspot-days", "0", dir},
+	} {
+		if err := run(args, nil, &stdout, &stderr); err == nil {
+			t.Errorf("run %v: expected error", args)
+		}
 	}
 }
 
-func TestRunMaxFileSize(t *testing.T) {
+func TestRunEntrypoints(t *testing.T) {
 	t.Parallel()
-	dir := t.TempDir()
-	writeTestFile(t, dir, "small.py", "x = 1")
-	writeTestFile(t, dir, "big.py", strings.Repeat("x = 1\n", 200))
+	dir := createSampleRepo(t)
+	writeTestFile(t, dir, "cli.py", "from models import User\n\ndef main():\n    User()\n\nif __name__ == \"__main__\":\n    main()\n")
 
 	var stdout, stderr bytes.Buffer
-	err := run([]string{"--max-file-size", "100", dir}, &stdout, &stderr)
-	if err != nil {
+	if err := run([]string{"--entrypoints", dir}, nil, &stdout, &stderr); err != nil {
 		t.Fatalf("run: %v", err)
 	}
-
-	out := stdout.String()
-	if !strings.Contains(out, "small.py") {
-		t.Error("missing small.py")
+	want := "entrypoints[2]{file,name,line}:\n  cli.py,main,3\n  cli.py,__main__,6"
+	if !strings.Contains(stdout.String(), want) {
+		t.Errorf("missing entrypoints table %q:\n%s", want, stdout.String())
 	}
-	if strings.Contains(out, "big.py") {
-		t.Error("big.py should be filtered out")
+
+	stdout.Reset()
+	if err := run([]string{dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
 	}
-	if !strings.Contains(stderr.String(), "Warning") {
-		t.Error("expected warning about skipped file")
+	if strings.Contains(stdout.String(), "entrypoints") {
+		t.Errorf("entrypoints should be opt-in:\n%s", stdout.String())
 	}
 }
 
-func TestRunCalls(t *testing.T) {
+func TestRunMetrics(t *testing.T) {
 	t.Parallel()
 	dir := t.TempDir()
-	writeTestFile(t, dir, "utils.py", `def helper():
-    pass
-`)
-	writeTestFile(t, dir, "main.py", `def greet():
-    helper()
-`)
+	writeTestFile(t, dir, "util.py", "def helper():\n    pass\n")
+	writeTestFile(t, dir, "a.py", "from util import helper\n\ndef a():\n    helper()\n")
+	writeTestFile(t, dir, "b.py", "from util import helper\nfrom a import a\n\ndef b():\n    helper()\n    a()\n")
 
 	var stdout, stderr bytes.Buffer
-	err := run([]string{dir}, &stdout, &stderr)
-	if err != nil {
-		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	if err := run([]string{"--metrics", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
 	}
-
 	out := stdout.String()
-	if !strings.Contains(out, "calls[") {
-		t.Errorf("missing calls section:\n%s", out)
+	for _, want := range []string{"metrics[3]{file,in_degree,out_degree,rank}:", "  util.py,2,0,", "  a.py,1,1,", "  b.py,0,2,"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
 	}
-	if !strings.Contains(out, "greet,helper") {
-		t.Errorf("missing greet→helper call edge:\n%s", out)
+
+	// Degrees count the whole graph even when only one file is shown.
+	stdout.Reset()
+	if err := run([]string{"--metrics", "-n", "1", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "metrics[1]{file,in_degree,out_degree,rank}:\n  util.py,2,0,") {
+		t.Errorf("expected util.py with full in-degree:\n%s", stdout.String())
 	}
 }
 
-func TestRunSymbolFilter(t *testing.T) {
+func TestRunWithOwners(t *testing.T) {
 	t.Parallel()
 	dir := t.TempDir()
-	writeTestFile(t, dir, "utils.py", `def helper():
-    pass
-`)
-	writeTestFile(t, dir, "main.py", `def greet():
-    helper()
-`)
+	writeTestFile(t, dir, ".github/CODEOWNERS", "*.py @team/python\n/api/ @team/api @alice\n")
+	writeTestFile(t, dir, "api/server.py", "from util import helper\n\ndef serve():\n    helper()\n")
+	writeTestFile(t, dir, "util.py", "def helper():\n    pass\n")
+	writeTestFile(t, dir, "tools/gen.rb", "def gen\nend\n")
 
 	var stdout, stderr bytes.Buffer
-	err := run([]string{"--symbol", "helper", dir}, &stdout, &stderr)
-	if err != nil {
+	if err := run([]string{"--raw", "--with-owners", dir}, nil, &stdout, &stderr); err != nil {
 		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
 	}
-
 	out := stdout.String()
-	if !strings.Contains(out, "utils.py") {
-		t.Errorf("utils.py (defines helper) should be in output:\n%s", out)
+	for _, want := range []string{
+		"files[3]{path,language,rank,owners}:",
+		",@team/python\n",
+		"api/server.py,python,",
+		",@team/api @alice\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	// No rule matches tools/gen.rb: its owners cell is empty.
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "  tools/gen.rb,ruby,") && !strings.HasSuffix(line, `,""`) {
+			t.Errorf("expected an empty owners cell: %q", line)
+		}
+	}
+
+	stdout.Reset()
+	if err := run([]string{"--with-owners", "--format", "json", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
 	}
-	// greet calls helper, so main.py should also be included via call expansion.
-	if !strings.Contains(out, "main.py") {
-		t.Errorf("main.py (defines greet which calls helper) should be in output:\n%s", out)
+	if !strings.Contains(stdout.String(), `"owners": [
+        "@team/api",
+        "@alice"
+      ]`) {
+		t.Errorf("JSON owners missing:\n%s", stdout.String())
 	}
-	if !strings.Contains(out, "helper,function") {
-		t.Errorf("helper definition should appear in symbols:\n%s", out)
+
+	// Without a CODEOWNERS file the column is empty, with a warning.
+	bare := createSampleRepo(t)
+	stderr.Reset()
+	if err := run([]string{"--with-owners", bare}, nil, &bytes.Buffer{}, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(stderr.String(), "no CODEOWNERS file found") {
+		t.Errorf("expected a warning, got %q", stderr.String())
 	}
 }
 
-func TestRunSymbolFilterNoMatch(t *testing.T) {
+func TestRunDiagnostics(t *testing.T) {
 	t.Parallel()
-	dir := t.TempDir()
-	writeTestFile(t, dir, "main.py", "def greet():\n    pass\n")
+	dir := createSampleRepo(t)
+	writeTestFile(t, dir, "broken.py", "def ok():\n    pass\n\ndef broken(:\n")
 
-	var stdout, stderr bytes.Buffer
-	err := run([]string{"--symbol", "NoSuchSymbol", dir}, &stdout, &stderr)
-	if err != nil {
+	var stdout bytes.Buffer
+	if err := run([]string{"--raw", dir}, nil, &stdout, &bytes.Buffer{}); err != nil {
 		t.Fatalf("run: %v", err)
 	}
+	if strings.Contains(stdout.String(), "diagnostics") {
+		t.Errorf("diagnostics table should need --diagnostics:\n%s", stdout.String())
+	}
 
-	out := stdout.String()
-	if !strings.Contains(out, "files[0]") {
-		t.Errorf("expected empty files table:\n%s", out)
+	// Reported even when -n leaves the broken file out of the map.
+	stdout.Reset()
+	if err := run([]string{"--raw", "--diagnostics", "-n", "1", dir}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run --diagnostics: %v", err)
+	}
+	want := "diagnostics[1]{file,message}:\n  broken.py,syntax errors; symbols may be incomplete"
+	if !strings.Contains(stdout.String(), want) {
+		t.Errorf("missing %q:\n%s", want, stdout.String())
 	}
 }
 
-func TestRunFileFilter(t *testing.T) {
+func TestRunGoPackages(t *testing.T) {
 	t.Parallel()
 	dir := t.TempDir()
-	writeTestFile(t, dir, "utils.py", "def helper():\n    pass\n")
-	writeTestFile(t, dir, "main.py", "def greet():\n    helper()\n")
+	writeTestFile(t, dir, "store/doc.go", "// Package store keeps records. It is not safe for concurrent use.\npackage store\n")
+	writeTestFile(t, dir, "store/store.go", "package store\n\nfunc Put() {}\n")
+	writeTestFile(t, dir, "main.go", "package main\n\nimport \"x/store\"\n\nfunc main() { store.Put() }\n")
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
 
-	var stdout, stderr bytes.Buffer
-	err := run([]string{"--file", "utils", dir}, &stdout, &stderr)
-	if err != nil {
-		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	var stdout bytes.Buffer
+	if err := run([]string{"--raw", "--cache", cachePath, dir}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for _, want := range []string{
+		"files[3]{path,language,rank,package}:",
+		"store/store.go,go,",
+		"packages[1]{dir,package,doc}:\n  store,store,Package store keeps records.",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("missing %q:\n%s", want, stdout.String())
+		}
 	}
 
-	out := stdout.String()
-	if !strings.Contains(out, "utils.py") {
-		t.Errorf("utils.py should be in output:\n%s", out)
+	// Packages survive the per-file cache, and -n still reports the doc of
+	// a package whose doc.go is not shown.
+	stdout.Reset()
+	if err := run([]string{"--format", "json", "--cache", cachePath, "-n", "1", dir}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run --format json: %v", err)
 	}
-	if strings.Contains(out, "main.py") && !strings.Contains(out, "dependencies") {
-		// main.py only appears if it's in a dep edge, not as a file row
-		t.Errorf("main.py should not appear as a file:\n%s", out)
+	var doc struct {
+		Files []struct {
+			Path    string `json:"path"`
+			Package string `json:"package"`
+		} `json:"files"`
+		Packages []struct {
+			Dir, Name, Doc string
+		} `json:"packages"`
 	}
-	if !strings.Contains(out, "helper,function") {
-		t.Errorf("helper definition should appear:\n%s", out)
+	if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(doc.Files) != 1 || doc.Files[0].Path != "store/store.go" || doc.Files[0].Package != "store" {
+		t.Errorf("files = %+v, want store/store.go in package store", doc.Files)
+	}
+	if len(doc.Packages) != 1 || doc.Packages[0].Doc != "Package store keeps records." {
+		t.Errorf("packages = %+v", doc.Packages)
 	}
 }
 
-func TestRunSymbolAndFileFilter(t *testing.T) {
+func TestRunWithIDs(t *testing.T) {
 	t.Parallel()
-	dir := t.TempDir()
-	writeTestFile(t, dir, "pkg/utils.py", "def helper():\n    pass\n")
-	writeTestFile(t, dir, "other/utils.py", "def other_helper():\n    pass\n")
+	dir := createSampleRepo(t)
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
 
-	var stdout, stderr bytes.Buffer
-	err := run([]string{"--symbol", "helper", "--file", "pkg", dir}, &stdout, &stderr)
-	if err != nil {
-		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	// The cached map has no ids, so --with-ids must not replay it.
+	if err := run([]string{"--cache", cachePath, dir}, nil, &bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
 	}
-
-	out := stdout.String()
-	// --file pkg filters to pkg/utils.py; --symbol helper must be in that file.
-	if !strings.Contains(out, "pkg/utils.py") {
-		t.Errorf("pkg/utils.py should be in output:\n%s", out)
+	var stdout bytes.Buffer
+	if err := run([]string{"--raw", "--with-ids", "--cache", cachePath, dir}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run --with-ids: %v", err)
+	}
+	for _, want := range []string{"{id,file,name,kind,line,signature}:", "{source,target,symbol_ids}:"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("missing %q:\n%s", want, stdout.String())
+		}
 	}
 }
 
-func TestRunSymbolFilterCacheSkipped(t *testing.T) {
+func TestRunCountOnly(t *testing.T) {
 	t.Parallel()
-	dir := t.TempDir()
-	writeTestFile(t, dir, "main.py", "def greet():\n    pass\n")
-	cachePath := filepath.Join(t.TempDir(), "cache.toon")
+	dir := createSampleRepo(t)
+	writeTestFile(t, dir, "test_models.py", "def test_user():\n    pass\n")
+	writeTestFile(t, dir, "cmd/main.go", "package main\n\nfunc main() {}\n")
+	writeTestFile(t, dir, "vendor_gen/skip.go", "package gen\n")
 
-	// First run: no filter, write cache.
-	var stdout1 bytes.Buffer
-	if err := run([]string{"--cache", cachePath, dir}, &stdout1, &bytes.Buffer{}); err != nil {
-		t.Fatalf("first run: %v", err)
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--count-only", "--exclude", "vendor_gen/**", dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v", err)
 	}
-	if _, err := os.Stat(cachePath); err != nil {
-		t.Fatalf("cache not written: %v", err)
+	want := "3\n  python       2\n  go           1\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("--count-only output = %q, want %q", got, want)
 	}
 
-	// Second run: with --symbol filter. Cache should be bypassed (filter still works).
-	var stdout2 bytes.Buffer
-	if err := run([]string{"--symbol", "greet", "--cache", cachePath, dir}, &stdout2, &bytes.Buffer{}); err != nil {
-		t.Fatalf("second run: %v", err)
+	stdout.Reset()
+	if err := run([]string{"--count-only", "-l", "go", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run -l go: %v", err)
 	}
-	if !strings.Contains(stdout2.String(), "greet") {
-		t.Errorf("filter should work even when cache exists:\n%s", stdout2.String())
+	if got := stdout.String(); got != "2\n  go           2\n" {
+		t.Errorf("--count-only -l go output = %q", got)
 	}
 }
 
-func TestRunSymbolFilterCallSites(t *testing.T) {
+func TestRunStats(t *testing.T) {
 	t.Parallel()
-	dir := t.TempDir()
-	writeTestFile(t, dir, "utils.py", "def helper():\n    pass\n")
-	writeTestFile(t, dir, "main.py", "def greet():\n    helper()\n    helper()\n")
+	dir := createSampleRepo(t)
+	writeTestFile(t, dir, "test_models.py", "def test_user():\n    pass\n")
+	writeTestFile(t, dir, "big.py", strings.Repeat("x = 1\n", 200))
 
 	var stdout, stderr bytes.Buffer
-	err := run([]string{"--symbol", "helper", dir}, &stdout, &stderr)
+	err := run([]string{"--stats", "--max-file-size", "500", dir}, nil, &stdout, &stderr)
 	if err != nil {
-		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+		t.Fatalf("run: %v", err)
 	}
 
-	out := stdout.String()
-	if !strings.Contains(out, "callsites") {
-		t.Errorf("--symbol output should include callsites table:\n%s", out)
+	got := stderr.String()
+	for _, want := range []string{
+		"files discovered:    4",
+		"test files skipped:  1",
+		"size-limit skipped:  1",
+		"files parsed:        2",
+		"files in map:        2",
+		"symbols:             3 (class 1, function 1, method 1)",
+		"dependency edges:    1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("stats missing %q:\n%s", want, got)
+		}
 	}
 }
 
-func TestRunFullMapNoCallSites(t *testing.T) {
+func TestRunProfile(t *testing.T) {
 	t.Parallel()
-	dir := t.TempDir()
+	dir := createSampleRepo(t)
+
+	var plain, stdout, stderr bytes.Buffer
+	if err := run([]string{dir}, nil, &plain, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if err := run([]string{"--profile", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run --profile: %v", err)
+	}
+	if stdout.String() != plain.String() {
+		t.Error("--profile should not change the map")
+	}
+	errOut := stderr.String()
+	for _, want := range []string{"Profile:", "discover:", "(2 files)", "parse:", "graph:", "rank:", "call graph:", "encode:", "total:"} {
+		if !strings.Contains(errOut, want) {
+			t.Errorf("profile missing %q:\n%s", want, errOut)
+		}
+	}
+}
+
+func TestRunVerbose(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	writeTestFile(t, dir, "test_models.py", "def test_user():\n    pass\n")
+
+	var plain, stdout, stderr bytes.Buffer
+	if err := run([]string{dir}, nil, &plain, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if err := run([]string{"--verbose", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run --verbose: %v", err)
+	}
+	if stdout.String() != plain.String() {
+		t.Error("--verbose should not change the map")
+	}
+	errOut := stderr.String()
+	for _, want := range []string{
+		"discover: add main.py (python)",
+		"filter: skip test_models.py (test file; use --with-tests)",
+		"parse: models.py: ",
+	} {
+		if !strings.Contains(errOut, want) {
+			t.Errorf("verbose log missing %q:\n%s", want, errOut)
+		}
+	}
+}
+
+func TestRunPageRankFlags(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	var plain, stdout bytes.Buffer
+	if err := run([]string{"--raw", dir}, nil, &plain, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if err := run([]string{"--raw", "--pagerank-alpha", "0.85", "--pagerank-iterations", "100", dir}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run with explicit defaults: %v", err)
+	}
+	if stdout.String() != plain.String() {
+		t.Errorf("explicit defaults changed the map:\n%s\nwant:\n%s", stdout.String(), plain.String())
+	}
+
+	stdout.Reset()
+	if err := run([]string{"--raw", "--pagerank-alpha", "0.1", dir}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run --pagerank-alpha 0.1: %v", err)
+	}
+	if stdout.String() == plain.String() {
+		t.Error("--pagerank-alpha 0.1 should change the ranks")
+	}
+
+	for _, args := range [][]string{
+		{"--pagerank-alpha", "1"},
+		{"--pagerank-alpha", "0"},
+		{"--pagerank-iterations", "0"},
+	} {
+		if err := run(append(args, dir), nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+			t.Errorf("run %v: expected an error", args)
+		}
+	}
+}
+
+func TestRunCollapseDirs(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "app/models.py", "class User:\n    pass\n")
+	writeTestFile(t, dir, "app/views.py", "from app.models import User\n\ndef show(u: User):\n    pass\n")
+	writeTestFile(t, dir, "main.py", "from app.views import show\n\ndef main():\n    show(None)\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--collapse-dirs", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"dirs[2]{path,rank,files}:", ",2\n", "dependencies[1]{source,target,symbols}:\n  .,app,show"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "symbols[") || strings.Contains(out, "# Repository Map") {
+		t.Errorf("collapsed view should have no symbols table or header:\n%s", out)
+	}
+
+	stdout.Reset()
+	if err := run([]string{"--collapse-dirs", "-n", "1", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run -n 1: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "dirs[1]") {
+		t.Errorf("-n 1 should keep one directory:\n%s", stdout.String())
+	}
+
+	if err := run([]string{"--collapse-dirs", "--format", "yaml", dir}, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Error("--collapse-dirs --format yaml: expected an error")
+	}
+}
+
+func TestRunNoGitignore(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	writeTestFile(t, dir, ".gitignore", "gen/\n")
+	writeTestFile(t, dir, "gen/schema.py", "class Schema:\n    pass\n")
+
+	var stdout bytes.Buffer
+	if err := run([]string{"--raw", dir}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if strings.Contains(stdout.String(), "schema.py") {
+		t.Errorf("gitignored file should be skipped by default:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	if err := run([]string{"--raw", "--no-gitignore", dir}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run --no-gitignore: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Schema") {
+		t.Errorf("--no-gitignore should map the gitignored file:\n%s", stdout.String())
+	}
+}
+
+func TestRunMinRank(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	// models.py is imported by main.py, so it outranks main.py.
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--raw", "--min-rank", "0.5", dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "files[1]") || !strings.Contains(out, "models.py,python") {
+		t.Errorf("expected only models.py, got:\n%s", out)
+	}
+	if !strings.Contains(out, "dependencies[0]") {
+		t.Errorf("dependency from dropped main.py should be pruned:\n%s", out)
+	}
+}
+
+func TestRunPerDir(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "core/a.py", "def a():\n    pass\n")
+	writeTestFile(t, dir, "core/b.py", "from core.a import a\n\ndef b():\n    a()\n")
+	writeTestFile(t, dir, "core/c.py", "from core.a import a\nfrom core.b import b\n\ndef c():\n    a()\n    b()\n")
+	writeTestFile(t, dir, "tools/x.py", "def x():\n    pass\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", "--per-dir", "1", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "files[2]") || !strings.Contains(out, "core/a.py,python") || !strings.Contains(out, "tools/x.py,python") {
+		t.Errorf("expected the top file of core and tools, got:\n%s", out)
+	}
+
+	for _, args := range [][]string{
+		{"--per-dir", "-1", dir},
+		{"--per-dir", "1", "--collapse-dirs", dir},
+		{"--per-dir", "1", "--search", "a", dir},
+	} {
+		if err := run(args, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
+
+func TestRunPruneUnreachable(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "go.mod", "module example.com/app\n")
+	writeTestFile(t, dir, "main.go", "package main\n\nfunc main() {\n\trun()\n}\n\nfunc run() {\n\tload()\n}\n")
+	writeTestFile(t, dir, "load.go", "package main\n\nfunc load() {}\n\nfunc dead() {\n\tload()\n}\n")
+	writeTestFile(t, dir, "legacy.go", "package main\n\nfunc legacy() {}\n")
+
+	for _, from := range []string{"main", "auto"} {
+		var stdout, stderr bytes.Buffer
+		if err := run([]string{"--raw", "--prune-unreachable-from", from, dir}, nil, &stdout, &stderr); err != nil {
+			t.Fatalf("%s: run: %v\nstderr: %s", from, err, stderr.String())
+		}
+		out := stdout.String()
+		if !strings.Contains(out, "files[2]") || strings.Contains(out, "legacy.go,go") {
+			t.Errorf("%s: legacy.go should be pruned:\n%s", from, out)
+		}
+		if !strings.Contains(out, "unreachable[2]{file,name,kind,line}:\n  legacy.go,legacy,function,3\n  load.go,dead,function,5") {
+			t.Errorf("%s: missing unreachable table:\n%s", from, out)
+		}
+		if !strings.Contains(stderr.String(), "Reachable from main: 2 files") {
+			t.Errorf("%s: stderr = %q", from, stderr.String())
+		}
+	}
+
+	for _, args := range [][]string{
+		{"--prune-unreachable-from", "nosuch", dir},
+		{"--prune-unreachable-from", ",", dir},
+		{"--prune-unreachable-from", "main", "--no-calls", dir},
+	} {
+		if err := run(args, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
+
+func TestRunNoCalls(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "util.py", `def helper():
+    pass
+`)
+	writeTestFile(t, dir, "main.py", `from util import helper
+
+def greet():
+    helper()
+`)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", "--no-calls", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	if strings.Contains(out, "calls[") {
+		t.Errorf("--no-calls should omit the calls table:\n%s", out)
+	}
+	if !strings.Contains(out, "dependencies[1]") {
+		t.Errorf("dependencies should still be present:\n%s", out)
+	}
+
+	stdout.Reset()
+	if err := run([]string{"--raw", "--no-calls", "--symbol", "helper", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "callsites[") {
+		t.Errorf("--no-calls should omit the callsites table:\n%s", stdout.String())
+	}
+}
+
+func TestRunExternals(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "main.py", `import json
+
+def greet():
+    print("hi")
+    print(json.dumps({}))
+`)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if strings.Contains(stdout.String(), "externals[") {
+		t.Errorf("externals table should be opt-in:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	if err := run([]string{"--raw", "--externals", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "externals[") || !strings.Contains(stdout.String(), "  print,2") {
+		t.Errorf("expected print,2 in externals table:\n%s", stdout.String())
+	}
+}
+
+func TestRunExternalsGo(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "main.go", `package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+func main() {
+	_ = errors.New("x")
+	_ = http.ListenAndServe(":8080", nil)
+}
+`)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", "--externals", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "  New,1") {
+		t.Errorf("expected New,1 in externals table:\n%s", out)
+	}
+	// Import paths are not symbols; quoted, they would show up as "\"errors\"".
+	if _, table, _ := strings.Cut(out, "externals["); strings.Contains(table, `"`) {
+		t.Errorf("externals table lists quoted import paths:\n%s", out)
+	}
+}
+
+func TestRunIncludeRefs(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", "--include-refs", "--file", "main.py", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "refs[1]{file,name,line}:\n  main.py,User,1") {
+		t.Errorf("expected main.py's import in refs table:\n%s", out)
+	}
+
+	stdout.Reset()
+	if err := run([]string{"--raw", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if strings.Contains(stdout.String(), "refs[") {
+		t.Errorf("refs table should be opt-in:\n%s", stdout.String())
+	}
+}
+
+func TestRunSymbolRegex(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", "--symbol-regex", "^gr.*t$", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "main.py,greet,function") {
+		t.Errorf("expected greet to match:\n%s", stdout.String())
+	}
+}
+
+func TestRunSymbolRegexInvalid(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr bytes.Buffer
+	// The root doesn't exist: the regex error must come first.
+	err := run([]string{"--symbol-regex", "(", "/nonexistent"}, nil, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "invalid --symbol-regex") {
+		t.Errorf("expected invalid regex error, got %v", err)
+	}
+
+	err = run([]string{"--symbol", "a", "--symbol-regex", "b", "."}, nil, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("expected mutual exclusion error, got %v", err)
+	}
+}
+
+func TestRunOutputFile(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	outPath := filepath.Join(t.TempDir(), "nested", "map.toon")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"-o", outPath, dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("stdout should stay clean with --output, got:\n%s", stdout.String())
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if !strings.Contains(string(data), "# Repository Map") || !strings.Contains(string(data), "models.py") {
+		t.Errorf("output file missing header or map:\n%s", data)
+	}
+
+	// Re-running with --raw overwrites the file.
+	if err := run([]string{"--raw", "--output", outPath, dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	data, err = os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "repo:") {
+		t.Errorf("--raw output should start with repo:, got:\n%s", data)
+	}
+}
+
+func TestRunOutputFileNotWrittenOnError(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	outPath := filepath.Join(t.TempDir(), "map.toon")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"-o", outPath, dir}, nil, &stdout, &stderr); err == nil {
+		t.Fatal("expected error for empty repo")
+	}
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Errorf("output file should not be created on error, stat err = %v", err)
+	}
+}
+
+func TestRunCallersOf(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "util.py", `def helper():
+    pass
+
+def unrelated():
+    pass
+`)
+	writeTestFile(t, dir, "main.py", `from util import helper
+
+def greet():
+    helper()
+
+def other():
+    unrelated()
+`)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", "--callers-of", "helper", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "greet,helper,main.py,4,call") {
+		t.Errorf("missing call site for greet→helper:\n%s", out)
+	}
+	if strings.Contains(out, "unrelated") || strings.Contains(out, "<import>") {
+		t.Errorf("--callers-of should show only calls to helper:\n%s", out)
+	}
+
+	stdout.Reset()
+	if err := run([]string{"--raw", "--callees-of", "other", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out = stdout.String()
+	if !strings.Contains(out, "util.py,unrelated,function") || strings.Contains(out, "greet") {
+		t.Errorf("--callees-of other should show only unrelated:\n%s", out)
+	}
+}
+
+func TestRunSince(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := createSampleRepo(t)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+	git("checkout", "-q", "-b", "feature")
+	writeTestFile(t, dir, "models.py", `class User:
+    def __init__(self, name: str, email: str) -> None:
+        self.name = name
+`)
+	git("commit", "-q", "-am", "change User")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", "--since", "main", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "files[1]") || !strings.Contains(out, "models.py") {
+		t.Errorf("--since should map only the changed file:\n%s", out)
+	}
+
+	stdout.Reset()
+	if err := run([]string{"--raw", "--since", "main", "--neighbors", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out = stdout.String()
+	if !strings.Contains(out, "main.py") || !strings.Contains(out, "models.py") {
+		t.Errorf("--neighbors should add files that use the changed file:\n%s", out)
+	}
+
+	err := run([]string{"--since", "main", createSampleRepo(t)}, nil, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "not inside a git repository") {
+		t.Errorf("expected non-git error, got %v", err)
+	}
+	if err := run([]string{"--neighbors", dir}, nil, &stdout, &stderr); err == nil {
+		t.Error("expected error for --neighbors without --since")
+	}
+}
+
+func TestRunTestGlob(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	writeTestFile(t, dir, "checks/smoke.py", `def smoke():
+    pass
+`)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", "--test-glob", "checks/**", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "smoke") {
+		t.Errorf("--test-glob match should be excluded as a test file:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	if err := run([]string{"--raw", "--with-tests", "--test-glob", "checks/**", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "smoke") {
+		t.Errorf("--with-tests should include --test-glob matches:\n%s", stdout.String())
+	}
+}
+
+func TestRunFormatYAML(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--format", "yaml", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.HasPrefix(out, "repo: ") {
+		t.Errorf("YAML output should start with repo: and have no header:\n%s", out)
+	}
+	for _, want := range []string{"\nfiles:\n  - path: ", "\nsymbols:\n", "    symbols: [User]\n", "\ncalls:"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("YAML output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunConfigFile(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	writeTestFile(t, dir, "gen/api.py", `def generated():
+    pass
+`)
+	writeTestFile(t, dir, ".repoguide.toml", `exclude = ["gen/**"]
+max_files = 1
+`)
+
+	// Config values apply when no flag is given.
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "files[1]") || strings.Contains(out, "generated") {
+		t.Errorf("expected config exclude and max_files to apply:\n%s", out)
+	}
+
+	// Explicit flags override the file.
+	stdout.Reset()
+	if err := run([]string{"--raw", "-n", "5", "--exclude", "none/**", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out = stdout.String()
+	if !strings.Contains(out, "files[3]") || !strings.Contains(out, "generated") {
+		t.Errorf("expected flags to override config:\n%s", out)
+	}
+}
+
+func TestRunConfigFileInvalid(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	writeTestFile(t, dir, ".repoguide.yaml", "max_filez: 3\n")
+
+	err := run([]string{dir}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), `.repoguide.yaml: line 1: unknown key "max_filez"`) {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+}
+
+func TestRunVersion(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"-V"}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "repoguide") {
+		t.Errorf("version output: %q", stdout.String())
+	}
+}
+
+func TestRunNoFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "readme.txt", "nothing here")
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{dir}, nil, &stdout, &stderr)
+	if err == nil {
+		t.Fatal("expected error for no parseable files")
+	}
+	if !strings.Contains(err.Error(), "no parseable files") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got := exitCode(err); got != exitNoFiles {
+		t.Errorf("exitCode = %d, want %d", got, exitNoFiles)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	writeTestFile(t, dir, "main_test.go", "package main\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"map written", []string{dir}, exitOK},
+		{"help", []string{"--help"}, exitOK},
+		{"bad flag", []string{"--no-such-flag"}, exitError},
+		{"bad path", []string{filepath.Join(dir, "missing")}, exitError},
+		{"all excluded", []string{"--exclude", "*.go", dir}, exitNoFiles},
+		{"only tests", []string{"-l", "go", "--exclude", "main.go", dir}, exitNoFiles},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var stdout, stderr bytes.Buffer
+			err := run(tt.args, nil, &stdout, &stderr)
+			if got := exitCode(err); got != tt.want {
+				t.Errorf("exitCode = %d, want %d (err: %v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRunUnsupportedLanguage(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"-l", "rust", t.TempDir()}, nil, &stdout, &stderr)
+	if err == nil {
+		t.Fatal("expected error for unsupported language")
+	}
+	if !strings.Contains(err.Error(), "unsupported language") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRunCache(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	cachePath := filepath.Join(t.TempDir(), "test.cache")
+
+	var stdout1, stderr1 bytes.Buffer
+	err := run([]string{"--cache", cachePath, dir}, nil, &stdout1, &stderr1)
+	if err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+
+	// Cache file should exist
+	if _, err := os.Stat(cachePath); err != nil {
+		t.Fatalf("cache not created: %v", err)
+	}
+
+	// Cache file should contain raw TOON (no header)
+	cacheData, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("reading cache: %v", err)
+	}
+	if strings.Contains(string(cacheData), "# Repository Map") {
+		t.Error("cache file should not contain agent header")
+	}
+
+	// Second run should use cache and include header
+	var stdout2, stderr2 bytes.Buffer
+	err = run([]string{"--cache", cachePath, dir}, nil, &stdout2, &stderr2)
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+
+	if stdout1.String() != stdout2.String() {
+		t.Errorf("cache mismatch:\nfirst:\n%s\nsecond:\n%s", stdout1.String(), stdout2.String())
+	}
+
+	if !strings.Contains(stdout2.String(), "# Repository Map") {
+		t.Error("cached output should include agent header")
+	}
+}
+
+func TestRunCacheRaw(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	cachePath := filepath.Join(t.TempDir(), "test.cache")
+
+	// First run populates cache (with header in output)
+	var stdout1, stderr1 bytes.Buffer
+	err := run([]string{"--cache", cachePath, dir}, nil, &stdout1, &stderr1)
+	if err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+
+	// Second run with --raw should use cache but suppress header
+	var stdout2, stderr2 bytes.Buffer
+	err = run([]string{"--raw", "--cache", cachePath, dir}, nil, &stdout2, &stderr2)
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+
+	out := stdout2.String()
+	if strings.Contains(out, "# Repository Map") {
+		t.Error("--raw with cache should suppress agent header")
+	}
+	if !strings.HasPrefix(out, "repo:") {
+		t.Errorf("--raw cached output should start with repo:, got:\n%s", out)
+	}
+}
+
+func TestRunSymbols(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	out := stdout.String()
+	// Check for class definition
+	if !strings.Contains(out, "User,class") {
+		t.Error("missing User class definition")
+	}
+	// Check for method
+	if !strings.Contains(out, "User.__init__,method") {
+		t.Error("missing User.__init__ method")
+	}
+	// Check for function
+	if !strings.Contains(out, "greet,function") {
+		t.Error("missing greet function")
+	}
+}
+
+func TestRunDependencies(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	out := stdout.String()
+	// main.py references User from models.py
+	if !strings.Contains(out, "main.py,models.py,User") {
+		t.Errorf("missing dependency main.py → models.py:\n%s", out)
+	}
+}
+
+func TestRunNotADirectory(t *testing.T) {
+	t.Parallel()
+	f := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(f, []byte("hi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{f}, nil, &stdout, &stderr)
+	if err == nil {
+		t.Fatal("expected error for non-directory")
+	}
+}
+
+func TestRunArchive(t *testing.T) {
+	t.Parallel()
+	archive := filepath.Join(t.TempDir(), "sample-1.0.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"sample-1.0/models.py": "class User:\n    pass\n",
+		"sample-1.0/main.py":   "from models import User\n\ndef greet(user: User) -> str:\n    return user.name\n",
+		"sample-1.0/big.py":    strings.Repeat("x = 1\n", 200),
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--archive", archive, "--max-file-size", "100", "--raw"}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out := stdout.String()
+	for _, want := range []string{"repo: sample-1.0", "main.py,models.py", "User"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "big.py") {
+		t.Error("big.py should be filtered out by --max-file-size")
+	}
+	if !strings.Contains(stderr.String(), "big.py: skipped") {
+		t.Errorf("expected size warning, got %q", stderr.String())
+	}
+
+	for _, args := range [][]string{
+		{"--archive", archive, "--cache", filepath.Join(t.TempDir(), "c")},
+		{"--archive", archive, t.TempDir()},
+		{"--archive", filepath.Join(t.TempDir(), "src.rar")},
+	} {
+		if err := run(args, nil, &stdout, &stderr); err == nil {
+			t.Errorf("run %v: expected error", args)
+		}
+	}
+}
+
+func TestRunMaxDepth(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	writeTestFile(t, dir, "vendor_sub/a/b/deep.py", "def deep():\n    pass\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--max-depth", "2", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if strings.Contains(stdout.String(), "deep.py") {
+		t.Errorf("--max-depth 2 should exclude a file 4 levels down:\n%s", stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "models.py") {
+		t.Errorf("missing top-level models.py:\n%s", stdout.String())
+	}
+
+	if err := run([]string{"--max-depth", "-1", dir}, nil, &stdout, &stderr); err == nil {
+		t.Error("expected error for negative --max-depth")
+	}
+}
+
+func TestRunMaxFileSize(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "small.py", "x = 1")
+	writeTestFile(t, dir, "big.py", strings.Repeat("x = 1\n", 200))
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--max-file-size", "100", dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	out := stdout.String()
+	if !strings.Contains(out, "small.py") {
+		t.Error("missing small.py")
+	}
+	if strings.Contains(out, "big.py") {
+		t.Error("big.py should be filtered out")
+	}
+	if !strings.Contains(stderr.String(), "Warning") {
+		t.Error("expected warning about skipped file")
+	}
+}
+
+func TestRunSkipsBinaryFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "main.py", "def main():\n    pass\n")
+	writeTestFile(t, dir, "model.py", "\x80\x04\x95\x1a\x00\x00\x00\x00\x00\x00\x00}\x94(\x8c\x01a\x94K\x01u.")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if out := stdout.String(); !strings.Contains(out, "files[1]") || strings.Contains(out, "model.py") {
+		t.Errorf("model.py should be skipped:\n%s", out)
+	}
+	if !strings.Contains(stderr.String(), "model.py: skipped (binary content)") {
+		t.Errorf("expected a warning about model.py, got: %s", stderr.String())
+	}
+}
+
+func TestRunCalls(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "utils.py", `def helper():
+    pass
+`)
+	writeTestFile(t, dir, "main.py", `def greet():
+    helper()
+`)
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+
+	out := stdout.String()
+	if !strings.Contains(out, "calls[") {
+		t.Errorf("missing calls section:\n%s", out)
+	}
+	if !strings.Contains(out, "greet,helper") {
+		t.Errorf("missing greet→helper call edge:\n%s", out)
+	}
+}
+
+func TestRunSymbolFilter(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "utils.py", `def helper():
+    pass
+`)
+	writeTestFile(t, dir, "main.py", `def greet():
+    helper()
+`)
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--symbol", "helper", dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+
+	out := stdout.String()
+	if !strings.Contains(out, "utils.py") {
+		t.Errorf("utils.py (defines helper) should be in output:\n%s", out)
+	}
+	// greet calls helper, so main.py should also be included via call expansion.
+	if !strings.Contains(out, "main.py") {
+		t.Errorf("main.py (defines greet which calls helper) should be in output:\n%s", out)
+	}
+	if !strings.Contains(out, "helper,function") {
+		t.Errorf("helper definition should appear in symbols:\n%s", out)
+	}
+}
+
+func TestRunSymbolFilterMultiple(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.py", "def alpha():\n    pass\n")
+	writeTestFile(t, dir, "b.py", "def beta():\n    pass\n")
+	writeTestFile(t, dir, "c.py", "def gamma():\n    pass\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--symbol", "alpha, gamma", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "alpha") || !strings.Contains(out, "gamma") {
+		t.Errorf("expected both alpha and gamma:\n%s", out)
+	}
+	if strings.Contains(out, "beta") {
+		t.Errorf("beta should not match:\n%s", out)
+	}
+
+	if err := run([]string{"--symbol", " , ", dir}, nil, &stdout, &stderr); err == nil {
+		t.Error("expected error for an empty --symbol list")
+	}
+}
+
+func TestRunSymbolFilterNoMatch(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "main.py", "def greet():\n    pass\n")
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--symbol", "NoSuchSymbol", dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	out := stdout.String()
+	if !strings.Contains(out, "files[0]") {
+		t.Errorf("expected empty files table:\n%s", out)
+	}
+}
+
+func TestRunFileFilter(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "utils.py", "def helper():\n    pass\n")
+	writeTestFile(t, dir, "main.py", "def greet():\n    helper()\n")
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--file", "utils", dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+
+	out := stdout.String()
+	if !strings.Contains(out, "utils.py") {
+		t.Errorf("utils.py should be in output:\n%s", out)
+	}
+	if strings.Contains(out, "main.py") && !strings.Contains(out, "dependencies") {
+		// main.py only appears if it's in a dep edge, not as a file row
+		t.Errorf("main.py should not appear as a file:\n%s", out)
+	}
+	if !strings.Contains(out, "helper,function") {
+		t.Errorf("helper definition should appear:\n%s", out)
+	}
+}
+
+func TestRunSymbolAndFileFilter(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "pkg/utils.py", "def helper():\n    pass\n")
+	writeTestFile(t, dir, "other/utils.py", "def other_helper():\n    pass\n")
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--symbol", "helper", "--file", "pkg", dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+
+	out := stdout.String()
+	// --file pkg filters to pkg/utils.py; --symbol helper must be in that file.
+	if !strings.Contains(out, "pkg/utils.py") {
+		t.Errorf("pkg/utils.py should be in output:\n%s", out)
+	}
+}
+
+func TestRunCacheIncremental(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	cachePath := filepath.Join(t.TempDir(), "test.cache")
+
+	if err := run([]string{"--raw", "--cache", cachePath, dir}, nil, &bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+
+	// Editing one file must invalidate the cached map and show the change.
+	writeTestFile(t, dir, "models.py", `class User:
+    def __init__(self, name: str) -> None:
+        self.name = name
+
+def load_user(name: str) -> User:
+    return User(name)
+`)
+	var stdout bytes.Buffer
+	if err := run([]string{"--raw", "--cache", cachePath, dir}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "load_user") {
+		t.Errorf("edited file not re-parsed:\n%s", stdout.String())
+	}
+
+	c := cache.Load(cachePath, cacheVersion())
+	if len(c.Files) != 2 {
+		t.Fatalf("expected 2 cached files, got %d", len(c.Files))
+	}
+	if c.Output != strings.TrimRight(stdout.String(), "\n") {
+		t.Errorf("cached output doesn't match printed map")
+	}
+}
+
+func TestRunCacheVersionMismatch(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+
+	if err := run([]string{"--cache", cachePath, dir}, nil, &bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	// Pretend an older release wrote the cache; its map must not be served.
+	c := cache.Load(cachePath, cacheVersion())
+	c.Version, c.Output = "1/v0.1.0", "stale-sentinel"
+	if err := c.Save(cachePath); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	if err := run([]string{"--raw", "--cache", cachePath, dir}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if strings.Contains(stdout.String(), "stale-sentinel") {
+		t.Errorf("a cache from another version was replayed:\n%s", stdout.String())
+	}
+	if c := cache.Load(cachePath, cacheVersion()); c.Version != cacheVersion() || c.Output == "" {
+		t.Errorf("cache not rewritten for this version: version %q", c.Version)
+	}
+}
+
+// createRankRepo returns a repo whose ranking depends on the ranking flags:
+// main.py imports three classes from models.py but calls util.py's helper
+// three times.
+func createRankRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "util.py", "def helper():\n    pass\n")
+	writeTestFile(t, dir, "models.py", "class User:\n    pass\n\nclass Account:\n    pass\n\nclass Order:\n    pass\n")
+	writeTestFile(t, dir, "main.py", `from models import User, Account, Order
+from util import helper
+
+def run():
+    helper()
+    helper()
+    helper()
+    return User
+`)
+	return dir
+}
+
+// checkCacheBypassed fails unless a run with args prints the same with a warm
+// cache as without one, and leaves the cached default map in place.
+func checkCacheBypassed(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	runMap := func(args ...string) (string, string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		if err := run(append([]string{"--raw"}, args...), nil, &stdout, &stderr); err != nil {
+			t.Fatalf("run %v: %v\nstderr: %s", args, err, stderr.String())
+		}
+		return stdout.String(), stderr.String()
+	}
+	withArgs := func(extra ...string) []string {
+		return append(append(append([]string{}, args...), extra...), dir)
+	}
+
+	def, _ := runMap("--cache", cachePath, dir)
+	want, wantErr := runMap(withArgs()...)
+	if want == def {
+		t.Fatalf("%v should change the map:\n%s", args, want)
+	}
+	got, gotErr := runMap(withArgs("--cache", cachePath)...)
+	if got != want || gotErr != wantErr {
+		t.Errorf("%v with a warm cache:\n%s%s\nwant:\n%s%s", args, gotErr, got, wantErr, want)
+	}
+	if after, _ := runMap("--cache", cachePath, dir); after != def {
+		t.Errorf("%v overwrote the cached default map:\n%s", args, after)
+	}
+}
+
+func TestRunCacheBypassRankByCalls(t *testing.T) {
+	t.Parallel()
+	checkCacheBypassed(t, createRankRepo(t), "--rank-by", "calls")
+}
+
+func TestRunCacheBypassMinRank(t *testing.T) {
+	t.Parallel()
+	checkCacheBypassed(t, createRankRepo(t), "--min-rank", "0.3")
+}
+
+func TestRunCacheBypassMaxTokens(t *testing.T) {
+	t.Parallel()
+	checkCacheBypassed(t, createRankRepo(t), "--max-tokens", "60")
+}
+
+func TestRunCacheKeyContent(t *testing.T) {
+	t.Parallel()
+
+	// touchAll gives every file a new mtime with the same contents, as a
+	// fresh checkout does.
+	touchAll := func(t *testing.T, dir string) {
+		t.Helper()
+		later := time.Now().Add(time.Hour)
+		for _, name := range []string{"main.py", "models.py"} {
+			if err := os.Chtimes(filepath.Join(dir, name), later, later); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// markOutput replaces the cached map with a sentinel, so a replayed
+	// cache is recognizable.
+	markOutput := func(t *testing.T, cachePath string) {
+		t.Helper()
+		c := cache.Load(cachePath, cacheVersion())
+		c.Output = "cached-sentinel"
+		if err := c.Save(cachePath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		key        string
+		wantReplay bool
+	}{
+		{"content", true},
+		{"mtime", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Parallel()
+			dir := createSampleRepo(t)
+			cachePath := filepath.Join(t.TempDir(), "test.cache")
+			args := []string{"--raw", "--cache", cachePath, "--cache-key", tt.key, dir}
+			if err := run(args, nil, &bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+				t.Fatalf("first run: %v", err)
+			}
+			markOutput(t, cachePath)
+			touchAll(t, dir)
+
+			var stdout bytes.Buffer
+			if err := run(args, nil, &stdout, &bytes.Buffer{}); err != nil {
+				t.Fatalf("second run: %v", err)
+			}
+			if got := stdout.String() == "cached-sentinel\n"; got != tt.wantReplay {
+				t.Errorf("replayed cached map = %v, want %v:\n%s", got, tt.wantReplay, stdout.String())
+			}
+		})
+	}
+
+	if err := run([]string{"--cache-key", "sha", createSampleRepo(t)}, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Error("expected error for unsupported --cache-key")
+	}
+}
+
+func TestParseFilesCachedReusesEntries(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	files := []discover.FileEntry{
+		{Path: "main.py", Language: "python"},
+		{Path: "models.py", Language: "python"},
+	}
+	stamps := stampFiles(dir, files, false)
+
+	// A cached entry with a matching stamp is used as-is, without parsing.
+	prev := &cache.Cache{Files: map[string]cache.Entry{
+		"models.py": {
+			Stamp:    stamps["models.py"],
+			Language: "python",
+			Tags:     []model.Tag{{Name: "Cached", Kind: model.Definition, SymbolKind: model.Class, Line: 1, File: "models.py"}},
+		},
+	}}
+	got := parseFilesCached(context.Background(), dir, files, prev, stamps, &bytes.Buffer{})
+	if len(got) != 2 || got[0].Path != "main.py" || got[1].Path != "models.py" {
+		t.Fatalf("unexpected files: %+v", got)
+	}
+	if len(got[1].Tags) != 1 || got[1].Tags[0].Name != "Cached" {
+		t.Errorf("expected cached tags for models.py, got %+v", got[1].Tags)
+	}
+	if len(got[0].Tags) == 0 {
+		t.Error("main.py should have been parsed")
+	}
+
+	// A stale stamp forces a re-parse.
+	stale := stamps["models.py"]
+	stale.Size++
+	prev.Files["models.py"] = cache.Entry{Stamp: stale, Language: "python", Tags: prev.Files["models.py"].Tags}
+	got = parseFilesCached(context.Background(), dir, files, prev, stamps, &bytes.Buffer{})
+	for _, tag := range got[1].Tags {
+		if tag.Name == "Cached" {
+			t.Error("stale cache entry should not be used")
+		}
+	}
+}
+
+func TestParseFilesCachedCanceled(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	files := []discover.FileEntry{
+		{Path: "main.py", Language: "python"},
+		{Path: "models.py", Language: "python"},
+	}
+	stamps := stampFiles(dir, files, false)
+	prev := &cache.Cache{Files: map[string]cache.Entry{
+		"models.py": {Stamp: stamps["models.py"], Language: "python"},
+	}}
+
+	// Once the context is done nothing more is parsed, but cached results
+	// still make it into the partial map.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got := parseFilesCached(ctx, dir, files, prev, stamps, &bytes.Buffer{})
+	if len(got) != 1 || got[0].Path != "models.py" {
+		t.Errorf("got %+v, want only the cached models.py", got)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", "--timeout", "1h", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if strings.Contains(stderr.String(), "--timeout") || !strings.Contains(stdout.String(), "files[2]") {
+		t.Errorf("a generous timeout should not cut the map short:\nstdout: %s\nstderr: %s", stdout.String(), stderr.String())
+	}
+
+	stderr.Reset()
+	err := run([]string{"--timeout", "1ns", dir}, nil, &bytes.Buffer{}, &stderr)
+	if !errors.Is(err, errNoFiles) || !strings.Contains(stderr.String(), "Warning: --timeout 1ns reached") {
+		t.Errorf("expired timeout: err %v, stderr %q", err, stderr.String())
+	}
+
+	if err := run([]string{"--timeout", "-1s", dir}, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for a negative timeout")
+	}
+}
+
+func TestRunSymbolFilterCacheSkipped(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "main.py", "def greet():\n    pass\n")
+	cachePath := filepath.Join(t.TempDir(), "cache.toon")
+
+	// First run: no filter, write cache.
+	var stdout1 bytes.Buffer
+	if err := run([]string{"--cache", cachePath, dir}, nil, &stdout1, &bytes.Buffer{}); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if _, err := os.Stat(cachePath); err != nil {
+		t.Fatalf("cache not written: %v", err)
+	}
+
+	// Second run: with --symbol filter. Cache should be bypassed (filter still works).
+	var stdout2 bytes.Buffer
+	if err := run([]string{"--symbol", "greet", "--cache", cachePath, dir}, nil, &stdout2, &bytes.Buffer{}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if !strings.Contains(stdout2.String(), "greet") {
+		t.Errorf("filter should work even when cache exists:\n%s", stdout2.String())
+	}
+}
+
+func TestRunSymbolFilterCallSites(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "utils.py", "def helper():\n    pass\n")
+	writeTestFile(t, dir, "main.py", "def greet():\n    helper()\n    helper()\n")
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--symbol", "helper", dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+
+	out := stdout.String()
+	if !strings.Contains(out, "callsites") {
+		t.Errorf("--symbol output should include callsites table:\n%s", out)
+	}
+}
+
+func TestRunFullMapNoCallSites(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
 	writeTestFile(t, dir, "utils.py", "def helper():\n    pass\n")
 	writeTestFile(t, dir, "main.py", "def greet():\n    helper()\n")
 
 	var stdout, stderr bytes.Buffer
-	err := run([]string{dir}, &stdout, &stderr)
-	if err != nil {
+	err := run([]string{dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+
+	if strings.Contains(stdout.String(), "callsites[") {
+		t.Errorf("full map output should not include callsites table:\n%s", stdout.String())
+	}
+}
+
+func TestRunFormatJSON(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--format", "json", dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+
+	var doc struct {
+		Repo  string `json:"repo"`
+		Files []struct {
+			Path string `json:"path"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, stdout.String())
+	}
+	if len(doc.Files) != 2 {
+		t.Errorf("expected 2 files, got %+v", doc.Files)
+	}
+}
+
+func TestRunFormatMermaid(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "utils.py", "def helper():\n    pass\n")
+	writeTestFile(t, dir, "main.py", "def greet():\n    helper()\n\ndef other():\n    pass\n")
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--format", "mermaid", "--symbol", "helper", dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.HasPrefix(out, "graph LR\n") {
+		t.Errorf("expected Mermaid graph, got:\n%s", out)
+	}
+	if !strings.Contains(out, "greet --> helper") {
+		t.Errorf("missing greet → helper edge:\n%s", out)
+	}
+}
+
+func TestRunFormatTree(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "pkg/utils.py", "def helper():\n    pass\n")
+	writeTestFile(t, dir, "main.py", "from pkg.utils import helper\n\ndef greet():\n    helper()\n")
+	writeTestFile(t, dir, "lib.go", "package lib\n\nfunc Lib() {}\n")
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--format", "tree", "-l", "python", dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.HasPrefix(out, filepath.Base(dir)+"/\n  pkg/\n    utils.py (") {
+		t.Errorf("expected the imported file's directory first, got:\n%s", out)
+	}
+	if !strings.Contains(out, "\n  main.py (") {
+		t.Errorf("missing main.py:\n%s", out)
+	}
+	if strings.Contains(out, "lib.go") {
+		t.Errorf("-l python should exclude lib.go:\n%s", out)
+	}
+}
+
+func TestRunFormatMarkdown(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	for i := range 35 {
+		writeTestFile(t, dir, fmt.Sprintf("extra%02d.py", i), "x = 1\n")
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--format", "markdown", "-n", "0", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"## Top Files by Centrality", "### `models.py`", "- **User** (class, line 1): `User`", "- `main.py` → `models.py`: User"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Repository Map") {
+		t.Error("markdown output should not include the agent context header")
+	}
+	if rows := strings.Count(out, "\n| "); rows != 1+37 {
+		t.Errorf("-n 0: got %d table rows, want header plus 37 files", rows)
+	}
+
+	stdout.Reset()
+	if err := run([]string{"--format", "markdown", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if rows := strings.Count(stdout.String(), "\n| "); rows != 1+defaultMarkdownFiles {
+		t.Errorf("got %d table rows, want header plus %d files", rows, defaultMarkdownFiles)
+	}
+}
+
+func TestRunFormatSymbolsJSON(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--format", "symbols-json", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	var symbols []struct {
+		Name string `json:"name"`
+		File string `json:"file"`
+		Line int    `json:"line"`
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &symbols); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout.String())
+	}
+	found := false
+	for _, s := range symbols {
+		if s.Name == "User.__init__" && s.File == "models.py" && s.Line == 2 && s.Kind == "method" {
+			found = true
+		}
+		if s.Name == "User" && s.Kind != "class" {
+			t.Errorf("User kind = %q, want class", s.Kind)
+		}
+	}
+	if !found {
+		t.Errorf("missing qualified method User.__init__: %s", stdout.String())
+	}
+}
+
+func TestRunFormatJSONL(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--format", "jsonl", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	var paths []string
+	for _, line := range lines {
+		var file struct {
+			Path     string `json:"path"`
+			Language string `json:"language"`
+			Tags     []struct {
+				Name string `json:"name"`
+			} `json:"tags"`
+		}
+		if err := json.Unmarshal([]byte(line), &file); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		if file.Language == "" || file.Tags == nil {
+			t.Errorf("incomplete line: %s", line)
+		}
+		paths = append(paths, file.Path)
+	}
+	if !slices.IsSorted(paths) || !slices.Contains(paths, "models.py") {
+		t.Errorf("paths = %v, want every file in path order", paths)
+	}
+	if strings.Contains(stdout.String(), `"rank"`) {
+		t.Errorf("JSON Lines output should be unranked:\n%s", stdout.String())
+	}
+}
+
+func TestRunFormatUnsupported(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--format", "xml", t.TempDir()}, nil, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "unsupported format") {
+		t.Errorf("expected unsupported format error, got %v", err)
+	}
+}
+
+func TestRunStdin(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	writeTestFile(t, dir, "other.py", "def unrelated():\n    pass\n")
+
+	var stdout, stderr bytes.Buffer
+	stdin := strings.NewReader("main.py\nmodels.py\nnotes.txt\n")
+	err := run([]string{"--stdin", dir}, stdin, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+
+	out := stdout.String()
+	if !strings.Contains(out, "files[2]") {
+		t.Errorf("expected only the 2 listed files, got:\n%s", out)
+	}
+	if strings.Contains(out, "other.py") {
+		t.Errorf("unlisted file should not appear:\n%s", out)
+	}
+	if !strings.Contains(stderr.String(), "notes.txt") {
+		t.Errorf("expected warning for unsupported file, got:\n%s", stderr.String())
+	}
+}
+
+func TestRunExclude(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "main.py", "from gen.api import Message\n\ndef run():\n    Message()\n")
+	writeTestFile(t, dir, "gen/api.py", "class Message:\n    pass\n")
+	writeTestFile(t, dir, "gen/other.py", "class Other:\n    pass\n")
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--exclude", "gen/**", dir}, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+
+	out := stdout.String()
+	if strings.Contains(out, "gen/") {
+		t.Errorf("excluded files should not appear as files or dependency targets:\n%s", out)
+	}
+	if !strings.Contains(out, "files[1]") {
+		t.Errorf("expected 1 file, got:\n%s", out)
+	}
+}
+
+func TestRunExcludeWithTests(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "app.py", "def run():\n    pass\n")
+	writeTestFile(t, dir, "spec/app_spec.py", "from app import run\n\ndef check_run():\n    run()\n")
+	writeTestFile(t, dir, "vendor_tests/fixture_test.py", "def fixture():\n    pass\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", "--with-tests", "--exclude", "vendor_tests/**", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "spec/app_spec.py,") {
+		t.Errorf("--with-tests should include spec/app_spec.py:\n%s", out)
+	}
+	if strings.Contains(out, "vendor_tests/") {
+		t.Errorf("--exclude should drop test files even with --with-tests:\n%s", out)
+	}
+}
+
+func TestRunExcludeBadPattern(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--exclude", "[", t.TempDir()}, nil, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+		t.Errorf("expected invalid pattern error, got %v", err)
+	}
+}
+
+func TestRunRankByCalls(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--rank-by", "calls", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "files[2]") {
+		t.Errorf("expected 2 files, got:\n%s", stdout.String())
+	}
+
+	err := run([]string{"--rank-by", "stars", dir}, nil, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "--rank-by") {
+		t.Errorf("expected --rank-by error, got %v", err)
+	}
+}
+
+func createCycleRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.py", "from b import b_func\n\ndef a_func():\n    b_func()\n")
+	writeTestFile(t, dir, "b.py", "from c import c_func\n\ndef b_func():\n    c_func()\n")
+	writeTestFile(t, dir, "c.py", "from a import a_func\n\ndef c_func():\n    a_func()\n")
+	return dir
+}
+
+func TestRunCycles(t *testing.T) {
+	t.Parallel()
+	dir := createCycleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", dir}, nil, &stdout, &stderr); err != nil {
 		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
 	}
+	if !strings.Contains(stdout.String(), "cycles[1]{files}:\n  a.py b.py c.py") {
+		t.Errorf("missing cycles table:\n%s", stdout.String())
+	}
+}
 
-	if strings.Contains(stdout.String(), "callsites[") {
-		t.Errorf("full map output should not include callsites table:\n%s", stdout.String())
+func TestRunCyclesOnly(t *testing.T) {
+	t.Parallel()
+	dir := createCycleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--cycles-only", dir}, nil, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "1 import cycle") {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+	if got := stdout.String(); got != "cycles[1]{files}:\n  a.py b.py c.py\n" {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+
+	// An acyclic repo passes.
+	stdout.Reset()
+	if err := run([]string{"--cycles-only", createSampleRepo(t)}, nil, &stdout, &stderr); err != nil {
+		t.Errorf("acyclic repo: %v", err)
+	}
+	if got := stdout.String(); got != "cycles[0]{files}:\n" {
+		t.Errorf("unexpected output:\n%s", got)
 	}
 }
 
@@ -478,6 +2253,8 @@ func TestReorderArgs(t *testing.T) {
 		{"no flags", []string{"."}, []string{"."}},
 		{"no args", nil, nil},
 		{"bool flag", []string{"-V"}, []string{"-V"}},
+		{"output-dir after path", []string{".", "--output-dir", "od", "--raw"}, []string{"--output-dir", "od", "--raw", "."}},
+		{"sort-symbols after path", []string{".", "--sort-symbols", "name", "--raw"}, []string{"--sort-symbols", "name", "--raw", "."}},
 	}
 
 	for _, tt := range tests {
@@ -496,3 +2273,504 @@ func TestReorderArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestRunOnlyExported(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "api.go", "package api\n\nfunc Serve() { listen() }\n\nfunc listen() {}\n")
+	writeTestFile(t, dir, "util.py", "def public_helper():\n    pass\n\ndef _private_helper():\n    pass\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--only-exported", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out := stdout.String()
+	for _, want := range []string{"api.go,Serve,", "util.py,public_helper,"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected symbol row %q:\n%s", want, out)
+		}
+	}
+	// Only the symbols table is filtered; the Serve -> listen call edge stays.
+	for _, hidden := range []string{"api.go,listen,", "util.py,_private_helper,"} {
+		if strings.Contains(out, hidden) {
+			t.Errorf("symbol row %q should be filtered out:\n%s", hidden, out)
+		}
+	}
+}
+
+func TestRunTypeScriptReExports(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "store.ts", "export class Store {}\n\nfunction evict(): void {}\n")
+	writeTestFile(t, dir, "index.ts", "export { Store } from './store';\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", "--only-exported", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	// The re-export makes index.ts depend on store.ts.
+	for _, want := range []string{"index.ts,store.ts,Store", "store.ts,Store,class"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "evict") {
+		t.Errorf("the unexported evict should be filtered out:\n%s", out)
+	}
+}
+
+func TestRunSortSymbols(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "core.py", "def zeta():\n    pass\n")
+	writeTestFile(t, dir, "main.py", "from core import zeta\n\ndef alpha():\n    zeta()\n")
+
+	var stdout bytes.Buffer
+	if err := run([]string{"--raw", "--sort-symbols", "name", dir}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out := stdout.String()
+	// core.py outranks main.py, but alpha sorts first.
+	if a, z := strings.Index(out, "main.py,alpha,"), strings.Index(out, "core.py,zeta,"); a < 0 || z < 0 || a > z {
+		t.Errorf("expected alpha before zeta in the symbols table:\n%s", out)
+	}
+	if f := strings.Index(out, "  core.py,python,"); f < 0 || f > strings.Index(out, "  main.py,python,") {
+		t.Errorf("files table should stay in rank order:\n%s", out)
+	}
+
+	for _, args := range [][]string{
+		{"--sort-symbols", "kind", dir},
+		{"--sort-symbols", "name", "--group-symbols", dir},
+	} {
+		if err := run(args, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+func TestRunRubyRequires(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "lib/shop.rb", "require 'shop/config'\nrequire_relative 'shop/boot'\n")
+	writeTestFile(t, dir, "lib/shop/config.rb", "DEFAULTS = {}\n")
+	writeTestFile(t, dir, "lib/shop/boot.rb", "puts 'booting'\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{`lib/shop.rb,lib/shop/boot.rb,""`, `lib/shop.rb,lib/shop/config.rb,""`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing dependency %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunOutputDir(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	out := filepath.Join(t.TempDir(), "map")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--output-dir", out, dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected nothing on stdout, got:\n%s", stdout.String())
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	index := read("index.toon")
+	for _, want := range []string{"# Repository Map", "files,files.toon", "symbols,symbols.toon", "dependencies,dependencies.toon", "calls,calls.toon"} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index.toon missing %q:\n%s", want, index)
+		}
+	}
+	if files := read("files.toon"); !strings.HasPrefix(files, "files[") {
+		t.Errorf("files.toon should hold the files table:\n%s", files)
+	}
+	if symbols := read("symbols.toon"); !strings.HasPrefix(symbols, "symbols[") {
+		t.Errorf("symbols.toon should hold the symbols table:\n%s", symbols)
+	}
+
+	for _, args := range [][]string{
+		{"--output-dir", out, "--format", "json", dir},
+		{"--output-dir", out, "-o", filepath.Join(out, "map.toon"), dir},
+	} {
+		if err := run(args, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+func TestRunImportersOf(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "util.py", "def helper():\n    pass\n\ndef unused():\n    pass\n")
+	writeTestFile(t, dir, "main.py", "from util import helper\n\ndef greet():\n    helper()\n")
+	writeTestFile(t, dir, "other.py", "def standalone():\n    pass\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", "--importers-of", "util.py", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"main.py,util.py,helper", "util.py,helper,function", "greet,helper,main.py,4"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "unused") || strings.Contains(out, "other.py") {
+		t.Errorf("--importers-of should show only imported symbols and importers:\n%s", out)
+	}
+
+	if err := run([]string{"--importers-of", "util.py", "--callers-of", "helper", dir}, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Error("--importers-of with --callers-of: expected an error")
+	}
+}
+
+func TestRunSearch(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "auth.py", `def authenticate_user(name, password):
+    """Check a user's password."""
+    return True
+
+def hash_password(password):
+    return password
+`)
+	writeTestFile(t, dir, "main.py", "from auth import authenticate_user\n\ndef main():\n    authenticate_user('a', 'b')\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"search", "user authentication", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	// hash_password matches only by its path (auth.py), so it comes second.
+	lines := strings.Split(out, "\n")
+	if len(lines) < 4 || lines[0] != "query: user authentication" || lines[1] != "results[2]{score,file,line,kind,name,signature}:" ||
+		!strings.Contains(lines[2], ",auth.py,1,function,authenticate_user,") || !strings.Contains(lines[3], ",hash_password,") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	stdout.Reset()
+	if err := run([]string{"--search", "password", "-n", "1", "--format", "json", dir}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	var doc struct {
+		Results []struct{ Name string }
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout.String())
+	}
+	if len(doc.Results) != 1 || doc.Results[0].Name != "hash_password" {
+		t.Errorf("-n 1 results = %+v, want hash_password only", doc.Results)
+	}
+
+	for _, args := range [][]string{
+		{"search"},
+		{"search", "--raw"},
+		{"--search", "user", "--symbol", "main", dir},
+		{"--search", "user", "--format", "yaml", dir},
+		{"--search", "user", "--max-tokens", "100", dir},
+	} {
+		if err := run(args, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
+
+func TestRunFormatProtobuf(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	out := filepath.Join(t.TempDir(), "map.pb")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--format", "protobuf", "-o", out, dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The whole file is one message: a trailing newline would not parse.
+	for b := data; len(b) > 0; {
+		_, _, n := protowire.ConsumeField(b)
+		if n < 0 {
+			t.Fatalf("invalid protobuf output: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+	var jsonOut bytes.Buffer
+	if err := run([]string{"--format", "json", dir}, nil, &jsonOut, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) >= jsonOut.Len() {
+		t.Errorf("protobuf (%d bytes) should be smaller than JSON (%d bytes)", len(data), jsonOut.Len())
+	}
+	if !bytes.Contains(data, []byte("models.py")) {
+		t.Errorf("protobuf output lacks models.py: %q", data)
+	}
+}
+
+func TestRunExport(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "auth.py", "def authenticate_user(name):\n    return hash_password(name)\n\ndef hash_password(password):\n    return password\n")
+	writeTestFile(t, dir, "main.py", "from auth import authenticate_user\n\ndef main():\n    authenticate_user('a')\n")
+
+	db := filepath.Join(t.TempDir(), "map.db")
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"export", "--sqlite", db, dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	if stdout.Len() != 0 || !strings.Contains(stderr.String(), "Wrote "+db) {
+		t.Errorf("stdout = %q, stderr = %q", stdout.String(), stderr.String())
+	}
+
+	conn, err := sql.Open("sqlite", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	var uncalled string
+	if err := conn.QueryRow(`SELECT s.name FROM symbols s JOIN files f ON f.id = s.file_id
+		WHERE s.name NOT IN (SELECT callee FROM call_edges) ORDER BY s.name`).Scan(&uncalled); err != nil {
+		t.Fatal(err)
+	}
+	if uncalled != "main" {
+		t.Errorf("first uncalled symbol = %q, want main", uncalled)
+	}
+	var file string
+	var line int
+	if err := conn.QueryRow(`SELECT f.path, c.line FROM call_sites c JOIN files f ON f.id = c.file_id
+		WHERE c.callee = 'hash_password'`).Scan(&file, &line); err != nil {
+		t.Fatal(err)
+	}
+	if file != "auth.py" || line != 2 {
+		t.Errorf("hash_password call site = %s:%d, want auth.py:2", file, line)
+	}
+
+	for _, args := range [][]string{
+		{"export"},
+		{"export", dir},
+		{"export", "--sqlite"},
+		{"--format", "sqlite", dir},
+		{"--format", "sqlite", "-o", db, "--cycles-only", dir},
+	} {
+		if err := run(args, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
+
+func TestFindRepoRoot(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "go.mod", "module example.com/app\n")
+	writeTestFile(t, dir, "web/package.json", "{}\n")
+	writeTestFile(t, dir, "web/src/app.js", "")
+	writeTestFile(t, dir, "internal/pkg/deep/x.go", "")
+
+	tests := []struct {
+		start, want string
+	}{
+		{"internal/pkg/deep", "."},
+		{".", "."},
+		{"web/src", "web"}, // the nearest marker wins
+	}
+	for _, tt := range tests {
+		got := findRepoRoot(filepath.Join(dir, tt.start))
+		if want := filepath.Join(dir, tt.want); got != want {
+			t.Errorf("findRepoRoot(%s) = %s, want %s", tt.start, got, want)
+		}
+	}
+}
+
+func TestRunFindRoot(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+	writeTestFile(t, dir, ".repoguide.toml", "")
+	writeTestFile(t, dir, "pkg/util.py", "def helper():\n    pass\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", "--find-root", filepath.Join(dir, "pkg")}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "files[3]") {
+		t.Errorf("--find-root should map the whole project:\n%s", stdout.String())
+	}
+	if want := "Using repo root " + dir; !strings.Contains(stderr.String(), want) {
+		t.Errorf("stderr should name the root (%q):\n%s", want, stderr.String())
+	}
+
+	// Without it, only the subdirectory is mapped.
+	stdout.Reset()
+	if err := run([]string{"--raw", filepath.Join(dir, "pkg")}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "files[1]") {
+		t.Errorf("expected only pkg/util.py:\n%s", stdout.String())
+	}
+}
+
+func TestRunFileAndSymbolGlob(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "internal/srv/handler.go", "package srv\n\nfunc HandleLogin() {}\n\nfunc HandleLogout() {}\n\nfunc serve() {}\n")
+	writeTestFile(t, dir, "internal/srv/util.py", "def helper():\n    pass\n")
+	writeTestFile(t, dir, "cmd/main.go", "package main\n\nfunc main() {}\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--file", "internal/**/*.go", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "handler.go") || strings.Contains(out, "util.py") || strings.Contains(out, "cmd/main.go") {
+		t.Errorf("expected only internal/srv/handler.go:\n%s", out)
+	}
+
+	stdout.Reset()
+	if err := run([]string{"--symbol", "handle*", "--depth", "0", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out = stdout.String()
+	if !strings.Contains(out, "HandleLogin") || !strings.Contains(out, "HandleLogout") {
+		t.Errorf("expected both Handle functions:\n%s", out)
+	}
+
+	if err := run([]string{"--file", "internal/[.go", dir}, nil, &stdout, &stderr); err == nil {
+		t.Error("expected error for a malformed --file glob")
+	}
+}
+
+func TestRunSymbolEnumConstants(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "color.go", "package p\n\ntype Color int\n\nconst (\n\tRed Color = iota\n\tGreen\n\tBlue\n)\n\nconst MaxLen = 10\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--symbol", "Color", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out := stdout.String()
+	for _, want := range []string{"color.go,Red,constant,6,Red Color = iota", "color.go,Green,constant,7,Green Color", "color.go,Blue,constant,8,Blue Color"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "MaxLen") {
+		t.Errorf("untyped constant should not be shown:\n%s", out)
+	}
+}
+
+func TestRunGoMethodCallEdges(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "server.go", "package app\n\ntype Server struct{}\n\nfunc (s *Server) Handle() {\n\ts.flush()\n}\n\nfunc (s *Server) flush() {}\n")
+	writeTestFile(t, dir, "main.go", "package app\n\nfunc run(srv *Server) {\n\tsrv.Handle()\n}\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out := stdout.String()
+	for _, want := range []string{"  Server.Handle,Server.flush", "  run,Server.Handle", "  main.go,server.go,Server.Handle"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunGoGenericCallEdges(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "parse.go", "package app\n\nfunc Parse[T any](s string) T {\n\tvar v T\n\treturn v\n}\n\nfunc Pair[K comparable, V any](k K, v V) {}\n")
+	writeTestFile(t, dir, "main.go", "package app\n\nfunc run() {\n\tParse[int](\"1\")\n\tPair[string, int](\"a\", 1)\n}\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--raw", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out := stdout.String()
+	for _, want := range []string{"  run,Parse,1", "  run,Pair,1", "  main.go,parse.go,Parse Pair"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunFormatCompact(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--format", "compact", "--stats", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\nstderr: %s", err, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"f[2]{i,p,l,r}:\n  0,models.py,p,", "  1,greet,3", "d[1]{s,t}:\n  1,0"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "# Repository Map") || strings.Contains(out, "calls[") {
+		t.Errorf("compact output should have no header or call graph:\n%s", out)
+	}
+	if !strings.Contains(stderr.String(), "compact size:") || !strings.Contains(stderr.String(), "% smaller") {
+		t.Errorf("--stats should report the compact savings:\n%s", stderr.String())
+	}
+}
+
+func TestRunFormatCtags(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--format", "ctags", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out := stdout.String()
+	if !strings.HasPrefix(out, "!_TAG_FILE_FORMAT\t2\t") {
+		t.Errorf("expected tags header first:\n%s", out)
+	}
+	for _, want := range []string{"User\tmodels.py\t", "greet\tmain.py\t"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Repository Map") {
+		t.Errorf("ctags output must not have the agent header:\n%s", out)
+	}
+}
+
+func TestRunRubyConstants(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "config.rb", "MAX_RETRIES = 3\n\nclass Client\n  TIMEOUT = 30\n\n  def call\n  end\nend\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--symbol", "MAX_RETRIES", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if want := "config.rb,MAX_RETRIES,constant,1,MAX_RETRIES = 3"; !strings.Contains(stdout.String(), want) {
+		t.Errorf("missing %q:\n%s", want, stdout.String())
+	}
+
+	// A class's constants are shown with it.
+	stdout.Reset()
+	if err := run([]string{"--symbol", "Client", dir}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if want := `config.rb,"Client::TIMEOUT",constant,4,TIMEOUT = 30`; !strings.Contains(stdout.String(), want) {
+		t.Errorf("missing %q:\n%s", want, stdout.String())
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/phobologic/repoguide/internal/graph"
+	"github.com/phobologic/repoguide/internal/jsonout"
+	"github.com/phobologic/repoguide/internal/model"
+	"github.com/phobologic/repoguide/internal/toon"
+)
+
+// runMerge implements the `repoguide merge` subcommand, which combines the
+// JSON maps of several shards (each covering part of a repository) into one
+// map, re-ranked over the combined dependency graph.
+func runMerge(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("repoguide merge", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var raw bool
+	fs.BoolVar(&raw, "raw", false, "omit the agent context header from TOON output")
+
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, `Usage: repoguide merge [flags] OUT SHARD.json...
+
+Merge the JSON maps of several shards, written with
+--format json --include-refs, into one map of the whole repository.
+Files are deduplicated by path, dependencies are rebuilt from the combined
+definitions (so references between shards resolve), and files are re-ranked
+over the combined graph.
+
+OUT ending in .json gets the merged map as JSON; any other path gets TOON.
+Use - for stdout.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return fmt.Errorf("merge needs an output path and at least one shard")
+	}
+	outPath, shardPaths := fs.Arg(0), fs.Args()[1:]
+
+	shards := make([]*model.RepoMap, 0, len(shardPaths))
+	for _, p := range shardPaths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		shard, err := jsonout.Decode(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		// Dependencies imply references, so a shard with dependencies but
+		// no refs was written without --include-refs.
+		if len(shard.Refs) == 0 && len(shard.Dependencies) > 0 {
+			_, _ = fmt.Fprintf(stderr, "Warning: %s has no refs (written without --include-refs); its references into other shards can't be resolved\n", p)
+		}
+		shards = append(shards, shard)
+	}
+
+	rm := mergeMaps(shards)
+	if len(rm.Files) == 0 {
+		return fmt.Errorf("%w (the shards contain no files)", errNoFiles)
+	}
+
+	var out bytes.Buffer
+	if strings.EqualFold(filepath.Ext(outPath), ".json") {
+		// Keep the refs, so that a merged map can itself be merged.
+		all := make(map[string]struct{}, len(rm.Files))
+		for i := range rm.Files {
+			all[rm.Files[i].Path] = struct{}{}
+		}
+		rm.Refs = graph.FileRefs(rm.Files, all)
+		output, err := jsonout.Encode(rm)
+		if err != nil {
+			return fmt.Errorf("encoding JSON: %w", err)
+		}
+		writeOutput(&out, output, "")
+	} else {
+		hdr := header(false, false)
+		if raw {
+			hdr = ""
+		}
+		writeOutput(&out, toon.Encode(rm, toon.Options{}), hdr)
+	}
+
+	if outPath == "-" {
+		_, err := stdout.Write(out.Bytes())
+		return err
+	}
+	if err := writeFile(outPath, out.Bytes()); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	_, _ = fmt.Fprintf(stderr, "Merged %d shards (%d files) into %s\n", len(shards), len(rm.Files), outPath)
+	return nil
+}
+
+// mergeMaps combines shard maps into one. A file in several shards keeps the
+// definitions of the first. Each file's references come from its shard's
+// refs and the symbols of its dependencies, so once every definition is in
+// one index, a reference into another shard resolves to a dependency; file
+// import edges, which have no symbols, are carried over as they are. Calls
+// and packages are the union of the shards' (an edge in several keeps its
+// highest count); ranks are recomputed.
+func mergeMaps(shards []*model.RepoMap) *model.RepoMap {
+	files := make(map[string]model.FileInfo)
+	refLines := make(map[string]map[string]int) // file → referenced name → first line, 0 if unknown
+	addRef := func(file, name string, line int) {
+		if refLines[file] == nil {
+			refLines[file] = make(map[string]int)
+		}
+		if prev, ok := refLines[file][name]; !ok || prev == 0 || (line > 0 && line < prev) {
+			refLines[file][name] = line
+		}
+	}
+	imports := make(map[string][]model.Import) // file → targets of its symbol-less (file import) edges
+	calls := make(map[model.CallEdge]int)      // edge without its count → count
+	packages := make(map[string]model.Package)
+
+	for _, shard := range shards {
+		for _, fi := range shard.Files {
+			if _, ok := files[fi.Path]; !ok {
+				fi.Rank = 0
+				files[fi.Path] = fi
+			}
+		}
+		for _, r := range shard.Refs {
+			addRef(r.File, r.Name, r.Line)
+		}
+		for _, d := range shard.Dependencies {
+			for _, sym := range d.Symbols {
+				addRef(d.Source, sym, 0)
+			}
+			// An edge without symbols came from a file import (a Ruby
+			// require); the target's full path resolves back to it.
+			if len(d.Symbols) == 0 {
+				imports[d.Source] = append(imports[d.Source], model.Import{Path: d.Target})
+			}
+		}
+		for _, c := range shard.CallEdges {
+			// A file in several shards reports the same calls in each, so
+			// counts are not summed.
+			count := c.Count
+			c.Count = 0
+			calls[c] = max(calls[c], count)
+		}
+		for _, p := range shard.Packages {
+			if _, ok := packages[p.Dir]; !ok {
+				packages[p.Dir] = p
+			}
+		}
+	}
+
+	fileInfos := make([]model.FileInfo, 0, len(files))
+	for _, fi := range files {
+		names := make([]string, 0, len(refLines[fi.Path]))
+		for name := range refLines[fi.Path] {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		tags := slices.Clip(fi.Tags)
+		for _, name := range names {
+			tags = append(tags, model.Tag{
+				Name:       name,
+				Kind:       model.Reference,
+				SymbolKind: model.Function,
+				Line:       refLines[fi.Path][name],
+				File:       fi.Path,
+			})
+		}
+		fi.Tags = tags
+		fi.Imports = imports[fi.Path]
+		fileInfos = append(fileInfos, fi)
+	}
+	slices.SortFunc(fileInfos, func(a, b model.FileInfo) int { return strings.Compare(a.Path, b.Path) })
+
+	deps := graph.BuildGraph(fileInfos)
+	graph.Rank(fileInfos, deps, graph.PageRankOptions{})
+
+	rm := &model.RepoMap{
+		Files:        fileInfos,
+		Dependencies: deps,
+		Cycles:       graph.FindCycles(deps),
+	}
+	if len(shards) > 0 {
+		rm.RepoName, rm.Root = shards[0].RepoName, shards[0].Root
+	}
+	for c, count := range calls {
+		c.Count = count
+		rm.CallEdges = append(rm.CallEdges, c)
+	}
+	slices.SortFunc(rm.CallEdges, func(a, b model.CallEdge) int {
+		if c := strings.Compare(a.Caller, b.Caller); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Callee, b.Callee)
+	})
+	for _, p := range packages {
+		rm.Packages = append(rm.Packages, p)
+	}
+	slices.SortFunc(rm.Packages, func(a, b model.Package) int { return strings.Compare(a.Dir, b.Dir) })
+	return rm
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+func TestRunMerge(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "lib/models.py", "class User:\n    def __init__(self, name):\n        self.name = name\n")
+	writeTestFile(t, dir, "app/main.py", "from lib.models import User\n\ndef greet():\n    return User('x')\n")
+
+	// Each shard maps one subtree; neither sees the other's files.
+	out := t.TempDir()
+	shard := func(name, file string) string {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		args := []string{"--stdin", "--format", "json", "--include-refs", dir}
+		if err := run(args, strings.NewReader(file+"\n"), &stdout, &stderr); err != nil {
+			t.Fatalf("shard %s: %v\n%s", name, err, stderr.String())
+		}
+		path := filepath.Join(out, name)
+		if err := os.WriteFile(path, stdout.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a := shard("a.json", "lib/models.py")
+	b := shard("b.json", "app/main.py")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"merge", "--raw", "-", a, b, a}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("merge: %v\n%s", err, stderr.String())
+	}
+	got := stdout.String()
+	for _, want := range []string{
+		"files[2]{path,language,rank}:\n  lib/models.py,python,",
+		"dependencies[1]{source,target,symbols}:\n  app/main.py,lib/models.py,User",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in merged map:\n%s", want, got)
+		}
+	}
+
+	// A merged JSON map keeps its refs, so it can be merged again.
+	merged := filepath.Join(out, "merged.json")
+	if err := run([]string{"merge", merged, a, b}, nil, &bytes.Buffer{}, &stderr); err != nil {
+		t.Fatalf("merge to JSON: %v", err)
+	}
+	stdout.Reset()
+	if err := run([]string{"merge", "--raw", "-", merged}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("re-merge: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "app/main.py,lib/models.py,User") {
+		t.Errorf("re-merged map lost the cross-shard dependency:\n%s", stdout.String())
+	}
+	var doc struct {
+		Refs []json.RawMessage `json:"refs"`
+	}
+	data, _ := os.ReadFile(merged)
+	if err := json.Unmarshal(data, &doc); err != nil || len(doc.Refs) == 0 {
+		t.Errorf("merged JSON should carry refs (err %v):\n%s", err, data)
+	}
+}
+
+func TestRunMergeErrors(t *testing.T) {
+	t.Parallel()
+
+	if err := run([]string{"merge", "-"}, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Error("expected an error without shards")
+	}
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(bad, []byte("files: []"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := run([]string{"merge", "-", bad}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "bad.json") {
+		t.Errorf("expected an error naming the invalid shard, got %v", err)
+	}
+}
+
+func TestMergeMapsKeepsFileImports(t *testing.T) {
+	t.Parallel()
+
+	shard := &model.RepoMap{
+		Files: []model.FileInfo{
+			{Path: "lib/shop.rb", Language: "ruby"},
+			{Path: "lib/shop/boot.rb", Language: "ruby"},
+		},
+		Dependencies: []model.Dependency{{Source: "lib/shop.rb", Target: "lib/shop/boot.rb"}},
+	}
+	rm := mergeMaps([]*model.RepoMap{shard})
+	if len(rm.Dependencies) != 1 || rm.Dependencies[0].Source != "lib/shop.rb" || rm.Dependencies[0].Target != "lib/shop/boot.rb" {
+		t.Errorf("expected the require edge to survive the merge, got %+v", rm.Dependencies)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+// runProfile collects per-phase timings for --profile. A nil *runProfile
+// records nothing, so call sites need no --profile checks.
+type runProfile struct {
+	start  time.Time
+	phases []phase
+}
+
+// phase is one timed step of the pipeline, with a short note on how much
+// work it did (e.g. "120 files").
+type phase struct {
+	name     string
+	duration time.Duration
+	detail   string
+}
+
+func newRunProfile() *runProfile {
+	return &runProfile{start: time.Now()}
+}
+
+// record adds a phase that began at start and ends now. detail is a
+// fmt.Sprintf format for the phase's counts; it may be empty.
+func (p *runProfile) record(name string, start time.Time, detail string, args ...any) {
+	if p == nil {
+		return
+	}
+	p.phases = append(p.phases, phase{name: name, duration: time.Since(start), detail: fmt.Sprintf(detail, args...)})
+}
+
+// write prints the phases in the order they ran, then the total wall time
+// of the run, which also covers the untimed steps between phases.
+func (p *runProfile) write(w io.Writer) {
+	if p == nil {
+		return
+	}
+	_, _ = fmt.Fprintln(w, "Profile:")
+	for _, ph := range p.phases {
+		line := fmt.Sprintf("  %-12s %10s", ph.name+":", formatDuration(ph.duration))
+		if ph.detail != "" {
+			line += "  (" + ph.detail + ")"
+		}
+		_, _ = fmt.Fprintln(w, line)
+	}
+	_, _ = fmt.Fprintf(w, "  %-12s %10s\n", "total:", formatDuration(time.Since(p.start)))
+}
+
+// formatDuration renders d in milliseconds with one decimal, which keeps the
+// column aligned from sub-millisecond phases up to multi-second parses.
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000)
+}
+
+// countDefinitions returns the number of definition tags across files.
+func countDefinitions(files []model.FileInfo) int {
+	n := 0
+	for i := range files {
+		for j := range files[i].Tags {
+			if files[i].Tags[j].Kind == model.Definition {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/phobologic/repoguide/internal/lang"
+	"github.com/phobologic/repoguide/internal/model"
+	"github.com/phobologic/repoguide/internal/parse"
+)
+
+// runQueries implements the `repoguide queries` subcommand, which prints the
+// tree-sitter query files repoguide extracts tags with.
+func runQueries(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("repoguide queries", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, `Usage: repoguide queries [language]
+
+Print the tree-sitter query used to extract tags for language, or for every
+language when none is given. Each query is preceded by a comment listing its
+captures and what each one records: @name marks the symbol name, and the
+other captures tag the matched node as a definition (def), reference (ref),
+or entrypoint (entry) of the given symbol kind.
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("queries takes at most one language")
+	}
+
+	var names []string
+	if fs.NArg() == 1 {
+		name := fs.Arg(0)
+		if _, ok := lang.Languages[name]; !ok {
+			return fmt.Errorf("unsupported language %q", name)
+		}
+		names = []string{name}
+	} else {
+		for name := range lang.Languages {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+	}
+
+	for i, name := range names {
+		text, err := describeQuery(lang.Languages[name])
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if i > 0 {
+			_, _ = fmt.Fprintln(stdout)
+		}
+		_, _ = fmt.Fprint(stdout, text)
+	}
+	return nil
+}
+
+// describeQuery returns l's query file, preceded by a comment naming the
+// language, its extensions, and the captures the query uses.
+func describeQuery(l *lang.Language) (string, error) {
+	src, err := l.QuerySource()
+	if err != nil {
+		return "", err
+	}
+	q, err := l.GetTagQuery()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, ";;; %s (%s)\n;;; captures:\n", l.Name, strings.Join(l.Extensions, " "))
+	names := make([]string, q.CaptureCount())
+	width := 0
+	for i := range names {
+		names[i] = q.CaptureNameForId(uint32(i))
+		width = max(width, len(names[i]))
+	}
+	for _, name := range names {
+		fmt.Fprintf(&b, ";;;   @%-*s  %s\n", width, name, captureMeaning(name))
+	}
+	b.WriteString("\n")
+	b.Write(src)
+	return b.String(), nil
+}
+
+// captureMeaning describes what a capture records, for describeQuery: the
+// tag kind and symbol kind it maps to (e.g. "def class").
+func captureMeaning(name string) string {
+	if name == "name" {
+		return "symbol name"
+	}
+	c, ok := parse.LookupCapture(name)
+	switch {
+	case !ok:
+		return "predicate helper, not tagged"
+	case c.Kind == model.Entry:
+		return string(c.Kind)
+	case c.Interface:
+		return fmt.Sprintf("%s %s (interface)", c.Kind, c.SymbolKind)
+	}
+	return fmt.Sprintf("%s %s", c.Kind, c.SymbolKind)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunQueries(t *testing.T) {
+	t.Parallel()
+
+	var stdout bytes.Buffer
+	if err := run([]string{"queries", "ruby"}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		";;; ruby (.rb)",
+		"@name  ",
+		"@definition.class",
+		"def class",
+		"@_attr_method",
+		"predicate helper, not tagged",
+		"(singleton_method",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, ";;; go ") {
+		t.Errorf("expected only the ruby query:\n%s", out)
+	}
+
+	stdout.Reset()
+	if err := run([]string{"queries"}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for _, want := range []string{";;; go (.go)", ";;; python (", ";;; typescript ("} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("all-language output missing %q", want)
+		}
+	}
+
+	if err := run([]string{"queries", "cobol"}, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for an unsupported language")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/phobologic/repoguide/internal/jsonout"
+)
+
+// runSchema implements the `repoguide schema` subcommand, which prints the
+// JSON Schema of the --format json output.
+func runSchema(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("repoguide schema", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, `Usage: repoguide schema
+
+Print a JSON Schema (draft 2020-12) describing the --format json output, for
+validating maps or generating types from them.
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("schema takes no arguments")
+	}
+
+	schema, err := jsonout.Schema()
+	if err != nil {
+		return fmt.Errorf("encoding schema: %w", err)
+	}
+	_, err = fmt.Fprintln(stdout, schema)
+	return err
+}
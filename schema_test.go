@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestRunSchema(t *testing.T) {
+	t.Parallel()
+
+	var stdout bytes.Buffer
+	if err := run([]string{"schema"}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v\n%s", err, stdout.String())
+	}
+	if schema["$schema"] == nil || schema["$defs"] == nil {
+		t.Errorf("expected $schema and $defs, got keys of %v", schema)
+	}
+
+	if err := run([]string{"schema", "extra"}, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for an argument")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runSearch implements the `repoguide search` subcommand, shorthand for
+// --search: the first argument is the query, and the rest are the flags and
+// path of an ordinary run.
+func runSearch(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		_, _ = fmt.Fprint(stderr, `Usage: repoguide search QUERY [flags] [path]
+
+List the definitions that best match the words of QUERY, for when you know
+what code does but not what it is called:
+
+  repoguide search "user authentication"
+
+Each word is matched against the words of every definition's name, doc
+comment, signature, and file path; identifiers split at case changes and
+underscores, and a word also matches its prefixes (auth, authentication).
+Hits are scored by how well they match, blended with the rank of their
+file, and listed best first with their file and line.
+
+Equivalent to repoguide --search QUERY, and takes the same flags: -n caps
+the results (default 20), --format json, --file, -l, --with-tests.
+`)
+		if len(args) == 0 {
+			return fmt.Errorf("search needs a query")
+		}
+		return nil
+	}
+	if strings.TrimSpace(args[0]) == "" || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("search needs a query before any flags")
+	}
+	return run(append([]string{"--search", args[0]}, args[1:]...), stdin, stdout, stderr)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// serveFlagsWithValue lists the serve flags that take a value, for
+// reorderArgsWith.
+var serveFlagsWithValue = map[string]bool{
+	"-addr": true, "--addr": true,
+	"-cache": true, "--cache": true,
+}
+
+// runServe implements the `repoguide serve` subcommand, which keeps the map
+// of a repository up to date and answers queries about it over HTTP, so that
+// tools can ask repeatedly without starting a process each time.
+func runServe(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("repoguide serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var addr, cachePath string
+	fs.StringVar(&addr, "addr", ":8080", "`address` to listen on")
+	fs.StringVar(&cachePath, "cache", "", "cache file `path` (default: a temporary file for the session)")
+
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, `Usage: repoguide serve [flags] [path]
+
+Build the map of path once, rebuild it whenever source files change, and
+serve it over HTTP:
+
+  GET /map            the full map
+  GET /symbol/{name}  the --symbol query for name
+  GET /file/{path}    the --file query for path
+
+Responses are TOON unless the Accept header asks for application/json.
+path defaults to the current directory.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(reorderArgsWith(args, serveFlagsWithValue)); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("serve takes at most one path, got %d arguments: %s", fs.NArg(), strings.Join(fs.Args(), " "))
+	}
+	root := "."
+	if fs.NArg() > 0 {
+		root = fs.Arg(0)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return fmt.Errorf("root path: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s: not a directory", root)
+	}
+
+	// As in --watch, a session cache lets rebuilds and queries reuse the
+	// parse of every unchanged file.
+	if cachePath == "" {
+		f, err := os.CreateTemp("", "repoguide-serve-*.json")
+		if err != nil {
+			return fmt.Errorf("creating serve cache: %w", err)
+		}
+		_ = f.Close()
+		defer func() { _ = os.Remove(f.Name()) }()
+		cachePath = f.Name()
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s := &mapServer{root: root, cachePath: cachePath, stderr: stderr}
+	srv := &http.Server{Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+	_, _ = fmt.Fprintf(stderr, "Serving %s on http://%s\n", root, ln.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// A failed server ends the session as an interrupt would.
+		if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+			_, _ = fmt.Fprintf(stderr, "error: %v\n", err)
+		}
+		stop()
+	}()
+	err = watch(ctx, root, s.rebuild, stderr)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(shutdownCtx)
+	return err
+}
+
+// mapServer answers HTTP queries about one repository. The full map is
+// rebuilt on each change and held in memory; symbol and file queries run on
+// demand against the shared cache.
+type mapServer struct {
+	root      string
+	cachePath string
+	stderr    io.Writer
+
+	// runMu serializes runs, which read and write the same cache file.
+	runMu sync.Mutex
+
+	mu      sync.RWMutex
+	toonMap string
+	jsonMap string
+}
+
+// rebuild regenerates the full map in both response formats.
+func (s *mapServer) rebuild() error {
+	toonMap, err := s.query("toon")
+	if err != nil {
+		return err
+	}
+	jsonMap, err := s.query("json")
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.toonMap, s.jsonMap = toonMap, jsonMap
+	s.mu.Unlock()
+	return nil
+}
+
+// query runs repoguide on the server's root with the given output format and
+// extra flags, and returns what it would have printed.
+func (s *mapServer) query(format string, flags ...string) (string, error) {
+	args := append([]string{"--cache", s.cachePath, "--format", format}, flags...)
+	args = append(args, s.root)
+	var out bytes.Buffer
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if err := run(args, nil, &out, s.stderr); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func (s *mapServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /map", s.handleMap)
+	mux.HandleFunc("GET /symbol/{name}", func(w http.ResponseWriter, r *http.Request) {
+		s.handleQuery(w, r, "--symbol", r.PathValue("name"))
+	})
+	mux.HandleFunc("GET /file/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		s.handleQuery(w, r, "--file", r.PathValue("path"))
+	})
+	return mux
+}
+
+func (s *mapServer) handleMap(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	output := s.toonMap
+	if wantsJSON(r) {
+		output = s.jsonMap
+	}
+	s.mu.RUnlock()
+	if output == "" {
+		http.Error(w, "map not built yet", http.StatusServiceUnavailable)
+		return
+	}
+	writeResponse(w, r, output)
+}
+
+func (s *mapServer) handleQuery(w http.ResponseWriter, r *http.Request, queryFlag, value string) {
+	format := "toon"
+	if wantsJSON(r) {
+		format = "json"
+	}
+	output, err := s.query(format, queryFlag, value)
+	switch {
+	case errors.Is(err, errNoFiles):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		// The server's own flags are fixed, so a failed query is down to
+		// the name or path asked for (e.g. an invalid glob).
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeResponse(w, r, output)
+}
+
+// wantsJSON reports whether the request's Accept header asks for JSON.
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func writeResponse(w http.ResponseWriter, r *http.Request, output string) {
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	_, _ = io.WriteString(w, output)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServeQueries(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	s := &mapServer{root: dir, cachePath: filepath.Join(t.TempDir(), "cache.json"), stderr: io.Discard}
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	get := func(path, accept string) (int, string, string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, resp.Header.Get("Content-Type"), string(body)
+	}
+
+	if code, _, _ := get("/map", ""); code != http.StatusServiceUnavailable {
+		t.Errorf("/map before first build: status %d, want 503", code)
+	}
+	if err := s.rebuild(); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+
+	code, ctype, body := get("/map", "")
+	if code != http.StatusOK || !strings.HasPrefix(ctype, "text/plain") {
+		t.Fatalf("/map: status %d, content type %q", code, ctype)
+	}
+	if !strings.Contains(body, "files[2]") {
+		t.Errorf("/map should be the full TOON map:\n%s", body)
+	}
+
+	code, ctype, body = get("/map", "application/json")
+	if code != http.StatusOK || ctype != "application/json" {
+		t.Fatalf("/map JSON: status %d, content type %q", code, ctype)
+	}
+	var m struct {
+		Files []struct{ Path string } `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(body), &m); err != nil {
+		t.Fatalf("/map JSON: %v\n%s", err, body)
+	}
+	if len(m.Files) != 2 {
+		t.Errorf("/map JSON: got %d files, want 2", len(m.Files))
+	}
+
+	_, _, body = get("/symbol/greet", "")
+	if !strings.Contains(body, "main.py,greet,function") || strings.Contains(body, "models.py,User,class") {
+		t.Errorf("/symbol/greet should show only greet:\n%s", body)
+	}
+
+	_, _, body = get("/file/models.py", "")
+	if !strings.Contains(body, "models.py,User,class") || strings.Contains(body, "greet") {
+		t.Errorf("/file/models.py should show only models.py:\n%s", body)
+	}
+
+	if code, _, _ := get("/file/%5Bmodels", ""); code != http.StatusBadRequest {
+		t.Errorf("invalid glob: status %d, want 400", code)
+	}
+	if code, _, _ := get("/nope", ""); code != http.StatusNotFound {
+		t.Errorf("unknown path: status %d, want 404", code)
+	}
+}
+
+func TestRunServeArgs(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	// A flag after the path is still parsed: the bad address fails to listen
+	// instead of serving on the default one.
+	err := runServe([]string{dir, "--addr", "bad-addr"}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "bad-addr") {
+		t.Errorf("flag after path: got %v, want a listen error for bad-addr", err)
+	}
+
+	err = runServe([]string{dir, "extra"}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "at most one path") {
+		t.Errorf("two paths: got %v, want a usage error", err)
+	}
+}
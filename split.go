@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+
+	"github.com/phobologic/repoguide/internal/model"
+	"github.com/phobologic/repoguide/internal/toon"
+)
+
+// splitIndex is the name of the file --output-dir writes to tie the section
+// files together.
+const splitIndex = "index.toon"
+
+// writeSplit writes rm to dir for --output-dir: each TOON section in its own
+// NAME.toon file, and an index.toon holding the repo, root, and a sections
+// table naming every section file in map order. The files, symbols,
+// dependencies, and calls sections are always written (calls may be empty),
+// so consumers can rely on them. The agent context header h, if any, goes at
+// the top of the index.
+func writeSplit(dir string, rm *model.RepoMap, opts toon.Options, h string, stderr io.Writer) error {
+	sections := toon.Sections(rm, opts)
+	if !opts.GroupByLanguage && !slices.ContainsFunc(sections, func(s toon.Section) bool { return s.Name == "calls" }) {
+		sections = append(sections, toon.Section{Name: "calls", Body: toon.EncodeCalls(rm, opts)})
+	}
+
+	for _, sec := range sections {
+		if err := writeFile(filepath.Join(dir, toon.SectionFile(sec.Name)), []byte(sec.Body+"\n")); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+	}
+
+	index := toon.EncodeIndex(rm, sections, opts)
+	if h != "" {
+		index = h + "\n" + index
+	}
+	if err := writeFile(filepath.Join(dir, splitIndex), []byte(index+"\n")); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	_, _ = fmt.Fprintf(stderr, "Wrote %d sections to %s\n", len(sections), dir)
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/phobologic/repoguide/internal/discover"
+	"github.com/phobologic/repoguide/internal/model"
+)
+
+// runStats collects pipeline counters for the --stats summary.
+type runStats struct {
+	discovered   int // files found by discovery (after --exclude)
+	skippedTests int // test files dropped (without --with-tests)
+	skippedSize  int // files over --max-file-size
+	parsed       int // files successfully parsed
+	failed       int // files that could not be read or parsed
+	syntaxErrors int // parsed files with syntax errors (tags kept from the recovered tree)
+}
+
+// write prints a one-block summary of the run and the final map to w.
+func (s *runStats) write(w io.Writer, rm *model.RepoMap) {
+	kinds := make(map[model.SymbolKind]int)
+	total := 0
+	for i := range rm.Files {
+		for j := range rm.Files[i].Tags {
+			tag := &rm.Files[i].Tags[j]
+			if tag.Kind == model.Definition {
+				kinds[tag.SymbolKind]++
+				total++
+			}
+		}
+	}
+	names := make([]string, 0, len(kinds))
+	for k := range kinds {
+		names = append(names, string(k))
+	}
+	sort.Strings(names)
+	byKind := make([]string, len(names))
+	for i, k := range names {
+		byKind[i] = fmt.Sprintf("%s %d", k, kinds[model.SymbolKind(k)])
+	}
+
+	_, _ = fmt.Fprintf(w, `Stats:
+  files discovered:    %d
+  test files skipped:  %d
+  size-limit skipped:  %d
+  files parsed:        %d
+  parse failures:      %d
+  with syntax errors:  %d
+  files in map:        %d
+  symbols:             %d (%s)
+  dependency edges:    %d
+  call edges:          %d
+`, s.discovered, s.skippedTests, s.skippedSize, s.parsed, s.failed, s.syntaxErrors, len(rm.Files),
+		total, strings.Join(byKind, ", "), len(rm.Dependencies), len(rm.CallEdges))
+}
+
+// writeCompactSavings prints the --stats line comparing the size of the
+// --format compact output with the TOON map of the same files. Tokens are
+// estimated at four characters each, as for --max-tokens.
+func writeCompactSavings(w io.Writer, compact, full string) {
+	saved := 0.0
+	if len(full) > 0 {
+		saved = 100 * float64(len(full)-len(compact)) / float64(len(full))
+	}
+	_, _ = fmt.Fprintf(w, "  compact size:        %d bytes (~%d tokens) vs %d bytes (~%d tokens) as TOON, %.0f%% smaller\n",
+		len(compact), (len(compact)+3)/4, len(full), (len(full)+3)/4, saved)
+}
+
+// writeFileCounts prints the --count-only report: the number of files on the
+// first line, so it can be read with head -1, then one indented line per
+// language, most files first.
+func writeFileCounts(w io.Writer, files []discover.FileEntry) {
+	counts := make(map[string]int)
+	for _, f := range files {
+		counts[f.Language]++
+	}
+	langs := make([]string, 0, len(counts))
+	for l := range counts {
+		langs = append(langs, l)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if counts[langs[i]] != counts[langs[j]] {
+			return counts[langs[i]] > counts[langs[j]]
+		}
+		return langs[i] < langs[j]
+	})
+
+	_, _ = fmt.Fprintln(w, len(files))
+	for _, l := range langs {
+		_, _ = fmt.Fprintf(w, "  %-12s %d\n", l, counts[l])
+	}
+}
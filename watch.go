@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/phobologic/repoguide/internal/config"
+	"github.com/phobologic/repoguide/internal/discover"
+	"github.com/phobologic/repoguide/internal/lang"
+)
+
+// watchDebounce is how long the tree must be quiet after a change before the
+// map is rebuilt, so a burst of saves (or a branch checkout) costs one run.
+const watchDebounce = 500 * time.Millisecond
+
+// runWatch implements --watch: it re-runs repoguide with args (which must
+// not include --watch) on every change under root until interrupted. Without
+// --cache, a temporary cache is used for the session so that only changed
+// files are re-parsed.
+func runWatch(root string, args []string, cachePath string, stderr io.Writer) error {
+	if cachePath == "" {
+		f, err := os.CreateTemp("", "repoguide-watch-*.json")
+		if err != nil {
+			return fmt.Errorf("creating watch cache: %w", err)
+		}
+		_ = f.Close()
+		defer func() { _ = os.Remove(f.Name()) }()
+		args = append([]string{"--cache", f.Name()}, args...)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return watch(ctx, root, func() error {
+		return run(args, nil, io.Discard, stderr)
+	}, stderr)
+}
+
+// watch calls rebuild once, then again after each batch of relevant changes
+// under root, until ctx is cancelled. Build errors are reported to stderr and
+// do not stop the watch.
+//
+// Directories rather than files are watched. A directory watch sees files
+// created later, and it survives editors that save by writing a temporary
+// file and renaming it over the original, which would orphan a watch on the
+// original file.
+func watch(ctx context.Context, root string, rebuild func() error, stderr io.Writer) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("starting watcher: %w", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if err := watchTree(w, root); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(stderr, "Watching %s for changes (Ctrl-C to stop)\n", root)
+
+	build := func() {
+		if err := rebuild(); err != nil {
+			_, _ = fmt.Fprintf(stderr, "error: %v\n", err)
+		}
+	}
+	build()
+
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Has(fsnotify.Create) {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					// New directories may already hold files (e.g. a
+					// checkout or a moved tree), so rebuild as well.
+					_ = watchTree(w, ev.Name)
+					timer.Reset(watchDebounce)
+					continue
+				}
+			}
+			if watchRelevant(ev) {
+				timer.Reset(watchDebounce)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			_, _ = fmt.Fprintf(stderr, "Warning: watch: %v\n", err)
+		case <-timer.C:
+			build()
+		}
+	}
+}
+
+// watchTree adds a watch on dir and every directory below it that discovery
+// would visit.
+func watchTree(w *fsnotify.Watcher, dir string) error {
+	dirs, err := discover.Dirs(dir)
+	if err != nil {
+		return fmt.Errorf("watching %s: %w", dir, err)
+	}
+	for _, d := range dirs {
+		if err := w.Add(d); err != nil {
+			return fmt.Errorf("watching %s: %w", d, err)
+		}
+	}
+	return nil
+}
+
+// watchRelevant reports whether an event can change the map: a write,
+// creation, removal, or rename of a source file, an ignore file, or a
+// repoguide config file. Permission changes are ignored.
+func watchRelevant(ev fsnotify.Event) bool {
+	if ev.Op == fsnotify.Chmod {
+		return false
+	}
+	name := filepath.Base(ev.Name)
+	switch {
+	case name == ".gitignore", name == discover.IgnoreFile, slices.Contains(config.FileNames, name):
+		return true
+	case strings.HasPrefix(name, "."):
+		return false // editor swap and temp files
+	}
+	return lang.ForExtension(filepath.Ext(name)) != ""
+}
+
+// withoutWatchFlag returns args with any --watch flag removed, for the
+// rebuilds run in watch mode.
+func withoutWatchFlag(args []string) []string {
+	var out []string
+	for _, a := range args {
+		name, _, _ := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if strings.HasPrefix(a, "-") && name == "watch" {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
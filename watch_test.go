@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// syncBuffer is a bytes.Buffer safe for the watcher goroutine to write while
+// the test reads it.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func TestWatchRebuildsOnChange(t *testing.T) {
+	t.Parallel()
+	dir := createSampleRepo(t)
+
+	builds := make(chan struct{}, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	var stderr syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- watch(ctx, dir, func() error {
+			builds <- struct{}{}
+			return nil
+		}, &stderr)
+	}()
+
+	waitBuild := func(what string) {
+		t.Helper()
+		select {
+		case <-builds:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no rebuild after %s; stderr: %s", what, stderr.String())
+		}
+	}
+	waitBuild("start")
+
+	// Atomic save: write a temp file and rename it over the original.
+	tmp := filepath.Join(dir, ".main.py.swp")
+	writeTestFile(t, dir, ".main.py.swp", "def greet():\n    pass\n")
+	if err := os.Rename(tmp, filepath.Join(dir, "main.py")); err != nil {
+		t.Fatal(err)
+	}
+	waitBuild("atomic save")
+
+	// The directory watch is still live after the rename.
+	writeTestFile(t, dir, "main.py", "def greet():\n    return 1\n")
+	waitBuild("second save")
+
+	// Files in new directories are picked up.
+	writeTestFile(t, dir, "pkg/new.py", "def fresh():\n    pass\n")
+	waitBuild("new directory")
+	writeTestFile(t, dir, "pkg/other.py", "def other():\n    pass\n")
+	waitBuild("file in new directory")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("watch: %v", err)
+	}
+	if !strings.Contains(stderr.String(), "Watching "+dir) {
+		t.Errorf("missing watch banner: %q", stderr.String())
+	}
+}
+
+func TestWatchRelevant(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		op   fsnotify.Op
+		want bool
+	}{
+		{"src/main.go", fsnotify.Write, true},
+		{"src/main.go", fsnotify.Rename, true},
+		{"src/main.go", fsnotify.Chmod, false},
+		{"README.md", fsnotify.Write, false},
+		{".main.go.swp", fsnotify.Create, false},
+		{"pkg/.gitignore", fsnotify.Write, true},
+		{".repoguideignore", fsnotify.Write, true},
+		{".repoguide.toml", fsnotify.Create, true},
+	}
+	for _, tt := range tests {
+		ev := fsnotify.Event{Name: tt.name, Op: tt.op}
+		if got := watchRelevant(ev); got != tt.want {
+			t.Errorf("watchRelevant(%s %s) = %v, want %v", tt.op, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunWatchRequiresOutput(t *testing.T) {
+	t.Parallel()
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--watch", createSampleRepo(t)}, nil, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "--watch requires") {
+		t.Errorf("expected --watch requires -o error, got %v", err)
+	}
+}
+
+func TestWithoutWatchFlag(t *testing.T) {
+	t.Parallel()
+	got := withoutWatchFlag([]string{"--watch", "-o", "map.toon", "-watch=true", "--with-tests", "."})
+	want := []string{"-o", "map.toon", "--with-tests", "."}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("withoutWatchFlag = %v, want %v", got, want)
+	}
+}